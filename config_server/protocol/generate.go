@@ -0,0 +1,24 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configserver_proto holds the protocol between the agents and the config server. The Go code is generated
+// from the proto files here, both into this package for the agent in plugin_main, and into config_server/service/proto
+// for the config server, which is a separate module. Regenerate both after changing the proto files, with protoc
+// v3.6.1 and protoc-gen-go v1.28.1 in PATH:
+//
+//	go generate ./config_server/protocol
+package configserver_proto //nolint:revive
+
+//go:generate protoc --go_out=. agent.proto
+//go:generate protoc --go_out=../service/proto agent.proto user.proto
@@ -37,6 +37,47 @@ type GlobalConfig struct {
 
 	EnableTimestampNanosecond      bool
 	EnableContainerdUpperDirDetect bool
+
+	// RateLimit caps the ingestion of a single config, zero limits mean no limit.
+	RateLimit RateLimitConfig
+	// PluginRestart controls the restart of the plugin goroutines of a config terminated by panics.
+	PluginRestart PluginRestartConfig
+	// Tracing samples the runs of the pipeline stages as spans exported to an OTLP gRPC endpoint.
+	Tracing TracingConfig
+}
+
+// RateLimitConfig represents the token-bucket limits applied to the events of a config before processing.
+type RateLimitConfig struct {
+	EventsPerSecond float64
+	BytesPerSecond  float64
+	// Policy decides what happens to the events over the limits: drop (default), sample or block.
+	Policy string
+	// SampleRatio is the ratio of the events over the limits kept by the sample policy.
+	SampleRatio float64
+}
+
+// PluginRestartConfig represents the exponential backoff of restarting the crashed plugins of a config.
+type PluginRestartConfig struct {
+	InitialBackoffMs int
+	MaxBackoffMs     int
+	// MaxFailures is the number of the consecutive failures of a plugin degrading the config, zero disables the restart.
+	MaxFailures int
+}
+
+// TracingConfig represents the agent-internal tracing of the pipeline stages. The exporter is shared by the process
+// and configured by the process global config, while the sample ratio can be overridden by the config.
+type TracingConfig struct {
+	// SampleRatio is the ratio of the stage runs traced, zero disables the tracing.
+	SampleRatio float64
+	// Endpoint is the OTLP gRPC endpoint receiving the spans, such as http://localhost:4317.
+	Endpoint    string
+	Headers     map[string]string
+	Compression string
+	TimeoutMs   int
+	// FlushIntervalMs is the interval of exporting the buffered spans.
+	FlushIntervalMs int
+	// MaxBufferedSpans caps the spans buffered between the exports, the others are dropped.
+	MaxBufferedSpans int
 }
 
 // LogtailGlobalConfig is the singleton instance of GlobalConfig.
@@ -55,6 +96,15 @@ func newGlobalConfig() (cfg GlobalConfig) {
 		DefaultLogGroupQueueSize: 4,
 		LogtailSysConfDir:        ".",
 		DelayStopSec:             300,
+		PluginRestart: PluginRestartConfig{
+			InitialBackoffMs: 1000,
+			MaxBackoffMs:     60000,
+			MaxFailures:      5,
+		},
+		Tracing: TracingConfig{
+			FlushIntervalMs:  5000,
+			MaxBufferedSpans: 10000,
+		},
 	}
 	return
 }
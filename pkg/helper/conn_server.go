@@ -0,0 +1,147 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// The backoff of retrying Accept after the temporary errors, such as EMFILE or ECONNABORTED.
+const (
+	acceptMinBackoff = 5 * time.Millisecond
+	acceptMaxBackoff = time.Second
+)
+
+// ConnServer accepts the connections of a TCP or TLS listener, and serves each connection in its own goroutine
+// until the server is stopped.
+type ConnServer struct {
+	// MaxConnections is the maximum count of the concurrent connections, new connections are rejected when
+	// exceeded. 0 means unlimited.
+	MaxConnections int
+	// Handle serves the connection, the connection is closed after Handle returns.
+	Handle func(conn net.Conn)
+	// OnReject is called before the connection rejected for MaxConnections is closed, it is optional.
+	OnReject func(conn net.Conn)
+	// OnAcceptError is called with the accept error except the one caused by Stop, Accept is retried after a
+	// backoff doubled from 5ms up to 1s until a connection is accepted. It is optional.
+	OnAcceptError func(err error)
+
+	listener net.Listener
+	done     chan struct{}
+	conns    map[net.Conn]struct{}
+	lock     sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// Start listens on the address, over TLS if tlsConfig is not nil, and accepts the connections in background.
+func (s *ConnServer) Start(address string, tlsConfig *tls.Config) error {
+	var listener net.Listener
+	var err error
+	if tlsConfig != nil {
+		listener, err = tls.Listen("tcp", address, tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	s.serve(listener)
+	return nil
+}
+
+// serve accepts the connections of the listener in background.
+func (s *ConnServer) serve(listener net.Listener) {
+	s.listener = listener
+	s.done = make(chan struct{})
+	s.conns = make(map[net.Conn]struct{})
+	s.wg.Add(1)
+	go s.accept()
+}
+
+// Addr returns the listening address, it must be called after Start succeeds.
+func (s *ConnServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+func (s *ConnServer) accept() {
+	defer s.wg.Done()
+	var backoff time.Duration
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if s.OnAcceptError != nil {
+				s.OnAcceptError(err)
+			}
+			if backoff == 0 {
+				backoff = acceptMinBackoff
+			} else if backoff *= 2; backoff > acceptMaxBackoff {
+				backoff = acceptMaxBackoff
+			}
+			select {
+			case <-s.done:
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+		s.lock.Lock()
+		if s.MaxConnections > 0 && len(s.conns) >= s.MaxConnections {
+			s.lock.Unlock()
+			if s.OnReject != nil {
+				s.OnReject(conn)
+			}
+			_ = conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.lock.Unlock()
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *ConnServer) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+		_ = conn.Close()
+	}()
+	s.Handle(conn)
+}
+
+// Stop closes the listener and all the connections, and waits for the handlers to return.
+func (s *ConnServer) Stop() {
+	if s.listener == nil {
+		return
+	}
+	close(s.done)
+	_ = s.listener.Close()
+	s.lock.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnServer(t *testing.T) {
+	var rejected int32
+	handled := make(chan struct{}, 1)
+	s := &ConnServer{
+		MaxConnections: 1,
+		Handle: func(conn net.Conn) {
+			handled <- struct{}{}
+			_, _ = io.Copy(io.Discard, conn)
+		},
+		OnReject: func(conn net.Conn) {
+			atomic.AddInt32(&rejected, 1)
+		},
+	}
+	require.NoError(t, s.Start("127.0.0.1:0", nil))
+	first, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+	<-handled
+
+	second, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+	_ = second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = second.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&rejected))
+
+	// Stop closes the connections being served.
+	s.Stop()
+	_ = first.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = first.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
+
+// flakyListener fails the first Accept calls.
+type flakyListener struct {
+	net.Listener
+	failures int32
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if atomic.AddInt32(&l.failures, -1) >= 0 {
+		return nil, errors.New("too many open files")
+	}
+	return l.Listener.Accept()
+}
+
+func TestConnServerAcceptError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	var acceptErrors int32
+	handled := make(chan struct{}, 1)
+	s := &ConnServer{
+		Handle: func(conn net.Conn) {
+			handled <- struct{}{}
+		},
+		OnAcceptError: func(err error) {
+			atomic.AddInt32(&acceptErrors, 1)
+		},
+	}
+	s.serve(&flakyListener{Listener: listener, failures: 3})
+	defer s.Stop()
+
+	// the server keeps accepting after the accept errors.
+	conn, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the connection is not handled after the accept errors")
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&acceptErrors))
+}
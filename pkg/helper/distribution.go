@@ -0,0 +1,185 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/protocol/otlp"
+)
+
+// DefaultDistributionSamples is the default count of samples kept by a Distribution for quantiles.
+const DefaultDistributionSamples = 1024
+
+// Distribution accumulates observed values to provide count, sum, min, max, quantiles and
+// explicit bucket histograms. Quantiles are computed from a uniform reservoir of at most
+// maxSamples values, so they are exact when fewer values are observed.
+// Distribution is not thread-safe.
+type Distribution struct {
+	count      int64
+	sum        float64
+	min        float64
+	max        float64
+	bounds     []float64
+	buckets    []int64
+	samples    []float64
+	maxSamples int
+	sorted     bool
+}
+
+// NewDistribution creates a Distribution with the upper bounds of the histogram buckets, the bounds
+// must be sorted in increasing order. If maxSamples is 0, no samples are kept and quantiles are unavailable.
+func NewDistribution(bounds []float64, maxSamples int) *Distribution {
+	d := &Distribution{
+		bounds:     bounds,
+		maxSamples: maxSamples,
+		min:        math.Inf(1),
+		max:        math.Inf(-1),
+	}
+	if len(bounds) > 0 {
+		d.buckets = make([]int64, len(bounds)+1)
+	}
+	return d
+}
+
+// Observe records one value.
+func (d *Distribution) Observe(v float64) {
+	d.ObserveN(v, 1)
+}
+
+// ObserveN records the value n times, which is used for sampled inputs.
+func (d *Distribution) ObserveN(v float64, n int64) {
+	if n <= 0 {
+		return
+	}
+	d.count += n
+	d.sum += v * float64(n)
+	if v < d.min {
+		d.min = v
+	}
+	if v > d.max {
+		d.max = v
+	}
+	if d.buckets != nil {
+		d.buckets[sort.SearchFloat64s(d.bounds, v)] += n
+	}
+	if d.maxSamples <= 0 {
+		return
+	}
+	d.sorted = false
+	if len(d.samples) < d.maxSamples {
+		d.samples = append(d.samples, v)
+		return
+	}
+	// reservoir sampling keeps every observed value with the same probability
+	if idx := rand.Int63n(d.count); idx < int64(d.maxSamples) { //nolint:gosec
+		d.samples[idx] = v
+	}
+}
+
+// Count returns the count of observed values.
+func (d *Distribution) Count() int64 {
+	return d.count
+}
+
+// Sum returns the sum of observed values.
+func (d *Distribution) Sum() float64 {
+	return d.sum
+}
+
+// Min returns the minimum observed value, or 0 if nothing is observed.
+func (d *Distribution) Min() float64 {
+	if d.count == 0 {
+		return 0
+	}
+	return d.min
+}
+
+// Max returns the maximum observed value, or 0 if nothing is observed.
+func (d *Distribution) Max() float64 {
+	if d.count == 0 {
+		return 0
+	}
+	return d.max
+}
+
+// Avg returns the mean of observed values, or 0 if nothing is observed.
+func (d *Distribution) Avg() float64 {
+	if d.count == 0 {
+		return 0
+	}
+	return d.sum / float64(d.count)
+}
+
+// Quantile returns the q-quantile (0 <= q <= 1) of the kept samples using the nearest rank method.
+func (d *Distribution) Quantile(q float64) float64 {
+	if len(d.samples) == 0 {
+		return 0
+	}
+	if !d.sorted {
+		sort.Float64s(d.samples)
+		d.sorted = true
+	}
+	switch {
+	case q <= 0:
+		return d.samples[0]
+	case q >= 1:
+		return d.samples[len(d.samples)-1]
+	}
+	rank := int(math.Ceil(q*float64(len(d.samples)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return d.samples[rank]
+}
+
+// SummaryValues returns the values of a summary metric, which follow the conventions of the otlp decoder:
+// quantiles keyed by their string representation, plus count and sum.
+func (d *Distribution) SummaryValues(quantiles []float64) *models.MetricMultiValue {
+	values := models.NewMetricMultiValue()
+	for _, q := range quantiles {
+		values.Add(strconv.FormatFloat(q, 'f', -1, 64), d.Quantile(q))
+	}
+	values.Add(otlp.FieldCount, float64(d.count))
+	values.Add(otlp.FieldSum, d.sum)
+	return values
+}
+
+// HistogramValues returns the values of a histogram metric, which follow the conventions of the otlp decoder:
+// count, sum, min, max and one field per bucket named like `(lower,upper]`.
+func (d *Distribution) HistogramValues() *models.MetricMultiValue {
+	values := models.NewMetricMultiValue()
+	values.Add(otlp.FieldCount, float64(d.count))
+	values.Add(otlp.FieldSum, d.sum)
+	if d.count > 0 {
+		values.Add(otlp.FieldMin, d.min)
+		values.Add(otlp.FieldMax, d.max)
+	}
+	for i, count := range d.buckets {
+		lower, upper := math.Inf(-1), math.Inf(1)
+		if i > 0 {
+			lower = d.bounds[i-1]
+		}
+		if i < len(d.bounds) {
+			upper = d.bounds[i]
+		}
+		values.Add(otlp.ComposeBucketFieldName(lower, upper, true), float64(count))
+	}
+	return values
+}
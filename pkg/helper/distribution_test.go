@@ -0,0 +1,60 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistribution(t *testing.T) {
+	d := NewDistribution([]float64{10, 100}, DefaultDistributionSamples)
+	assert.Equal(t, float64(0), d.Min())
+	assert.Equal(t, float64(0), d.Quantile(0.5))
+	for i := 1; i <= 200; i++ {
+		d.Observe(float64(i))
+	}
+	assert.Equal(t, int64(200), d.Count())
+	assert.Equal(t, float64(20100), d.Sum())
+	assert.Equal(t, float64(1), d.Min())
+	assert.Equal(t, float64(200), d.Max())
+	assert.Equal(t, 100.5, d.Avg())
+	assert.Equal(t, float64(100), d.Quantile(0.5))
+	assert.Equal(t, float64(198), d.Quantile(0.99))
+	assert.Equal(t, float64(200), d.Quantile(1))
+
+	histogram := d.HistogramValues().GetMultiValues()
+	assert.Equal(t, float64(10), histogram.Get("(-Inf,10]"))
+	assert.Equal(t, float64(90), histogram.Get("(10,100]"))
+	assert.Equal(t, float64(100), histogram.Get("(100,+Inf]"))
+	assert.Equal(t, float64(200), histogram.Get("count"))
+
+	summary := d.SummaryValues([]float64{0.5, 0.9}).GetMultiValues()
+	assert.Equal(t, float64(100), summary.Get("0.5"))
+	assert.Equal(t, float64(180), summary.Get("0.9"))
+	assert.Equal(t, float64(20100), summary.Get("sum"))
+}
+
+func TestDistributionReservoir(t *testing.T) {
+	d := NewDistribution(nil, 10)
+	for i := 0; i < 1000; i++ {
+		d.ObserveN(float64(i), 2)
+	}
+	assert.Equal(t, int64(2000), d.Count())
+	assert.Len(t, d.samples, 10)
+	assert.Nil(t, d.buckets)
+	assert.Equal(t, float64(999), d.Max())
+}
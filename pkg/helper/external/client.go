@@ -0,0 +1,399 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+// maxHealthCheckFailures is the count of the consecutive failed health checks to restart the plugin.
+const maxHealthCheckFailures = 3
+
+var errNotRunning = errors.New("external plugin is not running")
+
+// Options describes how to launch and supervise an external plugin.
+type Options struct {
+	// Name identifies the plugin in the logs.
+	Name string
+	// Kind is one of KindInput, KindProcessor and KindFlusher.
+	Kind string
+	Path string
+	Args []string
+	// Env is the extra environment variables in the form of KEY=VALUE.
+	Env    []string
+	Config json.RawMessage
+
+	StartTimeout        time.Duration
+	CallTimeout         time.Duration
+	HealthCheckInterval time.Duration
+	InitialBackoff      time.Duration
+	MaxBackoff          time.Duration
+}
+
+// Client launches an external plugin and calls it over gRPC. The plugin is restarted with exponential backoff when
+// the process exits or fails the health checks.
+type Client struct {
+	opts   Options
+	logCtx context.Context
+
+	mu       sync.RWMutex
+	current  *instance
+	restarts int64
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+type instance struct {
+	cmd     *exec.Cmd
+	dir     string
+	conn    *grpc.ClientConn
+	client  *pluginClient
+	health  healthpb.HealthClient
+	started time.Time
+	// exited is closed when the process exits
+	exited chan struct{}
+}
+
+// NewClient creates a client of the external plugin, logCtx is the runtime context to log with.
+func NewClient(logCtx context.Context, opts Options) *Client {
+	if opts.StartTimeout <= 0 {
+		opts.StartTimeout = 10 * time.Second
+	}
+	if opts.CallTimeout <= 0 {
+		opts.CallTimeout = 5 * time.Second
+	}
+	if opts.HealthCheckInterval <= 0 {
+		opts.HealthCheckInterval = 10 * time.Second
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = time.Second
+	}
+	if opts.MaxBackoff < opts.InitialBackoff {
+		opts.MaxBackoff = 60 * opts.InitialBackoff
+	}
+	return &Client{
+		opts:   opts,
+		logCtx: logCtx,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start launches the plugin and waits until it is initialized, the plugin is supervised after a successful start.
+func (c *Client) Start() error {
+	inst, err := c.launch()
+	if err != nil {
+		return err
+	}
+	c.setCurrent(inst)
+	c.wg.Add(1)
+	go c.supervise()
+	return nil
+}
+
+// Ready tells whether the plugin is running, the calls fail when it is restarting.
+func (c *Client) Ready() bool {
+	return c.getCurrent() != nil
+}
+
+// Restarts returns the count of the restarts of the plugin.
+func (c *Client) Restarts() int64 {
+	return atomic.LoadInt64(&c.restarts)
+}
+
+func (c *Client) Process(groupEventsArray []*models.PipelineGroupEvents) ([]*models.PipelineGroupEvents, error) {
+	inst := c.getCurrent()
+	if inst == nil {
+		return nil, errNotRunning
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.CallTimeout)
+	defer cancel()
+	out, err := inst.client.Process(ctx, &Batch{Groups: ToWire(groupEventsArray)})
+	if err != nil {
+		return nil, err
+	}
+	return FromWire(out.Groups)
+}
+
+func (c *Client) Export(groupEventsArray []*models.PipelineGroupEvents) error {
+	inst := c.getCurrent()
+	if inst == nil {
+		return errNotRunning
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.CallTimeout)
+	defer cancel()
+	return inst.client.Export(ctx, &Batch{Groups: ToWire(groupEventsArray)})
+}
+
+// Collect receives the events of the input plugin until the context is done or the client is stopped, the stream
+// is opened again after the plugin restarts.
+func (c *Client) Collect(ctx context.Context, collect func([]*models.PipelineGroupEvents)) {
+	for {
+		if inst := c.getCurrent(); inst != nil {
+			err := c.receive(ctx, inst, collect)
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warning(c.logCtx, "EXTERNAL_PLUGIN_ALARM", "external plugin", c.opts.Name, "collect stream broken, error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopCh:
+			return
+		case <-time.After(c.opts.InitialBackoff):
+		}
+	}
+}
+
+func (c *Client) receive(ctx context.Context, inst *instance, collect func([]*models.PipelineGroupEvents)) error {
+	stream, err := inst.client.Collect(ctx)
+	if err != nil {
+		return err
+	}
+	for {
+		batch := new(Batch)
+		if err = stream.RecvMsg(batch); err != nil {
+			return err
+		}
+		groupEventsArray, err := FromWire(batch.Groups)
+		if err != nil {
+			logger.Warning(c.logCtx, "EXTERNAL_PLUGIN_ALARM", "external plugin", c.opts.Name, "decode events error", err)
+			continue
+		}
+		collect(groupEventsArray)
+	}
+}
+
+// Stop stops the supervision and the plugin, the plugin is killed if it does not exit in time.
+func (c *Client) Stop() error {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
+	c.wg.Wait()
+	inst := c.getCurrent()
+	c.setCurrent(nil)
+	if inst == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.CallTimeout)
+	defer cancel()
+	err := inst.client.Stop(ctx)
+	select {
+	case <-inst.exited:
+	case <-ctx.Done():
+	}
+	inst.close()
+	return err
+}
+
+func (c *Client) getCurrent() *instance {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.current
+}
+
+func (c *Client) setCurrent(inst *instance) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = inst
+}
+
+// supervise restarts the plugin when it exits or becomes unhealthy, the backoff is reset after the plugin runs
+// longer than the max backoff.
+func (c *Client) supervise() {
+	defer c.wg.Done()
+	backoff := c.opts.InitialBackoff
+	for {
+		if inst := c.getCurrent(); inst != nil {
+			reason := c.watch(inst)
+			if reason == "" {
+				return
+			}
+			if time.Since(inst.started) > c.opts.MaxBackoff {
+				backoff = c.opts.InitialBackoff
+			}
+			logger.Warning(c.logCtx, "EXTERNAL_PLUGIN_ALARM", "external plugin", c.opts.Name, "restart, reason", reason, "backoff", backoff)
+			c.setCurrent(nil)
+			inst.close()
+		}
+		select {
+		case <-c.stopCh:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+		inst, err := c.launch()
+		if err != nil {
+			logger.Warning(c.logCtx, "EXTERNAL_PLUGIN_ALARM", "external plugin", c.opts.Name, "start error", err)
+			continue
+		}
+		atomic.AddInt64(&c.restarts, 1)
+		c.setCurrent(inst)
+	}
+}
+
+// watch blocks until the plugin exits or fails the health checks, and returns the reason. It returns an empty
+// reason when the client is stopped.
+func (c *Client) watch(inst *instance) string {
+	ticker := time.NewTicker(c.opts.HealthCheckInterval)
+	defer ticker.Stop()
+	failures := 0
+	for {
+		select {
+		case <-c.stopCh:
+			return ""
+		case <-inst.exited:
+			return "process exited: " + inst.cmd.ProcessState.String()
+		case <-ticker.C:
+			if err := c.checkHealth(inst); err != nil {
+				if failures++; failures >= maxHealthCheckFailures {
+					return "health check failed: " + err.Error()
+				}
+			} else {
+				failures = 0
+			}
+		}
+	}
+}
+
+func (c *Client) checkHealth(inst *instance) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.CallTimeout)
+	defer cancel()
+	resp, err := inst.health.Check(ctx, &healthpb.HealthCheckRequest{Service: ServiceName})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("status %v", resp.Status)
+	}
+	return nil
+}
+
+// launch starts the process, connects to its socket and initializes the plugin.
+func (c *Client) launch() (*instance, error) {
+	dir, err := os.MkdirTemp("", "ilogtail-plugin-")
+	if err != nil {
+		return nil, err
+	}
+	socket := filepath.Join(dir, "plugin.sock")
+	cmd := exec.Command(c.opts.Path, c.opts.Args...) //nolint:gosec
+	cmd.Env = append(os.Environ(), c.opts.Env...)
+	cmd.Env = append(cmd.Env, EnvSocket+"="+socket, EnvMagicCookie+"="+magicCookie)
+	// the output is read from an os pipe rather than cmd.StdoutPipe, so that waiting for the process does not depend
+	// on the output being drained, and the last output, such as a panic, is still logged after the process exits
+	r, w, err := os.Pipe()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		_ = r.Close()
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	go c.logOutput(r)
+	inst := &instance{cmd: cmd, dir: dir, started: time.Now(), exited: make(chan struct{})}
+	go func() {
+		_ = cmd.Wait()
+		close(inst.exited)
+	}()
+
+	if err = c.connect(inst, socket); err != nil {
+		inst.close()
+		return nil, err
+	}
+	return inst, nil
+}
+
+func (c *Client) connect(inst *instance, socket string) error {
+	deadline := time.Now().Add(c.opts.StartTimeout)
+	for {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		select {
+		case <-inst.exited:
+			return fmt.Errorf("process exited: %v", inst.cmd.ProcessState)
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("plugin does not listen on %v in %v", socket, c.opts.StartTimeout)
+		}
+	}
+	conn, err := grpc.Dial("unix:"+socket,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})))
+	if err != nil {
+		return err
+	}
+	inst.conn = conn
+	inst.client = &pluginClient{conn: conn}
+	inst.health = healthpb.NewHealthClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.StartTimeout)
+	defer cancel()
+	if err = inst.client.Init(ctx, &InitRequest{Kind: c.opts.Kind, Config: c.opts.Config}); err != nil {
+		return fmt.Errorf("init plugin error: %w", err)
+	}
+	return c.checkHealth(inst)
+}
+
+func (c *Client) logOutput(r io.ReadCloser) {
+	defer r.Close()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		logger.Info(c.logCtx, "external plugin", c.opts.Name, "output", scanner.Text())
+	}
+}
+
+// close kills the process if it is still running and releases the resources.
+func (inst *instance) close() {
+	if inst.conn != nil {
+		_ = inst.conn.Close()
+	}
+	select {
+	case <-inst.exited:
+	default:
+		_ = inst.cmd.Process.Kill()
+		<-inst.exited
+	}
+	_ = os.RemoveAll(inst.dir)
+}
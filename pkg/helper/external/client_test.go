@@ -0,0 +1,137 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+func newTestClient(t *testing.T, kind string, config string) *Client {
+	return NewClient(context.Background(), Options{
+		Name:                "test",
+		Kind:                kind,
+		Path:                os.Args[0],
+		Config:              json.RawMessage(config),
+		HealthCheckInterval: 50 * time.Millisecond,
+		InitialBackoff:      10 * time.Millisecond,
+		MaxBackoff:          100 * time.Millisecond,
+	})
+}
+
+func newTestLogs(body string) []*models.PipelineGroupEvents {
+	return []*models.PipelineGroupEvents{{
+		Group:  models.NewGroup(models.NewMetadata(), models.NewTags()),
+		Events: []models.PipelineEvent{models.NewSimpleLog([]byte(body), models.NewTags(), 1)},
+	}}
+}
+
+func TestClientProcessAndRestart(t *testing.T) {
+	c := newTestClient(t, KindProcessor, `{"Tag":"yes"}`)
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	out, err := c.Process(newTestLogs("hello"))
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+	require.Len(t, out[0].Events, 1)
+	assert.Equal(t, "yes", out[0].Events[0].GetTags().Get("processed"))
+	assert.Equal(t, []byte("hello"), out[0].Events[0].(*models.Log).GetBody())
+
+	_, err = c.Process(newTestLogs("crash"))
+	require.Error(t, err)
+	require.Eventually(t, func() bool {
+		return c.Restarts() == 1 && c.Ready()
+	}, 10*time.Second, 10*time.Millisecond)
+	out, err = c.Process(newTestLogs("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "yes", out[0].Events[0].GetTags().Get("processed"))
+}
+
+func TestClientExport(t *testing.T) {
+	c := newTestClient(t, KindFlusher, `{}`)
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	assert.NoError(t, c.Export(newTestLogs("hello")))
+	failed := newTestLogs("hello")
+	failed[0].Group.Tags.Add("fail", "true")
+	err := c.Export(failed)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "export fail")
+}
+
+func TestClientCollect(t *testing.T) {
+	c := newTestClient(t, KindInput, `{}`)
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan *models.PipelineGroupEvents, 100)
+	done := make(chan struct{})
+	go func() {
+		c.Collect(ctx, func(groupEventsArray []*models.PipelineGroupEvents) {
+			for _, groupEvents := range groupEventsArray {
+				select {
+				case received <- groupEvents:
+				default:
+				}
+			}
+		})
+		close(done)
+	}()
+	select {
+	case groupEvents := <-received:
+		assert.Equal(t, []byte("collected"), groupEvents.Events[0].(*models.Log).GetBody())
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for events")
+	}
+	cancel()
+	<-done
+}
+
+func TestClientStartError(t *testing.T) {
+	c := newTestClient(t, KindProcessor, `{"Fail":true}`)
+	err := c.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "init fail")
+
+	c = newTestClient(t, KindProcessor, `{}`)
+	c.opts.Path = "/not/exist/plugin"
+	assert.Error(t, c.Start())
+}
+
+func TestClientStop(t *testing.T) {
+	c := newTestClient(t, KindProcessor, `{}`)
+	require.NoError(t, c.Start())
+	inst := c.getCurrent()
+	require.NoError(t, c.Stop())
+	select {
+	case <-inst.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("plugin does not exit")
+	}
+	assert.False(t, c.Ready())
+	_, err := c.Process(newTestLogs("hello"))
+	assert.Error(t, err)
+}
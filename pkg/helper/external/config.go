@@ -0,0 +1,72 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// PluginConfig is the config shared by the input, processor and flusher plugins running external plugins.
+type PluginConfig struct {
+	Path                       string                 `comment:"the path of the plugin executable."`
+	Args                       []string               `comment:"the arguments of the plugin executable."`
+	Env                        map[string]string      `comment:"the extra environment variables of the plugin process."`
+	Config                     map[string]interface{} `comment:"the config passed to the plugin as JSON."`
+	StartTimeoutSeconds        int                    `comment:"the timeout of starting and initializing the plugin."`
+	TimeoutSeconds             int                    `comment:"the timeout of the calls to the plugin."`
+	HealthCheckIntervalSeconds int                    `comment:"the interval of the health checks, the plugin is restarted after 3 consecutive failures."`
+	MaxBackoffSeconds          int                    `comment:"the max backoff of restarting the plugin, the backoff starts from 1 second and doubles on every restart."`
+}
+
+func DefaultPluginConfig() PluginConfig {
+	return PluginConfig{
+		StartTimeoutSeconds:        10,
+		TimeoutSeconds:             5,
+		HealthCheckIntervalSeconds: 10,
+		MaxBackoffSeconds:          60,
+	}
+}
+
+// NewClient creates the client of the plugin of the kind, logCtx is the runtime context to log with.
+func (c *PluginConfig) NewClient(logCtx context.Context, pluginName, kind string) (*Client, error) {
+	if c.Path == "" {
+		return nil, fmt.Errorf("must specify Path for plugin %v", pluginName)
+	}
+	config, err := json.Marshal(c.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Config for plugin %v: %v", pluginName, err)
+	}
+	env := make([]string, 0, len(c.Env))
+	for k, v := range c.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return NewClient(logCtx, Options{
+		Name:                pluginName + "(" + c.Path + ")",
+		Kind:                kind,
+		Path:                c.Path,
+		Args:                c.Args,
+		Env:                 env,
+		Config:              config,
+		StartTimeout:        time.Duration(c.StartTimeoutSeconds) * time.Second,
+		CallTimeout:         time.Duration(c.TimeoutSeconds) * time.Second,
+		HealthCheckInterval: time.Duration(c.HealthCheckIntervalSeconds) * time.Second,
+		MaxBackoff:          time.Duration(c.MaxBackoffSeconds) * time.Second,
+	}), nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package externaltest provides an external plugin for the tests, which is served by the test binary itself.
+package externaltest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/helper/external"
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+// Main runs the test binary as the test plugin when it is launched as an external plugin, and runs the tests
+// otherwise. It is meant to be called by TestMain, so that the tests can use os.Args[0] as the plugin path.
+func Main(m *testing.M) {
+	if os.Getenv(external.EnvMagicCookie) != "" {
+		if err := external.Serve(&Plugin{}); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Plugin is an input, processor and flusher at the same time:
+//   - Init fails if Fail is set in the config.
+//   - Collect emits a log of Body, or "collected" if empty, every 10ms in a group tagged source=external.
+//   - Process adds the processed=Tag tag to the events, and exits the process on a log of "crash".
+//   - Process and Export fail for the groups tagged fail.
+type Plugin struct {
+	config struct {
+		Tag  string
+		Body string
+		Fail bool
+	}
+}
+
+func (p *Plugin) Init(kind string, config json.RawMessage) error {
+	if err := json.Unmarshal(config, &p.config); err != nil {
+		return err
+	}
+	if p.config.Fail {
+		return errors.New("init fail")
+	}
+	if p.config.Body == "" {
+		p.config.Body = "collected"
+	}
+	return nil
+}
+
+func (p *Plugin) Stop() error {
+	return nil
+}
+
+func (p *Plugin) Collect(ctx context.Context, emit func(...*models.PipelineGroupEvents) error) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			log := models.NewSimpleLog([]byte(p.config.Body), models.NewTags(), uint64(time.Now().UnixNano()))
+			group := models.NewGroup(models.NewMetadata(), models.NewTagsWithKeyValues("source", "external"))
+			if err := emit(&models.PipelineGroupEvents{Group: group, Events: []models.PipelineEvent{log}}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *Plugin) Process(groupEventsArray []*models.PipelineGroupEvents) ([]*models.PipelineGroupEvents, error) {
+	for _, groupEvents := range groupEventsArray {
+		if groupEvents.Group.GetTags().Get("fail") != "" {
+			return nil, errors.New("process fail")
+		}
+		for _, event := range groupEvents.Events {
+			if log, ok := event.(*models.Log); ok && string(log.GetBody()) == "crash" {
+				os.Exit(2)
+			}
+			event.GetTags().Add("processed", p.config.Tag)
+		}
+	}
+	return groupEventsArray, nil
+}
+
+func (p *Plugin) Export(groupEventsArray []*models.PipelineGroupEvents) error {
+	for _, groupEvents := range groupEventsArray {
+		if groupEvents.Group.GetTags().Get("fail") != "" {
+			return errors.New("export fail")
+		}
+	}
+	return nil
+}
+
+// NewLogs returns a group of a log of the body, the group is tagged with the tags given in key value pairs.
+func NewLogs(body string, tags ...string) []*models.PipelineGroupEvents {
+	return []*models.PipelineGroupEvents{{
+		Group:  models.NewGroup(models.NewMetadata(), models.NewTagsWithKeyValues(tags...)),
+		Events: []models.PipelineEvent{models.NewSimpleLog([]byte(body), models.NewTags(), 1)},
+	}}
+}
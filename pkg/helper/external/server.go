@@ -0,0 +1,196 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+// The environment variables set by ilogtail when launching the plugin executables.
+const (
+	// EnvSocket is the unix socket the plugin should listen on.
+	EnvSocket = "ILOGTAIL_PLUGIN_SOCKET"
+	// EnvMagicCookie tells the executable it is launched as a plugin rather than by a user.
+	EnvMagicCookie = "ILOGTAIL_PLUGIN_MAGIC_COOKIE"
+
+	magicCookie = "ilogtail-external-plugin-v1"
+)
+
+// Plugin is implemented by the external plugins, which also implement at least one of Input, Processor and Flusher.
+type Plugin interface {
+	// Init is called with the kind of the plugin and the config in JSON before the other calls, it is called again
+	// after the plugin is restarted.
+	Init(kind string, config json.RawMessage) error
+	// Stop is called before the plugin exits.
+	Stop() error
+}
+
+// Input collects events until the context is done, the emitted events are sent to the pipeline.
+type Input interface {
+	Plugin
+	Collect(ctx context.Context, emit func(...*models.PipelineGroupEvents) error) error
+}
+
+// Processor returns the processed events, the returned events replace the input events in the pipeline.
+type Processor interface {
+	Plugin
+	Process(groupEventsArray []*models.PipelineGroupEvents) ([]*models.PipelineGroupEvents, error)
+}
+
+// Flusher sends the events to the destination, an error makes the events retried or dropped by the pipeline.
+type Flusher interface {
+	Plugin
+	Export(groupEventsArray []*models.PipelineGroupEvents) error
+}
+
+// Serve serves the plugin on the socket given by ilogtail, it returns when the plugin is stopped, or the process
+// receives SIGINT or SIGTERM.
+func Serve(plugin Plugin) error {
+	if os.Getenv(EnvMagicCookie) != magicCookie {
+		return errors.New("this executable is an ilogtail plugin and should be launched by ilogtail")
+	}
+	socket := os.Getenv(EnvSocket)
+	if socket == "" {
+		return fmt.Errorf("environment variable %v is empty", EnvSocket)
+	}
+	// the socket appears after listening, as ilogtail connects once the socket exists
+	tmpSocket := socket + ".tmp"
+	_ = os.Remove(tmpSocket)
+	listener, err := net.Listen("unix", tmpSocket)
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmpSocket, socket); err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	server := grpc.NewServer(grpc.ForceServerCodec(codec{}))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	ps := &pluginService{plugin: plugin, health: healthServer}
+	ps.stop = func() {
+		go server.GracefulStop()
+	}
+	server.RegisterService(&serviceDesc, ps)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; ok {
+			_ = plugin.Stop()
+			server.Stop()
+		}
+	}()
+	return server.Serve(listener)
+}
+
+type pluginService struct {
+	plugin Plugin
+	health *health.Server
+	stop   func()
+}
+
+func (s *pluginService) Init(ctx context.Context, req *InitRequest) (*Empty, error) {
+	var ok bool
+	switch req.Kind {
+	case KindInput:
+		_, ok = s.plugin.(Input)
+	case KindProcessor:
+		_, ok = s.plugin.(Processor)
+	case KindFlusher:
+		_, ok = s.plugin.(Flusher)
+	}
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "the plugin cannot be used as %v", req.Kind)
+	}
+	if err := s.plugin.Init(req.Kind, req.Config); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	s.health.SetServingStatus(ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return &Empty{}, nil
+}
+
+func (s *pluginService) Process(ctx context.Context, batch *Batch) (*Batch, error) {
+	processor, ok := s.plugin.(Processor)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "the plugin is not a processor")
+	}
+	groupEventsArray, err := FromWire(batch.Groups)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if groupEventsArray, err = processor.Process(groupEventsArray); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &Batch{Groups: ToWire(groupEventsArray)}, nil
+}
+
+func (s *pluginService) Export(ctx context.Context, batch *Batch) (*Empty, error) {
+	flusher, ok := s.plugin.(Flusher)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "the plugin is not a flusher")
+	}
+	groupEventsArray, err := FromWire(batch.Groups)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err = flusher.Export(groupEventsArray); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &Empty{}, nil
+}
+
+func (s *pluginService) Collect(_ *Empty, stream grpc.ServerStream) error {
+	input, ok := s.plugin.(Input)
+	if !ok {
+		return status.Error(codes.Unimplemented, "the plugin is not an input")
+	}
+	// the stream does not support concurrent sending
+	var mu sync.Mutex
+	return input.Collect(stream.Context(), func(groupEventsArray ...*models.PipelineGroupEvents) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return stream.SendMsg(&Batch{Groups: ToWire(groupEventsArray)})
+	})
+}
+
+func (s *pluginService) Stop(ctx context.Context, _ *Empty) (*Empty, error) {
+	s.health.SetServingStatus(ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	err := s.plugin.Stop()
+	s.stop()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &Empty{}, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gogo/protobuf/proto"
+	"google.golang.org/grpc"
+	protov2 "google.golang.org/protobuf/proto"
+)
+
+// ServiceName is the gRPC service implemented by the external plugins, beside the standard grpc.health.v1.Health
+// service. The messages of the service are encoded as JSON.
+const ServiceName = "ilogtail.external.Plugin"
+
+// The plugin kinds passed to Init.
+const (
+	KindInput     = "input"
+	KindProcessor = "processor"
+	KindFlusher   = "flusher"
+)
+
+type InitRequest struct {
+	Kind string `json:"kind"`
+	// Config is the plugin config in JSON, which is passed through without being interpreted.
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+type Batch struct {
+	Groups []*GroupEvents `json:"groups,omitempty"`
+}
+
+type Empty struct{}
+
+// codec encodes the messages of the plugin service as JSON and the protobuf messages, such as the health check
+// messages, as protobuf, so that both services share a connection.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	switch m := v.(type) {
+	case protov2.Message:
+		return protov2.Marshal(m)
+	case proto.Message:
+		return proto.Marshal(m)
+	}
+	return json.Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	switch m := v.(type) {
+	case protov2.Message:
+		return protov2.Unmarshal(data, m)
+	case proto.Message:
+		return proto.Unmarshal(data, m)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+// pluginServer is the server API of the plugin service.
+type pluginServer interface {
+	Init(context.Context, *InitRequest) (*Empty, error)
+	Process(context.Context, *Batch) (*Batch, error)
+	Export(context.Context, *Batch) (*Empty, error)
+	Collect(*Empty, grpc.ServerStream) error
+	Stop(context.Context, *Empty) (*Empty, error)
+}
+
+func unaryHandler[Req any, Resp any](method string, call func(pluginServer, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(Req)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(pluginServer), ctx, in)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/" + method}
+			return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(pluginServer), ctx, req.(*Req))
+			})
+		},
+	}
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*pluginServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("Init", pluginServer.Init),
+		unaryHandler("Process", pluginServer.Process),
+		unaryHandler("Export", pluginServer.Export),
+		unaryHandler("Stop", pluginServer.Stop),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Collect",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				in := new(Empty)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				return srv.(pluginServer).Collect(in, stream)
+			},
+			ServerStreams: true,
+		},
+	},
+}
+
+// pluginClient is the client API of the plugin service.
+type pluginClient struct {
+	conn *grpc.ClientConn
+}
+
+func (c *pluginClient) invoke(ctx context.Context, method string, in, out interface{}, opts ...grpc.CallOption) error {
+	return c.conn.Invoke(ctx, "/"+ServiceName+"/"+method, in, out, append(opts, grpc.ForceCodec(codec{}))...)
+}
+
+// Init waits for the connection to be ready, as it is the first call after the plugin starts.
+func (c *pluginClient) Init(ctx context.Context, in *InitRequest) error {
+	return c.invoke(ctx, "Init", in, new(Empty), grpc.WaitForReady(true))
+}
+
+func (c *pluginClient) Process(ctx context.Context, in *Batch) (*Batch, error) {
+	out := new(Batch)
+	if err := c.invoke(ctx, "Process", in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pluginClient) Export(ctx context.Context, in *Batch) error {
+	return c.invoke(ctx, "Export", in, new(Empty))
+}
+
+func (c *pluginClient) Stop(ctx context.Context) error {
+	return c.invoke(ctx, "Stop", new(Empty), new(Empty))
+}
+
+// Collect opens the stream of the input plugin, the stream ends when the context is done.
+func (c *pluginClient) Collect(ctx context.Context) (grpc.ClientStream, error) {
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[0], "/"+ServiceName+"/Collect", grpc.ForceCodec(codec{}))
+	if err != nil {
+		return nil, err
+	}
+	if err = stream.SendMsg(new(Empty)); err != nil {
+		return nil, err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
@@ -0,0 +1,361 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"fmt"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+// The event types on the wire.
+const (
+	EventTypeLog     = "log"
+	EventTypeMetric  = "metric"
+	EventTypeSpan    = "span"
+	EventTypeBytes   = "bytes"
+	EventTypeProfile = "profile"
+)
+
+// GroupEvents is the wire format of models.PipelineGroupEvents, it is encoded as JSON so that the plugins could be
+// written in any language with a gRPC library.
+type GroupEvents struct {
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Events   []*Event          `json:"events,omitempty"`
+}
+
+// Event is the wire format of a models.PipelineEvent, only the field of its type is set.
+type Event struct {
+	Type              string            `json:"type"`
+	Name              string            `json:"name,omitempty"`
+	Tags              map[string]string `json:"tags,omitempty"`
+	Timestamp         uint64            `json:"timestamp,omitempty"`
+	ObservedTimestamp uint64            `json:"observedTimestamp,omitempty"`
+
+	Log     *Log     `json:"log,omitempty"`
+	Metric  *Metric  `json:"metric,omitempty"`
+	Span    *Span    `json:"span,omitempty"`
+	Bytes   []byte   `json:"bytes,omitempty"`
+	Profile *Profile `json:"profile,omitempty"`
+}
+
+type Log struct {
+	Level   string `json:"level,omitempty"`
+	SpanID  string `json:"spanID,omitempty"`
+	TraceID string `json:"traceID,omitempty"`
+	Offset  uint64 `json:"offset,omitempty"`
+	// Contents holds the contents except the bytes values, which are kept in BytesContents to keep their types.
+	Contents      map[string]interface{} `json:"contents,omitempty"`
+	BytesContents map[string][]byte      `json:"bytesContents,omitempty"`
+}
+
+type Metric struct {
+	// MetricType is one of the models.MetricTypeTexts, such as Counter.
+	MetricType  string                        `json:"metricType,omitempty"`
+	Unit        string                        `json:"unit,omitempty"`
+	Description string                        `json:"description,omitempty"`
+	Value       *float64                      `json:"value,omitempty"`
+	Values      map[string]float64            `json:"values,omitempty"`
+	TypedValues map[string]*models.TypedValue `json:"typedValues,omitempty"`
+}
+
+type Span struct {
+	TraceID      string `json:"traceID,omitempty"`
+	SpanID       string `json:"spanID,omitempty"`
+	ParentSpanID string `json:"parentSpanID,omitempty"`
+	TraceState   string `json:"traceState,omitempty"`
+	EndTime      uint64 `json:"endTime,omitempty"`
+	// Kind is one of the models.SpanKindTexts, such as server.
+	Kind   string       `json:"kind,omitempty"`
+	Status int          `json:"status,omitempty"`
+	Links  []*SpanLink  `json:"links,omitempty"`
+	Events []*SpanEvent `json:"events,omitempty"`
+}
+
+type SpanLink struct {
+	TraceID    string            `json:"traceID,omitempty"`
+	SpanID     string            `json:"spanID,omitempty"`
+	TraceState string            `json:"traceState,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+type SpanEvent struct {
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Name      string            `json:"name,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+type Profile struct {
+	ProfileID  string                     `json:"profileID,omitempty"`
+	Language   string                     `json:"language,omitempty"`
+	EndTime    uint64                     `json:"endTime,omitempty"`
+	ValueTypes []*models.ProfileValueType `json:"valueTypes,omitempty"`
+	Samples    []*ProfileSample           `json:"samples,omitempty"`
+}
+
+type ProfileSample struct {
+	Stack  []string          `json:"stack,omitempty"`
+	Values []int64           `json:"values,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ToWire converts the group events into the wire format.
+func ToWire(groupEventsArray []*models.PipelineGroupEvents) []*GroupEvents {
+	result := make([]*GroupEvents, 0, len(groupEventsArray))
+	for _, groupEvents := range groupEventsArray {
+		group := &GroupEvents{
+			Metadata: copyMap(groupEvents.Group.GetMetadata().Iterator()),
+			Tags:     copyMap(groupEvents.Group.GetTags().Iterator()),
+			Events:   make([]*Event, 0, len(groupEvents.Events)),
+		}
+		for _, event := range groupEvents.Events {
+			if e := toWireEvent(event); e != nil {
+				group.Events = append(group.Events, e)
+			}
+		}
+		result = append(result, group)
+	}
+	return result
+}
+
+// FromWire converts the wire format into group events, it fails on the events of unknown types.
+func FromWire(groups []*GroupEvents) ([]*models.PipelineGroupEvents, error) {
+	result := make([]*models.PipelineGroupEvents, 0, len(groups))
+	for _, group := range groups {
+		groupEvents := &models.PipelineGroupEvents{
+			Group:  models.NewGroup(models.NewMetadataWithMap(cloneMap(group.Metadata)), newTags(group.Tags)),
+			Events: make([]models.PipelineEvent, 0, len(group.Events)),
+		}
+		for _, e := range group.Events {
+			event, err := fromWireEvent(e)
+			if err != nil {
+				return nil, err
+			}
+			groupEvents.Events = append(groupEvents.Events, event)
+		}
+		result = append(result, groupEvents)
+	}
+	return result, nil
+}
+
+func toWireEvent(event models.PipelineEvent) *Event {
+	e := &Event{
+		Name:              event.GetName(),
+		Tags:              copyMap(event.GetTags().Iterator()),
+		Timestamp:         event.GetTimestamp(),
+		ObservedTimestamp: event.GetObservedTimestamp(),
+	}
+	switch v := event.(type) {
+	case *models.Log:
+		e.Type = EventTypeLog
+		e.Log = &Log{Level: v.Level, SpanID: v.SpanID, TraceID: v.TraceID, Offset: v.Offset}
+		for k, value := range v.GetIndices().Iterator() {
+			if b, ok := value.([]byte); ok {
+				if e.Log.BytesContents == nil {
+					e.Log.BytesContents = make(map[string][]byte)
+				}
+				e.Log.BytesContents[k] = b
+				continue
+			}
+			if e.Log.Contents == nil {
+				e.Log.Contents = make(map[string]interface{})
+			}
+			e.Log.Contents[k] = value
+		}
+	case *models.Metric:
+		e.Type = EventTypeMetric
+		e.Metric = &Metric{
+			MetricType:  models.MetricTypeTexts[v.GetMetricType()],
+			Unit:        v.Unit,
+			Description: v.Description,
+		}
+		value := v.GetValue()
+		if value.IsSingleValue() {
+			single := value.GetSingleValue()
+			e.Metric.Value = &single
+		} else if value.IsMultiValues() {
+			e.Metric.Values = copyMap(value.GetMultiValues().Iterator())
+		}
+		if v.TypedValue != nil && v.TypedValue.Len() > 0 {
+			e.Metric.TypedValues = copyMap(v.TypedValue.Iterator())
+		}
+	case *models.Span:
+		e.Type = EventTypeSpan
+		e.Span = &Span{
+			TraceID:      v.TraceID,
+			SpanID:       v.SpanID,
+			ParentSpanID: v.ParentSpanID,
+			TraceState:   v.TraceState,
+			EndTime:      v.EndTime,
+			Kind:         string(models.SpanKindTexts[v.Kind]),
+			Status:       int(v.Status),
+		}
+		for _, link := range v.Links {
+			e.Span.Links = append(e.Span.Links, &SpanLink{
+				TraceID:    link.TraceID,
+				SpanID:     link.SpanID,
+				TraceState: link.TraceState,
+				Tags:       copyMap(link.Tags.Iterator()),
+			})
+		}
+		for _, spanEvent := range v.Events {
+			e.Span.Events = append(e.Span.Events, &SpanEvent{
+				Timestamp: spanEvent.Timestamp,
+				Name:      spanEvent.Name,
+				Tags:      copyMap(spanEvent.Tags.Iterator()),
+			})
+		}
+	case models.ByteArray:
+		e.Type = EventTypeBytes
+		e.Bytes = v
+	case *models.Profile:
+		e.Type = EventTypeProfile
+		e.Profile = &Profile{
+			ProfileID:  v.ProfileID,
+			Language:   v.Language,
+			EndTime:    v.EndTime,
+			ValueTypes: v.ValueTypes,
+			Samples:    make([]*ProfileSample, 0, len(v.Samples)),
+		}
+		for _, s := range v.Samples {
+			e.Profile.Samples = append(e.Profile.Samples, &ProfileSample{
+				Stack:  s.Stack,
+				Values: s.Values,
+				Labels: copyMap(s.GetLabels().Iterator()),
+			})
+		}
+	default:
+		return nil
+	}
+	return e
+}
+
+func fromWireEvent(e *Event) (models.PipelineEvent, error) {
+	tags := newTags(e.Tags)
+	switch e.Type {
+	case EventTypeLog:
+		log := models.NewLog(e.Name, nil, "", "", "", tags, e.Timestamp)
+		log.ObservedTimestamp = e.ObservedTimestamp
+		if e.Log != nil {
+			log.Level = e.Log.Level
+			log.SpanID = e.Log.SpanID
+			log.TraceID = e.Log.TraceID
+			log.Offset = e.Log.Offset
+			log.Contents.AddAll(e.Log.Contents)
+			for k, v := range e.Log.BytesContents {
+				log.Contents.Add(k, v)
+			}
+		}
+		return log, nil
+	case EventTypeMetric:
+		var value models.MetricValue
+		var typedValues models.MetricTypedValues
+		metricType := models.MetricTypeUntyped
+		metric := e.Metric
+		if metric == nil {
+			metric = &Metric{}
+		}
+		if t, ok := models.MetricTypeValues[metric.MetricType]; ok {
+			metricType = t
+		}
+		switch {
+		case metric.Value != nil:
+			value = &models.MetricSingleValue{Value: *metric.Value}
+		case metric.Values != nil:
+			value = models.NewMetricMultiValueWithMap(cloneMap(metric.Values))
+		}
+		if metric.TypedValues != nil {
+			typedValues = models.NewMetricTypedValueWithMap(cloneMap(metric.TypedValues))
+		}
+		m := models.NewMetric(e.Name, metricType, tags, int64(e.Timestamp), value, typedValues)
+		m.Unit = metric.Unit
+		m.Description = metric.Description
+		m.ObservedTimestamp = e.ObservedTimestamp
+		return m, nil
+	case EventTypeSpan:
+		span := e.Span
+		if span == nil {
+			span = &Span{}
+		}
+		links := make([]*models.SpanLink, 0, len(span.Links))
+		for _, link := range span.Links {
+			links = append(links, &models.SpanLink{
+				TraceID:    link.TraceID,
+				SpanID:     link.SpanID,
+				TraceState: link.TraceState,
+				Tags:       newTags(link.Tags),
+			})
+		}
+		events := make([]*models.SpanEvent, 0, len(span.Events))
+		for _, spanEvent := range span.Events {
+			events = append(events, &models.SpanEvent{
+				Timestamp: spanEvent.Timestamp,
+				Name:      spanEvent.Name,
+				Tags:      newTags(spanEvent.Tags),
+			})
+		}
+		s := models.NewSpan(e.Name, span.TraceID, span.SpanID, models.SpanKindValues[models.SpanKindText(span.Kind)],
+			e.Timestamp, span.EndTime, tags, events, links)
+		s.ParentSpanID = span.ParentSpanID
+		s.TraceState = span.TraceState
+		s.Status = models.StatusCode(span.Status)
+		s.ObservedTimestamp = e.ObservedTimestamp
+		return s, nil
+	case EventTypeBytes:
+		return models.NewByteArray(e.Bytes), nil
+	case EventTypeProfile:
+		profile := e.Profile
+		if profile == nil {
+			profile = &Profile{}
+		}
+		p := models.NewProfile(e.Name, profile.ProfileID, profile.Language, e.Timestamp, profile.EndTime, profile.ValueTypes, tags)
+		p.ObservedTimestamp = e.ObservedTimestamp
+		for _, s := range profile.Samples {
+			var labels models.Tags
+			if len(s.Labels) > 0 {
+				labels = newTags(s.Labels)
+			}
+			p.AddSample(s.Stack, s.Values, labels)
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown event type %q", e.Type)
+	}
+}
+
+func copyMap[T any](m map[string]T) map[string]T {
+	if len(m) == 0 {
+		return nil
+	}
+	result := make(map[string]T, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
+
+// cloneMap always returns a non-nil map, as the key values created from a nil map ignore the added values.
+func cloneMap[T any](m map[string]T) map[string]T {
+	result := make(map[string]T, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
+
+func newTags(m map[string]string) models.Tags {
+	return models.NewTagsWithMap(cloneMap(m))
+}
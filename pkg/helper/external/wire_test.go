@@ -0,0 +1,93 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+func TestWireRoundTrip(t *testing.T) {
+	log := models.NewLog("log", []byte("body"), "info", "span", "trace", models.NewTagsWithKeyValues("a", "b"), 100)
+	log.Contents.Add("str", "v")
+	log.Offset = 7
+	metric := models.NewSingleValueMetric("cpu", models.MetricTypeGauge, models.NewTagsWithKeyValues("host", "h1"), 200, 0.5)
+	histogram := models.NewMultiValuesMetric("latency", models.MetricTypeHistogram, models.NewTags(), 300,
+		models.NewMetricMultiValueWithMap(map[string]float64{"sum": 10, "count": 2}).Values)
+	span := models.NewSpan("op", "t1", "s1", models.SpanKindServer, 400, 500, models.NewTags(),
+		[]*models.SpanEvent{{Timestamp: 450, Name: "e", Tags: models.NewTagsWithKeyValues("k", "v")}},
+		[]*models.SpanLink{{TraceID: "t2", SpanID: "s2", Tags: models.NewTags()}})
+	span.Status = models.StatusCodeError
+	profile := models.NewProfile("app", "id", "go", 600, 700, []*models.ProfileValueType{models.NewProfileValueType("cpu", "nanoseconds", "sum")}, models.NewTags())
+	profile.AddSample([]string{"main.work", "main.main"}, []int64{5}, models.NewTagsWithKeyValues("span", "s1"))
+
+	groups := []*models.PipelineGroupEvents{{
+		Group:  models.NewGroup(models.NewMetadataWithKeyValues("source", "x"), models.NewTagsWithKeyValues("host", "h1")),
+		Events: []models.PipelineEvent{log, metric, histogram, span, models.NewByteArray([]byte("raw")), profile},
+	}}
+	data, err := json.Marshal(ToWire(groups))
+	require.NoError(t, err)
+	var wire []*GroupEvents
+	require.NoError(t, json.Unmarshal(data, &wire))
+	result, err := FromWire(wire)
+	require.NoError(t, err)
+
+	require.Len(t, result, 1)
+	assert.Equal(t, "x", result[0].Group.GetMetadata().Get("source"))
+	assert.Equal(t, "h1", result[0].Group.GetTags().Get("host"))
+	require.Len(t, result[0].Events, 6)
+
+	l := result[0].Events[0].(*models.Log)
+	assert.Equal(t, []byte("body"), l.GetBody())
+	assert.Equal(t, "v", l.GetIndices().Get("str"))
+	assert.Equal(t, "info", l.Level)
+	assert.Equal(t, "trace", l.TraceID)
+	assert.Equal(t, uint64(7), l.Offset)
+	assert.Equal(t, uint64(100), l.Timestamp)
+	assert.Equal(t, "b", l.GetTags().Get("a"))
+
+	m := result[0].Events[1].(*models.Metric)
+	assert.Equal(t, models.MetricTypeGauge, m.MetricType)
+	assert.Equal(t, 0.5, m.GetValue().GetSingleValue())
+	h := result[0].Events[2].(*models.Metric)
+	assert.Equal(t, models.MetricTypeHistogram, h.MetricType)
+	assert.Equal(t, 2.0, h.GetValue().GetMultiValues().Get("count"))
+
+	s := result[0].Events[3].(*models.Span)
+	assert.Equal(t, models.SpanKindServer, s.Kind)
+	assert.Equal(t, models.StatusCodeError, s.Status)
+	assert.Equal(t, uint64(500), s.EndTime)
+	assert.Equal(t, "v", s.Events[0].Tags.Get("k"))
+	assert.Equal(t, "t2", s.Links[0].TraceID)
+
+	assert.Equal(t, models.ByteArray("raw"), result[0].Events[4])
+
+	p := result[0].Events[5].(*models.Profile)
+	assert.Equal(t, uint64(100), p.GetDuration())
+	assert.Equal(t, []string{"main.work", "main.main"}, p.GetSamples()[0].Stack)
+	assert.Equal(t, "s1", p.GetSamples()[0].GetLabels().Get("span"))
+
+	// the decoded tags could be modified
+	l.GetTags().Add("new", "tag")
+	assert.Equal(t, "tag", l.GetTags().Get("new"))
+
+	_, err = FromWire([]*GroupEvents{{Events: []*Event{{Type: "unknown"}}}})
+	assert.Error(t, err)
+}
@@ -30,6 +30,7 @@ type LocalContext struct {
 	StringMetrics  map[string]pipeline.StringMetric
 	CounterMetrics map[string]pipeline.CounterMetric
 	LatencyMetrics map[string]pipeline.LatencyMetric
+	MetricVecs     map[string]pipeline.MetricVec
 	AllCheckPoint  map[string][]byte
 
 	ctx         context.Context
@@ -98,6 +99,27 @@ func (p *LocalContext) RegisterLatencyMetric(metric pipeline.LatencyMetric) {
 	p.LatencyMetrics[metric.Name()] = metric
 }
 
+func (p *LocalContext) RegisterCounterVec(vec pipeline.CounterVec) {
+	p.registerMetricVec(vec)
+}
+
+func (p *LocalContext) RegisterGaugeVec(vec pipeline.GaugeVec) {
+	p.registerMetricVec(vec)
+}
+
+func (p *LocalContext) RegisterHistogramVec(vec pipeline.HistogramVec) {
+	p.registerMetricVec(vec)
+}
+
+func (p *LocalContext) registerMetricVec(vec pipeline.MetricVec) {
+	contextMutex.Lock()
+	defer contextMutex.Unlock()
+	if p.MetricVecs == nil {
+		p.MetricVecs = make(map[string]pipeline.MetricVec)
+	}
+	p.MetricVecs[vec.Name()] = vec
+}
+
 func (p *LocalContext) MetricSerializeToPB(log *protocol.Log) {
 	if log == nil {
 		return
@@ -127,6 +149,9 @@ func (p *LocalContext) MetricSerializeToPB(log *protocol.Log) {
 			value.Clear()
 		}
 	}
+	for _, vec := range p.MetricVecs {
+		vec.Serialize(log)
+	}
 }
 
 func (p *LocalContext) SaveCheckPoint(key string, value []byte) error {
@@ -0,0 +1,123 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+// NameLabel is the label of the application name in pyroscope.
+const NameLabel = "__name__"
+
+// Builder collects the stacks of the parsed raw profiles into profile events, the stacks with the same labels and
+// time range are grouped into a profile.
+type Builder struct {
+	meta      *Meta
+	tags      map[string]string
+	profileID string
+	profiles  map[string]*profileValues
+	order     []*profileValues
+}
+
+type profileValues struct {
+	profile *models.Profile
+	// the index of the value type keyed by type, unit and aggregation type
+	typeIndex map[string]int
+}
+
+func NewBuilder(meta *Meta, tags map[string]string) *Builder {
+	return &Builder{
+		meta:      meta,
+		tags:      tags,
+		profileID: GetProfileID(meta),
+		profiles:  make(map[string]*profileValues),
+	}
+}
+
+// Add is a CallbackFunc that adds the values of a stack. The labels are not modified, the tags of the builder are
+// added to a copy of them.
+func (b *Builder) Add(id uint64, stack *Stack, vals []uint64, types, units, aggs []string, startTime, endTime int64, labels map[string]string) {
+	merged := make(map[string]string, len(labels)+len(b.tags))
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range b.tags {
+		merged[k] = v
+	}
+	p := b.getProfile(startTime, endTime, merged)
+	values := make([]int64, len(p.typeIndex))
+	for i, v := range vals {
+		key := types[i] + "\x00" + units[i] + "\x00" + aggs[i]
+		idx, ok := p.typeIndex[key]
+		if !ok {
+			idx = len(p.typeIndex)
+			p.typeIndex[key] = idx
+			p.profile.ValueTypes = append(p.profile.ValueTypes, models.NewProfileValueType(types[i], units[i], aggs[i]))
+			values = append(values, 0)
+		}
+		values[idx] += int64(v)
+	}
+	frames := make([]string, 0, len(stack.Stack)+1)
+	frames = append(frames, stack.Name)
+	frames = append(frames, stack.Stack...)
+	p.profile.AddSample(frames, values, nil)
+}
+
+func (b *Builder) getProfile(startTime, endTime int64, labels map[string]string) *profileValues {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString(strconv.FormatInt(startTime, 10))
+	sb.WriteByte(',')
+	sb.WriteString(strconv.FormatInt(endTime, 10))
+	for _, k := range keys {
+		sb.WriteByte(',')
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(labels[k])
+	}
+	key := sb.String()
+	if p, ok := b.profiles[key]; ok {
+		return p
+	}
+	p := &profileValues{
+		profile:   models.NewProfile(labels[NameLabel], b.profileID, b.meta.SpyName, uint64(startTime), uint64(endTime), nil, models.NewTagsWithMap(labels)),
+		typeIndex: make(map[string]int),
+	}
+	b.profiles[key] = p
+	b.order = append(b.order, p)
+	return p
+}
+
+// Profiles returns the built profiles, the values of the samples are padded to the count of the value types.
+func (b *Builder) Profiles() []*models.Profile {
+	profiles := make([]*models.Profile, 0, len(b.order))
+	for _, p := range b.order {
+		for _, s := range p.profile.Samples {
+			for len(s.Values) < len(p.profile.ValueTypes) {
+				s.Values = append(s.Values, 0)
+			}
+		}
+		profiles = append(profiles, p.profile)
+	}
+	return profiles
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilderAddKeepsLabels(t *testing.T) {
+	b := NewBuilder(&Meta{SpyName: "gospy"}, map[string]string{"cluster": "c1"})
+	labels := map[string]string{NameLabel: "app.cpu"}
+	stack := &Stack{Name: "main.work", Stack: []string{"main.main"}}
+	b.Add(0, stack, []uint64{1}, []string{"cpu"}, []string{"samples"}, []string{"sum"}, 1, 2, labels)
+	b.Add(0, stack, []uint64{2}, []string{"cpu"}, []string{"samples"}, []string{"sum"}, 1, 2, labels)
+	require.Equal(t, map[string]string{NameLabel: "app.cpu"}, labels)
+
+	profiles := b.Profiles()
+	require.Len(t, profiles, 1)
+	require.Equal(t, "c1", profiles[0].Tags.Get("cluster"))
+	require.Equal(t, "app.cpu", profiles[0].Tags.Get(NameLabel))
+	require.Len(t, profiles[0].Samples, 2)
+}
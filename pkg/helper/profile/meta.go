@@ -21,6 +21,7 @@ import (
 
 	"github.com/gofrs/uuid"
 
+	"github.com/alibaba/ilogtail/pkg/models"
 	"github.com/alibaba/ilogtail/pkg/protocol"
 )
 
@@ -225,5 +226,8 @@ func (u Units) DetectValueType() string {
 }
 
 type RawProfile interface {
+	// Parse converts the raw profile into logs of stacks for the v1 pipeline.
 	Parse(ctx context.Context, meta *Meta, tags map[string]string) (logs []*protocol.Log, err error)
+	// ParseV2 converts the raw profile into profile events for the v2 pipeline.
+	ParseV2(ctx context.Context, meta *Meta, tags map[string]string) (profiles []*models.Profile, err error)
 }
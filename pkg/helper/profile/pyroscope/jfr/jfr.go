@@ -28,6 +28,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"github.com/alibaba/ilogtail/pkg/helper/profile"
+	"github.com/alibaba/ilogtail/pkg/models"
 	"github.com/alibaba/ilogtail/pkg/protocol"
 )
 
@@ -58,6 +59,18 @@ func (r *RawProfile) Parse(ctx context.Context, meta *profile.Meta, tags map[str
 	return
 }
 
+func (r *RawProfile) ParseV2(ctx context.Context, meta *profile.Meta, tags map[string]string) (profiles []*models.Profile, err error) {
+	reader, labels, err := r.extractProfileRaw()
+	if err != nil {
+		return nil, err
+	}
+	builder := profile.NewBuilder(meta, tags)
+	if err := r.ParseJFR(ctx, meta, reader, labels, builder.Add); err != nil {
+		return nil, err
+	}
+	return builder.Profiles(), nil
+}
+
 func (r *RawProfile) extractProfileV1(meta *profile.Meta, tags map[string]string) profile.CallbackFunc {
 	profileID := profile.GetProfileID(meta)
 	return func(id uint64, stack *profile.Stack, vals []uint64, types, units, aggs []string, startTime, endTime int64, labels map[string]string) {
@@ -0,0 +1,150 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	"github.com/pyroscope-io/pyroscope/pkg/convert/pprof"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/tree"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+// FromPprof converts a pprof profile, which may be gzipped, into a profile event without aggregating the stacks.
+// The frames are formatted as `function filename`, and the string labels of the samples are kept.
+func FromPprof(data []byte) (*models.Profile, error) {
+	var result *models.Profile
+	err := pprof.DecodePool(bytes.NewReader(data), func(p *tree.Profile) error {
+		str := func(i int64) string {
+			if i < 0 || int(i) >= len(p.StringTable) {
+				return ""
+			}
+			return p.StringTable[i]
+		}
+		valueTypes := make([]*models.ProfileValueType, 0, len(p.SampleType))
+		for _, st := range p.SampleType {
+			valueTypes = append(valueTypes, models.NewProfileValueType(str(st.Type), str(st.Unit), ""))
+		}
+		startTime := uint64(p.TimeNanos)
+		result = models.NewProfile("", "", "", startTime, startTime+uint64(p.DurationNanos), valueTypes, models.NewTags())
+		finder := tree.NewFinder(p)
+		for _, s := range p.Sample {
+			stack := make([]string, 0, len(s.LocationId))
+			for _, id := range s.LocationId {
+				loc, ok := finder.FindLocation(id)
+				if !ok {
+					continue
+				}
+				// the inlined functions are listed before their callers
+				for _, line := range loc.Line {
+					fn, ok := finder.FindFunction(line.FunctionId)
+					if !ok || str(fn.Name) == "" {
+						continue
+					}
+					frame := str(fn.Name)
+					if filename := str(fn.Filename); filename != "" {
+						frame += " " + filename
+					}
+					stack = append(stack, frame)
+				}
+			}
+			var labels models.Tags
+			for _, l := range s.Label {
+				if l.Str == 0 {
+					continue
+				}
+				if labels == nil {
+					labels = models.NewTags()
+				}
+				labels.Add(str(l.Key), str(l.Str))
+			}
+			values := make([]int64, len(s.Value))
+			copy(values, s.Value)
+			result.AddSample(stack, values, labels)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// ToPprof converts a profile event into a gzipped pprof profile, every frame is written as a function name.
+func ToPprof(p *models.Profile) ([]byte, error) {
+	b := &pprofBuilder{
+		profile:   &tree.Profile{StringTable: []string{""}},
+		strings:   map[string]int64{"": 0},
+		locations: make(map[string]uint64),
+	}
+	for _, vt := range p.GetValueTypes() {
+		b.profile.SampleType = append(b.profile.SampleType, &tree.ValueType{Type: b.str(vt.Type), Unit: b.str(vt.Unit)})
+	}
+	b.profile.TimeNanos = int64(p.GetStartTime())
+	b.profile.DurationNanos = int64(p.GetDuration())
+	for _, s := range p.GetSamples() {
+		sample := &tree.Sample{
+			LocationId: make([]uint64, 0, len(s.Stack)),
+			Value:      s.Values,
+		}
+		for _, frame := range s.Stack {
+			sample.LocationId = append(sample.LocationId, b.location(frame))
+		}
+		for k, v := range s.GetLabels().Iterator() {
+			sample.Label = append(sample.Label, &tree.Label{Key: b.str(k), Str: b.str(v)})
+		}
+		b.profile.Sample = append(b.profile.Sample, sample)
+	}
+	data, err := b.profile.MarshalVT()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type pprofBuilder struct {
+	profile   *tree.Profile
+	strings   map[string]int64
+	locations map[string]uint64
+}
+
+func (b *pprofBuilder) str(s string) int64 {
+	if i, ok := b.strings[s]; ok {
+		return i
+	}
+	i := int64(len(b.profile.StringTable))
+	b.profile.StringTable = append(b.profile.StringTable, s)
+	b.strings[s] = i
+	return i
+}
+
+// location returns the location id of the frame, each frame has a function and a location with the same id.
+func (b *pprofBuilder) location(frame string) uint64 {
+	if id, ok := b.locations[frame]; ok {
+		return id
+	}
+	id := uint64(len(b.locations) + 1)
+	b.profile.Function = append(b.profile.Function, &tree.Function{Id: id, Name: b.str(frame)})
+	b.profile.Location = append(b.profile.Location, &tree.Location{Id: id, Line: []*tree.Line{{FunctionId: id}}})
+	b.locations[frame] = id
+	return id
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pprof
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+func TestPprofConvert(t *testing.T) {
+	p := models.NewProfile("app", "id", "go", 1e9, 11e9, []*models.ProfileValueType{
+		models.NewProfileValueType("samples", "count", "sum"),
+		models.NewProfileValueType("cpu", "nanoseconds", "sum"),
+	}, models.NewTags())
+	p.AddSample([]string{"main.work", "main.main"}, []int64{1, 10}, models.NewTagsWithKeyValues("span", "s1"))
+	p.AddSample([]string{"main.idle", "main.main"}, []int64{2, 20}, nil)
+
+	data, err := ToPprof(p)
+	require.NoError(t, err)
+	// gzipped
+	assert.Equal(t, []byte{0x1f, 0x8b}, data[:2])
+
+	converted, err := FromPprof(data)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1e9), converted.GetStartTime())
+	assert.Equal(t, uint64(10e9), converted.GetDuration())
+	require.Len(t, converted.GetValueTypes(), 2)
+	assert.Equal(t, "cpu", converted.GetValueTypes()[1].Type)
+	assert.Equal(t, "nanoseconds", converted.GetValueTypes()[1].Unit)
+	require.Len(t, converted.GetSamples(), 2)
+	assert.Equal(t, []string{"main.work", "main.main"}, converted.GetSamples()[0].Stack)
+	assert.Equal(t, []int64{1, 10}, converted.GetSamples()[0].Values)
+	assert.Equal(t, "s1", converted.GetSamples()[0].GetLabels().Get("span"))
+	assert.Equal(t, []string{"main.idle", "main.main"}, converted.GetSamples()[1].Stack)
+	assert.Equal(t, 0, converted.GetSamples()[1].GetLabels().Len())
+
+	_, err = FromPprof([]byte("not a profile"))
+	assert.Error(t, err)
+}
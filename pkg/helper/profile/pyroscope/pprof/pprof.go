@@ -34,6 +34,7 @@ import (
 
 	"github.com/alibaba/ilogtail/pkg/helper/profile"
 	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
 	"github.com/alibaba/ilogtail/pkg/protocol"
 )
 
@@ -117,6 +118,14 @@ func (r *RawProfile) Parse(ctx context.Context, meta *profile.Meta, tags map[str
 	return
 }
 
+func (r *RawProfile) ParseV2(ctx context.Context, meta *profile.Meta, tags map[string]string) (profiles []*models.Profile, err error) {
+	builder := profile.NewBuilder(meta, tags)
+	if err = r.doParse(ctx, meta, builder.Add); err != nil {
+		return nil, err
+	}
+	return builder.Profiles(), nil
+}
+
 func (r *RawProfile) doParse(ctx context.Context, meta *profile.Meta, cb profile.CallbackFunc) error {
 	if r.pushMode {
 		if err := r.extractProfileRaw(); err != nil {
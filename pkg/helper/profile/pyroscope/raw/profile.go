@@ -28,6 +28,7 @@ import (
 
 	"github.com/alibaba/ilogtail/pkg/helper"
 	"github.com/alibaba/ilogtail/pkg/helper/profile"
+	"github.com/alibaba/ilogtail/pkg/models"
 	"github.com/alibaba/ilogtail/pkg/protocol"
 )
 
@@ -53,6 +54,30 @@ func (p *Profile) Parse(ctx context.Context, meta *profile.Meta, tags map[string
 	return p.logs, nil
 }
 
+func (p *Profile) ParseV2(ctx context.Context, meta *profile.Meta, tags map[string]string) (profiles []*models.Profile, err error) {
+	builder := profile.NewBuilder(meta, tags)
+	valueType := meta.Units.DetectValueType()
+	startTime, endTime := meta.StartTime.UnixNano(), meta.EndTime.UnixNano()
+	err = p.doParse(func(k []byte, v int) {
+		name, stack := p.extractNameAndStacks(k, meta.SpyName)
+		u, v := convertValue(meta, v)
+		builder.Add(xxhash.Sum64(k), &profile.Stack{Name: name, Stack: stack}, []uint64{uint64(v)},
+			[]string{valueType}, []string{string(u)}, []string{string(meta.AggregationType)}, startTime, endTime, meta.Tags)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return builder.Profiles(), nil
+}
+
+// convertValue converts the samples into nanoseconds by the sample rate.
+func convertValue(meta *profile.Meta, v int) (profile.Units, int) {
+	if meta.Units == profile.SamplesUnits && meta.SampleRate > 0 {
+		return profile.NanosecondsUnit, v * int(time.Second.Nanoseconds()/int64(meta.SampleRate))
+	}
+	return meta.Units, v
+}
+
 func (p *Profile) doParse(cb func([]byte, int)) error {
 	r := bytes.NewReader(p.RawData)
 	switch p.Format {
@@ -94,11 +119,7 @@ func (p *Profile) extractProfileV1(meta *profile.Meta, tags map[string]string) f
 		name, stack := p.extractNameAndStacks(k, meta.SpyName)
 		stackID := strconv.FormatUint(xxhash.Sum64(k), 16)
 		var content []*protocol.Log_Content
-		u := meta.Units
-		if meta.Units == profile.SamplesUnits {
-			u = profile.NanosecondsUnit
-			v *= int(time.Second.Nanoseconds() / int64(meta.SampleRate))
-		}
+		u, v := convertValue(meta, v)
 		content = append(content,
 			&protocol.Log_Content{
 				Key:   "name",
@@ -26,6 +26,25 @@ import (
 
 var mu sync.Mutex
 
+// LatencyBuckets are the upper bounds in seconds of the histogram buckets recorded by the latency metrics.
+var LatencyBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// CumulativeReader is implemented by the counters that keep the total since created, which is not reset by Clear.
+type CumulativeReader interface {
+	Total() int64
+}
+
+// GaugeReader is implemented by the metrics whose current value can be read without resetting them.
+type GaugeReader interface {
+	Value() float64
+}
+
+// HistogramReader is implemented by the histograms, the buckets are cumulative and keyed by the upper bounds.
+// The latency metrics record the distribution of the latencies in seconds with LatencyBuckets.
+type HistogramReader interface {
+	Histogram() (count uint64, sum float64, buckets map[float64]uint64)
+}
+
 type StrMetric struct {
 	name  string
 	value string
@@ -55,10 +74,12 @@ func (s *StrMetric) Serialize(log *protocol.Log) {
 type NormalMetric struct {
 	name  string
 	value int64
+	total int64
 }
 
 func (s *NormalMetric) Add(v int64) {
 	atomic.AddInt64(&s.value, v)
+	atomic.AddInt64(&s.total, v)
 }
 
 func (s *NormalMetric) Clear(v int64) {
@@ -69,6 +90,11 @@ func (s *NormalMetric) Get() int64 {
 	return atomic.LoadInt64(&s.value)
 }
 
+// Total returns the sum of all the added values, which is not reset by Clear.
+func (s *NormalMetric) Total() int64 {
+	return atomic.LoadInt64(&s.total)
+}
+
 func (s *NormalMetric) Name() string {
 	return s.name
 }
@@ -117,6 +143,16 @@ func (s *AvgMetric) GetAvg() float64 {
 	return avg
 }
 
+// Value returns the average of the values added since the last reset without resetting it.
+func (s *AvgMetric) Value() float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	if s.count > 0 {
+		return float64(s.value) / float64(s.count)
+	}
+	return s.prevAvg
+}
+
 func (s *AvgMetric) Name() string {
 	return s.name
 }
@@ -130,6 +166,11 @@ type LatMetric struct {
 	t          time.Time
 	count      int
 	latencySum time.Duration
+
+	// the histogram since created, which is not reset by Clear.
+	histCount   uint64
+	histSum     time.Duration
+	histBuckets []uint64
 }
 
 func (s *LatMetric) Name() string {
@@ -145,11 +186,52 @@ func (s *LatMetric) Begin() {
 func (s *LatMetric) End() {
 	endT := time.Now()
 	mu.Lock()
+	latency := endT.Sub(s.t)
 	s.count++
-	s.latencySum += endT.Sub(s.t)
+	s.latencySum += latency
+	s.observe(latency)
 	mu.Unlock()
 }
 
+// Observe records a latency in seconds.
+func (s *LatMetric) Observe(v float64) {
+	latency := time.Duration(v * float64(time.Second))
+	mu.Lock()
+	s.count++
+	s.latencySum += latency
+	s.observe(latency)
+	mu.Unlock()
+}
+
+func (s *LatMetric) observe(latency time.Duration) {
+	if s.histBuckets == nil {
+		s.histBuckets = make([]uint64, len(LatencyBuckets))
+	}
+	s.histCount++
+	s.histSum += latency
+	seconds := latency.Seconds()
+	for i, bound := range LatencyBuckets {
+		if seconds <= bound {
+			s.histBuckets[i]++
+			break
+		}
+	}
+}
+
+func (s *LatMetric) Histogram() (count uint64, sum float64, buckets map[float64]uint64) {
+	mu.Lock()
+	defer mu.Unlock()
+	buckets = make(map[float64]uint64, len(LatencyBuckets))
+	var cumulative uint64
+	for i, bound := range LatencyBuckets {
+		if s.histBuckets != nil {
+			cumulative += s.histBuckets[i]
+		}
+		buckets[bound] = cumulative
+	}
+	return s.histCount, s.histSum.Seconds(), buckets
+}
+
 func (s *LatMetric) Clear() {
 	mu.Lock()
 	s.count = 0
@@ -189,15 +271,15 @@ func NewLatencyMetric(n string) pipeline.LatencyMetric {
 }
 
 func NewCounterMetricAndRegister(n string, c pipeline.Context) pipeline.CounterMetric {
-	metric := &NormalMetric{name: n}
-	c.RegisterCounterMetric(metric)
-	return metric
+	return NewCounterVecAndRegister(n, nil, 1, c).WithLabelValues()
 }
 
 func NewAverageMetricAndRegister(n string, c pipeline.Context) pipeline.CounterMetric {
-	metric := &AvgMetric{name: n}
-	c.RegisterCounterMetric(metric)
-	return metric
+	vec := &counterVec{newMetricVec(n, nil, 1, func(name string) interface{} {
+		return &AvgMetric{name: name}
+	})}
+	c.RegisterCounterVec(vec)
+	return vec.WithLabelValues()
 }
 
 func NewStringMetricAndRegister(n string, c pipeline.Context) pipeline.StringMetric {
@@ -207,7 +289,9 @@ func NewStringMetricAndRegister(n string, c pipeline.Context) pipeline.StringMet
 }
 
 func NewLatencyMetricAndRegister(n string, c pipeline.Context) pipeline.LatencyMetric {
-	metric := &LatMetric{name: n}
-	c.RegisterLatencyMetric(metric)
-	return metric
+	vec := &histogramVec{newMetricVec(n, nil, 1, func(name string) interface{} {
+		return &LatMetric{name: name}
+	})}
+	c.RegisterHistogramVec(vec)
+	return vec.with(nil).(pipeline.LatencyMetric)
 }
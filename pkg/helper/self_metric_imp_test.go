@@ -694,3 +694,47 @@ func TestNewLatencyMetric(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalMetric_Total(t *testing.T) {
+	s := &NormalMetric{name: "n"}
+	s.Add(3)
+	s.Clear(0)
+	s.Add(2)
+	if s.Get() != 2 || s.Total() != 5 {
+		t.Errorf("NormalMetric.Get() = %v, Total() = %v, want 2, 5", s.Get(), s.Total())
+	}
+}
+
+func TestAvgMetric_Value(t *testing.T) {
+	s := &AvgMetric{name: "n"}
+	s.Add(1)
+	s.Add(3)
+	if got := s.Value(); got != 2 {
+		t.Errorf("AvgMetric.Value() = %v, want 2", got)
+	}
+	if got := s.GetAvg(); got != 2 {
+		t.Errorf("AvgMetric.GetAvg() = %v, want 2", got)
+	}
+	if got := s.Value(); got != 2 {
+		t.Errorf("AvgMetric.Value() = %v after GetAvg, want 2", got)
+	}
+}
+
+func TestLatMetric_Histogram(t *testing.T) {
+	s := &LatMetric{name: "n"}
+	for _, latency := range []time.Duration{time.Microsecond, 2 * time.Millisecond, 20 * time.Second} {
+		s.observe(latency)
+	}
+	s.Clear()
+	count, sum, buckets := s.Histogram()
+	if count != 3 {
+		t.Errorf("LatMetric.Histogram() count = %v, want 3", count)
+	}
+	if sum < 20 || sum > 20.1 {
+		t.Errorf("LatMetric.Histogram() sum = %v, want about 20", sum)
+	}
+	want := map[float64]uint64{0.0001: 1, 0.0005: 1, 0.001: 1, 0.005: 2, 0.01: 2, 0.05: 2, 0.1: 2, 0.5: 2, 1: 2, 5: 2, 10: 2}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("LatMetric.Histogram() buckets = %v, want %v", buckets, want)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+)
+
+// DefaultMaxSeries is the max number of the series of a metric vector when the limit is not specified.
+const DefaultMaxSeries = 1000
+
+// OverflowLabelValue is the value of all the labels of the series shared by the label values exceeding the limit.
+const OverflowLabelValue = "_overflow_"
+
+// DefaultHistogramBuckets are the upper bounds of the histogram buckets when the buckets are not specified.
+var DefaultHistogramBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type GaugeMetricImp struct {
+	name  string
+	value uint64
+}
+
+func (s *GaugeMetricImp) Name() string {
+	return s.name
+}
+
+func (s *GaugeMetricImp) Set(v float64) {
+	atomic.StoreUint64(&s.value, math.Float64bits(v))
+}
+
+func (s *GaugeMetricImp) Add(v float64) {
+	for {
+		old := atomic.LoadUint64(&s.value)
+		if atomic.CompareAndSwapUint64(&s.value, old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (s *GaugeMetricImp) Get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.value))
+}
+
+func (s *GaugeMetricImp) Serialize(log *protocol.Log) {
+	log.Contents = append(log.Contents, &protocol.Log_Content{Key: s.name, Value: strconv.FormatFloat(s.Get(), 'f', -1, 64)})
+}
+
+type HistogramMetricImp struct {
+	name   string
+	bounds []float64
+
+	mu      sync.Mutex
+	buckets []uint64
+	count   uint64
+	sum     float64
+	// the statistics since the last Clear.
+	windowCount int64
+	windowSum   float64
+}
+
+func (s *HistogramMetricImp) Name() string {
+	return s.name
+}
+
+func (s *HistogramMetricImp) Observe(v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	s.sum += v
+	s.windowCount++
+	s.windowSum += v
+	if i := sort.SearchFloat64s(s.bounds, v); i < len(s.bounds) {
+		s.buckets[i]++
+	}
+}
+
+func (s *HistogramMetricImp) Clear() {
+	s.mu.Lock()
+	s.windowCount = 0
+	s.windowSum = 0
+	s.mu.Unlock()
+}
+
+// Get returns the average of the observations since the last Clear.
+func (s *HistogramMetricImp) Get() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.windowCount == 0 {
+		return 0
+	}
+	return s.windowSum / float64(s.windowCount)
+}
+
+func (s *HistogramMetricImp) Histogram() (count uint64, sum float64, buckets map[float64]uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buckets = make(map[float64]uint64, len(s.bounds))
+	var cumulative uint64
+	for i, bound := range s.bounds {
+		cumulative += s.buckets[i]
+		buckets[bound] = cumulative
+	}
+	return s.count, s.sum, buckets
+}
+
+func (s *HistogramMetricImp) Serialize(log *protocol.Log) {
+	log.Contents = append(log.Contents, &protocol.Log_Content{Key: s.name, Value: strconv.FormatFloat(s.Get(), 'f', 4, 64)})
+}
+
+type metricSeries struct {
+	labelValues []string
+	metric      interface{}
+}
+
+// metricVec keeps the series of a metric vector, newMetric creates the metric of a series by the series name.
+type metricVec struct {
+	name       string
+	labelNames []string
+	maxSeries  int
+	newMetric  func(name string) interface{}
+
+	mu       sync.Mutex
+	series   map[string]*metricSeries
+	ordered  []*metricSeries
+	overflow *metricSeries
+}
+
+func newMetricVec(name string, labelNames []string, maxSeries int, newMetric func(name string) interface{}) *metricVec {
+	if maxSeries <= 0 {
+		maxSeries = DefaultMaxSeries
+	}
+	return &metricVec{
+		name:       name,
+		labelNames: append([]string(nil), labelNames...),
+		maxSeries:  maxSeries,
+		newMetric:  newMetric,
+		series:     make(map[string]*metricSeries),
+	}
+}
+
+func (v *metricVec) Name() string {
+	return v.name
+}
+
+func (v *metricVec) LabelNames() []string {
+	return v.labelNames
+}
+
+func (v *metricVec) Collect(fn func(labelValues []string, metric interface{})) {
+	v.mu.Lock()
+	series := append([]*metricSeries(nil), v.ordered...)
+	if v.overflow != nil {
+		series = append(series, v.overflow)
+	}
+	v.mu.Unlock()
+	for _, s := range series {
+		fn(s.labelValues, s.metric)
+	}
+}
+
+// with returns the metric of the label values, the missing values are empty and the extra ones are ignored.
+func (v *metricVec) with(values []string) interface{} {
+	if len(values) != len(v.labelNames) {
+		values = append(values, make([]string, len(v.labelNames))...)[:len(v.labelNames)]
+	}
+	name := seriesName(v.name, v.labelNames, values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[name]; ok {
+		return s.metric
+	}
+	if len(v.ordered) >= v.maxSeries {
+		if v.overflow == nil {
+			logger.Warning(context.Background(), "SELF_METRIC_ALARM", "the series of metric exceed the limit", v.name, "limit", v.maxSeries)
+			values := make([]string, len(v.labelNames))
+			for i := range values {
+				values[i] = OverflowLabelValue
+			}
+			v.overflow = &metricSeries{labelValues: values, metric: v.newMetric(seriesName(v.name, v.labelNames, values))}
+		}
+		return v.overflow.metric
+	}
+	s := &metricSeries{labelValues: append([]string(nil), values...), metric: v.newMetric(name)}
+	v.series[name] = s
+	v.ordered = append(v.ordered, s)
+	return s.metric
+}
+
+// seriesName is the name of the series in the self telemetry logs, such as name{label1=value1,label2=value2}.
+func seriesName(name string, labelNames, labelValues []string) string {
+	if len(labelNames) == 0 {
+		return name
+	}
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteByte('{')
+	for i, labelName := range labelNames {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(labelName)
+		sb.WriteByte('=')
+		sb.WriteString(labelValues[i])
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+type counterVec struct {
+	*metricVec
+}
+
+func (v *counterVec) WithLabelValues(values ...string) pipeline.CounterMetric {
+	return v.with(values).(pipeline.CounterMetric)
+}
+
+func (v *counterVec) Serialize(log *protocol.Log) {
+	v.Collect(func(_ []string, metric interface{}) {
+		counter := metric.(pipeline.CounterMetric)
+		counter.Serialize(log)
+		counter.Clear(0)
+	})
+}
+
+type gaugeVec struct {
+	*metricVec
+}
+
+func (v *gaugeVec) WithLabelValues(values ...string) pipeline.GaugeMetric {
+	return v.with(values).(pipeline.GaugeMetric)
+}
+
+func (v *gaugeVec) Serialize(log *protocol.Log) {
+	v.Collect(func(_ []string, metric interface{}) {
+		metric.(pipeline.GaugeMetric).Serialize(log)
+	})
+}
+
+type histogramVec struct {
+	*metricVec
+}
+
+func (v *histogramVec) WithLabelValues(values ...string) pipeline.HistogramMetric {
+	return v.with(values).(pipeline.HistogramMetric)
+}
+
+func (v *histogramVec) Serialize(log *protocol.Log) {
+	v.Collect(func(_ []string, metric interface{}) {
+		histogram := metric.(pipeline.HistogramMetric)
+		histogram.Serialize(log)
+		histogram.Clear()
+	})
+}
+
+// NewCounterVec creates a vector of counters, maxSeries is DefaultMaxSeries if it is not positive.
+func NewCounterVec(name string, labelNames []string, maxSeries int) pipeline.CounterVec {
+	return &counterVec{newMetricVec(name, labelNames, maxSeries, func(name string) interface{} {
+		return &NormalMetric{name: name}
+	})}
+}
+
+// NewGaugeVec creates a vector of gauges, maxSeries is DefaultMaxSeries if it is not positive.
+func NewGaugeVec(name string, labelNames []string, maxSeries int) pipeline.GaugeVec {
+	return &gaugeVec{newMetricVec(name, labelNames, maxSeries, func(name string) interface{} {
+		return &GaugeMetricImp{name: name}
+	})}
+}
+
+// NewHistogramVec creates a vector of histograms with the upper bounds of the buckets, the buckets are
+// DefaultHistogramBuckets if empty, and maxSeries is DefaultMaxSeries if it is not positive.
+func NewHistogramVec(name string, labelNames []string, buckets []float64, maxSeries int) pipeline.HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultHistogramBuckets
+	}
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	return &histogramVec{newMetricVec(name, labelNames, maxSeries, func(name string) interface{} {
+		return &HistogramMetricImp{name: name, bounds: bounds, buckets: make([]uint64, len(bounds))}
+	})}
+}
+
+func NewCounterVecAndRegister(name string, labelNames []string, maxSeries int, c pipeline.Context) pipeline.CounterVec {
+	vec := NewCounterVec(name, labelNames, maxSeries)
+	c.RegisterCounterVec(vec)
+	return vec
+}
+
+func NewGaugeVecAndRegister(name string, labelNames []string, maxSeries int, c pipeline.Context) pipeline.GaugeVec {
+	vec := NewGaugeVec(name, labelNames, maxSeries)
+	c.RegisterGaugeVec(vec)
+	return vec
+}
+
+func NewHistogramVecAndRegister(name string, labelNames []string, buckets []float64, maxSeries int, c pipeline.Context) pipeline.HistogramVec {
+	vec := NewHistogramVec(name, labelNames, buckets, maxSeries)
+	c.RegisterHistogramVec(vec)
+	return vec
+}
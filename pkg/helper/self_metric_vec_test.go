@@ -0,0 +1,122 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+)
+
+func logContents(log *protocol.Log) map[string]string {
+	contents := make(map[string]string)
+	for _, content := range log.Contents {
+		contents[content.Key] = content.Value
+	}
+	return contents
+}
+
+func TestCounterVec(t *testing.T) {
+	vec := NewCounterVec("send_bytes", []string{"topic", "partition"}, 2)
+	vec.WithLabelValues("a", "0").Add(1)
+	vec.WithLabelValues("a", "0").Add(2)
+	vec.WithLabelValues("a").Add(3)
+	vec.WithLabelValues("b", "1").Add(4)
+	vec.WithLabelValues("c", "2").Add(5)
+	assert.Equal(t, "send_bytes", vec.Name())
+	assert.Equal(t, []string{"topic", "partition"}, vec.LabelNames())
+
+	var labels [][]string
+	var totals []int64
+	vec.Collect(func(labelValues []string, metric interface{}) {
+		labels = append(labels, labelValues)
+		totals = append(totals, metric.(CumulativeReader).Total())
+	})
+	assert.Equal(t, [][]string{{"a", "0"}, {"a", ""}, {OverflowLabelValue, OverflowLabelValue}}, labels)
+	assert.Equal(t, []int64{3, 3, 9}, totals)
+
+	log := &protocol.Log{}
+	vec.Serialize(log)
+	assert.Equal(t, map[string]string{
+		"send_bytes{topic=a,partition=0}":                   "3",
+		"send_bytes{topic=a,partition=}":                    "3",
+		"send_bytes{topic=_overflow_,partition=_overflow_}": "9",
+	}, logContents(log))
+	assert.Equal(t, int64(0), vec.WithLabelValues("a", "0").Get())
+	assert.Equal(t, int64(3), vec.WithLabelValues("a", "0").(CumulativeReader).Total())
+}
+
+func TestGaugeVec(t *testing.T) {
+	vec := NewGaugeVec("queue_size", []string{"queue"}, 0)
+	vec.WithLabelValues("q1").Set(1.5)
+	vec.WithLabelValues("q1").Add(2)
+	vec.WithLabelValues("q2").Add(-1)
+	assert.Equal(t, 3.5, vec.WithLabelValues("q1").Get())
+
+	log := &protocol.Log{}
+	vec.Serialize(log)
+	assert.Equal(t, map[string]string{"queue_size{queue=q1}": "3.5", "queue_size{queue=q2}": "-1"}, logContents(log))
+	assert.Equal(t, 3.5, vec.WithLabelValues("q1").Get())
+}
+
+func TestHistogramVec(t *testing.T) {
+	vec := NewHistogramVec("request_size", []string{"endpoint"}, []float64{100, 10}, 0)
+	histogram := vec.WithLabelValues("/write")
+	for _, v := range []float64{5, 10, 50, 1000} {
+		histogram.Observe(v)
+	}
+	count, sum, buckets := histogram.(HistogramReader).Histogram()
+	assert.Equal(t, uint64(4), count)
+	assert.Equal(t, 1065.0, sum)
+	assert.Equal(t, map[float64]uint64{10: 2, 100: 3}, buckets)
+
+	log := &protocol.Log{}
+	vec.Serialize(log)
+	assert.Equal(t, map[string]string{"request_size{endpoint=/write}": "266.2500"}, logContents(log))
+	log = &protocol.Log{}
+	vec.Serialize(log)
+	assert.Equal(t, map[string]string{"request_size{endpoint=/write}": "0.0000"}, logContents(log))
+	count, _, _ = histogram.(HistogramReader).Histogram()
+	assert.Equal(t, uint64(4), count)
+}
+
+func TestMetricAndRegisterOnVec(t *testing.T) {
+	ctx := &LocalContext{}
+	ctx.InitContext("p", "l", "c")
+	counter := NewCounterMetricAndRegister("counter", ctx)
+	average := NewAverageMetricAndRegister("average", ctx)
+	latency := NewLatencyMetricAndRegister("latency", ctx)
+	NewGaugeVecAndRegister("gauge", []string{"k"}, 0, ctx).WithLabelValues("v").Set(1)
+	require.Len(t, ctx.MetricVecs, 4)
+	assert.Implements(t, (*pipeline.LatencyMetric)(nil), latency)
+
+	counter.Add(2)
+	average.Add(1)
+	average.Add(2)
+	latency.(pipeline.HistogramMetric).Observe(0.002)
+	log := &protocol.Log{}
+	ctx.MetricSerializeToPB(log)
+	contents := logContents(log)
+	assert.Equal(t, "2", contents["counter"])
+	assert.Equal(t, "1.5000", contents["average"])
+	assert.Equal(t, "2000.0000", contents["latency"])
+	assert.Equal(t, "1", contents["gauge{k=v}"])
+	assert.Equal(t, int64(0), counter.Get())
+	assert.Equal(t, int64(0), latency.Get())
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg"
+)
+
+const jsonTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// jsonLog is a log in the json format, which is written as a JSON object per line.
+type jsonLog struct {
+	Time      string                 `json:"time"`
+	Level     string                 `json:"level"`
+	Caller    string                 `json:"caller,omitempty"`
+	AlarmType string                 `json:"alarm_type,omitempty"`
+	Config    string                 `json:"config,omitempty"`
+	Logstore  string                 `json:"logstore,omitempty"`
+	Plugin    string                 `json:"plugin,omitempty"`
+	Message   string                 `json:"message"`
+	KVs       map[string]interface{} `json:"kvs,omitempty"`
+}
+
+// formatJSON formats the log in the json format, the message is the log without the logstore and the config.
+func formatJSON(e *entry, ltCtx *pkg.LogtailContextMeta, msg string, file string, line int) string {
+	l := jsonLog{
+		Time:      time.Now().Format(jsonTimeLayout),
+		Level:     e.level.String(),
+		AlarmType: e.alarmType,
+		Message:   msg,
+	}
+	if file != "" {
+		l.Caller = filepath.Base(file) + ":" + strconv.Itoa(line)
+	}
+	if ltCtx != nil {
+		l.Config = ltCtx.GetConfigName()
+		l.Logstore = ltCtx.GetLogStore()
+	}
+	if !e.formatted && len(e.kvPairs) != 0 {
+		l.KVs = make(map[string]interface{}, (len(e.kvPairs)+1)/2)
+		for i := 0; i < len(e.kvPairs); i += 2 {
+			key := fmt.Sprint(e.kvPairs[i])
+			var value interface{} = ""
+			if i+1 < len(e.kvPairs) {
+				value = jsonValue(e.kvPairs[i+1])
+			}
+			l.KVs[key] = value
+		}
+		if plugin, ok := l.KVs["plugin"].(string); ok {
+			l.Plugin = plugin
+		}
+	}
+	data, err := json.Marshal(&l)
+	if err != nil {
+		return msg
+	}
+	return string(data)
+}
+
+// jsonValue keeps the basic types of the values, and the others are formatted as strings.
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return val
+	case time.Duration:
+		return val.String()
+	case error:
+		return val.Error()
+	default:
+		return fmt.Sprint(val)
+	}
+}
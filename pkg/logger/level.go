@@ -0,0 +1,193 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cihub/seelog"
+
+	"github.com/alibaba/ilogtail/pkg/util"
+)
+
+// levelFileName is the file of the levels reloaded by the level signal, in the same format as the level endpoint.
+const levelFileName = "plugin_logger_level.json"
+
+// levelSettings are the min levels of the logs, the level of a module overrides the global level for the logs written
+// by the source files in the module directory, such as pluginmanager or plugins/input/docker.
+type levelSettings struct {
+	level   seelog.LogLevel
+	modules map[string]seelog.LogLevel
+}
+
+// LevelConfig is the body of the level endpoint and the level file.
+type LevelConfig struct {
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+var levels atomic.Value
+
+func init() {
+	levels.Store(&levelSettings{level: seelog.InfoLvl})
+}
+
+func currentLevels() *levelSettings {
+	return levels.Load().(*levelSettings)
+}
+
+func setLevels(settings *levelSettings) {
+	debug := settings.level <= seelog.DebugLvl
+	for _, level := range settings.modules {
+		debug = debug || level <= seelog.DebugLvl
+	}
+	levels.Store(settings)
+	if debug {
+		atomic.StoreInt32(&debugFlag, 1)
+	} else {
+		atomic.StoreInt32(&debugFlag, 0)
+	}
+}
+
+func hasModuleLevels() bool {
+	return len(currentLevels().modules) != 0
+}
+
+// levelEnabled reports whether the log written by the source file is enabled, the longest module matched wins.
+func levelEnabled(level seelog.LogLevel, file string) bool {
+	settings := currentLevels()
+	minLevel := settings.level
+	matched := 0
+	for module, moduleLevel := range settings.modules {
+		if len(module) > matched && strings.Contains(file, "/"+module+"/") {
+			minLevel = moduleLevel
+			matched = len(module)
+		}
+	}
+	return level >= minLevel
+}
+
+// SetLevel changes the global level and the levels of the modules at runtime. The empty level restores the level
+// in the config file.
+func SetLevel(level string, modules map[string]string) error {
+	settings := &levelSettings{level: configuredLevel}
+	if level != "" {
+		l, found := seelog.LogLevelFromString(strings.ToLower(level))
+		if !found {
+			return fmt.Errorf("invalid log level %s", level)
+		}
+		settings.level = l
+	}
+	if len(modules) != 0 {
+		settings.modules = make(map[string]seelog.LogLevel, len(modules))
+		for module, moduleLevel := range modules {
+			l, found := seelog.LogLevelFromString(strings.ToLower(moduleLevel))
+			if !found {
+				return fmt.Errorf("invalid log level %s of module %s", moduleLevel, module)
+			}
+			settings.modules[strings.Trim(module, "/")] = l
+		}
+	}
+	setLevels(settings)
+	return nil
+}
+
+// GetLevel returns the global level and the levels of the modules.
+func GetLevel() (string, map[string]string) {
+	settings := currentLevels()
+	var modules map[string]string
+	if len(settings.modules) != 0 {
+		modules = make(map[string]string, len(settings.modules))
+		for module, level := range settings.modules {
+			modules[module] = level.String()
+		}
+	}
+	return settings.level.String(), modules
+}
+
+// HandleLogLevel returns the levels for GET, and changes them by the LevelConfig in the body for PUT or POST.
+func HandleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("read body error"))
+			return
+		}
+		var cfg LevelConfig
+		if err = json.Unmarshal(body, &cfg); err == nil {
+			err = SetLevel(cfg.Level, cfg.Modules)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+		Info(context.Background(), "change log level", cfg.Level, "modules", cfg.Modules)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var cfg LevelConfig
+	cfg.Level, cfg.Modules = GetLevel()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cfg)
+}
+
+// ReloadLevelFile changes the levels by the level file, the levels are restored when the file does not exist.
+func ReloadLevelFile() error {
+	content, err := os.ReadFile(util.GetCurrentBinaryPath() + levelFileName)
+	if os.IsNotExist(err) {
+		return SetLevel("", nil)
+	}
+	if err != nil {
+		return err
+	}
+	var cfg LevelConfig
+	if err = json.Unmarshal(content, &cfg); err != nil {
+		return err
+	}
+	return SetLevel(cfg.Level, cfg.Modules)
+}
+
+// WatchLevelSignal reloads the level file when the level signal is received, it is SIGUSR1 and not supported on
+// windows.
+func WatchLevelSignal() {
+	if len(levelSignals) == 0 {
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, levelSignals...)
+	go func() {
+		for range ch {
+			if err := ReloadLevelFile(); err != nil {
+				Warning(context.Background(), "LOG_LEVEL_ALARM", "reload log level file error", err)
+				continue
+			}
+			level, modules := GetLevel()
+			Info(context.Background(), "reload log level", level, "modules", modules)
+		}
+	}()
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/util"
+)
+
+func TestSetLevel(t *testing.T) {
+	mu.Lock()
+	defer mu.Unlock()
+	excludeFlag()
+	clean()
+	initTestLogger(OptionOffConsole, OptionInfoLevel)
+	defer initTestLogger(OptionOffConsole)
+
+	Debug(context.Background(), "line0")
+	assert.Equal(t, "", readLog(0))
+	require.NoError(t, SetLevel("debug", nil))
+	assert.True(t, DebugFlag())
+	Debug(context.Background(), "line0")
+	assert.Contains(t, readLog(0), "line0")
+
+	// the level of the module overrides the global level.
+	require.NoError(t, SetLevel("error", map[string]string{"pkg/logger/": "debug", "pkg": "warn"}))
+	level, modules := GetLevel()
+	assert.Equal(t, "error", level)
+	assert.Equal(t, map[string]string{"pkg/logger": "debug", "pkg": "warn"}, modules)
+	Debug(context.Background(), "line1")
+	assert.Contains(t, readLog(1), "line1")
+	require.NoError(t, SetLevel("debug", map[string]string{"pkg": "warn"}))
+	Info(context.Background(), "line2")
+	assert.Equal(t, "", readLog(2))
+
+	assert.Error(t, SetLevel("verbose", nil))
+	assert.Error(t, SetLevel("info", map[string]string{"pkg": "verbose"}))
+	// the empty level restores the level in the config file.
+	require.NoError(t, SetLevel("", nil))
+	level, modules = GetLevel()
+	assert.Equal(t, "info", level)
+	assert.Nil(t, modules)
+	assert.False(t, DebugFlag())
+}
+
+func TestHandleLogLevel(t *testing.T) {
+	mu.Lock()
+	defer mu.Unlock()
+	excludeFlag()
+	clean()
+	initTestLogger(OptionOffConsole, OptionInfoLevel)
+	defer initTestLogger(OptionOffConsole)
+
+	w := httptest.NewRecorder()
+	HandleLogLevel(w, httptest.NewRequest(http.MethodGet, "/loglevel", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"info"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	HandleLogLevel(w, httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(`{"level":"warn","modules":{"pluginmanager":"debug"}}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"warn","modules":{"pluginmanager":"debug"}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	HandleLogLevel(w, httptest.NewRequest(http.MethodPost, "/loglevel", strings.NewReader(`{"level":"verbose"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	level, _ := GetLevel()
+	assert.Equal(t, "warn", level)
+
+	w = httptest.NewRecorder()
+	HandleLogLevel(w, httptest.NewRequest(http.MethodDelete, "/loglevel", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestReloadLevelFile(t *testing.T) {
+	mu.Lock()
+	defer mu.Unlock()
+	excludeFlag()
+	clean()
+	initTestLogger(OptionOffConsole, OptionInfoLevel)
+	defer initTestLogger(OptionOffConsole)
+
+	path := util.GetCurrentBinaryPath() + levelFileName
+	require.NoError(t, os.WriteFile(path, []byte(`{"level":"error","modules":{"plugins/input":"debug"}}`), 0600))
+	defer os.Remove(path)
+	require.NoError(t, ReloadLevelFile())
+	level, modules := GetLevel()
+	assert.Equal(t, "error", level)
+	assert.Equal(t, map[string]string{"plugins/input": "debug"}, modules)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"level":`), 0600))
+	assert.Error(t, ReloadLevelFile())
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, ReloadLevelFile())
+	level, modules = GetLevel()
+	assert.Equal(t, "info", level)
+	assert.Nil(t, modules)
+}
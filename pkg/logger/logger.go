@@ -24,6 +24,8 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -67,6 +69,13 @@ const (
 	FlagLevelName   = "logger-level"
 	FlagConsoleName = "logger-console"
 	FlagRetainName  = "logger-retain"
+	FlagFormatName  = "logger-format"
+)
+
+// The formats of the logs, the json format writes a JSON object per line.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
 )
 
 // logtailLogger is a global logger instance, which is shared with the whole plugins of LogtailPlugin.
@@ -81,6 +90,7 @@ var (
 	loggerLevel   = flag.String(FlagLevelName, "", "debug flag")
 	loggerConsole = flag.String(FlagConsoleName, "", "debug flag")
 	loggerRetain  = flag.String(FlagRetainName, "", "debug flag")
+	loggerFormat  = flag.String(FlagFormatName, "", "debug flag")
 )
 
 var (
@@ -90,12 +100,18 @@ var (
 	retainFlag         bool
 	levelFlag          string
 	debugFlag          int32
+	jsonFlag           bool
+	// configuredLevel is the min level in the config file, which is the level unless changed at runtime.
+	configuredLevel seelog.LogLevel
 
 	template          string
 	once              sync.Once
 	wait              sync.WaitGroup
 	closeChan         chan struct{}
 	closedCatchStdout bool
+
+	minLevelRegExp = regexp.MustCompile(`(?mi)(minlevel=")([^"]*)(")`)
+	formatRegExp   = regexp.MustCompile(`(?mi)(<format\s[^>]*format=")([^"]*)(")`)
 )
 
 func Init() {
@@ -135,126 +151,132 @@ func initTestLogger(options ...ConfigOption) {
 	setLogConf(util.GetCurrentBinaryPath() + "plugin_logger.xml")
 }
 
+// The logging functions and write are not inlined, since seelog finds the caller of the logs by the stack depth.
+
+//go:noinline
 func Debug(ctx context.Context, kvPairs ...interface{}) {
 	if !DebugFlag() {
 		return
 	}
-	ltCtx, ok := ctx.Value(pkg.LogTailMeta).(*pkg.LogtailContextMeta)
-	if ok {
-		logtailLogger.Debug(ltCtx.LoggerHeader(), generateLog(kvPairs...))
-	} else {
-		logtailLogger.Debug(generateLog(kvPairs...))
-	}
+	output(ctx, &entry{level: seelog.DebugLvl, kvPairs: kvPairs})
 }
 
+//go:noinline
 func Debugf(ctx context.Context, format string, params ...interface{}) {
 	if !DebugFlag() {
 		return
 	}
-	ltCtx, ok := ctx.Value(pkg.LogTailMeta).(*pkg.LogtailContextMeta)
-	if ok {
-		logtailLogger.Debugf(ltCtx.LoggerHeader()+format, params...)
-	} else {
-		logtailLogger.Debugf(format, params...)
-	}
+	output(ctx, &entry{level: seelog.DebugLvl, formatted: true, format: format, params: params})
 }
 
+//go:noinline
 func Info(ctx context.Context, kvPairs ...interface{}) {
-	ltCtx, ok := ctx.Value(pkg.LogTailMeta).(*pkg.LogtailContextMeta)
-	if ok {
-		logtailLogger.Info(ltCtx.LoggerHeader(), generateLog(kvPairs...))
-	} else {
-		logtailLogger.Info(generateLog(kvPairs...))
-	}
+	output(ctx, &entry{level: seelog.InfoLvl, kvPairs: kvPairs})
 }
 
+//go:noinline
 func Infof(ctx context.Context, format string, params ...interface{}) {
-	ltCtx, ok := ctx.Value(pkg.LogTailMeta).(*pkg.LogtailContextMeta)
-	if ok {
-		logtailLogger.Infof(ltCtx.LoggerHeader()+format, params...)
-	} else {
-		logtailLogger.Infof(format, params...)
-	}
+	output(ctx, &entry{level: seelog.InfoLvl, formatted: true, format: format, params: params})
 }
 
+//go:noinline
 func Warning(ctx context.Context, alarmType string, kvPairs ...interface{}) {
-	ltCtx, ok := ctx.Value(pkg.LogTailMeta).(*pkg.LogtailContextMeta)
-	if ok {
-		kvPairs = append(kvPairs, "logstore", ltCtx.GetLogStore(), "config", ltCtx.GetConfigName())
-	}
-	msg := generateLog(kvPairs...)
-	if ok {
-		_ = logtailLogger.Warn(ltCtx.LoggerHeader(), "AlarmType:", alarmType, "\t", msg)
-		if remoteFlag {
-			ltCtx.RecordAlarm(alarmType, msg)
-		}
-	} else {
-		_ = logtailLogger.Warn("AlarmType:", alarmType, "\t", msg)
-		if remoteFlag {
-			util.GlobalAlarm.Record(alarmType, msg)
-		}
-	}
+	output(ctx, &entry{level: seelog.WarnLvl, alarmType: alarmType, kvPairs: kvPairs})
 }
 
+//go:noinline
 func Warningf(ctx context.Context, alarmType string, format string, params ...interface{}) {
-	ltCtx, ok := ctx.Value(pkg.LogTailMeta).(*pkg.LogtailContextMeta)
-	if ok {
-		format += "\tlogstore:%v\tconfig:%v"
-		params = append(params, ltCtx.GetLogStore(), ltCtx.GetConfigName())
-	}
-	msg := fmt.Sprintf(format, params...)
-	if ok {
-		_ = logtailLogger.Warn(ltCtx.LoggerHeader(), "AlarmType:", alarmType, "\t", msg)
-		if remoteFlag {
-			ltCtx.RecordAlarm(alarmType, msg)
-		}
-	} else {
-		_ = logtailLogger.Warn("AlarmType:", alarmType, "\t", msg)
-		if remoteFlag {
-			util.GlobalAlarm.Record(alarmType, msg)
-		}
-	}
+	output(ctx, &entry{level: seelog.WarnLvl, alarmType: alarmType, formatted: true, format: format, params: params})
 }
 
+//go:noinline
 func Error(ctx context.Context, alarmType string, kvPairs ...interface{}) {
-	ltCtx, ok := ctx.Value(pkg.LogTailMeta).(*pkg.LogtailContextMeta)
-	if ok {
-		kvPairs = append(kvPairs, "logstore", ltCtx.GetLogStore(), "config", ltCtx.GetConfigName())
+	output(ctx, &entry{level: seelog.ErrorLvl, alarmType: alarmType, kvPairs: kvPairs})
+}
+
+//go:noinline
+func Errorf(ctx context.Context, alarmType string, format string, params ...interface{}) {
+	output(ctx, &entry{level: seelog.ErrorLvl, alarmType: alarmType, formatted: true, format: format, params: params})
+}
+
+// entry is a log before it is formatted, the key value pairs are formatted by generateLog, or the params are
+// formatted by the format when formatted is true.
+type entry struct {
+	level     seelog.LogLevel
+	alarmType string
+	kvPairs   []interface{}
+	formatted bool
+	format    string
+	params    []interface{}
+}
+
+// output writes the log called by the exported functions, the warnings and errors are also recorded as alarms. It
+// must be called by the exported functions directly, since the caller is found by the stack depth.
+func output(ctx context.Context, e *entry) {
+	file, line := "", 0
+	if jsonFlag || hasModuleLevels() {
+		_, file, line, _ = runtime.Caller(2)
 	}
-	msg := generateLog(kvPairs...)
-	if ok {
-		_ = logtailLogger.Error(ltCtx.LoggerHeader(), "AlarmType:", alarmType, "\t", msg)
-		if remoteFlag {
-			ltCtx.RecordAlarm(alarmType, msg)
+	if !levelEnabled(e.level, file) {
+		return
+	}
+	ltCtx, ok := ctx.Value(pkg.LogTailMeta).(*pkg.LogtailContextMeta)
+	var msg, alarmMsg string
+	if e.formatted {
+		msg = fmt.Sprintf(e.format, e.params...)
+		alarmMsg = msg
+		if ok && e.level >= seelog.WarnLvl {
+			alarmMsg += fmt.Sprintf("\tlogstore:%v\tconfig:%v", ltCtx.GetLogStore(), ltCtx.GetConfigName())
 		}
 	} else {
-		_ = logtailLogger.Error("AlarmType:", alarmType, "\t", msg)
-		if remoteFlag {
-			util.GlobalAlarm.Record(alarmType, msg)
+		msg = generateLog(e.kvPairs...)
+		alarmMsg = msg
+		if ok && e.level >= seelog.WarnLvl {
+			alarmMsg += generateLog("logstore", ltCtx.GetLogStore(), "config", ltCtx.GetConfigName())
 		}
 	}
-}
 
-func Errorf(ctx context.Context, alarmType string, format string, params ...interface{}) {
-	ltCtx, ok := ctx.Value(pkg.LogTailMeta).(*pkg.LogtailContextMeta)
-	if ok {
-		format += "\tlogstore:%v\tconfig:%v"
-		params = append(params, ltCtx.GetLogStore(), ltCtx.GetConfigName())
+	text := alarmMsg
+	if e.level >= seelog.WarnLvl {
+		text = "AlarmType:" + e.alarmType + "\t" + text
 	}
-	msg := fmt.Sprintf(format, params...)
 	if ok {
-		_ = logtailLogger.Error(ltCtx.LoggerHeader(), "AlarmType:", alarmType, "\t", msg)
-		if remoteFlag {
-			ltCtx.RecordAlarm(alarmType, msg)
+		text = ltCtx.LoggerHeader() + text
+	}
+	allowed, summaries := repeatLimiter.allow(e.level, text, time.Now())
+	for _, summary := range summaries {
+		write(summary.level, formatSummary(summary))
+	}
+	if allowed {
+		if jsonFlag {
+			write(e.level, formatJSON(e, ltCtx, msg, file, line))
+		} else {
+			write(e.level, text)
 		}
-	} else {
-		_ = logtailLogger.Error("AlarmType:", alarmType, "\t", msg)
-		if remoteFlag {
-			util.GlobalAlarm.Record(alarmType, msg)
+	}
+	if e.level >= seelog.WarnLvl && remoteFlag {
+		if ok {
+			ltCtx.RecordAlarm(e.alarmType, alarmMsg)
+		} else {
+			util.GlobalAlarm.Record(e.alarmType, alarmMsg)
 		}
 	}
 }
 
+//go:noinline
+func write(level seelog.LogLevel, text string) {
+	switch level {
+	case seelog.DebugLvl:
+		logtailLogger.Debug(text)
+	case seelog.InfoLvl:
+		logtailLogger.Info(text)
+	case seelog.WarnLvl:
+		_ = logtailLogger.Warn(text)
+	default:
+		_ = logtailLogger.Error(text)
+	}
+}
+
 // Flush logs to the output when using async logger.
 func Flush() {
 	logtailLogger.Flush()
@@ -280,24 +302,37 @@ func setLogConf(logConfig string) {
 	dat := string(content)
 	aliyunLogtailLogLevel := strings.ToLower(os.Getenv("LOGTAIL_LOG_LEVEL"))
 	if aliyunLogtailLogLevel != "" {
-		pattern := `(?mi)(minlevel=")([^"]*)(")`
-		regExp := regexp.MustCompile(pattern)
-		dat = regExp.ReplaceAllString(dat, `${1}`+aliyunLogtailLogLevel+`${3}`)
+		dat = minLevelRegExp.ReplaceAllString(dat, `${1}`+aliyunLogtailLogLevel+`${3}`)
+	}
+	if format := strings.ToLower(os.Getenv("LOGTAIL_LOG_FORMAT")); format != "" {
+		jsonFlag = format == logFormatJSON
+	}
+	if limit, err := strconv.Atoi(os.Getenv("LOGTAIL_LOG_REPEAT_LIMIT")); err == nil {
+		repeatLimiter.reset(limit, repeatLimiter.window)
+	}
+	// The levels are filtered before the logs are passed to seelog, so that they can be changed at runtime.
+	configuredLevel = seelog.TraceLvl
+	if match := minLevelRegExp.FindStringSubmatch(dat); match != nil {
+		if level, found := seelog.LogLevelFromString(strings.ToLower(match[2])); found {
+			configuredLevel = level
+		}
+	}
+	dat = minLevelRegExp.ReplaceAllString(dat, `${1}`+seelog.TraceStr+`${3}`)
+	if jsonFlag {
+		dat = formatRegExp.ReplaceAllString(dat, `${1}%Msg%n${3}`)
 	}
 	logger, err := seelog.LoggerFromConfigAsString(dat)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "init logger error", err)
 		return
 	}
-	if err := logger.SetAdditionalStackDepth(1); err != nil {
+	// The logs are written by write and output called by the exported functions.
+	if err := logger.SetAdditionalStackDepth(3); err != nil {
 		fmt.Fprintf(os.Stderr, "cannot set logger stack depth: %v\n", err)
 		return
 	}
 	logtailLogger = logger
-
-	if aliyunLogtailLogLevel == "debug" || strings.Contains(dat, "minlevel=\"debug\"") {
-		debugFlag = 1
-	}
+	setLevels(&levelSettings{level: configuredLevel})
 }
 
 func generateLog(kvPairs ...interface{}) string {
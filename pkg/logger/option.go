@@ -18,6 +18,7 @@ import (
 	"github.com/cihub/seelog"
 
 	"strconv"
+	"time"
 )
 
 var defaultProductionOptions = []ConfigOption{
@@ -26,6 +27,8 @@ var defaultProductionOptions = []ConfigOption{
 	OptionInfoLevel,
 	OptionRetainConfig,
 	OptionOffMemoryReceiver,
+	OptionTextFormat,
+	OptionRepeatLimit(10, 10*time.Second),
 }
 
 var defaultTestOptions = []ConfigOption{
@@ -34,6 +37,8 @@ var defaultTestOptions = []ConfigOption{
 	OptionInfoLevel,
 	OptionOverrideConfig,
 	OptionOffMemoryReceiver,
+	OptionTextFormat,
+	OptionRepeatLimit(0, 0),
 }
 
 type ConfigOption func()
@@ -70,6 +75,22 @@ func OptionErrorLevel() {
 	changeLevelFlag(seelog.ErrorStr)
 }
 
+func OptionTextFormat() {
+	changeFormatFlag(logFormatText)
+}
+
+func OptionJSONFormat() {
+	changeFormatFlag(logFormatJSON)
+}
+
+// OptionRepeatLimit allows at most limit identical logs in each window, the others are suppressed and counted in a
+// summary log. Zero limit disables it.
+func OptionRepeatLimit(limit int, window time.Duration) ConfigOption {
+	return func() {
+		repeatLimiter.reset(limit, window)
+	}
+}
+
 func OptionOverrideConfig() {
 	changeReatainFlag(false)
 }
@@ -112,3 +133,10 @@ func changeReatainFlag(retain bool) {
 		}
 	}
 }
+
+func changeFormatFlag(format string) {
+	jsonFlag = format == logFormatJSON
+	if *loggerFormat == logFormatText || *loggerFormat == logFormatJSON {
+		jsonFlag = *loggerFormat == logFormatJSON
+	}
+}
@@ -16,8 +16,11 @@ package logger
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -26,6 +29,7 @@ func excludeFlag() {
 	flag.Set(FlagLevelName, "xxx")
 	flag.Set(FlagConsoleName, "xxx")
 	flag.Set(FlagRetainName, "xxx")
+	flag.Set(FlagFormatName, "xxx")
 }
 
 func TestOptionDebugLevel(t *testing.T) {
@@ -124,3 +128,34 @@ func TestOpenMemoryReceiver(t *testing.T) {
 	_, ok := ReadMemoryLog(1)
 	assert.True(t, ok)
 }
+
+func TestOptionJSONFormat(t *testing.T) {
+	mu.Lock()
+	defer mu.Unlock()
+	excludeFlag()
+	clean()
+	initTestLogger(OptionOffConsole, OptionJSONFormat)
+	defer initTestLogger(OptionOffConsole, OptionTextFormat)
+	Warning(ctx, "ALARM", "plugin", "service_mock", "err", errors.New("failed"), "backoff", time.Second, "count", 3)
+	Infof(context.Background(), "hello %s", "world")
+
+	var l map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(readLog(0)), &l), "read %s", readLog(0))
+	assert.Equal(t, "warn", l["level"])
+	assert.Regexp(t, `^option_test.go:\d+$`, l["caller"])
+	assert.Equal(t, "ALARM", l["alarm_type"])
+	assert.Equal(t, testConfigName, l["config"])
+	assert.Equal(t, testLogstore, l["logstore"])
+	assert.Equal(t, "service_mock", l["plugin"])
+	assert.Equal(t, "plugin:service_mock\terr:failed\tbackoff:1s\tcount:3\t", l["message"])
+	assert.Equal(t, map[string]interface{}{"plugin": "service_mock", "err": "failed", "backoff": "1s", "count": float64(3)}, l["kvs"])
+	_, err := time.Parse(jsonTimeLayout, l["time"].(string))
+	assert.NoError(t, err)
+
+	l = nil
+	assert.NoError(t, json.Unmarshal([]byte(readLog(1)), &l), "read %s", readLog(1))
+	assert.Equal(t, "info", l["level"])
+	assert.Equal(t, "hello world", l["message"])
+	assert.NotContains(t, l, "kvs")
+	assert.NotContains(t, l, "config")
+}
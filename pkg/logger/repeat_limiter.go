@@ -0,0 +1,121 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cihub/seelog"
+)
+
+// maxRepeatEntries is the max number of the distinct logs tracked in a window, the others are not limited.
+const maxRepeatEntries = 10000
+
+// repeatLimiter is the limiter of the identical logs shared by the whole process.
+var repeatLimiter = &repeatedLogLimiter{}
+
+// repeatedLogLimiter allows at most limit identical logs in each window, and the suppressed logs are counted and
+// reported by a summary once the window ends.
+type repeatedLogLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	entries   map[string]*repeatEntry
+	lastSweep time.Time
+}
+
+type repeatEntry struct {
+	level      seelog.LogLevel
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// repeatSummary reports the number of the identical logs suppressed in a window.
+type repeatSummary struct {
+	level      seelog.LogLevel
+	text       string
+	suppressed int
+	window     time.Duration
+}
+
+func (l *repeatedLogLimiter) reset(limit int, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.window = window
+	l.entries = nil
+}
+
+// allow reports whether the log is allowed, and returns the summaries of the windows ended.
+func (l *repeatedLogLimiter) allow(level seelog.LogLevel, text string, now time.Time) (bool, []repeatSummary) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit <= 0 {
+		return true, nil
+	}
+	var summaries []repeatSummary
+	if now.Sub(l.lastSweep) >= l.window {
+		summaries = l.sweep(now)
+	}
+	e, ok := l.entries[text]
+	if ok && now.Sub(e.start) >= l.window {
+		if e.suppressed > 0 {
+			summaries = append(summaries, repeatSummary{level: e.level, text: text, suppressed: e.suppressed, window: l.window})
+		}
+		ok = false
+	}
+	if !ok {
+		if len(l.entries) >= maxRepeatEntries {
+			return true, summaries
+		}
+		if l.entries == nil {
+			l.entries = make(map[string]*repeatEntry)
+		}
+		e = &repeatEntry{level: level, start: now}
+		l.entries[text] = e
+	}
+	e.count++
+	if e.count > l.limit {
+		e.suppressed++
+		return false, summaries
+	}
+	return true, summaries
+}
+
+// sweep removes the entries of the windows ended, and returns the summaries of them. It must be called with mu held.
+func (l *repeatedLogLimiter) sweep(now time.Time) []repeatSummary {
+	l.lastSweep = now
+	var summaries []repeatSummary
+	for text, e := range l.entries {
+		if now.Sub(e.start) < l.window {
+			continue
+		}
+		if e.suppressed > 0 {
+			summaries = append(summaries, repeatSummary{level: e.level, text: text, suppressed: e.suppressed, window: l.window})
+		}
+		delete(l.entries, text)
+	}
+	return summaries
+}
+
+func formatSummary(s repeatSummary) string {
+	kvPairs := []interface{}{"suppressed repeated logs", s.suppressed, "window", s.window, "log", s.text}
+	if jsonFlag {
+		return formatJSON(&entry{level: s.level, kvPairs: kvPairs}, nil, generateLog(kvPairs...), "", 0)
+	}
+	return generateLog(kvPairs...)
+}
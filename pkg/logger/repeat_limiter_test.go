@@ -0,0 +1,69 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepeatedLogLimiter(t *testing.T) {
+	l := &repeatedLogLimiter{}
+	now := time.Now()
+	allowed, summaries := l.allow(seelog.InfoLvl, "a", now)
+	assert.True(t, allowed)
+	assert.Empty(t, summaries)
+
+	l.reset(2, time.Second)
+	for i := 0; i < 5; i++ {
+		allowed, summaries = l.allow(seelog.WarnLvl, "a", now)
+		assert.Equal(t, i < 2, allowed)
+		assert.Empty(t, summaries)
+	}
+	allowed, _ = l.allow(seelog.WarnLvl, "b", now)
+	assert.True(t, allowed)
+
+	// the suppressed logs are reported once the window ends.
+	allowed, summaries = l.allow(seelog.WarnLvl, "a", now.Add(time.Second))
+	assert.True(t, allowed)
+	assert.Equal(t, []repeatSummary{{level: seelog.WarnLvl, text: "a", suppressed: 3, window: time.Second}}, summaries)
+	assert.Len(t, l.entries, 1)
+	_, summaries = l.allow(seelog.WarnLvl, "a", now.Add(3*time.Second))
+	assert.Empty(t, summaries)
+}
+
+func TestRepeatLimit(t *testing.T) {
+	mu.Lock()
+	defer mu.Unlock()
+	excludeFlag()
+	clean()
+	initTestLogger(OptionOffConsole, OptionRepeatLimit(1, 100*time.Millisecond))
+	defer initTestLogger(OptionOffConsole)
+
+	for i := 0; i < 3; i++ {
+		Warning(ctx, "ALARM", "repeated", "log")
+	}
+	Info(context.Background(), "another")
+	time.Sleep(100 * time.Millisecond)
+	Info(context.Background(), "after window")
+	assert.Contains(t, readLog(0), "repeated:log")
+	assert.Contains(t, readLog(1), "another")
+	assert.Regexp(t, `\[WRN\] .*suppressed repeated logs:2\twindow:100ms\tlog:\[mock-configname,mock-logstore\]\tAlarmType:ALARM\trepeated:log`, readLog(2))
+	assert.Contains(t, readLog(3), "after window")
+}
@@ -26,6 +26,7 @@ const (
 	EventTypeSpan
 	EventTypeLogging
 	EventTypeByteArray
+	EventTypeProfile
 )
 
 type ValueType int
@@ -143,6 +143,34 @@ func NewSpan(name, traceID, spanID string, kind SpanKind, startTime, endTime uin
 	}
 }
 
+func NewProfile(name, profileID, language string, startTime, endTime uint64, valueTypes []*ProfileValueType, tags Tags) *Profile {
+	return &Profile{
+		Name:       name,
+		ProfileID:  profileID,
+		Language:   language,
+		StartTime:  startTime,
+		EndTime:    endTime,
+		ValueTypes: valueTypes,
+		Tags:       tags,
+	}
+}
+
+func NewProfileValueType(valueType, unit, aggregationType string) *ProfileValueType {
+	return &ProfileValueType{
+		Type:            valueType,
+		Unit:            unit,
+		AggregationType: aggregationType,
+	}
+}
+
+func NewProfileSample(stack []string, values []int64, labels Tags) *ProfileSample {
+	return &ProfileSample{
+		Stack:  stack,
+		Values: values,
+		Labels: labels,
+	}
+}
+
 func NewByteArray(bytes []byte) ByteArray {
 	return ByteArray(bytes)
 }
@@ -0,0 +1,177 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+var (
+	noopProfileValueTypes = make([]*ProfileValueType, 0)
+	noopProfileSamples    = make([]*ProfileSample, 0)
+)
+
+// ProfileValueType describes a kind of the sample values, such as cpu in nanoseconds.
+type ProfileValueType struct {
+	Type string
+	Unit string
+	// AggregationType is sum or avg, which tells how to merge the values of the same stack
+	AggregationType string
+}
+
+// ProfileSample is a stack with its values, the values are in the order of the value types of the profile.
+type ProfileSample struct {
+	// Stack is the frames from the leaf to the root.
+	Stack  []string
+	Values []int64
+	Labels Tags
+}
+
+func (s *ProfileSample) GetLabels() Tags {
+	if s != nil && s.Labels != nil {
+		return s.Labels
+	}
+	return NilStringValues
+}
+
+// A Profile is a set of stack samples collected in a time range, like the pprof format.
+type Profile struct {
+	Name      string
+	ProfileID string
+	// Language is the language or spy name of the profiled application, such as go and java.
+	Language string
+
+	StartTime         uint64
+	EndTime           uint64
+	ObservedTimestamp uint64
+
+	ValueTypes []*ProfileValueType
+	Samples    []*ProfileSample
+	Tags       Tags
+}
+
+func (m *Profile) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Profile) SetName(name string) {
+	if m != nil {
+		m.Name = name
+	}
+}
+
+func (m *Profile) GetTags() Tags {
+	if m != nil && m.Tags != nil {
+		return m.Tags
+	}
+	return NilStringValues
+}
+
+func (m *Profile) GetType() EventType {
+	return EventTypeProfile
+}
+
+func (m *Profile) GetTimestamp() uint64 {
+	if m != nil {
+		return m.StartTime
+	}
+	return 0
+}
+
+func (m *Profile) GetObservedTimestamp() uint64 {
+	if m != nil {
+		return m.ObservedTimestamp
+	}
+	return 0
+}
+
+func (m *Profile) SetObservedTimestamp(timestamp uint64) {
+	if m != nil {
+		m.ObservedTimestamp = timestamp
+	}
+}
+
+func (m *Profile) GetProfileID() string {
+	if m != nil {
+		return m.ProfileID
+	}
+	return ""
+}
+
+func (m *Profile) GetLanguage() string {
+	if m != nil {
+		return m.Language
+	}
+	return ""
+}
+
+func (m *Profile) GetStartTime() uint64 {
+	if m != nil {
+		return m.StartTime
+	}
+	return 0
+}
+
+func (m *Profile) GetEndTime() uint64 {
+	if m != nil {
+		return m.EndTime
+	}
+	return 0
+}
+
+// GetDuration returns the duration of the profile in nanoseconds.
+func (m *Profile) GetDuration() uint64 {
+	if m != nil && m.EndTime > m.StartTime {
+		return m.EndTime - m.StartTime
+	}
+	return 0
+}
+
+func (m *Profile) GetValueTypes() []*ProfileValueType {
+	if m != nil {
+		return m.ValueTypes
+	}
+	return noopProfileValueTypes
+}
+
+func (m *Profile) GetSamples() []*ProfileSample {
+	if m != nil {
+		return m.Samples
+	}
+	return noopProfileSamples
+}
+
+// AddSample appends a sample, the values should be in the order of the value types.
+func (m *Profile) AddSample(stack []string, values []int64, labels Tags) {
+	if m != nil {
+		m.Samples = append(m.Samples, NewProfileSample(stack, values, labels))
+	}
+}
+
+func (m *Profile) Clone() PipelineEvent {
+	if m != nil {
+		return &Profile{
+			Name:              m.Name,
+			ProfileID:         m.ProfileID,
+			Language:          m.Language,
+			StartTime:         m.StartTime,
+			EndTime:           m.EndTime,
+			ObservedTimestamp: m.ObservedTimestamp,
+			ValueTypes:        m.ValueTypes,
+			Samples:           m.Samples,
+			Tags:              m.Tags,
+		}
+	}
+	return nil
+}
@@ -38,6 +38,9 @@ type Context interface {
 	RegisterCounterMetric(metric CounterMetric)
 	RegisterStringMetric(metric StringMetric)
 	RegisterLatencyMetric(metric LatencyMetric)
+	RegisterCounterVec(vec CounterVec)
+	RegisterGaugeVec(vec GaugeVec)
+	RegisterHistogramVec(vec HistogramVec)
 
 	MetricSerializeToPB(log *protocol.Log)
 
@@ -38,3 +38,9 @@ type ProcessorV2 interface {
 	Processor
 	Process(in *models.PipelineGroupEvents, context PipelineContext)
 }
+
+// StoppableProcessor is implemented by the processors holding resources, such as child processes, which are released
+// when the pipeline stops.
+type StoppableProcessor interface {
+	Stop() error
+}
@@ -54,3 +54,59 @@ type LatencyMetric interface {
 
 	Serialize(log *protocol.Log)
 }
+
+type GaugeMetric interface {
+	Name() string
+
+	Set(v float64)
+
+	Add(v float64)
+
+	Get() float64
+
+	Serialize(log *protocol.Log)
+}
+
+type HistogramMetric interface {
+	Name() string
+
+	Observe(v float64)
+
+	// Clear resets the statistics serialized since the last time, the distribution is not reset.
+	Clear()
+
+	Serialize(log *protocol.Log)
+}
+
+// MetricVec is a set of metrics with the same name partitioned by the values of the labels. The number of the
+// series is limited, the label values exceeding the limit share an overflow series.
+type MetricVec interface {
+	Name() string
+
+	LabelNames() []string
+
+	// Collect calls fn with the label values and the metric of each series in the order of creation.
+	Collect(fn func(labelValues []string, metric interface{}))
+
+	// Serialize appends all the series to the log, the name of a series is the name of the vector with the labels.
+	Serialize(log *protocol.Log)
+}
+
+type CounterVec interface {
+	MetricVec
+
+	// WithLabelValues returns the counter of the label values, which are in the same order as the label names.
+	WithLabelValues(values ...string) CounterMetric
+}
+
+type GaugeVec interface {
+	MetricVec
+
+	WithLabelValues(values ...string) GaugeMetric
+}
+
+type HistogramVec interface {
+	MetricVec
+
+	WithLabelValues(values ...string) HistogramMetric
+}
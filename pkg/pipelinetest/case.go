@@ -0,0 +1,174 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pipelinetest runs the processors and the aggregators of the pipeline configs against the input events
+// declared in the test case files, and compares the output with the golden files.
+package pipelinetest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// CaseSuffix is the suffix of the test case files searched in the directories.
+	CaseSuffix = ".case.json"
+	// GoldenSuffix replaces CaseSuffix in the default name of the golden file of a test case.
+	GoldenSuffix = ".golden.json"
+
+	inputFormatText = "text"
+	inputFormatJSON = "json"
+	// contentKey is the field of the events read from the text input files.
+	contentKey = "content"
+)
+
+// Event is an input event of the test cases or an output event in the golden files.
+type Event struct {
+	// Time is the timestamp in seconds, the input event without it is stamped with the current time.
+	Time     *uint32           `json:"time,omitempty"`
+	Contents map[string]string `json:"contents"`
+}
+
+// Group is an output log group in the golden files.
+type Group struct {
+	Topic string            `json:"topic,omitempty"`
+	Tags  map[string]string `json:"tags,omitempty"`
+	Logs  []Event           `json:"logs"`
+}
+
+// Case is a test case of a pipeline config, the paths in it are relative to the case file.
+type Case struct {
+	// Config is the path of the pipeline config, only the processors and the aggregators in it are run.
+	Config string `json:"config"`
+	// Inputs are the input events, the events read from InputFile follow them.
+	Inputs    []Event `json:"inputs"`
+	InputFile string  `json:"input_file"`
+	// InputFormat is the format of InputFile, text for the lines as the content field of the events,
+	// or json for the lines as the JSON objects of the contents. The default is text.
+	InputFormat string `json:"input_format"`
+	// Expected is the path of the golden file, the default is the case file with GoldenSuffix.
+	Expected string `json:"expected"`
+	// AssertTime and AssertTags make the time of the events and the tags of the groups compared with the golden file,
+	// the pack id tag is never compared.
+	AssertTime bool `json:"assert_time"`
+	AssertTags bool `json:"assert_tags"`
+
+	path string
+}
+
+// LoadCase reads the test case file.
+func LoadCase(path string) (*Case, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	c := &Case{path: path}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("parse test case %s error: %v", path, err)
+	}
+	if c.Config == "" {
+		return nil, fmt.Errorf("test case %s has no config", path)
+	}
+	return c, nil
+}
+
+// FindCases returns the test case files in the paths, the directories are searched recursively for the files
+// with CaseSuffix.
+func FindCases(paths []string) ([]string, error) {
+	var cases []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			cases = append(cases, path)
+			continue
+		}
+		var found []string
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, CaseSuffix) {
+				found = append(found, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		cases = append(cases, found...)
+	}
+	return cases, nil
+}
+
+// Name is the path of the case file.
+func (c *Case) Name() string {
+	return c.path
+}
+
+func (c *Case) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(c.path), path)
+}
+
+func (c *Case) goldenPath() string {
+	if c.Expected != "" {
+		return c.resolve(c.Expected)
+	}
+	return strings.TrimSuffix(c.path, CaseSuffix) + GoldenSuffix
+}
+
+// inputs returns the inline events followed by the events read from the input file.
+func (c *Case) inputs() ([]Event, error) {
+	events := append([]Event(nil), c.Inputs...)
+	if c.InputFile == "" {
+		return events, nil
+	}
+	f, err := os.Open(c.resolve(c.InputFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		switch c.InputFormat {
+		case "", inputFormatText:
+			events = append(events, Event{Contents: map[string]string{contentKey: scanner.Text()}})
+		case inputFormatJSON:
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			contents := make(map[string]string)
+			if err = json.Unmarshal(scanner.Bytes(), &contents); err != nil {
+				return nil, fmt.Errorf("parse line %d of %s error: %v", line, c.InputFile, err)
+			}
+			events = append(events, Event{Contents: contents})
+		default:
+			return nil, fmt.Errorf("unknown input format %s", c.InputFormat)
+		}
+	}
+	return events, scanner.Err()
+}
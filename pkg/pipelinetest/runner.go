@@ -0,0 +1,386 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipelinetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/pkg/util"
+)
+
+// DefaultAggregator is used when the config has no aggregators, the same as the pipeline.
+const DefaultAggregator = "aggregator_default"
+
+type pluginConfig struct {
+	Type   string      `json:"type"`
+	Detail interface{} `json:"detail"`
+}
+
+type pipelineConfig struct {
+	Processors  []pluginConfig `json:"processors"`
+	Aggregators []pluginConfig `json:"aggregators"`
+}
+
+// groupQueue keeps the log groups pushed by the aggregators.
+type groupQueue struct {
+	groups []*protocol.LogGroup
+}
+
+func (q *groupQueue) Add(logGroup *protocol.LogGroup) error {
+	q.groups = append(q.groups, logGroup)
+	return nil
+}
+
+func (q *groupQueue) AddWithWait(logGroup *protocol.LogGroup, duration time.Duration) error {
+	return q.Add(logGroup)
+}
+
+// pluginType removes the optional ID from the plugin name, such as processor_regex/2.
+func pluginType(name string) string {
+	if idx := strings.IndexByte(name, '/'); idx != -1 {
+		return name[:idx]
+	}
+	return name
+}
+
+func applyPluginConfig(plugin interface{}, detail interface{}) error {
+	if detail == nil {
+		return nil
+	}
+	data, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, plugin)
+}
+
+func loadProcessors(configs []pluginConfig, ctx pipeline.Context) ([]pipeline.ProcessorV1, error) {
+	processors := make([]pipeline.ProcessorV1, 0, len(configs))
+	for _, cfg := range configs {
+		creator, ok := pipeline.Processors[pluginType(cfg.Type)]
+		if !ok {
+			return nil, fmt.Errorf("can't find processor %s", cfg.Type)
+		}
+		processor, ok := creator().(pipeline.ProcessorV1)
+		if !ok {
+			return nil, fmt.Errorf("processor %s does not support pipeline v1", cfg.Type)
+		}
+		if err := applyPluginConfig(processor, cfg.Detail); err != nil {
+			return nil, fmt.Errorf("parse processor %s error: %v", cfg.Type, err)
+		}
+		if err := processor.Init(ctx); err != nil {
+			return nil, fmt.Errorf("init processor %s error: %v", cfg.Type, err)
+		}
+		processors = append(processors, processor)
+	}
+	return processors, nil
+}
+
+func loadAggregators(configs []pluginConfig, ctx pipeline.Context, queue pipeline.LogGroupQueue) ([]pipeline.AggregatorV1, error) {
+	if len(configs) == 0 {
+		configs = []pluginConfig{{Type: DefaultAggregator}}
+	}
+	aggregators := make([]pipeline.AggregatorV1, 0, len(configs))
+	for _, cfg := range configs {
+		creator, ok := pipeline.Aggregators[pluginType(cfg.Type)]
+		if !ok {
+			return nil, fmt.Errorf("can't find aggregator %s", cfg.Type)
+		}
+		aggregator, ok := creator().(pipeline.AggregatorV1)
+		if !ok {
+			return nil, fmt.Errorf("aggregator %s does not support pipeline v1", cfg.Type)
+		}
+		if err := applyPluginConfig(aggregator, cfg.Detail); err != nil {
+			return nil, fmt.Errorf("parse aggregator %s error: %v", cfg.Type, err)
+		}
+		if _, err := aggregator.Init(ctx, queue); err != nil {
+			return nil, fmt.Errorf("init aggregator %s error: %v", cfg.Type, err)
+		}
+		aggregators = append(aggregators, aggregator)
+	}
+	return aggregators, nil
+}
+
+func (e *Event) toLog(now time.Time) *protocol.Log {
+	keys := make([]string, 0, len(e.Contents))
+	for key := range e.Contents {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	log := &protocol.Log{}
+	for _, key := range keys {
+		log.Contents = append(log.Contents, &protocol.Log_Content{Key: key, Value: e.Contents[key]})
+	}
+	if e.Time != nil {
+		protocol.SetLogTime(log, *e.Time)
+	} else {
+		protocol.SetLogTime(log, uint32(now.Unix()))
+	}
+	return log
+}
+
+// Run pushes the input events one by one through the processors like the pipeline, then adds the processed
+// events to the aggregators, and returns the non-empty groups output by the aggregators.
+func (c *Case) Run() ([]*protocol.LogGroup, error) {
+	data, err := os.ReadFile(c.resolve(c.Config))
+	if err != nil {
+		return nil, err
+	}
+	var config pipelineConfig
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse config %s error: %v", c.Config, err)
+	}
+	inputs, err := c.inputs()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &helper.LocalContext{}
+	ctx.InitContext("pipelinetest", "pipelinetest", c.path)
+	processors, err := loadProcessors(config.Processors, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, processor := range processors {
+			if stoppable, ok := processor.(pipeline.StoppableProcessor); ok {
+				_ = stoppable.Stop()
+			}
+		}
+	}()
+	queue := &groupQueue{}
+	aggregators, err := loadAggregators(config.Aggregators, ctx, queue)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, input := range inputs {
+		logs := []*protocol.Log{input.toLog(now)}
+		for _, processor := range processors {
+			logs = processor.ProcessLogs(logs)
+			if len(logs) == 0 {
+				break
+			}
+		}
+		for _, aggregator := range aggregators {
+			for _, log := range logs {
+				if len(log.Contents) == 0 {
+					continue
+				}
+				if err = aggregator.Add(log, nil); err != nil {
+					return nil, fmt.Errorf("add to aggregator %s error: %v", aggregator.Description(), err)
+				}
+			}
+		}
+	}
+	groups := queue.groups
+	for _, aggregator := range aggregators {
+		groups = append(groups, aggregator.Flush()...)
+	}
+	output := make([]*protocol.LogGroup, 0, len(groups))
+	for _, group := range groups {
+		if len(group.Logs) != 0 {
+			output = append(output, group)
+		}
+	}
+	return output, nil
+}
+
+// golden converts the log groups to the format of the golden file, the time and the tags are kept if asserted.
+func (c *Case) golden(logGroups []*protocol.LogGroup) []Group {
+	groups := make([]Group, 0, len(logGroups))
+	for _, logGroup := range logGroups {
+		group := Group{Topic: logGroup.Topic, Logs: make([]Event, 0, len(logGroup.Logs))}
+		if c.AssertTags {
+			for _, tag := range logGroup.LogTags {
+				if tag.Key == util.PackIDTagKey {
+					continue
+				}
+				if group.Tags == nil {
+					group.Tags = make(map[string]string)
+				}
+				group.Tags[tag.Key] = tag.Value
+			}
+		}
+		for _, log := range logGroup.Logs {
+			event := Event{Contents: make(map[string]string, len(log.Contents))}
+			for _, content := range log.Contents {
+				event.Contents[content.Key] = content.Value
+			}
+			if c.AssertTime {
+				t := log.Time
+				event.Time = &t
+			}
+			group.Logs = append(group.Logs, event)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// normalize drops the fields of the golden file which are not asserted.
+func (c *Case) normalize(groups []Group) []Group {
+	for i := range groups {
+		if !c.AssertTags {
+			groups[i].Tags = nil
+		}
+		delete(groups[i].Tags, util.PackIDTagKey)
+		if len(groups[i].Tags) == 0 {
+			groups[i].Tags = nil
+		}
+		if groups[i].Logs == nil {
+			groups[i].Logs = []Event{}
+		}
+		for j := range groups[i].Logs {
+			if !c.AssertTime {
+				groups[i].Logs[j].Time = nil
+			}
+			if groups[i].Logs[j].Contents == nil {
+				groups[i].Logs[j].Contents = map[string]string{}
+			}
+		}
+	}
+	if groups == nil {
+		groups = []Group{}
+	}
+	return groups
+}
+
+func marshalGroups(groups []Group) (string, error) {
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// Result is the result of a test case.
+type Result struct {
+	Case    string
+	Passed  bool
+	Updated bool
+	// Diff is the unified diff from the golden file to the actual output when the case fails.
+	Diff string
+	Err  error
+}
+
+// Check runs the case and compares the output with the golden file, the golden file is overwritten by the output
+// if update is true.
+func (c *Case) Check(update bool) (result Result) {
+	result.Case = c.path
+	defer func() {
+		if err := recover(); err != nil {
+			result.Passed = false
+			result.Err = fmt.Errorf("panicked: %v", err)
+		}
+	}()
+	logGroups, err := c.Run()
+	if err != nil {
+		result.Err = err
+		return
+	}
+	actual, err := marshalGroups(c.golden(logGroups))
+	if err != nil {
+		result.Err = err
+		return
+	}
+	path := c.goldenPath()
+	if update {
+		if result.Err = os.WriteFile(path, []byte(actual), 0600); result.Err == nil {
+			result.Passed, result.Updated = true, true
+		}
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		result.Err = fmt.Errorf("read golden file error: %v, run with update to generate it", err)
+		return
+	}
+	var groups []Group
+	if err = json.Unmarshal(data, &groups); err != nil {
+		result.Err = fmt.Errorf("parse golden file %s error: %v", path, err)
+		return
+	}
+	expected, err := marshalGroups(c.normalize(groups))
+	if err != nil {
+		result.Err = err
+		return
+	}
+	if expected == actual {
+		result.Passed = true
+		return
+	}
+	result.Diff, result.Err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(expected),
+		B:        difflib.SplitLines(actual),
+		FromFile: path,
+		ToFile:   "actual",
+		Context:  3,
+	})
+	return
+}
+
+// RunCases checks the test cases in the paths and writes the results to w, it returns the number of the failed cases.
+func RunCases(paths []string, update bool, w io.Writer) (int, error) {
+	files, err := FindCases(paths)
+	if err != nil {
+		return 0, err
+	}
+	failed := 0
+	for _, file := range files {
+		var result Result
+		c, err := LoadCase(file)
+		if err != nil {
+			result = Result{Case: file, Err: err}
+		} else {
+			result = c.Check(update)
+		}
+		switch {
+		case result.Updated:
+			fmt.Fprintf(w, "--- UPDATE: %s\n", result.Case)
+		case result.Passed:
+			fmt.Fprintf(w, "--- PASS: %s\n", result.Case)
+		default:
+			failed++
+			fmt.Fprintf(w, "--- FAIL: %s\n", result.Case)
+			if result.Err != nil {
+				fmt.Fprintf(w, "    %v\n", result.Err)
+			}
+			if result.Diff != "" {
+				for _, line := range strings.Split(strings.TrimSuffix(result.Diff, "\n"), "\n") {
+					fmt.Fprintf(w, "    %s\n", line)
+				}
+			}
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "FAIL\t%d of %d cases failed\n", failed, len(files))
+	} else {
+		fmt.Fprintf(w, "ok\t%d cases passed\n", len(files))
+	}
+	return failed, nil
+}
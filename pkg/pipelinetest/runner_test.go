@@ -0,0 +1,230 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipelinetest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/pkg/util"
+)
+
+// upperProcessor copies the upper case of the content to the Target field and drops the events of the content drop.
+type upperProcessor struct {
+	Target string
+}
+
+func (p *upperProcessor) Init(pipeline.Context) error { return nil }
+
+func (p *upperProcessor) Description() string { return "upper processor for test" }
+
+func (p *upperProcessor) ProcessLogs(logs []*protocol.Log) []*protocol.Log {
+	result := logs[:0]
+	for _, log := range logs {
+		if log.Contents[0].Value == "drop" {
+			continue
+		}
+		log.Contents = append(log.Contents, &protocol.Log_Content{Key: p.Target, Value: strings.ToUpper(log.Contents[0].Value)})
+		result = append(result, log)
+	}
+	return result
+}
+
+// oneGroupAggregator outputs all the events in a group with the env tag and the pack id tag.
+type oneGroupAggregator struct {
+	logs []*protocol.Log
+}
+
+func (a *oneGroupAggregator) Init(pipeline.Context, pipeline.LogGroupQueue) (int, error) {
+	return 0, nil
+}
+
+func (a *oneGroupAggregator) Description() string { return "one group aggregator for test" }
+
+func (a *oneGroupAggregator) Reset() {}
+
+func (a *oneGroupAggregator) Add(log *protocol.Log, ctx map[string]interface{}) error {
+	a.logs = append(a.logs, log)
+	return nil
+}
+
+func (a *oneGroupAggregator) Flush() []*protocol.LogGroup {
+	group := &protocol.LogGroup{Topic: "test", Logs: a.logs, LogTags: []*protocol.LogTag{
+		{Key: "env", Value: "prod"},
+		{Key: util.PackIDTagKey, Value: "ABC-1"},
+	}}
+	a.logs = nil
+	return []*protocol.LogGroup{group}
+}
+
+func init() {
+	pipeline.Processors["processor_test_upper"] = func() pipeline.Processor {
+		return &upperProcessor{Target: "upper"}
+	}
+	pipeline.Aggregators["aggregator_test_one_group"] = func() pipeline.Aggregator {
+		return &oneGroupAggregator{}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+const testConfig = `{
+  "processors": [{"type": "processor_test_upper/1", "detail": {"Target": "UPPER"}}],
+  "aggregators": [{"type": "aggregator_test_one_group"}]
+}`
+
+func TestRunCases(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "configs", "upper.json"), testConfig)
+	writeFile(t, filepath.Join(dir, "cases", "input.log"), "b\ndrop\nc\n")
+	casePath := filepath.Join(dir, "cases", "upper"+CaseSuffix)
+	writeFile(t, casePath, `{
+  "config": "../configs/upper.json",
+  "inputs": [{"time": 1690000000, "contents": {"content": "a", "k": "v"}}],
+  "input_file": "input.log"
+}`)
+
+	var out bytes.Buffer
+	failed, err := RunCases([]string{dir}, false, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+	assert.Contains(t, out.String(), "--- FAIL: "+casePath)
+	assert.Contains(t, out.String(), "run with update to generate it")
+
+	out.Reset()
+	failed, err = RunCases([]string{dir}, true, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 0, failed)
+	assert.Contains(t, out.String(), "--- UPDATE: "+casePath)
+	golden, err := os.ReadFile(filepath.Join(dir, "cases", "upper"+GoldenSuffix))
+	require.NoError(t, err)
+	assert.Equal(t, `[
+  {
+    "topic": "test",
+    "logs": [
+      {
+        "contents": {
+          "UPPER": "A",
+          "content": "a",
+          "k": "v"
+        }
+      },
+      {
+        "contents": {
+          "UPPER": "B",
+          "content": "b"
+        }
+      },
+      {
+        "contents": {
+          "UPPER": "C",
+          "content": "c"
+        }
+      }
+    ]
+  }
+]
+`, string(golden))
+
+	out.Reset()
+	failed, err = RunCases([]string{casePath}, false, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 0, failed)
+	assert.Equal(t, "--- PASS: "+casePath+"\nok\t1 cases passed\n", out.String())
+
+	writeFile(t, filepath.Join(dir, "cases", "input.log"), "b\nd\n")
+	out.Reset()
+	failed, err = RunCases([]string{dir}, false, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+	assert.Contains(t, out.String(), `    -          "UPPER": "C",`)
+	assert.Contains(t, out.String(), `    +          "UPPER": "D",`)
+	assert.Contains(t, out.String(), "FAIL\t1 of 1 cases failed\n")
+}
+
+func TestCheckAssertTimeAndTags(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "upper.json"), testConfig)
+	casePath := filepath.Join(dir, "json"+CaseSuffix)
+	writeFile(t, casePath, `{
+  "config": "upper.json",
+  "inputs": [{"time": 1690000000, "contents": {"content": "a"}}],
+  "input_file": "input.json",
+  "input_format": "json",
+  "expected": "expected.json",
+  "assert_time": true,
+  "assert_tags": true
+}`)
+	writeFile(t, filepath.Join(dir, "input.json"), `{"content": "b", "level": "info"}`+"\n\n")
+	writeFile(t, filepath.Join(dir, "expected.json"), `[{
+  "topic": "test",
+  "tags": {"env": "prod", "__pack_id__": "ignored"},
+  "logs": [
+    {"time": 1690000000, "contents": {"content": "a", "UPPER": "A"}},
+    {"time": 1690000000, "contents": {"content": "b", "level": "info", "UPPER": "B"}}
+  ]
+}]`)
+
+	c, err := LoadCase(casePath)
+	require.NoError(t, err)
+	result := c.Check(false)
+	require.NoError(t, result.Err)
+	assert.False(t, result.Passed)
+	// the event without time is stamped with the current time.
+	assert.Contains(t, result.Diff, `-        "time": 1690000000`)
+
+	c.AssertTime = false
+	result = c.Check(false)
+	require.NoError(t, result.Err)
+	assert.True(t, result.Passed)
+
+	c.AssertTags = false
+	c.Expected = ""
+	result = c.Check(true)
+	require.NoError(t, result.Err)
+	assert.True(t, result.Updated)
+	golden, err := os.ReadFile(filepath.Join(dir, "json"+GoldenSuffix))
+	require.NoError(t, err)
+	assert.NotContains(t, string(golden), "tags")
+	assert.NotContains(t, string(golden), "time")
+}
+
+func TestLoadCaseErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadCase(filepath.Join(dir, "missing"+CaseSuffix))
+	assert.Error(t, err)
+
+	writeFile(t, filepath.Join(dir, "empty"+CaseSuffix), `{}`)
+	_, err = LoadCase(filepath.Join(dir, "empty"+CaseSuffix))
+	assert.EqualError(t, err, "test case "+filepath.Join(dir, "empty"+CaseSuffix)+" has no config")
+
+	writeFile(t, filepath.Join(dir, "unknown.json"), `{"processors": [{"type": "processor_unknown"}]}`)
+	writeFile(t, filepath.Join(dir, "unknown"+CaseSuffix), `{"config": "unknown.json"}`)
+	c, err := LoadCase(filepath.Join(dir, "unknown"+CaseSuffix))
+	require.NoError(t, err)
+	result := c.Check(false)
+	assert.EqualError(t, result.Err, "can't find processor processor_unknown")
+}
@@ -41,8 +41,25 @@ type Decoder struct {
 }
 
 func (d *Decoder) DecodeV2(data []byte, req *http.Request) (groups []*models.PipelineGroupEvents, err error) {
-	// do nothing
-	return nil, nil
+	in, err := d.extractRawInput(data, req)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := in.Profile.ParseV2(context.Background(), &in.Metadata, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+	events := make([]models.PipelineEvent, 0, len(profiles))
+	for _, p := range profiles {
+		events = append(events, p)
+	}
+	return []*models.PipelineGroupEvents{{
+		Group:  models.NewGroup(models.NewMetadata(), models.NewTags()),
+		Events: events,
+	}}, nil
 }
 
 func (d *Decoder) Decode(data []byte, req *http.Request, tags map[string]string) (logs []*protocol.Log, err error) {
@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/models"
 	"github.com/alibaba/ilogtail/pkg/protocol"
 
 	"github.com/pyroscope-io/pyroscope/pkg/structs/transporttrie"
@@ -122,6 +123,66 @@ func TestDecoder_DecodePprofCumulative(t *testing.T) {
 	require.Equal(t, ReadLogVal(logs[3], "val"), "524432.00")
 }
 
+func TestDecoder_DecodeV2Tire(t *testing.T) {
+	trie := transporttrie.New()
+	trie.Insert([]byte("foo;bar;baz"), 1)
+	trie.Insert([]byte("foo;qux"), 2)
+	var buf bytes.Buffer
+	trie.Serialize(&buf)
+	request, err := http.NewRequest("POST", "http://localhost:8080?aggregationType=sum&from=1673495500&name=demo.cpu{a=b}&sampleRate=100&spyName=ebpfspy&units=samples&until=1673495510", &buf)
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "binary/octet-stream+trie")
+	groups, err := new(Decoder).DecodeV2(buf.Bytes(), request)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	require.Len(t, groups[0].Events, 1)
+	p := groups[0].Events[0].(*models.Profile)
+	require.Equal(t, models.EventTypeProfile, p.GetType())
+	require.Equal(t, "demo", p.GetName())
+	require.Equal(t, "ebpf", p.GetLanguage())
+	require.Equal(t, "b", p.GetTags().Get("a"))
+	require.NotEmpty(t, p.GetProfileID())
+	require.Equal(t, uint64(10e9), p.GetDuration())
+	require.Equal(t, []*models.ProfileValueType{{Type: "cpu", Unit: "nanoseconds", AggregationType: "sum"}}, p.GetValueTypes())
+	samples := p.GetSamples()
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Stack[0] < samples[j].Stack[0] })
+	require.Len(t, samples, 2)
+	require.Equal(t, []string{"baz", "bar", "foo"}, samples[0].Stack)
+	require.Equal(t, []int64{10000000}, samples[0].Values)
+	require.Equal(t, []string{"qux", "foo"}, samples[1].Stack)
+	require.Equal(t, []int64{20000000}, samples[1].Values)
+}
+
+func TestDecoder_DecodeV2PprofCumulative(t *testing.T) {
+	data, err := ioutil.ReadFile("test/dump_pprof_mem_data")
+	require.NoError(t, err)
+	var length uint32
+	require.NoError(t, binary.Read(bytes.NewBuffer(data), binary.BigEndian, &length))
+	var d helper.DumpData
+	require.NoError(t, json.Unmarshal(data[4:4+int(length)], &d))
+	request, err := http.NewRequest("POST", d.Req.URL, bytes.NewReader(d.Req.Body))
+	require.NoError(t, err)
+	request.Header = d.Req.Header
+	groups, err := new(Decoder).DecodeV2(d.Req.Body, request)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	var samples int
+	values := map[string]int64{}
+	for _, e := range groups[0].Events {
+		p := e.(*models.Profile)
+		require.Equal(t, "go", p.GetLanguage())
+		for _, s := range p.GetSamples() {
+			samples++
+			for i, vt := range p.GetValueTypes() {
+				values[s.Stack[0]+" "+vt.Type] += s.Values[i]
+			}
+		}
+	}
+	require.Equal(t, 2, samples)
+	require.Equal(t, int64(924248), values["compress/flate.NewWriter /Users/evan/sdk/go1.19.4/src/compress/flate/deflate.go alloc_space"])
+	require.Equal(t, int64(1820), values["runtime/pprof.WithLabels /Users/evan/sdk/go1.19.4/src/runtime/pprof/label.go alloc_objects"])
+}
+
 // ReadLogVal returns the log content value for the input key, and returns empty string when not found.
 func ReadLogVal(log *protocol.Log, key string) string {
 	for _, content := range log.Contents {
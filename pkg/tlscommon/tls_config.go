@@ -89,6 +89,23 @@ func (c *TLSConfig) LoadTLSConfig() (*tls.Config, error) {
 	}, nil
 }
 
+// LoadServerTLSConfig loads the tls.Config of a server, the certificate and key are required, and the CA verifies
+// the client certificates if set. It returns nil if TLS is not enabled.
+func (c *TLSConfig) LoadServerTLSConfig() (*tls.Config, error) {
+	tlsConfig, err := c.LoadTLSConfig()
+	if err != nil || tlsConfig == nil {
+		return nil, err
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("must specify both certificate and key for the TLS server")
+	}
+	if tlsConfig.RootCAs != nil {
+		tlsConfig.ClientCAs = tlsConfig.RootCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
 func (c TLSConfig) loadCert(caPath string) (*x509.CertPool, error) {
 	caPEM, err := os.ReadFile(filepath.Clean(caPath))
 	if err != nil {
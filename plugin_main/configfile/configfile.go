@@ -0,0 +1,380 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package configfile reads the config files of plugin_main in YAML or JSON, substitutes the environment variables
+// and the secret files referenced in the values, expands the included files, and converts them to the JSON
+// expected by the plugin manager.
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// IncludeKey includes the files at the root of a config, or in place of an item of a list.
+	IncludeKey = "include"
+	// secretFileRef makes a reference read the content of the file instead of the environment variable.
+	secretFileRef   = "file"
+	maxIncludeDepth = 16
+)
+
+// referencePattern matches ${NAME}, ${NAME:default} and ${file:path}, $${ is the escape of ${.
+var referencePattern = regexp.MustCompile(`\$\$\{|\$\{([^}:]+)(:([^}]*))?\}`)
+
+// IsYAML reports whether the file is read as YAML by its extension, the other files are read as JSON.
+func IsYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Load reads the config file and returns its JSON.
+func Load(path string) ([]byte, error) {
+	value, err := LoadValue(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("convert %s to json error: %v", path, err)
+	}
+	return data, nil
+}
+
+// LoadLegacy is like Load, but the references to the unset environment variables without defaults are kept as is.
+// It reads the legacy config files given by the flags, whose values may contain ${NAME} meant for the plugins, such
+// as the dynamic topics of flusher_kafka_v2.
+func LoadLegacy(path string) ([]byte, error) {
+	value, err := load(path, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("convert %s to json error: %v", path, err)
+	}
+	return data, nil
+}
+
+// LoadValue reads the config file and returns the decoded value, the maps are map[string]interface{}.
+func LoadValue(path string) (interface{}, error) {
+	return load(path, nil, false)
+}
+
+// load reads the config file, the references to the unset environment variables are kept if keepUnset, otherwise
+// they are errors.
+func load(path string, stack []string, keepUnset bool) (interface{}, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range stack {
+		if p == absPath {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(stack, absPath), " -> "))
+		}
+	}
+	if len(stack) >= maxIncludeDepth {
+		return nil, fmt.Errorf("include depth of %s exceeds %d", path, maxIncludeDepth)
+	}
+	stack = append(stack, absPath)
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+	var value interface{}
+	if IsYAML(path) {
+		value, err = decodeYAML(data, dir, keepUnset)
+	} else {
+		value, err = decodeJSON(data, dir, keepUnset)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s error: %v", path, err)
+	}
+	if value, err = expand(value, dir, stack, true, keepUnset); err != nil {
+		return nil, fmt.Errorf("include in %s error: %v", path, err)
+	}
+	return value, nil
+}
+
+func decodeYAML(data []byte, dir string, keepUnset bool) (interface{}, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	if err := substituteNode(&node, dir, keepUnset); err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+	return normalize(value)
+}
+
+// substituteNode substitutes the references in the scalars. A substituted scalar is a string unless it is tagged
+// explicitly like `Port: !!int ${PORT}`, so that `Password: ${PASSWORD}` is not turned into a number or a boolean by
+// the content of the variable.
+func substituteNode(node *yaml.Node, dir string, keepUnset bool) error {
+	if node.Kind == yaml.ScalarNode {
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+		value, err := substitute(node.Value, dir, keepUnset)
+		if err != nil {
+			return err
+		}
+		node.Value = value
+		if node.Style&yaml.TaggedStyle == 0 {
+			node.Tag = "!!str"
+		}
+		return nil
+	}
+	for _, child := range node.Content {
+		if err := substituteNode(child, dir, keepUnset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalize converts the YAML mappings to map[string]interface{}, which are supported by encoding/json.
+func normalize(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			item, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = item
+		}
+		return v, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v is not a string", key)
+			}
+			item, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = item
+		}
+		return m, nil
+	case []interface{}:
+		for i, item := range v {
+			item, err := normalize(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = item
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+func decodeJSON(data []byte, dir string, keepUnset bool) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return substituteValue(value, dir, keepUnset)
+}
+
+// substituteValue substitutes the references in the strings of the JSON value.
+func substituteValue(value interface{}, dir string, keepUnset bool) (interface{}, error) {
+	var err error
+	switch v := value.(type) {
+	case string:
+		return substitute(v, dir, keepUnset)
+	case map[string]interface{}:
+		for key, item := range v {
+			if v[key], err = substituteValue(item, dir, keepUnset); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if v[i], err = substituteValue(item, dir, keepUnset); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value, nil
+}
+
+// substitute replaces ${NAME} with the environment variable, which must be set unless it has a default as
+// ${NAME:default} or keepUnset is true, and replaces ${file:path} with the content of the file without the trailing
+// newlines, the relative path is relative to the config file.
+func substitute(s string, dir string, keepUnset bool) (string, error) {
+	var err error
+	result := referencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		match := referencePattern.FindStringSubmatch(ref)
+		name, hasDefault, defaultValue := match[1], match[2] != "", match[3]
+		if hasDefault && name == secretFileRef {
+			data, readErr := os.ReadFile(filepath.Clean(resolvePath(dir, defaultValue)))
+			if readErr != nil {
+				err = fmt.Errorf("read secret file error: %v", readErr)
+				return ""
+			}
+			return strings.TrimRight(string(data), "\r\n")
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		if !hasDefault {
+			if keepUnset {
+				return ref
+			}
+			err = fmt.Errorf("environment variable %s is not set", name)
+			return ""
+		}
+		return defaultValue
+	})
+	return result, err
+}
+
+// expand merges the files included at the root of the config, and replaces the items of the lists like
+// {include: path} with the content of the file, a list in the file is spliced into the list.
+func expand(value interface{}, dir string, stack []string, root bool, keepUnset bool) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var includes []string
+		if root {
+			if include, ok := v[IncludeKey]; ok {
+				var err error
+				if includes, err = includePaths(include); err != nil {
+					return nil, err
+				}
+				delete(v, IncludeKey)
+			}
+		}
+		for key, item := range v {
+			item, err := expand(item, dir, stack, false, keepUnset)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = item
+		}
+		merged := make(map[string]interface{})
+		for _, path := range includes {
+			included, err := load(resolvePath(dir, path), stack, keepUnset)
+			if err != nil {
+				return nil, err
+			}
+			m, ok := included.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s included at the root is not a map", path)
+			}
+			merge(merged, m)
+		}
+		merge(merged, v)
+		return merged, nil
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok && len(m) == 1 {
+				if include, ok := m[IncludeKey]; ok {
+					paths, err := includePaths(include)
+					if err != nil {
+						return nil, err
+					}
+					for _, path := range paths {
+						included, err := load(resolvePath(dir, path), stack, keepUnset)
+						if err != nil {
+							return nil, err
+						}
+						if list, ok := included.([]interface{}); ok {
+							result = append(result, list...)
+						} else {
+							result = append(result, included)
+						}
+					}
+					continue
+				}
+			}
+			item, err := expand(item, dir, stack, root, keepUnset)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, item)
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+// merge merges src into dst, the lists are appended and the maps are merged, the other values of src override dst.
+func merge(dst, src map[string]interface{}) {
+	for key, value := range src {
+		switch v := value.(type) {
+		case []interface{}:
+			if list, ok := dst[key].([]interface{}); ok {
+				dst[key] = append(list, v...)
+				continue
+			}
+		case map[string]interface{}:
+			if m, ok := dst[key].(map[string]interface{}); ok {
+				merge(m, v)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+func includePaths(include interface{}) ([]string, error) {
+	switch v := include.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		paths := make([]string, 0, len(v))
+		for _, item := range v {
+			path, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("include %v is not a path", item)
+			}
+			paths = append(paths, path)
+		}
+		return paths, nil
+	default:
+		return nil, fmt.Errorf("include %v is not a path or a list of paths", include)
+	}
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+}
+
+func TestLoadYAML(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONFIGFILE_TEST_PORT", "8080")
+	t.Setenv("CONFIGFILE_TEST_HOST", "127.0.0.1")
+	writeFile(t, filepath.Join(dir, "secrets", "password"), "p@ss: word\n")
+	path := filepath.Join(dir, "plugin.yaml")
+	writeFile(t, path, `
+inputs:
+  - type: service_http_server
+    detail:
+      Address: ${CONFIGFILE_TEST_HOST}:${CONFIGFILE_TEST_PORT}
+      Port: !!int ${CONFIGFILE_TEST_PORT}
+      Plain: ${CONFIGFILE_TEST_PORT}
+      Quoted: "${CONFIGFILE_TEST_PORT}"
+      Enabled: ${CONFIGFILE_TEST_ENABLED:true}
+      Empty: "${CONFIGFILE_TEST_EMPTY:}"
+      Password: ${file:secrets/password}
+      Literal: $${CONFIGFILE_TEST_PORT}
+flushers:
+  - type: flusher_stdout
+`)
+	data, err := Load(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "inputs": [{"type": "service_http_server", "detail": {
+    "Address": "127.0.0.1:8080",
+    "Port": 8080,
+    "Plain": "8080",
+    "Quoted": "8080",
+    "Enabled": "true",
+    "Empty": "",
+    "Password": "p@ss: word",
+    "Literal": "${CONFIGFILE_TEST_PORT}"
+  }}],
+  "flushers": [{"type": "flusher_stdout"}]
+}`, string(data))
+
+	writeFile(t, path, `Port: ${CONFIGFILE_TEST_UNSET}`)
+	_, err = Load(path)
+	assert.EqualError(t, err, "parse "+path+" error: environment variable CONFIGFILE_TEST_UNSET is not set")
+
+	writeFile(t, path, `{1: a}`)
+	_, err = Load(path)
+	assert.EqualError(t, err, "parse "+path+" error: key 1 is not a string")
+}
+
+func TestLoadJSON(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONFIGFILE_TEST_ENDPOINT", "cn-hangzhou.log.aliyuncs.com")
+	path := filepath.Join(dir, "plugin.json")
+	writeFile(t, path, `[{"flushers": [{"type": "flusher_sls", "detail": {"Endpoint": "${CONFIGFILE_TEST_ENDPOINT}", "Timeout": 1234567890123}}]}]`)
+	data, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"flushers":[{"detail":{"Endpoint":"cn-hangzhou.log.aliyuncs.com","Timeout":1234567890123},"type":"flusher_sls"}]}]`, string(data))
+
+	writeFile(t, path, `{"inputs": [`)
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func TestLoadLegacy(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CONFIGFILE_TEST_ENDPOINT", "cn-hangzhou.log.aliyuncs.com")
+	path := filepath.Join(dir, "plugin.json")
+	writeFile(t, path, `{"flushers": [{"type": "flusher_kafka_v2", "detail": {"Brokers": ["${CONFIGFILE_TEST_ENDPOINT}"], "Topic": "ilogtail_${CONFIGFILE_TEST_UNSET}"}}]}`)
+	// the references to the unset variables are left to the plugins.
+	data, err := LoadLegacy(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"flushers":[{"detail":{"Brokers":["cn-hangzhou.log.aliyuncs.com"],"Topic":"ilogtail_${CONFIGFILE_TEST_UNSET}"},"type":"flusher_kafka_v2"}]}`, string(data))
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func TestLoadInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "shared", "parse.yaml"), `
+- type: processor_regex
+  detail:
+    Regex: (\S+) (\S+)
+    Keys: [ip, method]
+- include: drop.json
+`)
+	writeFile(t, filepath.Join(dir, "shared", "drop.json"), `{"type": "processor_drop", "detail": {"DropKeys": ["ip"]}}`)
+	writeFile(t, filepath.Join(dir, "shared", "output.yaml"), `
+global:
+  DefaultLogQueueSize: 10
+  AlwaysOnline: true
+flushers:
+  - type: flusher_sls
+`)
+	path := filepath.Join(dir, "nginx.yaml")
+	writeFile(t, path, `
+include: shared/output.yaml
+global:
+  DefaultLogQueueSize: 20
+inputs:
+  - type: metric_mock
+processors:
+  - type: processor_add_fields
+  - include: shared/parse.yaml
+flushers:
+  - type: flusher_stdout
+`)
+	data, err := Load(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "global": {"DefaultLogQueueSize": 20, "AlwaysOnline": true},
+  "inputs": [{"type": "metric_mock"}],
+  "processors": [
+    {"type": "processor_add_fields"},
+    {"type": "processor_regex", "detail": {"Regex": "(\\S+) (\\S+)", "Keys": ["ip", "method"]}},
+    {"type": "processor_drop", "detail": {"DropKeys": ["ip"]}}
+  ],
+  "flushers": [{"type": "flusher_sls"}, {"type": "flusher_stdout"}]
+}`, string(data))
+
+	writeFile(t, filepath.Join(dir, "a.yaml"), `include: b.yaml`)
+	writeFile(t, filepath.Join(dir, "b.yaml"), `include: a.yaml`)
+	_, err = Load(filepath.Join(dir, "a.yaml"))
+	assert.ErrorContains(t, err, "include cycle: ")
+
+	writeFile(t, path, `include: shared/parse.yaml`)
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "shared/parse.yaml included at the root is not a map")
+}
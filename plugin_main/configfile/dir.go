@@ -0,0 +1,163 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configfile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/logger"
+)
+
+const configDirAlarm = "CONFIG_DIR_ALARM"
+
+// Loader loads the pipeline configs of the directory. It is called with all the configs by name whenever any of
+// them changes, and with the names of the added, changed and removed configs.
+type Loader func(configs map[string]string, changed []string)
+
+// isConfigFile reports whether the file in the config directory is a pipeline config, the hidden files are skipped.
+func isConfigFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".json" || ext == ".yaml" || ext == ".yml"
+}
+
+// LoadDir reads the pipeline configs in the directory, the subdirectories are not read so that they can keep the
+// included files. A config is named by the file name without the extension, and each of them must be a map.
+// The configs failed to read are returned in the errors by name.
+func LoadDir(dir string) (map[string]string, map[string]error, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	configs := make(map[string]string)
+	errs := make(map[string]error)
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !isConfigFile(entry.Name()) {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if file, ok := files[name]; ok {
+			delete(configs, name)
+			errs[name] = fmt.Errorf("config %s is defined by both %s and %s", name, file, entry.Name())
+			continue
+		}
+		files[name] = entry.Name()
+		path := filepath.Join(dir, entry.Name())
+		value, err := LoadValue(path)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		if _, ok := value.(map[string]interface{}); !ok {
+			errs[name] = fmt.Errorf("config %s is not a map", path)
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			errs[name] = fmt.Errorf("convert %s to json error: %v", path, err)
+			continue
+		}
+		configs[name] = string(data)
+	}
+	return configs, errs, nil
+}
+
+// Diff returns the sorted names of the configs added, changed or removed from old to configs.
+func Diff(old, configs map[string]string) []string {
+	var changed []string
+	for name, config := range configs {
+		if oldConfig, ok := old[name]; !ok || oldConfig != config {
+			changed = append(changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := configs[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Watcher checks the config directory periodically, and reloads the configs when any of them changes, including
+// the changes of the included files and the referenced environment variables and secret files. A config failed to
+// read keeps its last version.
+type Watcher struct {
+	dir      string
+	interval time.Duration
+	loader   Loader
+	configs  map[string]string
+	errs     map[string]string
+}
+
+func NewWatcher(dir string, interval time.Duration, loader Loader) *Watcher {
+	return &Watcher{
+		dir:      dir,
+		interval: interval,
+		loader:   loader,
+		configs:  make(map[string]string),
+		errs:     make(map[string]string),
+	}
+}
+
+// Check reads the config directory, and returns all the configs and the names of the changed ones.
+func (w *Watcher) Check() (map[string]string, []string) {
+	configs, errs, err := LoadDir(w.dir)
+	if err != nil {
+		logger.Warning(context.Background(), configDirAlarm, "read config dir error", err, "dir", w.dir)
+		return w.configs, nil
+	}
+	lastErrs := w.errs
+	w.errs = make(map[string]string, len(errs))
+	for name, err := range errs {
+		w.errs[name] = err.Error()
+		if lastErrs[name] != w.errs[name] {
+			logger.Warning(context.Background(), configDirAlarm, "read config error", err, "config", name)
+		}
+		if config, ok := w.configs[name]; ok {
+			configs[name] = config
+		}
+	}
+	changed := Diff(w.configs, configs)
+	w.configs = configs
+	return configs, changed
+}
+
+// Run checks the config directory every interval and calls the loader with the changed configs until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if configs, changed := w.Check(); len(changed) > 0 {
+				logger.Info(context.Background(), "config dir changed, configs", changed)
+				w.loader(configs, changed)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.yaml"), "inputs:\n  - type: metric_mock\n")
+	writeFile(t, filepath.Join(dir, "b.json"), `{"inputs": [{"type": "metric_mock"}]}`)
+	writeFile(t, filepath.Join(dir, "c.yml"), "- type: metric_mock\n")
+	writeFile(t, filepath.Join(dir, "d.yaml"), "{}")
+	writeFile(t, filepath.Join(dir, "d.json"), "{}")
+	writeFile(t, filepath.Join(dir, ".e.yaml"), "{}")
+	writeFile(t, filepath.Join(dir, "README.md"), "")
+	writeFile(t, filepath.Join(dir, "shared", "f.yaml"), "{}")
+
+	configs, errs, err := LoadDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"a": `{"inputs":[{"type":"metric_mock"}]}`,
+		"b": `{"inputs":[{"type":"metric_mock"}]}`,
+	}, configs)
+	require.Len(t, errs, 2)
+	assert.EqualError(t, errs["c"], "config "+filepath.Join(dir, "c.yml")+" is not a map")
+	assert.EqualError(t, errs["d"], "config d is defined by both d.json and d.yaml")
+
+	_, _, err = LoadDir(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	assert.Empty(t, Diff(map[string]string{"a": "1"}, map[string]string{"a": "1"}))
+	assert.Equal(t, []string{"a", "b", "c"}, Diff(
+		map[string]string{"a": "1", "c": "3", "d": "4"},
+		map[string]string{"a": "2", "b": "2", "d": "4"}))
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "shared", "flushers.yaml"), "- type: flusher_stdout\n")
+	writeFile(t, filepath.Join(dir, "a.yaml"), "flushers:\n  - include: shared/flushers.yaml\n")
+	writeFile(t, filepath.Join(dir, "b.yaml"), "{}")
+
+	type reload struct {
+		configs map[string]string
+		changed []string
+	}
+	reloads := make(chan reload, 10)
+	w := NewWatcher(dir, 10*time.Millisecond, func(configs map[string]string, changed []string) {
+		reloads <- reload{configs: configs, changed: changed}
+	})
+	configs, changed := w.Check()
+	assert.Equal(t, []string{"a", "b"}, changed)
+	assert.Equal(t, `{"flushers":[{"type":"flusher_stdout"}]}`, configs["a"])
+	_, changed = w.Check()
+	assert.Empty(t, changed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	wait := func() reload {
+		select {
+		case r := <-reloads:
+			return r
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "no reload")
+			return reload{}
+		}
+	}
+
+	// the change of the included file reloads the config including it.
+	writeFile(t, filepath.Join(dir, "shared", "flushers.yaml"), "- type: flusher_sls\n")
+	r := wait()
+	assert.Equal(t, []string{"a"}, r.changed)
+	assert.Equal(t, `{"flushers":[{"type":"flusher_sls"}]}`, r.configs["a"])
+
+	// the config failed to read keeps the last version, and the removed config is reloaded.
+	writeFile(t, filepath.Join(dir, "a.yaml"), "flushers: [")
+	require.NoError(t, os.Remove(filepath.Join(dir, "b.yaml")))
+	r = wait()
+	assert.Equal(t, []string{"b"}, r.changed)
+	assert.Equal(t, map[string]string{"a": `{"flushers":[{"type":"flusher_sls"}]}`}, r.configs)
+}
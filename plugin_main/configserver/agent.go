@@ -0,0 +1,360 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"google.golang.org/protobuf/proto"
+
+	configserverproto "github.com/alibaba/ilogtail/config_server/protocol"
+	"github.com/alibaba/ilogtail/pkg/config"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/util"
+)
+
+const (
+	heartBeatPath           = "/Agent/HeartBeat"
+	fetchPipelineConfigPath = "/Agent/FetchPipelineConfig"
+	contentType             = "application/x-protobuf"
+
+	agentType     = "ilogtail"
+	runningStatus = "running"
+
+	// CommandReloadConfigs makes the agent fetch all the pipeline configs again in the next heartbeat.
+	CommandReloadConfigs = "reload_configs"
+
+	maxExecutedCommands = 1024
+)
+
+// PipelineConfig is a pipeline config fetched from the config server, Detail is the JSON config of the pipeline.
+type PipelineConfig struct {
+	Name    string
+	Version int64
+	Context string
+	Detail  string
+}
+
+// Loader loads the pipeline configs. It is called with all the pipeline configs of the agent whenever any of them
+// changes, and returns the errors of the configs that cannot be loaded by config name.
+type Loader func(configs []*PipelineConfig) map[string]error
+
+// CommandHandler executes a custom command sent by the config server.
+type CommandHandler func(cmd *configserverproto.Command) error
+
+type Options struct {
+	// Addresses are the addresses of the config server, the next one is used after a request fails.
+	Addresses []string
+	AgentID   string
+	Tags      []string
+	Interval  time.Duration
+	Timeout   time.Duration
+}
+
+// Agent registers to the config server by heartbeats, and loads the pipeline configs applied to it. A config failed
+// to load is not fetched again until its version changes.
+type Agent struct {
+	opts        Options
+	loader      Loader
+	client      *http.Client
+	commands    map[string]CommandHandler
+	startupTime int64
+	address     int
+
+	configs     map[string]*PipelineConfig
+	failed      map[string]int64
+	reloading   bool
+	executed    map[string]struct{}
+	executedIDs []string
+}
+
+func NewAgent(opts Options, loader Loader) (*Agent, error) {
+	if len(opts.Addresses) == 0 {
+		return nil, fmt.Errorf("no config server address")
+	}
+	addresses := make([]string, 0, len(opts.Addresses))
+	for _, address := range opts.Addresses {
+		if !strings.Contains(address, "://") {
+			address = "http://" + address
+		}
+		addresses = append(addresses, strings.TrimRight(address, "/"))
+	}
+	opts.Addresses = addresses
+	if opts.AgentID == "" {
+		opts.AgentID = util.GetHostName() + "_" + util.GetIPAddress()
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = opts.Interval
+	}
+	a := &Agent{
+		opts:        opts,
+		loader:      loader,
+		client:      &http.Client{Timeout: opts.Timeout},
+		commands:    make(map[string]CommandHandler),
+		startupTime: time.Now().Unix(),
+		configs:     make(map[string]*PipelineConfig),
+		failed:      make(map[string]int64),
+		executed:    make(map[string]struct{}),
+	}
+	a.commands[CommandReloadConfigs] = func(*configserverproto.Command) error {
+		a.reloading = true
+		return nil
+	}
+	return a, nil
+}
+
+// RegisterCommand registers the handler of the custom commands of the type, it must be called before Run.
+func (a *Agent) RegisterCommand(commandType string, handler CommandHandler) {
+	a.commands[commandType] = handler
+}
+
+// Run sends heartbeats until ctx is done.
+func (a *Agent) Run(ctx context.Context) {
+	logger.Info(context.Background(), "config server agent start", a.opts.AgentID, "addresses", a.opts.Addresses)
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := a.heartBeat(ctx); err != nil && ctx.Err() == nil {
+			logger.Warning(context.Background(), "CONFIG_SERVER_ALARM", "heartbeat to config server error", err)
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info(context.Background(), "config server agent stop", a.opts.AgentID)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Agent) heartBeat(ctx context.Context) error {
+	req := &configserverproto.HeartBeatRequest{
+		RequestId: newRequestID(),
+		AgentId:   a.opts.AgentID,
+		AgentType: agentType,
+		Attributes: &configserverproto.AgentAttributes{
+			Version:  config.BaseVersion,
+			Ip:       util.GetIPAddress(),
+			Hostname: util.GetHostName(),
+		},
+		Tags:          a.opts.Tags,
+		RunningStatus: runningStatus,
+		StartupTime:   a.startupTime,
+		Interval:      int32(a.opts.Interval / time.Second),
+	}
+	if !a.reloading {
+		req.PipelineConfigs = a.configInfos()
+	}
+	res := &configserverproto.HeartBeatResponse{}
+	if err := a.post(ctx, heartBeatPath, req, res); err != nil {
+		return err
+	}
+
+	reloading := a.reloading
+	configs := make(map[string]*PipelineConfig, len(a.configs))
+	if reloading {
+		a.failed = make(map[string]int64)
+	} else {
+		for name, cfg := range a.configs {
+			configs[name] = cfg
+		}
+	}
+	changed := reloading
+	a.reloading = false
+	var fetches []*configserverproto.ConfigInfo
+	for _, result := range res.PipelineCheckResults {
+		switch result.CheckStatus {
+		case configserverproto.CheckStatus_DELETED:
+			if _, ok := configs[result.Name]; ok {
+				delete(configs, result.Name)
+				changed = true
+			}
+			delete(a.failed, result.Name)
+		case configserverproto.CheckStatus_NEW, configserverproto.CheckStatus_MODIFIED:
+			if version, ok := a.failed[result.Name]; ok && version == result.NewVersion {
+				continue
+			}
+			fetches = append(fetches, &configserverproto.ConfigInfo{
+				Type:    configserverproto.ConfigType_PIPELINE_CONFIG,
+				Name:    result.Name,
+				Version: result.NewVersion,
+				Context: result.Context,
+			})
+		}
+	}
+	if len(fetches) > 0 {
+		details, err := a.fetchPipelineConfigs(ctx, fetches)
+		if err != nil {
+			if reloading {
+				a.reloading = true
+				return err
+			}
+			// the deleted configs are still unloaded, the others are fetched again in the next heartbeat.
+			logger.Warning(context.Background(), "CONFIG_SERVER_ALARM", "fetch pipeline configs error", err)
+		}
+		for _, detail := range details {
+			configs[detail.Name] = &PipelineConfig{
+				Name:    detail.Name,
+				Version: detail.Version,
+				Context: detail.Context,
+				Detail:  detail.Detail,
+			}
+			delete(a.failed, detail.Name)
+			changed = true
+		}
+	}
+	if changed {
+		a.configs = configs
+		a.load()
+	}
+	a.execute(res.CustomCommands)
+	return nil
+}
+
+func (a *Agent) fetchPipelineConfigs(ctx context.Context, infos []*configserverproto.ConfigInfo) ([]*configserverproto.ConfigDetail, error) {
+	req := &configserverproto.FetchPipelineConfigRequest{
+		RequestId:  newRequestID(),
+		AgentId:    a.opts.AgentID,
+		ReqConfigs: infos,
+	}
+	res := &configserverproto.FetchPipelineConfigResponse{}
+	if err := a.post(ctx, fetchPipelineConfigPath, req, res); err != nil {
+		return nil, err
+	}
+	if res.Code != configserverproto.RespCode_ACCEPT {
+		// some configs are not found, the found ones are still returned.
+		logger.Warning(context.Background(), "CONFIG_SERVER_ALARM", "fetch pipeline configs error", res.Message)
+	}
+	return res.ConfigDetails, nil
+}
+
+// load loads all the configs, the configs failed to load are removed and reported as absent to the server.
+func (a *Agent) load() {
+	names := make([]string, 0, len(a.configs))
+	for name := range a.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	configs := make([]*PipelineConfig, 0, len(names))
+	for _, name := range names {
+		configs = append(configs, a.configs[name])
+	}
+	logger.Info(context.Background(), "load configs from config server", names)
+	for name, err := range a.loader(configs) {
+		cfg, ok := a.configs[name]
+		if !ok {
+			continue
+		}
+		logger.Warning(context.Background(), "CONFIG_LOAD_ALARM", "load config from config server error", err, "config", name, "version", cfg.Version)
+		a.failed[name] = cfg.Version
+		delete(a.configs, name)
+	}
+}
+
+func (a *Agent) execute(commands []*configserverproto.Command) {
+	for _, cmd := range commands {
+		if _, ok := a.executed[cmd.Id]; ok {
+			continue
+		}
+		a.markExecuted(cmd.Id)
+		handler, ok := a.commands[cmd.Type]
+		if !ok {
+			logger.Warning(context.Background(), "CONFIG_SERVER_ALARM", "unknown command type", cmd.Type, "name", cmd.Name, "id", cmd.Id)
+			continue
+		}
+		if err := handler(cmd); err != nil {
+			logger.Warning(context.Background(), "CONFIG_SERVER_ALARM", "execute command error", err, "type", cmd.Type, "name", cmd.Name, "id", cmd.Id)
+			continue
+		}
+		logger.Info(context.Background(), "execute command", cmd.Type, "name", cmd.Name, "id", cmd.Id)
+	}
+}
+
+// markExecuted records the command id, so that the command is executed only once even if the server sends it again.
+func (a *Agent) markExecuted(id string) {
+	if len(a.executedIDs) >= maxExecutedCommands {
+		delete(a.executed, a.executedIDs[0])
+		a.executedIDs = a.executedIDs[1:]
+	}
+	a.executed[id] = struct{}{}
+	a.executedIDs = append(a.executedIDs, id)
+}
+
+func (a *Agent) configInfos() []*configserverproto.ConfigInfo {
+	infos := make([]*configserverproto.ConfigInfo, 0, len(a.configs))
+	for _, cfg := range a.configs {
+		infos = append(infos, &configserverproto.ConfigInfo{
+			Type:    configserverproto.ConfigType_PIPELINE_CONFIG,
+			Name:    cfg.Name,
+			Version: cfg.Version,
+			Context: cfg.Context,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+type response interface {
+	proto.Message
+	GetCode() configserverproto.RespCode
+	GetMessage() string
+}
+
+func (a *Agent) post(ctx context.Context, path string, req proto.Message, res response) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	address := a.opts.Addresses[a.address]
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, address+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		a.address = (a.address + 1) % len(a.opts.Addresses)
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err = proto.Unmarshal(data, res); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%v%v returns status %v", address, path, resp.StatusCode)
+		}
+		return fmt.Errorf("invalid response of %v%v: %v", address, path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v%v returns status %v, code %v: %v", address, path, resp.StatusCode, res.GetCode(), res.GetMessage())
+	}
+	return nil
+}
+
+func newRequestID() string {
+	return uuid.Must(uuid.NewV4()).String()
+}
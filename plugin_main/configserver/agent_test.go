@@ -0,0 +1,270 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	configserverproto "github.com/alibaba/ilogtail/config_server/protocol"
+)
+
+// fakeServer checks the config updates in the same way as the config server.
+type fakeServer struct {
+	mu       sync.Mutex
+	configs  map[string]*configserverproto.ConfigDetail
+	commands []*configserverproto.Command
+	reported [][]*configserverproto.ConfigInfo
+	fetched  [][]string
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{configs: make(map[string]*configserverproto.ConfigDetail)}
+}
+
+func (s *fakeServer) setConfig(name string, version int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configs[name] = &configserverproto.ConfigDetail{
+		Type:    configserverproto.ConfigType_PIPELINE_CONFIG,
+		Name:    name,
+		Version: version,
+		Detail:  fmt.Sprintf(`{"name":"%s","version":%d}`, name, version),
+	}
+}
+
+func (s *fakeServer) deleteConfig(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.configs, name)
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	var res proto.Message
+	switch r.URL.Path {
+	case heartBeatPath:
+		req := &configserverproto.HeartBeatRequest{}
+		if err := proto.Unmarshal(body, req); err != nil || req.AgentId == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.reported = append(s.reported, req.PipelineConfigs)
+		heartBeatRes := &configserverproto.HeartBeatResponse{RequestId: req.RequestId, CustomCommands: s.commands}
+		reported := make(map[string]bool)
+		for _, info := range req.PipelineConfigs {
+			reported[info.Name] = true
+			cfg, ok := s.configs[info.Name]
+			switch {
+			case !ok:
+				heartBeatRes.PipelineCheckResults = append(heartBeatRes.PipelineCheckResults, &configserverproto.ConfigCheckResult{
+					Name: info.Name, OldVersion: info.Version, NewVersion: info.Version, CheckStatus: configserverproto.CheckStatus_DELETED,
+				})
+			case cfg.Version > info.Version:
+				heartBeatRes.PipelineCheckResults = append(heartBeatRes.PipelineCheckResults, &configserverproto.ConfigCheckResult{
+					Name: info.Name, OldVersion: info.Version, NewVersion: cfg.Version, CheckStatus: configserverproto.CheckStatus_MODIFIED,
+				})
+			}
+		}
+		for name, cfg := range s.configs {
+			if !reported[name] {
+				heartBeatRes.PipelineCheckResults = append(heartBeatRes.PipelineCheckResults, &configserverproto.ConfigCheckResult{
+					Name: name, NewVersion: cfg.Version, CheckStatus: configserverproto.CheckStatus_NEW,
+				})
+			}
+		}
+		res = heartBeatRes
+	case fetchPipelineConfigPath:
+		req := &configserverproto.FetchPipelineConfigRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fetchRes := &configserverproto.FetchPipelineConfigResponse{RequestId: req.RequestId}
+		var names []string
+		for _, info := range req.ReqConfigs {
+			names = append(names, info.Name)
+			if cfg, ok := s.configs[info.Name]; ok {
+				fetchRes.ConfigDetails = append(fetchRes.ConfigDetails, cfg)
+			}
+		}
+		s.fetched = append(s.fetched, names)
+		res = fetchRes
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	data, _ := proto.Marshal(res)
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(data)
+}
+
+// fakeLoader records the loaded configs and fails the configs whose names are in failures.
+type fakeLoader struct {
+	loaded   [][]string
+	failures map[string]bool
+}
+
+func (l *fakeLoader) load(configs []*PipelineConfig) map[string]error {
+	var names []string
+	errs := make(map[string]error)
+	for _, cfg := range configs {
+		names = append(names, fmt.Sprintf("%s@%d", cfg.Name, cfg.Version))
+		if l.failures[cfg.Name] {
+			errs[cfg.Name] = fmt.Errorf("invalid config %s", cfg.Name)
+		}
+	}
+	l.loaded = append(l.loaded, names)
+	return errs
+}
+
+func newTestAgent(t *testing.T, addresses ...string) (*Agent, *fakeLoader) {
+	loader := &fakeLoader{failures: make(map[string]bool)}
+	agent, err := NewAgent(Options{Addresses: addresses, AgentID: "test", Tags: []string{"default"}}, loader.load)
+	require.NoError(t, err)
+	return agent, loader
+}
+
+func TestNewAgent(t *testing.T) {
+	_, err := NewAgent(Options{}, nil)
+	assert.Error(t, err)
+	agent, err := NewAgent(Options{Addresses: []string{"127.0.0.1:8899", "https://config/"}}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://127.0.0.1:8899", "https://config"}, agent.opts.Addresses)
+	assert.NotEmpty(t, agent.opts.AgentID)
+	assert.Equal(t, 10*time.Second, agent.opts.Interval)
+}
+
+func TestHeartBeatLoadsConfigs(t *testing.T) {
+	server := newFakeServer()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	agent, loader := newTestAgent(t, httpServer.URL)
+	ctx := context.Background()
+
+	server.setConfig("a", 1)
+	server.setConfig("b", 1)
+	loader.failures["b"] = true
+	require.NoError(t, agent.heartBeat(ctx))
+	assert.Equal(t, [][]string{{"a@1", "b@1"}}, loader.loaded)
+	assert.Equal(t, map[string]int64{"b": 1}, agent.failed)
+
+	// the failed config is not fetched again until it is modified.
+	require.NoError(t, agent.heartBeat(ctx))
+	assert.Len(t, loader.loaded, 1)
+	require.Len(t, server.reported, 2)
+	require.Len(t, server.reported[1], 1)
+	assert.Equal(t, "a", server.reported[1][0].Name)
+
+	server.setConfig("a", 2)
+	server.setConfig("b", 2)
+	loader.failures["b"] = false
+	require.NoError(t, agent.heartBeat(ctx))
+	assert.Equal(t, []string{"a@2", "b@2"}, loader.loaded[1])
+	assert.Empty(t, agent.failed)
+	assert.ElementsMatch(t, []string{"a", "b"}, server.fetched[len(server.fetched)-1])
+
+	server.deleteConfig("a")
+	require.NoError(t, agent.heartBeat(ctx))
+	assert.Equal(t, []string{"b@2"}, loader.loaded[2])
+
+	require.NoError(t, agent.heartBeat(ctx))
+	assert.Len(t, loader.loaded, 3)
+}
+
+func TestHeartBeatCommands(t *testing.T) {
+	server := newFakeServer()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	agent, loader := newTestAgent(t, httpServer.URL)
+	var executed []string
+	agent.RegisterCommand("echo", func(cmd *configserverproto.Command) error {
+		executed = append(executed, cmd.Args["value"])
+		return nil
+	})
+	ctx := context.Background()
+
+	server.setConfig("a", 1)
+	server.commands = []*configserverproto.Command{
+		{Type: "echo", Name: "echo", Id: "1", Args: map[string]string{"value": "hello"}},
+		{Type: "unknown", Name: "unknown", Id: "2"},
+	}
+	require.NoError(t, agent.heartBeat(ctx))
+	require.NoError(t, agent.heartBeat(ctx))
+	assert.Equal(t, []string{"hello"}, executed)
+	assert.Len(t, loader.loaded, 1)
+
+	server.commands = []*configserverproto.Command{{Type: CommandReloadConfigs, Name: "reload", Id: "3"}}
+	require.NoError(t, agent.heartBeat(ctx))
+	server.commands = nil
+	require.NoError(t, agent.heartBeat(ctx))
+	assert.Empty(t, server.reported[len(server.reported)-1])
+	assert.Equal(t, [][]string{{"a@1"}, {"a@1"}}, loader.loaded)
+}
+
+func TestHeartBeatFailover(t *testing.T) {
+	server := newFakeServer()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	closed := httptest.NewServer(server)
+	closed.Close()
+	agent, loader := newTestAgent(t, closed.URL, httpServer.URL)
+	ctx := context.Background()
+
+	server.setConfig("a", 1)
+	assert.Error(t, agent.heartBeat(ctx))
+	require.NoError(t, agent.heartBeat(ctx))
+	assert.Equal(t, [][]string{{"a@1"}}, loader.loaded)
+}
+
+func TestRun(t *testing.T) {
+	server := newFakeServer()
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	server.setConfig("a", 1)
+	loaded := make(chan []*PipelineConfig, 1)
+	agent, err := NewAgent(Options{Addresses: []string{httpServer.URL}, Interval: 10 * time.Millisecond}, func(configs []*PipelineConfig) map[string]error {
+		loaded <- configs
+		return nil
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		agent.Run(ctx)
+		close(done)
+	}()
+	select {
+	case configs := <-loaded:
+		require.Len(t, configs, 1)
+		assert.Equal(t, `{"name":"a","version":1}`, configs[0].Detail)
+	case <-time.After(5 * time.Second):
+		t.Fatal("configs are not loaded")
+	}
+	cancel()
+	<-done
+}
@@ -19,11 +19,12 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"os"
 	"sync"
 
 	"github.com/alibaba/ilogtail/pkg/logger"
 	"github.com/alibaba/ilogtail/pkg/util"
+	"github.com/alibaba/ilogtail/plugin_main/configfile"
 )
 
 const (
@@ -45,13 +46,32 @@ var (
 	HTTPAddr         = flag.String("server", ":18689", "http server address.")
 	Doc              = flag.Bool("doc", false, "generate plugin docs")
 	DocPath          = flag.String("docpath", "./docs/en/plugins", "generate plugin docs")
+	PipelineTest     = flag.String("test", "", "run the pipeline test cases in the comma-separated files or directories and exit.")
+	UpdateGolden     = flag.Bool("update", false, "regenerate the golden files of the pipeline test cases run by -test.")
 	HTTPLoadFlag     = flag.Bool("http-load", false, "export http endpoint for load plugin config.")
+	HTTPMetricsFlag  = flag.Bool("http-metrics", false, "export http endpoint /metrics for the self metrics in Prometheus format.")
+	HTTPHealthFlag   = flag.Bool("http-health", false, "export http endpoints /healthz and /readyz for the health and readiness probes.")
+	HTTPLogLevelFlag = flag.Bool("http-log-level", false, "export http endpoint /loglevel to get or change the log levels at runtime.")
 	FileIOFlag       = flag.Bool("file-io", false, "use file for input or output.")
 	InputFile        = flag.String("input-file", "./input.log", "input file")
 	InputField       = flag.String("input-field", "content", "input file")
 	InputLineLimit   = flag.Int("input-line-limit", 1000, "input file")
 	OutputFile       = flag.String("output-file", "./output.log", "output file")
 	StatefulSetFlag  = flag.Bool("ALICLOUD_LOG_STATEFULSET_FLAG", false, "alibaba log export ports flag, set true if you want to use it")
+
+	ConfigDir         = flag.String("config-dir", "", "the directory of the pipeline configs in YAML or JSON, which are reloaded when changed.")
+	ConfigDirInterval = flag.Int("config-dir-interval", 5, "the interval in seconds to check the changes of the configs in config-dir.")
+
+	ConfigServerAddress = flag.String("config-server", "", "the comma-separated addresses of the config server, the pipeline configs are loaded from the config server when set.")
+	AgentID             = flag.String("agent-id", "", "the agent id registered to the config server, the default is the hostname and ip.")
+	AgentTags           = flag.String("agent-tags", "", "the comma-separated agent tags registered to the config server, which select the agent groups.")
+	HeartBeatInterval   = flag.Int("heartbeat-interval", 10, "the interval in seconds of the heartbeats to the config server.")
+
+	HealthMaxQueueRatio = flag.Float64("health-max-queue-ratio", 0.9, "/readyz fails when the input or flush queue of a config is fuller than the ratio.")
+	HealthMaxFlushDelay = flag.Int("health-max-flush-delay", 300, "/readyz fails when a config has pending data but no successful flush in the seconds.")
+	HealthMinReadyRatio = flag.Float64("health-min-ready-ratio", 0.5, "/readyz fails when the ratio of the successful IsReady checks of the flushers of a config is lower.")
+	HealthMaxPanics     = flag.Int("health-max-panics", 0, "/healthz fails when the panics caught in the plugins in health-panic-window exceed the number.")
+	HealthPanicWindow   = flag.Int("health-panic-window", 300, "the window in seconds of the panics counted by /healthz.")
 )
 
 var (
@@ -60,12 +80,24 @@ var (
 	flusherLoadOnce sync.Once
 )
 
+// readConfigFile reads the config file in YAML or JSON and converts it to JSON, it returns the default config if the
+// file doesn't exist.
+func readConfigFile(path string, defaultConfig string) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return defaultConfig, nil
+	}
+	data, err := configfile.LoadLegacy(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // LoadConfig read the plugin content.
 func LoadConfig() (globalCfg string, pluginCfgs []string, err error) {
-	if gCfg, errRead := ioutil.ReadFile(*GlobalConfig); errRead != nil {
-		globalCfg = defaultGlobalConfig
-	} else {
-		globalCfg = string(gCfg)
+	if globalCfg, err = readConfigFile(*GlobalConfig, defaultGlobalConfig); err != nil {
+		err = fmt.Errorf("illegal input global config:%v", err)
+		return
 	}
 
 	if !json.Valid([]byte(globalCfg)) {
@@ -74,10 +106,9 @@ func LoadConfig() (globalCfg string, pluginCfgs []string, err error) {
 	}
 
 	var pluginCfg string
-	if pCfg, errRead := ioutil.ReadFile(*PluginConfig); errRead == nil {
-		pluginCfg = string(pCfg)
-	} else {
-		pluginCfg = defaultPluginConfig
+	if pluginCfg, err = readConfigFile(*PluginConfig, defaultPluginConfig); err != nil {
+		err = fmt.Errorf("illegal input plugin config:%v", err)
+		return
 	}
 
 	if !json.Valid([]byte(pluginCfg)) {
@@ -118,7 +149,7 @@ func GetFlusherConfiguration() (flusherCategory string, flusherOptions map[strin
 			}
 			return c, options, true
 		}
-		if fCfg, err := ioutil.ReadFile(*FlusherConfig); err == nil {
+		if fCfg, err := configfile.LoadLegacy(*FlusherConfig); err == nil {
 			category, options, ok := extract(fCfg)
 			if ok {
 				flusherType = category
@@ -0,0 +1,131 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/config"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/plugin_main/configfile"
+	"github.com/alibaba/ilogtail/plugin_main/flags"
+	"github.com/alibaba/ilogtail/pluginmanager"
+)
+
+// The project of the configs loaded from the config directory, the logstore is the config name.
+const configDirProject = "config_dir"
+
+// configDirConfigName returns the name of the config loaded from the config directory, which is prefixed by
+// configDirProject to not collide with the configs from the config server.
+func configDirConfigName(name string) string {
+	return configDirProject + "/" + name
+}
+
+// configDirConfigs are the configs read from the config directory by name, guarded by controlLock.
+var configDirConfigs map[string]string
+
+// loadConfigDir reads the configs in the config directory and loads them before the configs are resumed, the
+// returned watcher reloads them when changed.
+func loadConfigDir(staticCfgs []string) (*configfile.Watcher, error) {
+	if info, err := os.Stat(*flags.ConfigDir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("config dir %s is not a directory", *flags.ConfigDir)
+	}
+	watcher := configfile.NewWatcher(*flags.ConfigDir, time.Duration(*flags.ConfigDirInterval)*time.Second,
+		func(configs map[string]string, changed []string) {
+			reloadConfigDirConfigs(staticCfgs, configs, changed)
+		})
+	configs, _ := watcher.Check()
+	controlLock.Lock()
+	defer controlLock.Unlock()
+	configDirConfigs = configs
+	logConfigDirErrors(loadConfigDirConfigs())
+	return watcher, nil
+}
+
+// startConfigDirWatcher runs the watcher of the config directory, the returned function stops it.
+func startConfigDirWatcher(watcher *configfile.Watcher) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watcher.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// reloadConfigDirConfigs reloads the changed configs when the configs in the config directory change, the other
+// configs keep running untouched. All the configs are loaded if they are held on.
+func reloadConfigDirConfigs(staticCfgs []string, configs map[string]string, changed []string) {
+	controlLock.Lock()
+	defer controlLock.Unlock()
+	logger.Info(context.Background(), "reload the changed configs in config dir", changed)
+	configDirConfigs = configs
+	var results []pluginmanager.ReloadResult
+	if started {
+		changedConfigs := make([]*config.LoadedConfig, 0, len(changed))
+		for _, name := range changed {
+			// the removed configs are of empty JSONStr
+			changedConfigs = append(changedConfigs, &config.LoadedConfig{Project: configDirProject, Logstore: name, ConfigName: configDirConfigName(name), JSONStr: configs[name]})
+		}
+		results = pluginmanager.UpdateConfigs(changedConfigs)
+	} else {
+		results = reloadConfigs(pipelineConfigs(staticCfgs))
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			logger.Warning(context.Background(), "CONFIG_DIR_ALARM", "reload config error", result.Error, "config", result.ConfigName)
+		} else if result.Action != pluginmanager.ReloadActionUnchanged {
+			logger.Info(context.Background(), "reload config", result.ConfigName, "action", result.Action)
+		}
+	}
+}
+
+func sortedConfigDirNames() []string {
+	names := make([]string, 0, len(configDirConfigs))
+	for name := range configDirConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadConfigDirConfigs loads the configs of the config directory, and returns the errors of the configs that cannot
+// be loaded by config name. It must be called with controlLock held.
+func loadConfigDirConfigs() map[string]error {
+	errs := make(map[string]error)
+	for _, name := range sortedConfigDirNames() {
+		if err := pluginmanager.LoadLogstoreConfig(configDirProject, name, configDirConfigName(name), 0, configDirConfigs[name]); err != nil {
+			errs[name] = err
+		}
+	}
+	return errs
+}
+
+func logConfigDirErrors(errs map[string]error) {
+	for name, err := range errs {
+		logger.Warning(context.Background(), "CONFIG_DIR_ALARM", "load config error", err, "config", name)
+	}
+}
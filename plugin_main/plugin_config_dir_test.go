@@ -0,0 +1,60 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || windows
+// +build linux windows
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/plugin_main/configserver"
+	_ "github.com/alibaba/ilogtail/plugins/aggregator"
+	"github.com/alibaba/ilogtail/pluginmanager"
+)
+
+func TestConfigNamesOfSources(t *testing.T) {
+	cfg := fmt.Sprintf(configTemplateJSONStr, 0)
+	Resume()
+	defer func() {
+		HoldOn(0)
+		configServerConfigs, configDirConfigs = nil, nil
+	}()
+
+	// the configs of the same name from the config server and the config directory are both loaded.
+	errs := loadConfigServerConfigs(nil, []*configserver.PipelineConfig{{Name: "same", Detail: cfg}})
+	assert.Empty(t, errs)
+	reloadConfigDirConfigs(nil, map[string]string{"same": cfg}, []string{"same"})
+	server, ok := pluginmanager.GetLogtailConfig("config_server/same")
+	require.True(t, ok)
+	_, ok = pluginmanager.GetLogtailConfig("config_dir/same")
+	require.True(t, ok)
+
+	// removing the config of the config directory keeps the config of the config server.
+	reloadConfigDirConfigs(nil, map[string]string{}, []string{"same"})
+	_, ok = pluginmanager.GetLogtailConfig("config_dir/same")
+	assert.False(t, ok)
+	lc, ok := pluginmanager.GetLogtailConfig("config_server/same")
+	require.True(t, ok)
+	assert.Same(t, server, lc)
+
+	// the errors are reported by the names of the config server.
+	errs = loadConfigServerConfigs(nil, []*configserver.PipelineConfig{{Name: "same", Detail: `{"inputs": [`}})
+	assert.Contains(t, errs, "same")
+}
@@ -0,0 +1,126 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	configserverproto "github.com/alibaba/ilogtail/config_server/protocol"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/plugin_main/configserver"
+	"github.com/alibaba/ilogtail/plugin_main/flags"
+)
+
+// The project of the configs loaded from the config server, the logstore is the config name.
+const configServerProject = "config_server"
+
+// configServerConfigName returns the name of the config loaded from the config server, which is prefixed by
+// configServerProject to not collide with the configs from the config directory.
+func configServerConfigName(name string) string {
+	return configServerProject + "/" + name
+}
+
+// startConfigServerAgent registers to the config server and loads the pipeline configs applied to the agent together
+// with the static configs, the returned function stops the agent.
+func startConfigServerAgent(staticCfgs []string) (func(), error) {
+	agent, err := configserver.NewAgent(configserver.Options{
+		Addresses: splitFlag(*flags.ConfigServerAddress),
+		AgentID:   *flags.AgentID,
+		Tags:      splitFlag(*flags.AgentTags),
+		Interval:  time.Duration(*flags.HeartBeatInterval) * time.Second,
+	}, func(configs []*configserver.PipelineConfig) map[string]error {
+		return loadConfigServerConfigs(staticCfgs, configs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	registerCommands(agent)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		agent.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}, nil
+}
+
+// configServerConfigs are the configs fetched from the config server, guarded by controlLock.
+var configServerConfigs []*configserver.PipelineConfig
+
+// loadConfigServerConfigs reloads the configs, only the changed configs are restarted.
+func loadConfigServerConfigs(staticCfgs []string, configs []*configserver.PipelineConfig) map[string]error {
+	controlLock.Lock()
+	defer controlLock.Unlock()
+	configServerConfigs = configs
+	// the errors are reported to the config server by the names of its configs.
+	names := make(map[string]string, len(configs))
+	for _, cfg := range configs {
+		names[configServerConfigName(cfg.Name)] = cfg.Name
+	}
+	errs := make(map[string]error)
+	for _, result := range reloadConfigs(pipelineConfigs(staticCfgs)) {
+		if result.Error == "" {
+			continue
+		}
+		if name, ok := names[result.ConfigName]; ok {
+			errs[name] = errors.New(result.Error)
+		} else {
+			logger.Warning(context.Background(), "CONFIG_LOAD_ALARM", "reload config error", result.Error, "config", result.ConfigName)
+		}
+	}
+	return errs
+}
+
+func registerCommands(agent *configserver.Agent) {
+	agent.RegisterCommand("force_gc", func(*configserverproto.Command) error {
+		ForceGC()
+		return nil
+	})
+	agent.RegisterCommand("dump_mem", func(*configserverproto.Command) error {
+		go DumpMemInfo(0)
+		return nil
+	})
+	agent.RegisterCommand("dump_cpu", func(cmd *configserverproto.Command) error {
+		seconds := 30
+		if value, ok := cmd.Args["seconds"]; ok {
+			var err error
+			if seconds, err = strconv.Atoi(value); err != nil || seconds <= 0 {
+				return fmt.Errorf("invalid seconds %v", value)
+			}
+		}
+		go DumpCPUInfo(seconds)
+		return nil
+	})
+}
+
+func splitFlag(value string) []string {
+	var res []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -126,7 +126,7 @@ func UnloadConfig(project string, logstore string, configName string) int {
 
 //export ProcessRawLog
 func ProcessRawLog(configName string, rawLog []byte, packID string, topic string) int {
-	plugin, flag := pluginmanager.LogtailConfig[configName]
+	plugin, flag := pluginmanager.GetLogtailConfig(configName)
 	if !flag {
 		return -1
 	}
@@ -138,7 +138,7 @@ func ProcessRawLog(configName string, rawLog []byte, packID string, topic string
 
 //export ProcessRawLogV2
 func ProcessRawLogV2(configName string, rawLog []byte, packID string, topic string, tags []byte) int {
-	config, exists := pluginmanager.LogtailConfig[configName]
+	config, exists := pluginmanager.GetLogtailConfig(configName)
 	if !exists {
 		return -1
 	}
@@ -147,7 +147,7 @@ func ProcessRawLogV2(configName string, rawLog []byte, packID string, topic stri
 
 //export ProcessLog
 func ProcessLog(configName string, logBytes []byte, packID string, topic string, tags []byte) int {
-	config, exists := pluginmanager.LogtailConfig[configName]
+	config, exists := pluginmanager.GetLogtailConfig(configName)
 	if !exists {
 		logger.Debug(context.Background(), "config not found", configName)
 		return -1
@@ -157,7 +157,7 @@ func ProcessLog(configName string, logBytes []byte, packID string, topic string,
 
 //export ProcessLogGroup
 func ProcessLogGroup(configName string, logBytes []byte, packID string) int {
-	config, exists := pluginmanager.LogtailConfig[configName]
+	config, exists := pluginmanager.GetLogtailConfig(configName)
 	if !exists {
 		logger.Debug(context.Background(), "config not found", configName)
 		return -1
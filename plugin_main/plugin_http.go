@@ -16,6 +16,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -73,6 +74,11 @@ func HandleCPU180(w http.ResponseWriter, req *http.Request) {
 
 // HandleForceGC trigger force GC.
 func HandleForceGC(w http.ResponseWriter, req *http.Request) {
+	ForceGC()
+}
+
+// ForceGC runs GC and returns as much memory to the OS as possible.
+func ForceGC() {
 	logger.Info(context.Background(), "GC begin")
 	runtime.GC()
 	logger.Info(context.Background(), "GC done")
@@ -113,11 +119,9 @@ func HandleLoadConfig(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte("parse body error"))
 		return
 	}
-	HoldOn(0)
-	for _, cfg := range loadConfigs {
-		LoadConfig(cfg.Project, cfg.Logstore, cfg.ConfigName, cfg.LogstoreKey, cfg.JSONStr)
-	}
-	Resume()
+	results := reloadConfigs(loadConfigs)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(results)
 }
 
 // HandleHoldOn hold on the ilogtail process.
@@ -194,6 +198,16 @@ func InitHTTPServer() {
 				go DumpMemInfo(100)
 			}
 		}
+		if *flags.HTTPMetricsFlag {
+			handlers["/metrics"] = &handler{handlerFunc: pluginmanager.SelfMetricsHandler().ServeHTTP, description: "export self metrics in Prometheus or OpenMetrics format"}
+		}
+		if *flags.HTTPHealthFlag {
+			handlers["/healthz"] = &handler{handlerFunc: pluginmanager.HandleHealthz, description: "report whether the process is healthy"}
+			handlers["/readyz"] = &handler{handlerFunc: pluginmanager.HandleReadyz, description: "report the readiness of the running configs"}
+		}
+		if *flags.HTTPLogLevelFlag {
+			handlers["/loglevel"] = &handler{handlerFunc: logger.HandleLogLevel, description: "get or change the log levels at runtime"}
+		}
 		if *flags.StatefulSetFlag {
 			handlers["/export/port"] = &handler{handlerFunc: pluginmanager.FindPort, description: "export ilogtail's LISTEN ports"}
 		}
@@ -18,16 +18,22 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 	"runtime"
+	"strings"
 
 	_ "github.com/alibaba/ilogtail/helper/envconfig"
+	"github.com/alibaba/ilogtail/pkg/config"
 	"github.com/alibaba/ilogtail/pkg/doc"
 	"github.com/alibaba/ilogtail/pkg/logger"
 	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/pipelinetest"
 	"github.com/alibaba/ilogtail/pkg/signals"
 	"github.com/alibaba/ilogtail/pkg/util"
+	"github.com/alibaba/ilogtail/plugin_main/configfile"
 	"github.com/alibaba/ilogtail/plugin_main/flags"
 	_ "github.com/alibaba/ilogtail/plugin_main/wrapmemcpy"
+	"github.com/alibaba/ilogtail/pluginmanager"
 	_ "github.com/alibaba/ilogtail/plugins/all"
 )
 
@@ -39,6 +45,9 @@ func main() {
 		generatePluginDoc()
 		return
 	}
+	if *flags.PipelineTest != "" {
+		os.Exit(runPipelineTest())
+	}
 	cpu := runtime.NumCPU()
 	procs := runtime.GOMAXPROCS(0)
 	fmt.Println("cpu num:", cpu, " GOMAXPROCS:", procs)
@@ -54,27 +63,100 @@ func main() {
 	} else if InitPluginBaseV2(globalCfg) != 0 {
 		return
 	}
+	// change the log levels by the level file when receiving the level signal.
+	logger.WatchLevelSignal()
 	// load the static configs.
-	for i, cfg := range pluginCfgs {
-		p := fmt.Sprintf("PluginProject_%d", i)
-		l := fmt.Sprintf("PluginLogstore_%d", i)
-		c := fmt.Sprintf("1.0#PluginProject_%d##Config%d", i, i)
-		if LoadConfig(p, l, c, 123, cfg) != 0 {
-			logger.Warningf(context.Background(), "START_PLUGIN_ALARM", "%s_%s_%s start fail, config is %s", p, l, c, cfg)
+	if !loadStaticConfigs(pluginCfgs) {
+		return
+	}
+	var watcher *configfile.Watcher
+	if *flags.ConfigDir != "" {
+		if watcher, err = loadConfigDir(pluginCfgs); err != nil {
+			logger.Error(context.Background(), "CONFIG_DIR_ALARM", "load config dir error", err)
 			return
 		}
 	}
 	Resume()
+	stopWatcher := func() {}
+	if watcher != nil {
+		stopWatcher = startConfigDirWatcher(watcher)
+	}
+	stopAgent := func() {}
+	if *flags.ConfigServerAddress != "" {
+		if stopAgent, err = startConfigServerAgent(pluginCfgs); err != nil {
+			logger.Error(context.Background(), "CONFIG_SERVER_ALARM", "start config server agent error", err)
+			return
+		}
+	}
 
 	// handle the first shutdown signal gracefully, and exit directly if FileIOFlag is true
 	if !*flags.FileIOFlag {
 		<-signals.SetupSignalHandler()
 	}
 	logger.Info(context.Background(), "########################## exit process begin ##########################")
+	stopAgent()
+	stopWatcher()
 	HoldOn(1)
 	logger.Info(context.Background(), "########################## exit process done ##########################")
 }
 
+// staticConfigs names the configs read from the plugin config file.
+func staticConfigs(pluginCfgs []string) []*config.LoadedConfig {
+	configs := make([]*config.LoadedConfig, 0, len(pluginCfgs))
+	for i, cfg := range pluginCfgs {
+		configs = append(configs, &config.LoadedConfig{
+			Project:     fmt.Sprintf("PluginProject_%d", i),
+			Logstore:    fmt.Sprintf("PluginLogstore_%d", i),
+			ConfigName:  fmt.Sprintf("1.0#PluginProject_%d##Config%d", i, i),
+			LogstoreKey: 123,
+			JSONStr:     cfg,
+		})
+	}
+	return configs
+}
+
+// loadStaticConfigs loads the configs read from the plugin config file, it returns false if any of them fails.
+func loadStaticConfigs(pluginCfgs []string) bool {
+	for _, cfg := range staticConfigs(pluginCfgs) {
+		if LoadConfig(cfg.Project, cfg.Logstore, cfg.ConfigName, cfg.LogstoreKey, cfg.JSONStr) != 0 {
+			logger.Warningf(context.Background(), "START_PLUGIN_ALARM", "%s_%s_%s start fail, config is %s", cfg.Project, cfg.Logstore, cfg.ConfigName, cfg.JSONStr)
+			return false
+		}
+	}
+	return true
+}
+
+// pipelineConfigs returns the static configs and the configs from the config server and the config directory.
+// It must be called with controlLock held.
+func pipelineConfigs(staticCfgs []string) []*config.LoadedConfig {
+	configs := staticConfigs(staticCfgs)
+	for _, cfg := range configServerConfigs {
+		configs = append(configs, &config.LoadedConfig{Project: configServerProject, Logstore: cfg.Name, ConfigName: configServerConfigName(cfg.Name), JSONStr: cfg.Detail})
+	}
+	for _, name := range sortedConfigDirNames() {
+		configs = append(configs, &config.LoadedConfig{Project: configDirProject, Logstore: name, ConfigName: configDirConfigName(name), JSONStr: configDirConfigs[name]})
+	}
+	return configs
+}
+
+// reloadConfigs replaces the running configs with the configs, only the changed configs are restarted when the
+// configs are running, otherwise the configs are loaded and resumed. It must be called with controlLock held.
+func reloadConfigs(configs []*config.LoadedConfig) []pluginmanager.ReloadResult {
+	if started {
+		return pluginmanager.ReloadConfigs(configs)
+	}
+	results := make([]pluginmanager.ReloadResult, 0, len(configs))
+	for _, cfg := range configs {
+		result := pluginmanager.ReloadResult{ConfigName: cfg.ConfigName, Action: pluginmanager.ReloadActionAdded}
+		if err := pluginmanager.LoadLogstoreConfig(cfg.Project, cfg.Logstore, cfg.ConfigName, cfg.LogstoreKey, cfg.JSONStr); err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	Resume()
+	return results
+}
+
 func generatePluginDoc() {
 	for name, creator := range pipeline.ServiceInputs {
 		doc.Register("service_input", name, creator())
@@ -93,3 +175,16 @@ func generatePluginDoc() {
 	}
 	doc.Generate(*flags.DocPath)
 }
+
+// runPipelineTest runs the pipeline test cases and returns the exit code, which is 1 when any case fails.
+func runPipelineTest() int {
+	failed, err := pipelinetest.RunCases(strings.Split(*flags.PipelineTest, ","), *flags.UpdateGolden, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
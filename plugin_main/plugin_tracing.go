@@ -0,0 +1,34 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pluginmanager"
+	"github.com/alibaba/ilogtail/plugins/flusher/opentelemetry"
+)
+
+// newTraceExporter exports the pipeline spans by the OTLP gRPC client of flusher_otlp.
+func newTraceExporter(grpcConfig *helper.GrpcClientConfig) (pluginmanager.TraceExporter, error) {
+	exporter, err := opentelemetry.NewTraceExporter(grpcConfig)
+	if err != nil {
+		return nil, err
+	}
+	return exporter, nil
+}
+
+func init() {
+	pluginmanager.TraceExporterFactory = newTraceExporter
+}
@@ -31,10 +31,14 @@ var errAggAdd = errors.New("loggroup queue is full")
 // passes log groups to associated LogstoreConfig through channel LogGroupsChan.
 // In fact, LogGroupsChan == (associated) LogstoreConfig.LogGroupsChan.
 type AggregatorWrapper struct {
+	// Name is the plugin name with the optional ID, which labels the restarts of the plugin.
+	Name          string
 	Aggregator    pipeline.AggregatorV1
 	Config        *LogstoreConfig
 	LogGroupsChan chan *protocol.LogGroup
 	Interval      time.Duration
+
+	tracker *pipelineTracker
 }
 
 // Add inserts @loggroup to LogGroupsChan if @loggroup is not empty.
@@ -44,10 +48,12 @@ func (p *AggregatorWrapper) Add(loggroup *protocol.LogGroup) error {
 	if len(loggroup.Logs) == 0 {
 		return nil
 	}
+	p.enqueue(loggroup)
 	select {
 	case p.LogGroupsChan <- loggroup:
 		return nil
 	default:
+		p.cancel(loggroup)
 		return errAggAdd
 	}
 }
@@ -61,10 +67,12 @@ func (p *AggregatorWrapper) AddWithWait(loggroup *protocol.LogGroup, duration ti
 		return nil
 	}
 	timer := time.NewTimer(duration)
+	p.enqueue(loggroup)
 	select {
 	case p.LogGroupsChan <- loggroup:
 		return nil
 	case <-timer.C:
+		p.cancel(loggroup)
 		return errAggAdd
 	}
 }
@@ -72,10 +80,13 @@ func (p *AggregatorWrapper) AddWithWait(loggroup *protocol.LogGroup, duration ti
 // Run calls periodically Aggregator.Flush to get log groups from associated aggregator and
 // pass them to LogstoreConfig through LogGroupsChan.
 func (p *AggregatorWrapper) Run(control *pipeline.AsyncControl) {
-	defer panicRecover(p.Aggregator.Description())
 	for {
 		exitFlag := util.RandomSleep(p.Interval, 0.1, control.CancelToken())
+		span := p.Config.startSpan(spanAggregate, p.Name, unknownGroupSize)
 		logGroups := p.Aggregator.Flush()
+		span.setGroupSize(len(logGroups))
+		span.finish(nil)
+		p.enqueue(logGroups...)
 		for _, logGroup := range logGroups {
 			if len(logGroup.Logs) == 0 {
 				continue
@@ -87,3 +98,25 @@ func (p *AggregatorWrapper) Run(control *pipeline.AsyncControl) {
 		}
 	}
 }
+
+// enqueue stamps the non-empty log groups for the pipeline latency metrics before they are put into LogGroupsChan.
+func (p *AggregatorWrapper) enqueue(loggroups ...*protocol.LogGroup) {
+	if p.tracker == nil {
+		return
+	}
+	groups := make([]interface{}, 0, len(loggroups))
+	for _, loggroup := range loggroups {
+		if len(loggroup.Logs) != 0 {
+			groups = append(groups, loggroup)
+		}
+	}
+	if len(groups) != 0 {
+		p.tracker.enqueue(groups...)
+	}
+}
+
+func (p *AggregatorWrapper) cancel(loggroup *protocol.LogGroup) {
+	if p.tracker != nil {
+		p.tracker.cancel(loggroup)
+	}
+}
@@ -138,7 +138,7 @@ func (p *checkPointManager) keyMatch(key []byte) bool {
 		logger.Error(context.Background(), "CHECKPOINT_ALARM", "key format not match, key", keyStr)
 		return false
 	}
-	_, existFlag := LogtailConfig[keyStr[0:index]]
+	_, existFlag := GetLogtailConfig(keyStr[0:index])
 	if existFlag {
 		return true
 	}
@@ -0,0 +1,174 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/alibaba/ilogtail/pkg/config"
+	"github.com/alibaba/ilogtail/pkg/logger"
+)
+
+// The actions taken on the configs by ReloadConfigs.
+const (
+	ReloadActionAdded     = "added"
+	ReloadActionUpdated   = "updated"
+	ReloadActionRemoved   = "removed"
+	ReloadActionUnchanged = "unchanged"
+)
+
+// ReloadResult is the result of a config reloaded by ReloadConfigs.
+type ReloadResult struct {
+	ConfigName string `json:"config_name"`
+	Action     string `json:"action"`
+	Error      string `json:"error,omitempty"`
+}
+
+var reloadLock sync.Mutex
+
+// isSameConfig reports whether the running config is created from the loaded config.
+func isSameConfig(lc *LogstoreConfig, cfg *config.LoadedConfig) bool {
+	return lc.ProjectName == cfg.Project && lc.LogstoreName == cfg.Logstore && lc.LogstoreKey == cfg.LogstoreKey &&
+		lc.configDetailHash == configDetailHash(cfg.JSONStr)
+}
+
+// ReloadConfigs replaces the running configs with the configs without holding on the others, it returns the results
+// sorted by config name. Only the removed and changed configs are stopped, and only the added and changed configs
+// are created and started, the unchanged configs keep running with their states. A changed config is stopped only after
+// the new one is created, and the new one inherits its unsent log groups like HoldOn and Resume; if the new one fails
+// to be created, the old one keeps running and the error is reported. A config with empty JSONStr is removed.
+// It must be called between Resume and HoldOn.
+func ReloadConfigs(configs []*config.LoadedConfig) []ReloadResult {
+	return reloadConfigs(configs, false)
+}
+
+// UpdateConfigs is like ReloadConfigs, but only the configs given are reloaded or removed, the other running configs
+// are kept without being compared, and are absent from the results.
+// It must be called between Resume and HoldOn.
+func UpdateConfigs(configs []*config.LoadedConfig) []ReloadResult {
+	return reloadConfigs(configs, true)
+}
+
+// reloadConfigs reloads the configs, the running configs absent from the configs are kept if partial, otherwise
+// they are removed.
+func reloadConfigs(configs []*config.LoadedConfig, partial bool) []ReloadResult {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	defer panicRecover("Reload configs")
+
+	desired := make(map[string]*config.LoadedConfig, len(configs))
+	removed := make(map[string]struct{})
+	for _, cfg := range configs {
+		if len(cfg.JSONStr) == 0 {
+			delete(desired, cfg.ConfigName)
+			removed[cfg.ConfigName] = struct{}{}
+			continue
+		}
+		desired[cfg.ConfigName] = cfg
+		delete(removed, cfg.ConfigName)
+	}
+	// The checkpoints of the configs absent from LogtailConfig are cleaned by the checkpoint manager.
+	CheckPointManager.HoldOn()
+	defer CheckPointManager.Resume()
+
+	results := make([]ReloadResult, 0, len(desired))
+	running := make(map[string]*LogstoreConfig, len(desired))
+	updated := make(map[string]*LogstoreConfig)
+	for _, lc := range getLogtailConfigs() {
+		name := lc.ConfigName
+		cfg, ok := desired[name]
+		if _, ok := removed[name]; partial && !ok && cfg == nil {
+			running[name] = lc
+			continue
+		}
+		if !ok {
+			logger.Info(lc.Context.GetRuntimeContext(), "reload config", "remove")
+			stopConfig(lc, true)
+			results = append(results, ReloadResult{ConfigName: name, Action: ReloadActionRemoved})
+			continue
+		}
+		if isSameConfig(lc, cfg) {
+			running[name] = lc
+			results = append(results, ReloadResult{ConfigName: name, Action: ReloadActionUnchanged})
+			continue
+		}
+		// The changed config keeps running until the new one is created.
+		updated[name] = lc
+	}
+
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		if _, ok := running[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cfg := desired[name]
+		result := ReloadResult{ConfigName: name, Action: ReloadActionAdded}
+		last, isUpdate := updated[name]
+		if isUpdate {
+			result.Action = ReloadActionUpdated
+		}
+		logger.Info(context.Background(), "load config", name, "logstore", cfg.Logstore)
+		lc, err := createLogstoreConfig(cfg.Project, cfg.Logstore, name, cfg.LogstoreKey, cfg.JSONStr)
+		if err != nil {
+			logger.Error(context.Background(), "CONFIG_LOAD_ALARM", "reload config error, project", cfg.Project,
+				"logstore", cfg.Logstore, "config", name, "error", err)
+			if isUpdate {
+				logger.Info(last.Context.GetRuntimeContext(), "reload config", "keep the old one")
+				running[name] = last
+			}
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		if isUpdate {
+			logger.Info(last.Context.GetRuntimeContext(), "reload config", "update")
+			stopReplacedConfig(last)
+		}
+		running[name] = lc
+		if lc.alreadyStarted {
+			lc.resume()
+		} else {
+			lc.Start()
+		}
+		if isUpdate {
+			// Move unsent LogGroups from the old config to the running new config.
+			lc.PluginRunner.Merge(last.PluginRunner)
+		}
+		results = append(results, result)
+	}
+
+	// Replace instead of modifying the map, which may be iterated by the running built-in configs.
+	LogtailConfigLock.Lock()
+	LogtailConfig = running
+	LogtailConfigLock.Unlock()
+	setRunningConfigs(running)
+	sort.Slice(results, func(i, j int) bool { return results[i].ConfigName < results[j].ConfigName })
+	return results
+}
+
+// stopReplacedConfig stops the config replaced by a new one, the AlwaysOnline config is stopped too instead of
+// being cached by the AlwaysOnlineManager.
+func stopReplacedConfig(lc *LogstoreConfig) {
+	stopConfig(lc, false)
+	if cached, ok := GetAlwaysOnlineManager().GetCachedConfig(lc.ConfigName); ok {
+		cached.resume()
+		_ = cached.Stop(false)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || windows
+// +build linux windows
+
+package pluginmanager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/config"
+	"github.com/alibaba/ilogtail/plugins/flusher/checker"
+)
+
+func reloadTestConfig(name string, maxLogCount int) *config.LoadedConfig {
+	return &config.LoadedConfig{
+		Project:    "test_prj",
+		Logstore:   name,
+		ConfigName: name,
+		JSONStr: fmt.Sprintf(`{
+			"global": {"AggregatIntervalMs": 100, "FlushIntervalMs": 100},
+			"inputs": [{"type": "service_mock", "detail": {"LogsPerSecond": 100, "MaxLogCount": %d, "Fields": {"content": "reload"}}}],
+			"flushers": [{"type": "flusher_checker"}]
+		}`, maxLogCount),
+	}
+}
+
+func reloadTestLogCount(lc *LogstoreConfig) int {
+	return GetConfigFlushers(lc.PluginRunner)[0].(*checker.FlusherChecker).GetLogCount()
+}
+
+func TestReloadConfigs(t *testing.T) {
+	require.NoError(t, Resume())
+	defer func() {
+		require.NoError(t, HoldOn(false))
+		LogtailConfig = make(map[string]*LogstoreConfig)
+	}()
+
+	results := ReloadConfigs([]*config.LoadedConfig{reloadTestConfig("a", 100), reloadTestConfig("b", 100)})
+	assert.Equal(t, []ReloadResult{
+		{ConfigName: "a", Action: ReloadActionAdded},
+		{ConfigName: "b", Action: ReloadActionAdded},
+	}, results)
+	a, b := LogtailConfig["a"], LogtailConfig["b"]
+	require.NotNil(t, a)
+	require.NotNil(t, b)
+	require.Len(t, getRunningConfigs(), 2)
+	// wait for the service inputs to start.
+	time.Sleep(time.Second)
+
+	invalid := &config.LoadedConfig{Project: "test_prj", Logstore: "d", ConfigName: "d", JSONStr: `{"inputs": [`}
+	results = ReloadConfigs([]*config.LoadedConfig{reloadTestConfig("a", 100), reloadTestConfig("b", 200), reloadTestConfig("c", 100), invalid})
+	require.Len(t, results, 4)
+	assert.Equal(t, ReloadResult{ConfigName: "a", Action: ReloadActionUnchanged}, results[0])
+	assert.Equal(t, ReloadResult{ConfigName: "b", Action: ReloadActionUpdated}, results[1])
+	assert.Equal(t, ReloadResult{ConfigName: "c", Action: ReloadActionAdded}, results[2])
+	assert.Equal(t, "d", results[3].ConfigName)
+	assert.Equal(t, ReloadActionAdded, results[3].Action)
+	assert.NotEmpty(t, results[3].Error)
+	// the unchanged config keeps running, and the changed config is replaced.
+	assert.Same(t, a, LogtailConfig["a"])
+	assert.NotSame(t, b, LogtailConfig["b"])
+	assert.NotNil(t, LogtailConfig["c"])
+	assert.NotContains(t, LogtailConfig, "d")
+	require.Len(t, getRunningConfigs(), 3)
+
+	time.Sleep(2 * time.Second)
+	assert.Equal(t, 100, reloadTestLogCount(a))
+	assert.Equal(t, 200, reloadTestLogCount(LogtailConfig["b"]))
+
+	c := LogtailConfig["c"]
+	results = ReloadConfigs([]*config.LoadedConfig{reloadTestConfig("a", 100), {ConfigName: "b"}})
+	assert.Equal(t, []ReloadResult{
+		{ConfigName: "a", Action: ReloadActionUnchanged},
+		{ConfigName: "b", Action: ReloadActionRemoved},
+		{ConfigName: "c", Action: ReloadActionRemoved},
+	}, results)
+	assert.Equal(t, map[string]*LogstoreConfig{"a": a}, LogtailConfig)
+	assert.Equal(t, 100, reloadTestLogCount(c))
+
+	// the changed config keeps running if the new one fails to be created.
+	results = ReloadConfigs([]*config.LoadedConfig{{Project: "test_prj", Logstore: "a", ConfigName: "a", JSONStr: `{"inputs": [`}})
+	require.Len(t, results, 1)
+	assert.Equal(t, ReloadActionUpdated, results[0].Action)
+	assert.NotEmpty(t, results[0].Error)
+	assert.Equal(t, map[string]*LogstoreConfig{"a": a}, LogtailConfig)
+	require.Len(t, getRunningConfigs(), 1)
+}
+
+func TestUpdateConfigs(t *testing.T) {
+	require.NoError(t, Resume())
+	defer func() {
+		require.NoError(t, HoldOn(false))
+		LogtailConfig = make(map[string]*LogstoreConfig)
+	}()
+
+	results := UpdateConfigs([]*config.LoadedConfig{reloadTestConfig("a", 100), reloadTestConfig("b", 100)})
+	require.Len(t, results, 2)
+	a := LogtailConfig["a"]
+	require.NotNil(t, a)
+	// wait for the service inputs to start.
+	time.Sleep(time.Second)
+
+	// only the given configs are reloaded, the others keep running without being reported.
+	results = UpdateConfigs([]*config.LoadedConfig{reloadTestConfig("b", 200), reloadTestConfig("c", 100)})
+	assert.Equal(t, []ReloadResult{
+		{ConfigName: "b", Action: ReloadActionUpdated},
+		{ConfigName: "c", Action: ReloadActionAdded},
+	}, results)
+	assert.Same(t, a, LogtailConfig["a"])
+	require.Len(t, getRunningConfigs(), 3)
+	time.Sleep(time.Second)
+
+	results = UpdateConfigs([]*config.LoadedConfig{{ConfigName: "b"}, {ConfigName: "absent"}})
+	assert.Equal(t, []ReloadResult{{ConfigName: "b", Action: ReloadActionRemoved}}, results)
+	assert.Len(t, LogtailConfig, 2)
+	assert.Same(t, a, LogtailConfig["a"])
+	assert.NotNil(t, LogtailConfig["c"])
+}
@@ -103,7 +103,7 @@ func recordDeleteContainers(logGroup *protocol.LogGroup, containerIDs map[string
 		projectSet := make(map[string]struct{})
 
 		// get project list
-		for _, logstoreConfig := range LogtailConfig {
+		for _, logstoreConfig := range getLogtailConfigs() {
 			projectSet[logstoreConfig.ProjectName] = struct{}{}
 		}
 		keys := make([]string, 0, len(projectSet))
@@ -128,7 +128,7 @@ func refreshEnvAndLabel() {
 	envSet = make(map[string]struct{})
 	containerLabelSet = make(map[string]struct{})
 	k8sLabelSet = make(map[string]struct{})
-	for _, logstoreConfig := range LogtailConfig {
+	for _, logstoreConfig := range getLogtailConfigs() {
 		if logstoreConfig.CollectContainersFlag {
 			for key := range logstoreConfig.EnvSet {
 				envSet[key] = struct{}{}
@@ -149,7 +149,7 @@ func compareEnvAndLabel() (diffEnvSet, diffContainerLabelSet, diffK8sLabelSet ma
 	diffEnvSet = make(map[string]struct{})
 	diffContainerLabelSet = make(map[string]struct{})
 	diffK8sLabelSet = make(map[string]struct{})
-	for _, logstoreConfig := range LogtailConfig {
+	for _, logstoreConfig := range getLogtailConfigs() {
 		if logstoreConfig.CollectContainersFlag {
 			for key := range logstoreConfig.EnvSet {
 				if _, ok := envSet[key]; !ok {
@@ -178,7 +178,7 @@ func getContainersToRecord(containerIDs map[string]struct{}) (map[string]struct{
 	projectSet := make(map[string]struct{})
 	recordedContainerIds := make(map[string]struct{})
 
-	for _, logstoreConfig := range LogtailConfig {
+	for _, logstoreConfig := range getLogtailConfigs() {
 		projectSet[logstoreConfig.ProjectName] = struct{}{}
 	}
 	keys := make([]string, 0, len(projectSet))
@@ -227,7 +227,7 @@ func compareEnvAndLabelAndRecordContainer() []*helper.ContainerDetail {
 
 	if len(diffEnvSet) != 0 || len(diffContainerLabelSet) != 0 || len(diffK8sLabelSet) != 0 {
 		projectSet := make(map[string]struct{})
-		for _, logstoreConfig := range LogtailConfig {
+		for _, logstoreConfig := range getLogtailConfigs() {
 			projectSet[logstoreConfig.ProjectName] = struct{}{}
 		}
 		keys := make([]string, 0, len(projectSet))
@@ -267,7 +267,7 @@ func compareEnvAndLabelAndRecordContainer() []*helper.ContainerDetail {
 }
 
 func isCollectContainers() bool {
-	for _, logstoreConfig := range LogtailConfig {
+	for _, logstoreConfig := range getLogtailConfigs() {
 		if logstoreConfig.CollectContainersFlag {
 			return true
 		}
@@ -31,11 +31,22 @@ type ContextImp struct {
 	StringMetrics  map[string]pipeline.StringMetric
 	CounterMetrics map[string]pipeline.CounterMetric
 	LatencyMetrics map[string]pipeline.LatencyMetric
+	MetricVecs     map[string]pipeline.MetricVec
 
 	common      *pkg.LogtailContextMeta
 	pluginNames string
 	ctx         context.Context
 	logstoreC   *LogstoreConfig
+
+	// plugin is the plugin being loaded, the metrics registered meanwhile belong to it.
+	plugin  string
+	metrics []pluginMetric
+}
+
+// pluginMetric is a registered self metric or metric vector with the plugin registering it, which is exported by /metrics.
+type pluginMetric struct {
+	plugin string
+	metric interface{ Name() string }
 }
 
 var contextMutex sync.Mutex
@@ -104,6 +115,7 @@ func (p *ContextImp) RegisterCounterMetric(metric pipeline.CounterMetric) {
 		p.CounterMetrics = make(map[string]pipeline.CounterMetric)
 	}
 	p.CounterMetrics[metric.Name()] = metric
+	p.addPluginMetric(metric)
 }
 
 func (p *ContextImp) RegisterStringMetric(metric pipeline.StringMetric) {
@@ -113,6 +125,7 @@ func (p *ContextImp) RegisterStringMetric(metric pipeline.StringMetric) {
 		p.StringMetrics = make(map[string]pipeline.StringMetric)
 	}
 	p.StringMetrics[metric.Name()] = metric
+	p.addPluginMetric(metric)
 }
 
 func (p *ContextImp) RegisterLatencyMetric(metric pipeline.LatencyMetric) {
@@ -122,6 +135,61 @@ func (p *ContextImp) RegisterLatencyMetric(metric pipeline.LatencyMetric) {
 		p.LatencyMetrics = make(map[string]pipeline.LatencyMetric)
 	}
 	p.LatencyMetrics[metric.Name()] = metric
+	p.addPluginMetric(metric)
+}
+
+func (p *ContextImp) RegisterCounterVec(vec pipeline.CounterVec) {
+	p.registerMetricVec(vec)
+}
+
+func (p *ContextImp) RegisterGaugeVec(vec pipeline.GaugeVec) {
+	p.registerMetricVec(vec)
+}
+
+func (p *ContextImp) RegisterHistogramVec(vec pipeline.HistogramVec) {
+	p.registerMetricVec(vec)
+}
+
+func (p *ContextImp) registerMetricVec(vec pipeline.MetricVec) {
+	contextMutex.Lock()
+	defer contextMutex.Unlock()
+	if p.MetricVecs == nil {
+		p.MetricVecs = make(map[string]pipeline.MetricVec)
+	}
+	p.MetricVecs[vec.Name()] = vec
+	p.addPluginMetric(vec)
+}
+
+// addPluginMetric records the metric with the plugin being loaded, it replaces the metric of the same name
+// registered by the plugin before. It must be called with contextMutex held.
+func (p *ContextImp) addPluginMetric(metric interface{ Name() string }) {
+	for i := range p.metrics {
+		if p.metrics[i].plugin == p.plugin && p.metrics[i].metric.Name() == metric.Name() {
+			p.metrics[i].metric = metric
+			return
+		}
+	}
+	p.metrics = append(p.metrics, pluginMetric{plugin: p.plugin, metric: metric})
+}
+
+// bindPlugin makes the metrics registered afterwards belong to the plugin, the returned function restores the
+// previous plugin, since extensions may be loaded while loading other plugins.
+func (p *ContextImp) bindPlugin(plugin string) func() {
+	contextMutex.Lock()
+	defer contextMutex.Unlock()
+	prev := p.plugin
+	p.plugin = plugin
+	return func() {
+		contextMutex.Lock()
+		defer contextMutex.Unlock()
+		p.plugin = prev
+	}
+}
+
+func (p *ContextImp) pluginMetrics() []pluginMetric {
+	contextMutex.Lock()
+	defer contextMutex.Unlock()
+	return append([]pluginMetric(nil), p.metrics...)
 }
 
 func (p *ContextImp) MetricSerializeToPB(log *protocol.Log) {
@@ -153,6 +221,9 @@ func (p *ContextImp) MetricSerializeToPB(log *protocol.Log) {
 			value.Clear()
 		}
 	}
+	for _, vec := range p.MetricVecs {
+		vec.Serialize(log)
+	}
 }
 
 func (p *ContextImp) SaveCheckPoint(key string, value []byte) error {
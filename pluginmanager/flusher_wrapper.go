@@ -22,6 +22,8 @@ import (
 )
 
 type FlusherWrapper struct {
+	// Name is the plugin name with the optional ID, such as flusher_sls/2, which labels the pipeline metrics.
+	Name          string
 	Flusher       pipeline.FlusherV1
 	Config        *LogstoreConfig
 	LogGroupsChan chan *protocol.LogGroup
@@ -0,0 +1,278 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alibaba/ilogtail/plugin_main/flags"
+)
+
+const (
+	healthStatusOK   = "ok"
+	healthStatusFail = "fail"
+
+	// readyHistorySize is the number of the latest IsReady checks of the flushers kept by each config.
+	readyHistorySize = 100
+	// minReadyChecks is the number of the IsReady checks required before the ready ratio is checked.
+	minReadyChecks = 10
+	// maxPanicRecords is the number of the latest panics kept for the health check.
+	maxPanicRecords = 100
+)
+
+// HealthThresholds are the thresholds of the health and readiness checks.
+type HealthThresholds struct {
+	// MaxQueueRatio is the max fill ratio of the input and flush queues of a ready config.
+	MaxQueueRatio float64
+	// MaxFlushDelay is the max time without successful flushes of a ready config when the data is pending.
+	MaxFlushDelay time.Duration
+	// MinReadyRatio is the min ratio of the successful IsReady checks of the flushers of a ready config.
+	MinReadyRatio float64
+	// MaxPanics is the max number of the panics in PanicWindow of a healthy process.
+	MaxPanics   int
+	PanicWindow time.Duration
+}
+
+func healthThresholdsFromFlags() HealthThresholds {
+	return HealthThresholds{
+		MaxQueueRatio: *flags.HealthMaxQueueRatio,
+		MaxFlushDelay: time.Duration(*flags.HealthMaxFlushDelay) * time.Second,
+		MinReadyRatio: *flags.HealthMinReadyRatio,
+		MaxPanics:     *flags.HealthMaxPanics,
+		PanicWindow:   time.Duration(*flags.HealthPanicWindow) * time.Second,
+	}
+}
+
+// HealthReport is the response of the health and readiness checks.
+type HealthReport struct {
+	Status   string         `json:"status"`
+	Problems []string       `json:"problems,omitempty"`
+	Configs  []ConfigStatus `json:"configs,omitempty"`
+	Panics   []PanicRecord  `json:"panics,omitempty"`
+}
+
+// ConfigStatus is the state of a running config.
+type ConfigStatus struct {
+	Name              string   `json:"name"`
+	Inputs            int      `json:"inputs"`
+	InputQueueRatio   float64  `json:"input_queue_ratio"`
+	FlushQueueRatio   float64  `json:"flush_queue_ratio"`
+	FlusherReadyRatio float64  `json:"flusher_ready_ratio"`
+	LastFlushTime     string   `json:"last_flush_time,omitempty"`
+	LastFlushError    string   `json:"last_flush_error,omitempty"`
+	PluginRestarts    int      `json:"plugin_restarts,omitempty"`
+	DegradedPlugins   []string `json:"degraded_plugins,omitempty"`
+	Problems          []string `json:"problems,omitempty"`
+}
+
+// PanicRecord is a panic caught by panicRecover.
+type PanicRecord struct {
+	Time   time.Time `json:"time"`
+	Plugin string    `json:"plugin"`
+	Error  string    `json:"error"`
+}
+
+// runnerStatus is the state of the inputs and the queues of a plugin runner.
+type runnerStatus struct {
+	inputs        int
+	inputQueueLen int
+	inputQueueCap int
+	flushQueueLen int
+	flushQueueCap int
+}
+
+// configHealth records the IsReady checks of the flushers and the flush results of a config.
+type configHealth struct {
+	mu           sync.Mutex
+	started      time.Time
+	readyHistory [readyHistorySize]bool
+	readyCount   int
+	readyNext    int
+	lastFlush    time.Time
+	lastFailure  time.Time
+	lastError    string
+}
+
+func (h *configHealth) start(now time.Time) {
+	h.mu.Lock()
+	h.started = now
+	h.mu.Unlock()
+}
+
+func (h *configHealth) recordReady(ready bool) {
+	h.mu.Lock()
+	h.readyHistory[h.readyNext] = ready
+	h.readyNext = (h.readyNext + 1) % readyHistorySize
+	if h.readyCount < readyHistorySize {
+		h.readyCount++
+	}
+	h.mu.Unlock()
+}
+
+func (h *configHealth) recordFlush(now time.Time, err error) {
+	h.mu.Lock()
+	if err == nil {
+		h.lastFlush = now
+	} else {
+		h.lastFailure = now
+		h.lastError = err.Error()
+	}
+	h.mu.Unlock()
+}
+
+var (
+	recentPanics     []PanicRecord
+	recentPanicsLock sync.Mutex
+)
+
+func recordPanic(plugin string, err interface{}) {
+	recentPanicsLock.Lock()
+	defer recentPanicsLock.Unlock()
+	if len(recentPanics) >= maxPanicRecords {
+		recentPanics = recentPanics[1:]
+	}
+	recentPanics = append(recentPanics, PanicRecord{Time: time.Now(), Plugin: plugin, Error: fmt.Sprint(err)})
+}
+
+func panicsSince(since time.Time) []PanicRecord {
+	recentPanicsLock.Lock()
+	defer recentPanicsLock.Unlock()
+	var panics []PanicRecord
+	for _, p := range recentPanics {
+		if !p.Time.Before(since) {
+			panics = append(panics, p)
+		}
+	}
+	return panics
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// configStatus checks the state of the config against the thresholds, the problems are reported in the status.
+func configStatus(lc *LogstoreConfig, now time.Time, thresholds HealthThresholds) ConfigStatus {
+	runner := lc.PluginRunner.Status()
+	status := ConfigStatus{
+		Name:            lc.ConfigName,
+		Inputs:          runner.inputs,
+		InputQueueRatio: ratio(runner.inputQueueLen, runner.inputQueueCap),
+		FlushQueueRatio: ratio(runner.flushQueueLen, runner.flushQueueCap),
+	}
+	h := &lc.health
+	h.mu.Lock()
+	ready := 0
+	for i := 0; i < h.readyCount; i++ {
+		if h.readyHistory[i] {
+			ready++
+		}
+	}
+	readyCount := h.readyCount
+	started, lastFlush, lastFailure, lastError := h.started, h.lastFlush, h.lastFailure, h.lastError
+	h.mu.Unlock()
+
+	status.FlusherReadyRatio = 1
+	if readyCount > 0 {
+		status.FlusherReadyRatio = ratio(ready, readyCount)
+	}
+	if !lastFlush.IsZero() {
+		status.LastFlushTime = lastFlush.Format(time.RFC3339)
+	}
+	if lastFailure.After(lastFlush) {
+		status.LastFlushError = lastError
+	}
+	status.PluginRestarts, status.DegradedPlugins = lc.supervisor.status()
+
+	if status.InputQueueRatio > thresholds.MaxQueueRatio {
+		status.Problems = append(status.Problems, fmt.Sprintf("input queue ratio %.2f exceeds %.2f", status.InputQueueRatio, thresholds.MaxQueueRatio))
+	}
+	if status.FlushQueueRatio > thresholds.MaxQueueRatio {
+		status.Problems = append(status.Problems, fmt.Sprintf("flush queue ratio %.2f exceeds %.2f", status.FlushQueueRatio, thresholds.MaxQueueRatio))
+	}
+	if readyCount >= minReadyChecks && status.FlusherReadyRatio < thresholds.MinReadyRatio {
+		status.Problems = append(status.Problems, fmt.Sprintf("flusher ready ratio %.2f is below %.2f", status.FlusherReadyRatio, thresholds.MinReadyRatio))
+	}
+	for _, plugin := range status.DegradedPlugins {
+		status.Problems = append(status.Problems, fmt.Sprintf("plugin %s is degraded by repeated crashes", plugin))
+	}
+	// the data is pending when the flush queue is not empty or the last flush failed.
+	if runner.flushQueueLen > 0 || lastFailure.After(lastFlush) {
+		since := started
+		if lastFlush.After(since) {
+			since = lastFlush
+		}
+		if !since.IsZero() && now.Sub(since) > thresholds.MaxFlushDelay {
+			status.Problems = append(status.Problems, fmt.Sprintf("no successful flush in %v", now.Sub(since).Truncate(time.Second)))
+		}
+	}
+	return status
+}
+
+// checkHealth reports the panics in the window, and the process is unhealthy when they exceed the threshold.
+func checkHealth(now time.Time, thresholds HealthThresholds) HealthReport {
+	report := HealthReport{Status: healthStatusOK, Panics: panicsSince(now.Add(-thresholds.PanicWindow))}
+	if len(report.Panics) > thresholds.MaxPanics {
+		report.Status = healthStatusFail
+		report.Problems = append(report.Problems, fmt.Sprintf("%d panics in %v exceed %d", len(report.Panics), thresholds.PanicWindow, thresholds.MaxPanics))
+	}
+	return report
+}
+
+// checkReady reports the state of the running configs, and the process is not ready when the configs are
+// being loaded or any of them has problems.
+func checkReady(now time.Time, thresholds HealthThresholds) HealthReport {
+	report := HealthReport{Status: healthStatusOK}
+	configs := getRunningConfigs()
+	if configs == nil {
+		report.Status = healthStatusFail
+		report.Problems = append(report.Problems, "configs are being loaded")
+		return report
+	}
+	for _, lc := range configs {
+		status := configStatus(lc, now, thresholds)
+		if len(status.Problems) > 0 {
+			report.Status = healthStatusFail
+		}
+		report.Configs = append(report.Configs, status)
+	}
+	return report
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != healthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// HandleHealthz reports whether the process is healthy, it responds 503 when the panics caught in the plugins
+// exceed the threshold.
+func HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, checkHealth(time.Now(), healthThresholdsFromFlags()))
+}
+
+// HandleReadyz reports the state of the running configs, it responds 503 when the configs are being loaded or
+// any of them exceeds the thresholds or is degraded.
+func HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, checkReady(time.Now(), healthThresholdsFromFlags()))
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+)
+
+var testHealthThresholds = HealthThresholds{
+	MaxQueueRatio: 0.9,
+	MaxFlushDelay: time.Minute,
+	MinReadyRatio: 0.5,
+	MaxPanics:     1,
+	PanicWindow:   time.Minute,
+}
+
+func newHealthTestConfig(t *testing.T, name string) *LogstoreConfig {
+	lc := &LogstoreConfig{ConfigName: name}
+	lc.PluginRunner = &pluginv1Runner{LogstoreConfig: lc, FlushOutStore: NewFlushOutStore[protocol.LogGroup]()}
+	require.NoError(t, lc.PluginRunner.Init(2, 4))
+	return lc
+}
+
+func TestConfigStatus(t *testing.T) {
+	now := time.Now()
+	lc := newHealthTestConfig(t, "c")
+	lc.health.start(now.Add(-time.Hour))
+	status := configStatus(lc, now, testHealthThresholds)
+	assert.Equal(t, ConfigStatus{Name: "c", FlusherReadyRatio: 1}, status)
+
+	runner := lc.PluginRunner.(*pluginv1Runner)
+	runner.LogsChan <- &pipeline.LogWithContext{}
+	runner.LogsChan <- &pipeline.LogWithContext{}
+	runner.LogGroupsChan <- &protocol.LogGroup{}
+	for i := 0; i < minReadyChecks; i++ {
+		lc.health.recordReady(i%4 == 0)
+	}
+	lc.health.recordFlush(now.Add(-2*time.Minute), nil)
+	lc.health.recordFlush(now.Add(-time.Minute), errors.New("unauthorized"))
+	status = configStatus(lc, now, testHealthThresholds)
+	assert.Equal(t, 1.0, status.InputQueueRatio)
+	assert.Equal(t, 0.25, status.FlushQueueRatio)
+	assert.Equal(t, 0.3, status.FlusherReadyRatio)
+	assert.Equal(t, now.Add(-2*time.Minute).Format(time.RFC3339), status.LastFlushTime)
+	assert.Equal(t, "unauthorized", status.LastFlushError)
+	assert.Equal(t, []string{
+		"input queue ratio 1.00 exceeds 0.90",
+		"flusher ready ratio 0.30 is below 0.50",
+		"no successful flush in 2m0s",
+	}, status.Problems)
+
+	// the delay is not checked when nothing is pending.
+	<-runner.LogGroupsChan
+	lc.health.recordFlush(now.Add(-30*time.Second), nil)
+	status = configStatus(lc, now, testHealthThresholds)
+	assert.Empty(t, status.LastFlushError)
+	assert.Equal(t, []string{
+		"input queue ratio 1.00 exceeds 0.90",
+		"flusher ready ratio 0.30 is below 0.50",
+	}, status.Problems)
+}
+
+func TestCheckHealth(t *testing.T) {
+	recentPanicsLock.Lock()
+	recentPanics = nil
+	recentPanicsLock.Unlock()
+	report := checkHealth(time.Now(), testHealthThresholds)
+	assert.Equal(t, HealthReport{Status: healthStatusOK}, report)
+
+	func() {
+		defer panicRecover("processor_test")
+		panic("boom")
+	}()
+	report = checkHealth(time.Now(), testHealthThresholds)
+	assert.Equal(t, healthStatusOK, report.Status)
+	require.Len(t, report.Panics, 1)
+	assert.Equal(t, "processor_test", report.Panics[0].Plugin)
+	assert.Equal(t, "boom", report.Panics[0].Error)
+
+	recordPanic("processor_test", "boom")
+	report = checkHealth(time.Now(), testHealthThresholds)
+	assert.Equal(t, healthStatusFail, report.Status)
+	assert.Equal(t, []string{"2 panics in 1m0s exceed 1"}, report.Problems)
+	// the panics out of the window are ignored.
+	report = checkHealth(time.Now().Add(2*time.Minute), testHealthThresholds)
+	assert.Equal(t, healthStatusOK, report.Status)
+}
+
+func TestHandleReadyz(t *testing.T) {
+	readyz := func() (int, HealthReport) {
+		w := httptest.NewRecorder()
+		HandleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var report HealthReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return w.Code, report
+	}
+
+	setRunningConfigs(nil)
+	code, report := readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"configs are being loaded"}, report.Problems)
+
+	lc := newHealthTestConfig(t, "c")
+	setRunningConfigs(map[string]*LogstoreConfig{"c": lc})
+	defer setRunningConfigs(nil)
+	code, report = readyz()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, healthStatusOK, report.Status)
+	require.Len(t, report.Configs, 1)
+	assert.Equal(t, "c", report.Configs[0].Name)
+
+	for i := 0; i < minReadyChecks; i++ {
+		lc.health.recordReady(false)
+	}
+	code, report = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, healthStatusFail, report.Status)
+	assert.Equal(t, []string{"flusher ready ratio 0.00 is below 0.50"}, report.Configs[0].Problems)
+}
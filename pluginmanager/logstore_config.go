@@ -24,6 +24,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/alibaba/ilogtail/pkg/config"
 	"github.com/alibaba/ilogtail/pkg/helper"
@@ -78,6 +79,19 @@ type LogstoreStatistics struct {
 	FlushLogGroupMetric  pipeline.CounterMetric
 	FlushReadyMetric     pipeline.CounterMetric
 	FlushLatencyMetric   pipeline.LatencyMetric
+	// ThrottledEventsMetric and ThrottledBytesMetric count the events over the rate limits of the config,
+	// they are dropped, sampled or delayed according to the policy.
+	ThrottledEventsMetric pipeline.CounterMetric
+	ThrottledBytesMetric  pipeline.CounterMetric
+	// The pipeline metrics are the histograms in seconds: the time the groups wait in the flush queue, the time from
+	// the ingestion to handed to the aggregators, the time to flush, and the age of the events when they are flushed.
+	// All but the processing time are labeled by the flusher.
+	PipelineQueueWaitMetric pipeline.HistogramVec
+	PipelineProcessMetric   pipeline.HistogramVec
+	PipelineFlushMetric     pipeline.HistogramVec
+	EventAgeMetric          pipeline.HistogramVec
+	// PluginRestartMetric counts the restarts of the crashed plugin goroutines labeled by the plugin.
+	PluginRestartMetric pipeline.CounterVec
 }
 
 type ConfigVersion string
@@ -112,6 +126,9 @@ type LogstoreConfig struct {
 	// processWaitSema  sync.WaitGroup
 	// flushWaitSema    sync.WaitGroup
 	pauseOrResumeWg sync.WaitGroup
+	rateLimiter     *rateLimiter
+	health          configHealth
+	supervisor      *pluginSupervisor
 
 	K8sLabelSet           map[string]struct{}
 	ContainerLabelSet     map[string]struct{}
@@ -127,6 +144,13 @@ func (p *LogstoreStatistics) Init(context pipeline.Context) {
 	p.FlushLogGroupMetric = helper.NewCounterMetric("flush_loggroup")
 	p.FlushReadyMetric = helper.NewAverageMetric("flush_ready")
 	p.FlushLatencyMetric = helper.NewLatencyMetric("flush_latency")
+	p.ThrottledEventsMetric = helper.NewCounterMetric("throttled_events")
+	p.ThrottledBytesMetric = helper.NewCounterMetric("throttled_bytes")
+	p.PipelineQueueWaitMetric = helper.NewHistogramVec("pipeline_queue_wait_seconds", []string{"flusher"}, helper.LatencyBuckets, 0)
+	p.PipelineProcessMetric = helper.NewHistogramVec("pipeline_process_seconds", nil, helper.LatencyBuckets, 0)
+	p.PipelineFlushMetric = helper.NewHistogramVec("pipeline_flush_seconds", []string{"flusher"}, helper.LatencyBuckets, 0)
+	p.EventAgeMetric = helper.NewHistogramVec("event_age_seconds", []string{"flusher"}, EventAgeBuckets, 0)
+	p.PluginRestartMetric = helper.NewCounterVec("plugin_restarts", []string{"plugin_name"}, 0)
 
 	context.RegisterLatencyMetric(p.CollecLatencytMetric)
 	context.RegisterCounterMetric(p.RawLogMetric)
@@ -135,6 +159,13 @@ func (p *LogstoreStatistics) Init(context pipeline.Context) {
 	context.RegisterCounterMetric(p.FlushLogGroupMetric)
 	context.RegisterCounterMetric(p.FlushReadyMetric)
 	context.RegisterLatencyMetric(p.FlushLatencyMetric)
+	context.RegisterCounterMetric(p.ThrottledEventsMetric)
+	context.RegisterCounterMetric(p.ThrottledBytesMetric)
+	context.RegisterHistogramVec(p.PipelineQueueWaitMetric)
+	context.RegisterHistogramVec(p.PipelineProcessMetric)
+	context.RegisterHistogramVec(p.PipelineFlushMetric)
+	context.RegisterHistogramVec(p.EventAgeMetric)
+	context.RegisterCounterVec(p.PluginRestartMetric)
 }
 
 // Start initializes plugin instances in config and starts them.
@@ -151,6 +182,7 @@ func (lc *LogstoreConfig) Start() {
 
 	lc.pauseChan = make(chan struct{}, 1)
 	lc.resumeChan = make(chan struct{}, 1)
+	lc.health.start(time.Now())
 
 	lc.PluginRunner.Run()
 
@@ -338,6 +370,10 @@ func hasDockerStdoutInput(plugins map[string]interface{}) bool {
 
 var enableAlwaysOnlineForStdout = true
 
+func configDetailHash(jsonStr string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(jsonStr))) //nolint:gosec
+}
+
 func createLogstoreConfig(project string, logstore string, configName string, logstoreKey int64, jsonStr string) (*LogstoreConfig, error) {
 	var err error
 	contextImp := &ContextImp{}
@@ -348,7 +384,7 @@ func createLogstoreConfig(project string, logstore string, configName string, lo
 		ConfigName:       configName,
 		LogstoreKey:      logstoreKey,
 		Context:          contextImp,
-		configDetailHash: fmt.Sprintf("%x", md5.Sum([]byte(jsonStr))), //nolint:gosec
+		configDetailHash: configDetailHash(jsonStr),
 	}
 	contextImp.logstoreC = logstoreC
 
@@ -494,6 +530,10 @@ func createLogstoreConfig(project string, logstore string, configName string, lo
 	}
 
 	logstoreC.Statistics.Init(logstoreC.Context)
+	logstoreC.supervisor = newPluginSupervisor(logstoreC, &logstoreC.GlobalConfig.PluginRestart)
+	if logstoreC.rateLimiter, err = newRateLimiter(&logstoreC.GlobalConfig.RateLimit, &logstoreC.Statistics); err != nil {
+		return nil, err
+	}
 
 	// extensions should be initialized first
 	pluginConfig, ok := plugins["extensions"]
@@ -677,7 +717,9 @@ func initPluginRunner(lc *LogstoreConfig) (PluginRunner, error) {
 func LoadLogstoreConfig(project string, logstore string, configName string, logstoreKey int64, jsonStr string) error {
 	if len(jsonStr) == 0 {
 		logger.Info(context.Background(), "delete config", configName, "logstore", logstore)
+		LogtailConfigLock.Lock()
 		delete(LogtailConfig, configName)
+		LogtailConfigLock.Unlock()
 		return nil
 	}
 	logger.Info(context.Background(), "load config", configName, "logstore", logstore)
@@ -685,7 +727,9 @@ func LoadLogstoreConfig(project string, logstore string, configName string, logs
 	if err != nil {
 		return err
 	}
+	LogtailConfigLock.Lock()
 	LogtailConfig[configName] = logstoreC
+	LogtailConfigLock.Unlock()
 	return nil
 }
 
@@ -700,6 +744,7 @@ func loadBuiltinConfig(name string, project string, logstore string,
 // @logstoreConfig: where to store the created metric plugin object.
 // It returns any error encountered.
 func loadMetric(pluginType string, logstoreConfig *LogstoreConfig, configInterface interface{}) (err error) {
+	defer bindPluginMetrics(logstoreConfig, pluginType)()
 	creator, existFlag := pipeline.MetricInputs[getPluginType(pluginType)]
 	if !existFlag || creator == nil {
 		return fmt.Errorf("can't find plugin %s", pluginType)
@@ -732,6 +777,7 @@ func loadMetric(pluginType string, logstoreConfig *LogstoreConfig, configInterfa
 // @logstoreConfig: where to store the created service plugin object.
 // It returns any error encountered.
 func loadService(pluginType string, logstoreConfig *LogstoreConfig, configInterface interface{}) (err error) {
+	defer bindPluginMetrics(logstoreConfig, pluginType)()
 	creator, existFlag := pipeline.ServiceInputs[getPluginType(pluginType)]
 	if !existFlag || creator == nil {
 		return fmt.Errorf("can't find plugin %s", pluginType)
@@ -747,6 +793,7 @@ func loadService(pluginType string, logstoreConfig *LogstoreConfig, configInterf
 }
 
 func loadProcessor(pluginType string, priority int, logstoreConfig *LogstoreConfig, configInterface interface{}) (err error) {
+	defer bindPluginMetrics(logstoreConfig, pluginType)()
 	creator, existFlag := pipeline.Processors[getPluginType(pluginType)]
 	if !existFlag || creator == nil {
 		logger.Error(logstoreConfig.Context.GetRuntimeContext(), "INVALID_PROCESSOR_TYPE", "invalid processor type, maybe type is wrong or logtail version is too old", pluginType)
@@ -763,6 +810,7 @@ func loadProcessor(pluginType string, priority int, logstoreConfig *LogstoreConf
 }
 
 func loadAggregator(pluginType string, logstoreConfig *LogstoreConfig, configInterface interface{}) (err error) {
+	defer bindPluginMetrics(logstoreConfig, pluginType)()
 	creator, existFlag := pipeline.Aggregators[getPluginType(pluginType)]
 	if !existFlag || creator == nil {
 		logger.Error(logstoreConfig.Context.GetRuntimeContext(), "INVALID_AGGREGATOR_TYPE", "invalid aggregator type, maybe type is wrong or logtail version is too old", pluginType)
@@ -776,6 +824,7 @@ func loadAggregator(pluginType string, logstoreConfig *LogstoreConfig, configInt
 }
 
 func loadFlusher(pluginType string, logstoreConfig *LogstoreConfig, configInterface interface{}) (err error) {
+	defer bindPluginMetrics(logstoreConfig, pluginType)()
 	creator, existFlag := pipeline.Flushers[getPluginType(pluginType)]
 	if !existFlag || creator == nil {
 		return fmt.Errorf("can't find plugin %s", pluginType)
@@ -791,6 +840,7 @@ func loadFlusher(pluginType string, logstoreConfig *LogstoreConfig, configInterf
 }
 
 func loadExtension(pluginType string, logstoreConfig *LogstoreConfig, configInterface interface{}) (err error) {
+	defer bindPluginMetrics(logstoreConfig, pluginType)()
 	creator, existFlag := pipeline.Extensions[getPluginType(pluginType)]
 	if !existFlag || creator == nil {
 		return fmt.Errorf("can't find plugin %s", pluginType)
@@ -805,6 +855,14 @@ func loadExtension(pluginType string, logstoreConfig *LogstoreConfig, configInte
 	return logstoreConfig.PluginRunner.AddPlugin(pluginType, pluginExtension, extension, map[string]interface{}{})
 }
 
+// bindPluginMetrics labels the self metrics registered while loading the plugin with the plugin id.
+func bindPluginMetrics(logstoreConfig *LogstoreConfig, pluginType string) func() {
+	if contextImp, ok := logstoreConfig.Context.(*ContextImp); ok {
+		return contextImp.bindPlugin(pluginType)
+	}
+	return func() {}
+}
+
 func applyPluginConfig(plugin interface{}, pluginConfig interface{}) error {
 	config, err := json.Marshal(pluginConfig)
 	if err != nil {
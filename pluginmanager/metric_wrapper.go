@@ -25,6 +25,8 @@ import (
 )
 
 type MetricWrapper struct {
+	// Name is the plugin name with the optional ID, which labels the restarts of the plugin.
+	Name     string
 	Input    pipeline.MetricInputV1
 	Config   *LogstoreConfig
 	Tags     map[string]string
@@ -36,12 +38,13 @@ type MetricWrapper struct {
 
 func (p *MetricWrapper) Run(control *pipeline.AsyncControl) {
 	logger.Info(p.Config.Context.GetRuntimeContext(), "start run metric ", p.Input.Description())
-	defer panicRecover(p.Input.Description())
 	for {
 		exitFlag := util.RandomSleep(p.Interval, 0.1, control.CancelToken())
+		span := p.Config.startSpan(spanInput, p.Name, unknownGroupSize)
 		p.LatencyMetric.Begin()
 		err := p.Input.Collect(p)
 		p.LatencyMetric.End()
+		span.finish(err)
 		if err != nil {
 			logger.Error(p.Config.Context.GetRuntimeContext(), "INPUT_COLLECT_ALARM", "error", err)
 		}
@@ -51,6 +54,14 @@ func (p *MetricWrapper) Run(control *pipeline.AsyncControl) {
 	}
 }
 
+// reinit re-initializes the input when it is restarted, the interval is kept.
+func (p *MetricWrapper) reinit() error {
+	return reinitPlugin(p.Config, p.Name, nil, func() error {
+		_, err := p.Input.Init(p.Config.Context)
+		return err
+	})
+}
+
 func (p *MetricWrapper) AddData(tags map[string]string, fields map[string]string, t ...time.Time) {
 	p.AddDataWithContext(tags, fields, nil, t...)
 }
@@ -0,0 +1,145 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+// EventAgeBuckets are the upper bounds in seconds of the histogram buckets of the event age,
+// which is much longer than the latencies inside the pipeline when the collection is delayed.
+var EventAgeBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600}
+
+// pipelineTracker stamps the groups with the time they are put into the flush queue, so that the flushers can
+// measure how long the groups wait in the queue. Each group is stamped by itself when it is queued, the aggregators
+// may output groups at any time and from any goroutine.
+type pipelineTracker struct {
+	mu     sync.Mutex
+	groups map[interface{}]time.Time
+}
+
+func newPipelineTracker() *pipelineTracker {
+	return &pipelineTracker{groups: make(map[interface{}]time.Time)}
+}
+
+// enqueue stamps the groups which are going to be put into the flush queue.
+func (t *pipelineTracker) enqueue(groups ...interface{}) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, group := range groups {
+		t.groups[group] = now
+	}
+}
+
+// cancel forgets the group failed to be put into the flush queue.
+func (t *pipelineTracker) cancel(group interface{}) {
+	t.mu.Lock()
+	delete(t.groups, group)
+	t.mu.Unlock()
+}
+
+// dequeue returns and forgets the enqueue time of the group taken from the flush queue. The groups restored from
+// the flush out store of the previous runner are not stamped.
+func (t *pipelineTracker) dequeue(group interface{}) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	enqueued, ok := t.groups[group]
+	if ok {
+		delete(t.groups, group)
+	}
+	return enqueued, ok
+}
+
+// reset forgets all the groups, such as the ones moved to the flush out store when the runner stops.
+func (t *pipelineTracker) reset() {
+	t.mu.Lock()
+	t.groups = make(map[interface{}]time.Time)
+	t.mu.Unlock()
+}
+
+// trackedCollector stamps the groups collected into the flush queue by the v2 aggregators.
+type trackedCollector struct {
+	pipeline.PipelineCollector
+	tracker *pipelineTracker
+}
+
+func (c *trackedCollector) Collect(group *models.GroupInfo, events ...models.PipelineEvent) {
+	if len(events) == 0 {
+		return
+	}
+	c.CollectList(&models.PipelineGroupEvents{Group: group, Events: events})
+}
+
+// CollectList stamps the non-empty groups, the empty ones are skipped by the flusher runner and never dequeued.
+func (c *trackedCollector) CollectList(groups ...*models.PipelineGroupEvents) {
+	if len(groups) == 0 {
+		return
+	}
+	keys := make([]interface{}, 0, len(groups))
+	for _, group := range groups {
+		if len(group.Events) != 0 {
+			keys = append(keys, group)
+		}
+	}
+	if len(keys) != 0 {
+		c.tracker.enqueue(keys...)
+	}
+	c.PipelineCollector.CollectList(groups...)
+}
+
+type trackedPipelineContext struct {
+	collector *trackedCollector
+}
+
+func (p *trackedPipelineContext) Collector() pipeline.PipelineCollector {
+	return p.collector
+}
+
+func newTrackedPipelineContext(context pipeline.PipelineContext, tracker *pipelineTracker) pipeline.PipelineContext {
+	return &trackedPipelineContext{collector: &trackedCollector{PipelineCollector: context.Collector(), tracker: tracker}}
+}
+
+// recordProcess records the time a group of events takes from taken from the input queue at ingested to handed to
+// the aggregators. Each group carries its own ingestion time through the processors.
+func (p *LogstoreStatistics) recordProcess(ingested time.Time) {
+	p.PipelineProcessMetric.WithLabelValues().Observe(time.Since(ingested).Seconds())
+}
+
+// recordFlush records the latencies of the groups sent by the flusher from begin to end: the queue wait is from the
+// groups put into the flush queue at enqueueTimes to taken by the flusher at dequeued, and the event age is from the
+// timestamps of the events in nanoseconds to end.
+func (p *LogstoreStatistics) recordFlush(flusher string, dequeued time.Time, enqueueTimes []time.Time, begin, end time.Time, eventTimes []int64) {
+	queueWait := p.PipelineQueueWaitMetric.WithLabelValues(flusher)
+	for _, enqueued := range enqueueTimes {
+		queueWait.Observe(dequeued.Sub(enqueued).Seconds())
+	}
+	p.PipelineFlushMetric.WithLabelValues(flusher).Observe(end.Sub(begin).Seconds())
+	age := p.EventAgeMetric.WithLabelValues(flusher)
+	endNano := end.UnixNano()
+	for _, eventTime := range eventTimes {
+		if eventTime <= 0 {
+			continue
+		}
+		if eventTime > endNano {
+			eventTime = endNano
+		}
+		age.Observe(float64(endNano-eventTime) / float64(time.Second))
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+)
+
+func TestPipelineTracker(t *testing.T) {
+	tracker := newPipelineTracker()
+	g1, g2, g3 := &protocol.LogGroup{}, &protocol.LogGroup{}, &protocol.LogGroup{}
+	before := time.Now()
+	tracker.enqueue(g1, g2)
+	// the groups are stamped by themselves, queuing one does not affect the others.
+	time.Sleep(time.Millisecond)
+	tracker.enqueue(g3)
+
+	enqueued, ok := tracker.dequeue(g1)
+	require.True(t, ok)
+	assert.False(t, enqueued.Before(before))
+	_, ok = tracker.dequeue(g1)
+	assert.False(t, ok)
+	enqueued3, ok := tracker.dequeue(g3)
+	require.True(t, ok)
+	assert.True(t, enqueued3.After(enqueued))
+
+	// the group failed to be queued is forgotten.
+	tracker.enqueue(g3)
+	tracker.cancel(g3)
+	_, ok = tracker.dequeue(g3)
+	assert.False(t, ok)
+
+	tracker.reset()
+	_, ok = tracker.dequeue(g2)
+	assert.False(t, ok)
+}
+
+func TestTrackedPipelineContext(t *testing.T) {
+	tracker := newPipelineTracker()
+	context := newTrackedPipelineContext(pipeline.NewObservePipelineConext(10), tracker)
+	context.Collector().Collect(models.NewGroup(models.NewMetadata(), models.NewTags()))
+	context.Collector().Collect(models.NewGroup(models.NewMetadata(), models.NewTags()), models.NewLog("a", nil, "", "", "", models.NewTags(), 0))
+	context.Collector().CollectList(&models.PipelineGroupEvents{})
+
+	groups := context.Collector().ToArray()
+	require.Len(t, groups, 2)
+	// the empty group is not stamped, as the flusher runner never dequeues it.
+	_, ok := tracker.dequeue(groups[1])
+	assert.False(t, ok)
+	_, ok = tracker.dequeue(groups[0])
+	assert.True(t, ok)
+	assert.Empty(t, tracker.groups)
+}
+
+func TestAggregatorWrapperTracksLogGroups(t *testing.T) {
+	tracker := newPipelineTracker()
+	wrapper := &AggregatorWrapper{LogGroupsChan: make(chan *protocol.LogGroup, 1), tracker: tracker}
+	g1 := &protocol.LogGroup{Logs: []*protocol.Log{{}}}
+	g2 := &protocol.LogGroup{Logs: []*protocol.Log{{}}}
+	require.NoError(t, wrapper.Add(g1))
+	require.Error(t, wrapper.Add(g2))
+	require.NoError(t, wrapper.Add(&protocol.LogGroup{}))
+
+	_, ok := tracker.dequeue(<-wrapper.LogGroupsChan)
+	assert.True(t, ok)
+	_, ok = tracker.dequeue(g2)
+	assert.False(t, ok)
+}
+
+func TestRecordFlush(t *testing.T) {
+	stats := newTestStatistics()
+	end := time.Now()
+	begin := end.Add(-20 * time.Millisecond)
+	dequeued := begin.Add(-10 * time.Millisecond)
+	enqueueTimes := []time.Time{dequeued.Add(-2 * time.Second)}
+	eventTimes := []int64{end.Add(-time.Minute).UnixNano(), end.Add(time.Minute).UnixNano(), 0}
+	stats.recordFlush("flusher_sls/2", dequeued, enqueueTimes, begin, end, eventTimes)
+	stats.recordProcess(time.Now().Add(-time.Second))
+
+	histogram := func(vec pipeline.HistogramVec) (uint64, float64) {
+		count, sum, _ := vec.WithLabelValues("flusher_sls/2").(helper.HistogramReader).Histogram()
+		return count, sum
+	}
+	count, sum := histogram(stats.PipelineQueueWaitMetric)
+	assert.Equal(t, uint64(1), count)
+	assert.InDelta(t, 2, sum, 1e-6)
+	count, sum, _ = stats.PipelineProcessMetric.WithLabelValues().(helper.HistogramReader).Histogram()
+	assert.Equal(t, uint64(1), count)
+	assert.InDelta(t, 1, sum, 0.1)
+	count, sum = histogram(stats.PipelineFlushMetric)
+	assert.Equal(t, uint64(1), count)
+	assert.InDelta(t, 0.02, sum, 1e-6)
+	// the events without timestamps are ignored, and the ones in the future are of age 0.
+	count, sum = histogram(stats.EventAgeMetric)
+	assert.Equal(t, uint64(2), count)
+	assert.InDelta(t, 60, sum, 1e-6)
+}
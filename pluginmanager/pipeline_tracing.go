@@ -0,0 +1,302 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/alibaba/ilogtail/pkg/config"
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/util"
+)
+
+// The names of the spans of the pipeline stages, the processor and flusher spans are the children of the process
+// and flush spans for each plugin. The input span of a service input covers a run of the service from its start to
+// its return.
+//
+// The spans of the stages are standalone, each of them starts a new trace. The events collected by many input runs
+// are merged into one group by the aggregators, so a group has no single input span to be linked to.
+const (
+	spanInput     = "input"
+	spanProcess   = "process"
+	spanProcessor = "processor"
+	spanAggregate = "aggregate"
+	spanFlush     = "flush"
+	spanFlusher   = "flusher"
+)
+
+// unknownGroupSize is the group size of the spans not knowing the number of the events, which is not exported.
+const unknownGroupSize = -1
+
+// The attributes of the spans.
+const (
+	attrConfig    = "config.name"
+	attrPlugin    = "plugin.name"
+	attrGroupSize = "group.size"
+)
+
+const tracingScope = "github.com/alibaba/ilogtail/pluginmanager"
+
+// TraceExporter exports the traces of the pipeline spans.
+type TraceExporter interface {
+	Export(traces ptrace.Traces) error
+	Close() error
+}
+
+// TraceExporterFactory creates the exporter of the pipeline spans, which is set to the OTLP trace exporter of
+// flusher_otlp by plugin_main. The tracing is disabled when it is nil.
+var TraceExporterFactory func(grpcConfig *helper.GrpcClientConfig) (TraceExporter, error)
+
+// pipelineTracer buffers the spans of the sampled runs of the pipeline stages, and exports them periodically.
+type pipelineTracer struct {
+	exporter TraceExporter
+	interval time.Duration
+	maxSpans int
+
+	mu      sync.Mutex
+	spans   []*stageSpan
+	dropped int
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// pipelineTracing is the tracer shared by the configs, nil when the tracing is not configured.
+var pipelineTracing atomic.Pointer[pipelineTracer]
+
+// stageSpan is a span of a run of a pipeline stage or a plugin in it. The nil span is not sampled, and all of its
+// methods are no-op.
+type stageSpan struct {
+	tracer    *pipelineTracer
+	traceID   pcommon.TraceID
+	spanID    pcommon.SpanID
+	parentID  pcommon.SpanID
+	name      string
+	config    string
+	plugin    string
+	groupSize int
+	start     time.Time
+	end       time.Time
+	err       error
+}
+
+// initPipelineTracer replaces the tracer with the one configured by the process global config.
+func initPipelineTracer(cfg *config.TracingConfig) {
+	if old := pipelineTracing.Swap(nil); old != nil {
+		old.stop()
+	}
+	if cfg.Endpoint == "" || TraceExporterFactory == nil {
+		return
+	}
+	exporter, err := TraceExporterFactory(&helper.GrpcClientConfig{
+		Endpoint:    cfg.Endpoint,
+		Headers:     cfg.Headers,
+		Compression: cfg.Compression,
+		Timeout:     cfg.TimeoutMs,
+	})
+	if err != nil {
+		logger.Warning(context.Background(), "PIPELINE_TRACING_ALARM", "init pipeline tracing exporter error", err, "endpoint", cfg.Endpoint)
+		return
+	}
+	logger.Info(context.Background(), "pipeline tracing endpoint", cfg.Endpoint, "sample ratio", cfg.SampleRatio)
+	pipelineTracing.Store(newPipelineTracer(exporter, time.Duration(cfg.FlushIntervalMs)*time.Millisecond, cfg.MaxBufferedSpans))
+}
+
+func newPipelineTracer(exporter TraceExporter, interval time.Duration, maxSpans int) *pipelineTracer {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	t := &pipelineTracer{
+		exporter: exporter,
+		interval: interval,
+		maxSpans: maxSpans,
+		stopCh:   make(chan struct{}),
+	}
+	t.wg.Add(1)
+	go t.run()
+	return t
+}
+
+func (t *pipelineTracer) run() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stopCh:
+			return
+		case <-ticker.C:
+			t.flush()
+		}
+	}
+}
+
+// stop exports the buffered spans and closes the exporter.
+func (t *pipelineTracer) stop() {
+	close(t.stopCh)
+	t.wg.Wait()
+	t.flush()
+	_ = t.exporter.Close()
+}
+
+// flush exports the buffered spans.
+func (t *pipelineTracer) flush() {
+	t.mu.Lock()
+	spans, dropped := t.spans, t.dropped
+	t.spans, t.dropped = nil, 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		logger.Warning(context.Background(), "PIPELINE_TRACING_ALARM", "drop spans over max buffered spans", dropped)
+	}
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.exporter.Export(buildTraces(spans)); err != nil {
+		logger.Warning(context.Background(), "PIPELINE_TRACING_ALARM", "export pipeline spans error", err, "spans", len(spans))
+	}
+}
+
+func (t *pipelineTracer) record(span *stageSpan) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.maxSpans > 0 && len(t.spans) >= t.maxSpans {
+		t.dropped++
+		return
+	}
+	t.spans = append(t.spans, span)
+}
+
+// flushPipelineTracer exports the buffered spans before the process exits.
+func flushPipelineTracer() {
+	if t := pipelineTracing.Load(); t != nil {
+		t.flush()
+	}
+}
+
+// startSpan starts the span of a run of the stage in a new trace if it is sampled by the sample ratio of the config,
+// it returns nil otherwise.
+func (lc *LogstoreConfig) startSpan(name, plugin string, groupSize int) *stageSpan {
+	t := pipelineTracing.Load()
+	if t == nil || lc.GlobalConfig == nil {
+		return nil
+	}
+	if ratio := lc.GlobalConfig.Tracing.SampleRatio; ratio <= 0 || (ratio < 1 && rand.Float64() >= ratio) { //nolint:gosec
+		return nil
+	}
+	span := &stageSpan{
+		tracer:    t,
+		spanID:    newSpanID(),
+		name:      name,
+		config:    lc.ConfigName,
+		plugin:    plugin,
+		groupSize: groupSize,
+		start:     time.Now(),
+	}
+	binary.BigEndian.PutUint64(span.traceID[:8], rand.Uint64()) //nolint:gosec
+	binary.BigEndian.PutUint64(span.traceID[8:], rand.Uint64()) //nolint:gosec
+	return span
+}
+
+// child starts the span of a plugin in the stage of the span.
+func (s *stageSpan) child(name, plugin string, groupSize int) *stageSpan {
+	if s == nil {
+		return nil
+	}
+	return &stageSpan{
+		tracer:    s.tracer,
+		traceID:   s.traceID,
+		spanID:    newSpanID(),
+		parentID:  s.spanID,
+		name:      name,
+		config:    s.config,
+		plugin:    plugin,
+		groupSize: groupSize,
+		start:     time.Now(),
+	}
+}
+
+func newSpanID() (id pcommon.SpanID) {
+	binary.BigEndian.PutUint64(id[:], rand.Uint64()) //nolint:gosec
+	return
+}
+
+// setGroupSize sets the number of the events or the groups handled in the span when known after the run.
+func (s *stageSpan) setGroupSize(groupSize int) {
+	if s != nil {
+		s.groupSize = groupSize
+	}
+}
+
+// finish ends the span, the error marks the status of the span.
+func (s *stageSpan) finish(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	s.tracer.record(s)
+}
+
+// countEvents returns the number of the events in the groups, which is the group size of the processor spans.
+func countEvents(groups []*models.PipelineGroupEvents) int {
+	count := 0
+	for _, group := range groups {
+		count += len(group.Events)
+	}
+	return count
+}
+
+// buildTraces converts the spans to the OTLP traces.
+func buildTraces(spans []*stageSpan) ptrace.Traces {
+	traces := ptrace.NewTraces()
+	resourceSpans := traces.ResourceSpans().AppendEmpty()
+	resource := resourceSpans.Resource().Attributes()
+	resource.PutStr("service.name", "ilogtail")
+	resource.PutStr("host.name", util.GetHostName())
+	resource.PutStr("host.ip", util.GetIPAddress())
+	scopeSpans := resourceSpans.ScopeSpans().AppendEmpty()
+	scopeSpans.Scope().SetName(tracingScope)
+	for _, s := range spans {
+		span := scopeSpans.Spans().AppendEmpty()
+		span.SetTraceID(s.traceID)
+		span.SetSpanID(s.spanID)
+		span.SetParentSpanID(s.parentID)
+		span.SetName(s.name)
+		span.SetKind(ptrace.SpanKindInternal)
+		span.SetStartTimestamp(pcommon.NewTimestampFromTime(s.start))
+		span.SetEndTimestamp(pcommon.NewTimestampFromTime(s.end))
+		attrs := span.Attributes()
+		attrs.PutStr(attrConfig, s.config)
+		attrs.PutStr(attrPlugin, s.plugin)
+		if s.groupSize != unknownGroupSize {
+			attrs.PutInt(attrGroupSize, int64(s.groupSize))
+		}
+		if s.err != nil {
+			span.Status().SetCode(ptrace.StatusCodeError)
+			span.Status().SetMessage(s.err.Error())
+		}
+	}
+	return traces
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || windows
+// +build linux windows
+
+package pluginmanager
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/alibaba/ilogtail/pkg/config"
+	"github.com/alibaba/ilogtail/pkg/helper"
+)
+
+type mockTraceExporter struct {
+	mu     sync.Mutex
+	spans  []ptrace.Span
+	closed bool
+}
+
+func (e *mockTraceExporter) Export(traces ptrace.Traces) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := traces.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	for i := 0; i < spans.Len(); i++ {
+		e.spans = append(e.spans, spans.At(i))
+	}
+	return nil
+}
+
+func (e *mockTraceExporter) Close() error {
+	e.closed = true
+	return nil
+}
+
+func (e *mockTraceExporter) spansByName() map[string][]ptrace.Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := make(map[string][]ptrace.Span)
+	for _, span := range e.spans {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+	return spans
+}
+
+func useMockTraceExporter(t *testing.T, maxSpans int) *mockTraceExporter {
+	exporter := &mockTraceExporter{}
+	TraceExporterFactory = func(grpcConfig *helper.GrpcClientConfig) (TraceExporter, error) {
+		return exporter, nil
+	}
+	initPipelineTracer(&config.TracingConfig{Endpoint: "localhost:4317", FlushIntervalMs: 3600000, MaxBufferedSpans: maxSpans})
+	require.NotNil(t, pipelineTracing.Load())
+	t.Cleanup(func() {
+		initPipelineTracer(&config.TracingConfig{})
+		TraceExporterFactory = nil
+	})
+	return exporter
+}
+
+func TestStageSpan(t *testing.T) {
+	exporter := useMockTraceExporter(t, 3)
+	lc := &LogstoreConfig{ConfigName: "c", GlobalConfig: &config.GlobalConfig{}}
+	assert.Nil(t, lc.startSpan(spanProcess, supervisedProcessors, 1))
+	// the nil span is not sampled.
+	var nilSpan *stageSpan
+	nilSpan.child(spanProcessor, "p", 1).finish(nil)
+	nilSpan.setGroupSize(1)
+
+	lc.GlobalConfig.Tracing.SampleRatio = 1
+	span := lc.startSpan(spanFlush, supervisedFlushers, 2)
+	require.NotNil(t, span)
+	child := span.child(spanFlusher, "flusher_checker", 2)
+	child.finish(errors.New("flush error"))
+	span.finish(nil)
+	input := lc.startSpan(spanInput, "metric_mock", unknownGroupSize)
+	input.finish(nil)
+	// the spans over max buffered spans are dropped.
+	lc.startSpan(spanInput, "metric_mock", unknownGroupSize).finish(nil)
+	flushPipelineTracer()
+
+	spans := exporter.spansByName()
+	require.Len(t, spans[spanFlush], 1)
+	require.Len(t, spans[spanFlusher], 1)
+	require.Len(t, spans[spanInput], 1)
+	flush, flusher := spans[spanFlush][0], spans[spanFlusher][0]
+	assert.Equal(t, flush.TraceID(), flusher.TraceID())
+	assert.Equal(t, flush.SpanID(), flusher.ParentSpanID())
+	assert.True(t, flush.ParentSpanID().IsEmpty())
+	assert.NotEqual(t, flush.TraceID(), spans[spanInput][0].TraceID())
+	assert.Equal(t, map[string]interface{}{attrConfig: "c", attrPlugin: "flusher_checker", attrGroupSize: int64(2)}, flusher.Attributes().AsRaw())
+	assert.Equal(t, ptrace.StatusCodeError, flusher.Status().Code())
+	assert.Equal(t, "flush error", flusher.Status().Message())
+	assert.Equal(t, ptrace.StatusCodeUnset, flush.Status().Code())
+	assert.Equal(t, map[string]interface{}{attrConfig: "c", attrPlugin: "metric_mock"}, spans[spanInput][0].Attributes().AsRaw())
+	assert.False(t, flush.EndTimestamp().AsTime().Before(flush.StartTimestamp().AsTime()))
+
+	initPipelineTracer(&config.TracingConfig{})
+	assert.Nil(t, pipelineTracing.Load())
+	assert.True(t, exporter.closed)
+}
+
+func TestPipelineTracing(t *testing.T) {
+	exporter := useMockTraceExporter(t, 0)
+	// drop the configs loaded but never started by the other tests.
+	LogtailConfig = make(map[string]*LogstoreConfig)
+	require.NoError(t, Resume())
+	defer func() {
+		require.NoError(t, HoldOn(false))
+		LogtailConfig = make(map[string]*LogstoreConfig)
+	}()
+
+	results := ReloadConfigs([]*config.LoadedConfig{{
+		Project:    "test_prj",
+		Logstore:   "tracing",
+		ConfigName: "tracing",
+		JSONStr: `{
+			"global": {"AggregatIntervalMs": 100, "FlushIntervalMs": 100, "Tracing": {"SampleRatio": 1}},
+			"inputs": [{"type": "service_mock", "detail": {"LogsPerSecond": 100, "MaxLogCount": 10, "Fields": {"content": "tracing"}}}],
+			"processors": [{"type": "processor_regex", "detail": {"SourceKey": "content", "Regex": "(.*)", "Keys": ["msg"]}}],
+			"flushers": [{"type": "flusher_checker"}]
+		}`,
+	}})
+	require.Equal(t, []ReloadResult{{ConfigName: "tracing", Action: ReloadActionAdded}}, results)
+	time.Sleep(2 * time.Second)
+	flushPipelineTracer()
+
+	spans := exporter.spansByName()
+	require.NotEmpty(t, spans[spanProcess])
+	require.Len(t, spans[spanProcessor], len(spans[spanProcess]))
+	assert.Equal(t, spans[spanProcess][0].SpanID(), spans[spanProcessor][0].ParentSpanID())
+	assert.Equal(t, map[string]interface{}{attrConfig: "tracing", attrPlugin: "processor_regex", attrGroupSize: int64(1)}, spans[spanProcessor][0].Attributes().AsRaw())
+	assert.NotEmpty(t, spans[spanAggregate])
+	require.NotEmpty(t, spans[spanFlush])
+	require.NotEmpty(t, spans[spanFlusher])
+	assert.Equal(t, "flusher_checker", spans[spanFlusher][0].Attributes().AsRaw()[attrPlugin])
+	// the service input returns after MaxLogCount logs.
+	require.Len(t, spans[spanInput], 1)
+	assert.Equal(t, map[string]interface{}{attrConfig: "tracing", attrPlugin: "service_mock"}, spans[spanInput][0].Attributes().AsRaw())
+}
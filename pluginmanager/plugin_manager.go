@@ -29,6 +29,9 @@ import (
 )
 
 // Following variables are exported so that tests of main package can reference them.
+// LogtailConfig is replaced or modified under LogtailConfigLock, read it with GetLogtailConfig outside of the
+// plugin manager.
+var LogtailConfigLock sync.RWMutex
 var LogtailConfig map[string]*LogstoreConfig
 var LastLogtailConfig map[string]*LogstoreConfig
 var ContainerConfig *LogstoreConfig
@@ -103,12 +106,17 @@ var containerConfigJSON = `{
 
 func panicRecover(pluginName string) {
 	if err := recover(); err != nil {
-		trace := make([]byte, 2048)
-		runtime.Stack(trace, true)
-		logger.Error(context.Background(), "PLUGIN_RUNTIME_ALARM", "plugin", pluginName, "panicked", err, "stack", string(trace))
+		logPanic(pluginName, err)
 	}
 }
 
+func logPanic(pluginName string, err interface{}) {
+	trace := make([]byte, 2048)
+	runtime.Stack(trace, true)
+	logger.Error(context.Background(), "PLUGIN_RUNTIME_ALARM", "plugin", pluginName, "panicked", err, "stack", string(trace))
+	recordPanic(pluginName, err)
+}
+
 // Init initializes plugin manager.
 func Init() (err error) {
 	logger.Info(context.Background(), "init plugin, local env tags", helper.EnvTags)
@@ -131,6 +139,7 @@ func Init() (err error) {
 		return
 	}
 	logger.Info(context.Background(), "loadBuiltinConfig container")
+	initPipelineTracer(&config.LogtailGlobalConfig.Tracing)
 	return
 }
 
@@ -169,21 +178,27 @@ func timeoutStop(config *LogstoreConfig, flag bool) bool {
 	}
 }
 
+// stopConfig stops the config with timeoutStop, the config is disabled if it stops slowly.
+func stopConfig(logstoreConfig *LogstoreConfig, exitFlag bool) {
+	if hasStopped := timeoutStop(logstoreConfig, exitFlag); !hasStopped {
+		// TODO: This alarm can not be sent to server in current alarm design.
+		logger.Error(logstoreConfig.Context.GetRuntimeContext(), "CONFIG_STOP_TIMEOUT_ALARM",
+			"timeout when stop config, goroutine might leak")
+		DisabledLogtailConfigLock.Lock()
+		DisabledLogtailConfig[logstoreConfig.ConfigName] = logstoreConfig
+		DisabledLogtailConfigLock.Unlock()
+	}
+}
+
 // HoldOn stops all config instance and checkpoint manager so that it is ready
 // to load new configs or quit.
 // For user-defined config, timeoutStop is used to avoid hanging.
 func HoldOn(exitFlag bool) error {
 	defer panicRecover("Run plugin")
+	setRunningConfigs(nil)
 
-	for _, logstoreConfig := range LogtailConfig {
-		if hasStopped := timeoutStop(logstoreConfig, exitFlag); !hasStopped {
-			// TODO: This alarm can not be sent to server in current alarm design.
-			logger.Error(logstoreConfig.Context.GetRuntimeContext(), "CONFIG_STOP_TIMEOUT_ALARM",
-				"timeout when stop config, goroutine might leak")
-			DisabledLogtailConfigLock.Lock()
-			DisabledLogtailConfig[logstoreConfig.ConfigName] = logstoreConfig
-			DisabledLogtailConfigLock.Unlock()
-		}
+	for _, logstoreConfig := range getLogtailConfigs() {
+		stopConfig(logstoreConfig, exitFlag)
 	}
 	if StatisticsConfig != nil {
 		if *flags.ForceSelfCollect {
@@ -213,9 +228,14 @@ func HoldOn(exitFlag bool) error {
 		_ = ContainerConfig.Stop(exitFlag)
 	}
 	// clear all config
+	LogtailConfigLock.Lock()
 	LastLogtailConfig = LogtailConfig
 	LogtailConfig = make(map[string]*LogstoreConfig)
+	LogtailConfigLock.Unlock()
 	CheckPointManager.HoldOn()
+	if exitFlag {
+		flushPipelineTracer()
+	}
 	return nil
 }
 
@@ -232,7 +252,9 @@ func Resume() error {
 		ContainerConfig.Start()
 	}
 	// Remove deleted configs from online manager.
+	LogtailConfigLock.RLock()
 	deletedCachedConfigs := GetAlwaysOnlineManager().GetDeletedConfigs(LogtailConfig)
+	LogtailConfigLock.RUnlock()
 	for _, cfg := range deletedCachedConfigs {
 		go func(config *LogstoreConfig) {
 			defer panicRecover(config.ConfigName)
@@ -241,7 +263,7 @@ func Resume() error {
 			logger.Infof(config.Context.GetRuntimeContext(), "always online config %v stopped, error: %v", config.ConfigName, err)
 		}(cfg)
 	}
-	for _, logstoreConfig := range LogtailConfig {
+	for _, logstoreConfig := range getLogtailConfigs() {
 		if logstoreConfig.alreadyStarted {
 			logstoreConfig.resume()
 			continue
@@ -256,9 +278,31 @@ func Resume() error {
 	CheckPointManager.Resume()
 	// clear last logtail config
 	LastLogtailConfig = make(map[string]*LogstoreConfig)
+	LogtailConfigLock.RLock()
+	setRunningConfigs(LogtailConfig)
+	LogtailConfigLock.RUnlock()
 	return nil
 }
 
+// GetLogtailConfig returns the running config with the name.
+func GetLogtailConfig(configName string) (*LogstoreConfig, bool) {
+	LogtailConfigLock.RLock()
+	defer LogtailConfigLock.RUnlock()
+	lc, ok := LogtailConfig[configName]
+	return lc, ok
+}
+
+// getLogtailConfigs returns a snapshot of the running configs, so that they can be iterated without holding the lock.
+func getLogtailConfigs() []*LogstoreConfig {
+	LogtailConfigLock.RLock()
+	defer LogtailConfigLock.RUnlock()
+	configs := make([]*LogstoreConfig, 0, len(LogtailConfig))
+	for _, lc := range LogtailConfig {
+		configs = append(configs, lc)
+	}
+	return configs
+}
+
 func init() {
 	go func() {
 		for {
@@ -44,7 +44,12 @@ type PluginRunner interface {
 
 	RunPlugins(category pluginCategory, control *pipeline.AsyncControl)
 
+	// Merge moves the unsent log groups of p into the runner, they are put into the flush queue by Init, or at once
+	// if the runner has been initialized, which must be running then to make room for them.
 	Merge(p PluginRunner)
 
+	// Status returns the state of the inputs and the queues for the readiness check.
+	Status() runnerStatus
+
 	Stop(exit bool) error
 }
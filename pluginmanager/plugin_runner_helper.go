@@ -28,6 +28,8 @@ import (
 )
 
 type timerRunner struct {
+	// name is the plugin name with the optional ID, which labels the restarts of the plugin.
+	name          string
 	interval      time.Duration
 	context       pipeline.Context
 	latencyMetric pipeline.LatencyMetric
@@ -36,7 +38,6 @@ type timerRunner struct {
 
 func (p *timerRunner) Run(task func(state interface{}) error, cc *pipeline.AsyncControl) {
 	logger.Info(p.context.GetRuntimeContext(), "task run", "start", "interval", p.interval, "state", fmt.Sprintf("%T", p.state))
-	defer panicRecover(fmt.Sprint(p.state))
 	for {
 		exitFlag := util.RandomSleep(p.interval, 0.1, cc.CancelToken())
 		if p.latencyMetric != nil {
@@ -44,6 +44,11 @@ type pluginv1Runner struct {
 	ProcessControl   *pipeline.AsyncControl
 	AggregateControl *pipeline.AsyncControl
 	FlushControl     *pipeline.AsyncControl
+
+	tracker *pipelineTracker
+	// enqueueTimes and eventTimes are reused by the flusher goroutine for the pipeline metrics.
+	enqueueTimes []time.Time
+	eventTimes   []int64
 }
 
 func (p *pluginv1Runner) Init(inputQueueSize int, flushQueueSize int) error {
@@ -57,6 +62,7 @@ func (p *pluginv1Runner) Init(inputQueueSize int, flushQueueSize int) error {
 	p.AggregatorPlugins = make([]*AggregatorWrapper, 0)
 	p.FlusherPlugins = make([]*FlusherWrapper, 0)
 	p.ExtensionPlugins = make(map[string]pipeline.Extension, 0)
+	p.tracker = newPipelineTracker()
 	p.LogsChan = make(chan *pipeline.LogWithContext, inputQueueSize)
 	p.LogGroupsChan = make(chan *protocol.LogGroup, helper.Max(flushQueueSize, p.FlushOutStore.Len()))
 	p.FlushOutStore.Write(p.LogGroupsChan)
@@ -84,23 +90,23 @@ func (p *pluginv1Runner) AddPlugin(pluginName string, category pluginCategory, p
 	switch category {
 	case pluginMetricInput:
 		if metric, ok := plugin.(pipeline.MetricInputV1); ok {
-			return p.addMetricInput(metric, config["interval"].(int))
+			return p.addMetricInput(pluginName, metric, config["interval"].(int))
 		}
 	case pluginServiceInput:
 		if service, ok := plugin.(pipeline.ServiceInputV1); ok {
-			return p.addServiceInput(service)
+			return p.addServiceInput(pluginName, service)
 		}
 	case pluginProcessor:
 		if processor, ok := plugin.(pipeline.ProcessorV1); ok {
-			return p.addProcessor(processor, config["priority"].(int))
+			return p.addProcessor(pluginName, processor, config["priority"].(int))
 		}
 	case pluginAggregator:
 		if aggregator, ok := plugin.(pipeline.AggregatorV1); ok {
-			return p.addAggregator(aggregator)
+			return p.addAggregator(pluginName, aggregator)
 		}
 	case pluginFlusher:
 		if flusher, ok := plugin.(pipeline.FlusherV1); ok {
-			return p.addFlusher(flusher)
+			return p.addFlusher(pluginName, flusher)
 		}
 	case pluginExtension:
 		if extension, ok := plugin.(pipeline.Extension); ok {
@@ -132,8 +138,9 @@ func (p *pluginv1Runner) RunPlugins(category pluginCategory, control *pipeline.A
 	}
 }
 
-func (p *pluginv1Runner) addMetricInput(input pipeline.MetricInputV1, interval int) error {
+func (p *pluginv1Runner) addMetricInput(name string, input pipeline.MetricInputV1, interval int) error {
 	var wrapper MetricWrapper
+	wrapper.Name = name
 	wrapper.Config = p.LogstoreConfig
 	wrapper.Input = input
 	wrapper.Interval = time.Duration(interval) * time.Millisecond
@@ -143,8 +150,9 @@ func (p *pluginv1Runner) addMetricInput(input pipeline.MetricInputV1, interval i
 	return nil
 }
 
-func (p *pluginv1Runner) addServiceInput(input pipeline.ServiceInputV1) error {
+func (p *pluginv1Runner) addServiceInput(name string, input pipeline.ServiceInputV1) error {
 	var wrapper ServiceWrapper
+	wrapper.Name = name
 	wrapper.Config = p.LogstoreConfig
 	wrapper.Input = input
 	wrapper.LogsChan = p.LogsChan
@@ -152,8 +160,9 @@ func (p *pluginv1Runner) addServiceInput(input pipeline.ServiceInputV1) error {
 	return nil
 }
 
-func (p *pluginv1Runner) addProcessor(processor pipeline.ProcessorV1, priority int) error {
+func (p *pluginv1Runner) addProcessor(name string, processor pipeline.ProcessorV1, priority int) error {
 	var wrapper ProcessorWrapper
+	wrapper.Name = name
 	wrapper.Config = p.LogstoreConfig
 	wrapper.Processor = processor
 	wrapper.LogsChan = p.LogsChan
@@ -162,11 +171,13 @@ func (p *pluginv1Runner) addProcessor(processor pipeline.ProcessorV1, priority i
 	return nil
 }
 
-func (p *pluginv1Runner) addAggregator(aggregator pipeline.AggregatorV1) error {
+func (p *pluginv1Runner) addAggregator(name string, aggregator pipeline.AggregatorV1) error {
 	var wrapper AggregatorWrapper
+	wrapper.Name = name
 	wrapper.Config = p.LogstoreConfig
 	wrapper.Aggregator = aggregator
 	wrapper.LogGroupsChan = p.LogGroupsChan
+	wrapper.tracker = p.tracker
 	interval, err := aggregator.Init(p.LogstoreConfig.Context, &wrapper)
 	if err != nil {
 		logger.Error(p.LogstoreConfig.Context.GetRuntimeContext(), "AGGREGATOR_INIT_ERROR", "Aggregator failed to initialize", aggregator.Description(), "error", err)
@@ -180,8 +191,9 @@ func (p *pluginv1Runner) addAggregator(aggregator pipeline.AggregatorV1) error {
 	return nil
 }
 
-func (p *pluginv1Runner) addFlusher(flusher pipeline.FlusherV1) error {
+func (p *pluginv1Runner) addFlusher(name string, flusher pipeline.FlusherV1) error {
 	var wrapper FlusherWrapper
+	wrapper.Name = name
 	wrapper.Config = p.LogstoreConfig
 	wrapper.Flusher = flusher
 	wrapper.LogGroupsChan = p.LogGroupsChan
@@ -207,13 +219,13 @@ func (p *pluginv1Runner) runInput() {
 func (p *pluginv1Runner) runMetricInput(async *pipeline.AsyncControl) {
 	for _, metric := range p.MetricPlugins {
 		m := metric
-		async.Run(m.Run)
+		async.Run(p.LogstoreConfig.supervisor.supervise(m.Name, m.Run, m.reinit))
 	}
 }
 
 func (p *pluginv1Runner) runProcessor() {
 	p.ProcessControl.Reset()
-	p.ProcessControl.Run(p.runProcessorInternal)
+	p.ProcessControl.Run(p.LogstoreConfig.supervisor.supervise(supervisedProcessors, p.runProcessorInternal, nil))
 }
 
 // runProcessorInternal is the routine of processors.
@@ -228,7 +240,6 @@ func (p *pluginv1Runner) runProcessor() {
 //
 // It returns when processShutdown is closed.
 func (p *pluginv1Runner) runProcessorInternal(cc *pipeline.AsyncControl) {
-	defer panicRecover(p.LogstoreConfig.ConfigName)
 	var logCtx *pipeline.LogWithContext
 	for {
 		select {
@@ -237,18 +248,27 @@ func (p *pluginv1Runner) runProcessorInternal(cc *pipeline.AsyncControl) {
 				return
 			}
 		case logCtx = <-p.LogsChan:
+			ingestTime := time.Now()
 			logs := []*protocol.Log{logCtx.Log}
 			p.LogstoreConfig.Statistics.RawLogMetric.Add(int64(len(logs)))
+			if !p.LogstoreConfig.rateLimiter.allow(logCtx.Log.Size(), cc.CancelToken()) {
+				break
+			}
+			span := p.LogstoreConfig.startSpan(spanProcess, supervisedProcessors, len(logs))
 			for _, processor := range p.ProcessorPlugins {
+				child := span.child(spanProcessor, processor.Name, len(logs))
 				logs = processor.Processor.ProcessLogs(logs)
+				child.finish(nil)
 				if len(logs) == 0 {
 					break
 				}
 			}
+			span.finish(nil)
 			nowTime := time.Now()
 
 			if len(logs) > 0 {
 				p.LogstoreConfig.Statistics.SplitLogMetric.Add(int64(len(logs)))
+				p.LogstoreConfig.Statistics.recordProcess(ingestTime)
 				for _, aggregator := range p.AggregatorPlugins {
 					for _, l := range logs {
 						if len(l.Contents) == 0 {
@@ -282,17 +302,29 @@ func (p *pluginv1Runner) runAggregator() {
 	p.AggregateControl.Reset()
 	for _, aggregator := range p.AggregatorPlugins {
 		a := aggregator
-		p.AggregateControl.Run(a.Run)
+		p.AggregateControl.Run(p.LogstoreConfig.supervisor.supervise(a.Name, a.Run, nil))
 	}
 }
 
 func (p *pluginv1Runner) runFlusher() {
 	p.FlushControl.Reset()
-	p.FlushControl.Run(p.runFlusherInternal)
+	p.FlushControl.Run(p.LogstoreConfig.supervisor.supervise(supervisedFlushers, p.runFlusherInternal, p.reinitFlushers))
+}
+
+// reinitFlushers stops and re-initializes the flushers when the flusher goroutine is restarted.
+func (p *pluginv1Runner) reinitFlushers() error {
+	for _, flusher := range p.FlusherPlugins {
+		f := flusher
+		if err := reinitPlugin(p.LogstoreConfig, f.Name, f.Flusher.Stop, func() error {
+			return f.Flusher.Init(p.LogstoreConfig.Context)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (p *pluginv1Runner) runFlusherInternal(cc *pipeline.AsyncControl) {
-	defer panicRecover(p.LogstoreConfig.ConfigName)
 	var logGroup *protocol.LogGroup
 	for {
 		select {
@@ -321,7 +353,10 @@ func (p *pluginv1Runner) runFlusherInternal(cc *pipeline.AsyncControl) {
 			for i := 1; i < listLen; i++ {
 				logGroups[i] = <-p.LogGroupsChan
 			}
+			dequeued := time.Now()
+			span := p.LogstoreConfig.startSpan(spanFlush, supervisedFlushers, len(logGroups))
 			p.LogstoreConfig.Statistics.FlushLogGroupMetric.Add(int64(len(logGroups)))
+			enqueueTimes, eventTimes := p.enqueueTimes[:0], p.eventTimes[:0]
 
 			// Add tags for each non-empty LogGroup, includes: default hostname tag,
 			// env tags and global tags in config.
@@ -330,11 +365,18 @@ func (p *pluginv1Runner) runFlusherInternal(cc *pipeline.AsyncControl) {
 					continue
 				}
 				p.LogstoreConfig.Statistics.FlushLogMetric.Add(int64(len(logGroup.Logs)))
+				if t, ok := p.tracker.dequeue(logGroup); ok {
+					enqueueTimes = append(enqueueTimes, t)
+				}
+				for _, log := range logGroup.Logs {
+					eventTimes = append(eventTimes, int64(log.Time)*int64(time.Second)+int64(log.GetTimeNs()))
+				}
 				logGroup.Source = util.GetIPAddress()
 				for key, value := range loadAdditionalTags(p.LogstoreConfig.GlobalConfig).Iterator() {
 					logGroup.LogTags = append(logGroup.LogTags, &protocol.LogTag{Key: key, Value: value})
 				}
 			}
+			p.enqueueTimes, p.eventTimes = enqueueTimes, eventTimes
 
 			// Flush LogGroups to all flushers.
 			// Note: multiple flushers is unrecommended, because all flushers will
@@ -348,13 +390,19 @@ func (p *pluginv1Runner) runFlusherInternal(cc *pipeline.AsyncControl) {
 						break
 					}
 				}
+				p.LogstoreConfig.health.recordReady(allReady)
 				if allReady {
 					for _, flusher := range p.FlusherPlugins {
 						p.LogstoreConfig.Statistics.FlushReadyMetric.Add(1)
 						p.LogstoreConfig.Statistics.FlushLatencyMetric.Begin()
+						begin := time.Now()
+						child := span.child(spanFlusher, flusher.Name, len(logGroups))
 						err := flusher.Flusher.Flush(p.LogstoreConfig.ProjectName,
 							p.LogstoreConfig.LogstoreName, p.LogstoreConfig.ConfigName, logGroups)
+						child.finish(err)
 						p.LogstoreConfig.Statistics.FlushLatencyMetric.End()
+						p.LogstoreConfig.Statistics.recordFlush(flusher.Name, dequeued, enqueueTimes, begin, time.Now(), eventTimes)
+						p.LogstoreConfig.health.recordFlush(time.Now(), err)
 						if err != nil {
 							logger.Error(p.LogstoreConfig.Context.GetRuntimeContext(), "FLUSH_DATA_ALARM", "flush data error",
 								p.LogstoreConfig.ProjectName, p.LogstoreConfig.LogstoreName, err)
@@ -372,10 +420,21 @@ func (p *pluginv1Runner) runFlusherInternal(cc *pipeline.AsyncControl) {
 				p.FlushOutStore.Add(logGroups...)
 				break
 			}
+			span.finish(nil)
 		}
 	}
 }
 
+func (p *pluginv1Runner) Status() runnerStatus {
+	return runnerStatus{
+		inputs:        len(p.MetricPlugins) + len(p.ServicePlugins),
+		inputQueueLen: len(p.LogsChan),
+		inputQueueCap: cap(p.LogsChan),
+		flushQueueLen: len(p.LogGroupsChan),
+		flushQueueCap: cap(p.LogGroupsChan),
+	}
+}
+
 func (p *pluginv1Runner) Stop(exit bool) error {
 	for _, flusher := range p.FlusherPlugins {
 		flusher.Flusher.SetUrgent(exit)
@@ -414,6 +473,7 @@ func (p *pluginv1Runner) Stop(exit bool) error {
 		}
 	}
 	logger.Info(p.LogstoreConfig.Context.GetRuntimeContext(), "flusher plugins stop", "done")
+	p.tracker.reset()
 
 	for _, extension := range p.ExtensionPlugins {
 		err := extension.Stop()
@@ -434,5 +494,8 @@ func (p *pluginv1Runner) ReceiveRawLog(log *pipeline.LogWithContext) {
 func (p *pluginv1Runner) Merge(r PluginRunner) {
 	if other, ok := r.(*pluginv1Runner); ok {
 		p.FlushOutStore.Merge(other.FlushOutStore)
+		if p.LogGroupsChan != nil {
+			p.FlushOutStore.Write(p.LogGroupsChan)
+		}
 	}
 }
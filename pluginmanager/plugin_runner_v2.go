@@ -52,6 +52,17 @@ type pluginv2Runner struct {
 
 	FlushOutStore  *FlushOutStore[models.PipelineGroupEvents]
 	LogstoreConfig *LogstoreConfig
+
+	// flusherNames are the plugin names of FlusherPlugins, which label the pipeline metrics.
+	flusherNames []string
+	// serviceNames are the plugin names of ServicePlugins, which label the restarts.
+	serviceNames []string
+	// processorNames are the plugin names of ProcessorPlugins, which label the spans.
+	processorNames []string
+	tracker        *pipelineTracker
+	// enqueueTimes and eventTimes are reused by the flusher goroutine for the pipeline metrics.
+	enqueueTimes []time.Time
+	eventTimes   []int64
 }
 
 func (p *pluginv2Runner) Init(inputQueueSize int, flushQueueSize int) error {
@@ -65,9 +76,10 @@ func (p *pluginv2Runner) Init(inputQueueSize int, flushQueueSize int) error {
 	p.AggregatorPlugins = make([]pipeline.AggregatorV2, 0)
 	p.FlusherPlugins = make([]pipeline.FlusherV2, 0)
 	p.ExtensionPlugins = make(map[string]pipeline.Extension, 0)
+	p.tracker = newPipelineTracker()
 	p.InputPipeContext = pipeline.NewObservePipelineConext(inputQueueSize)
 	p.ProcessPipeContext = pipeline.NewGroupedPipelineConext()
-	p.AggregatePipeContext = pipeline.NewObservePipelineConext(flushQueueSize)
+	p.AggregatePipeContext = newTrackedPipelineContext(pipeline.NewObservePipelineConext(flushQueueSize), p.tracker)
 	p.FlushPipeContext = pipeline.NewNoopPipelineConext()
 	p.FlushOutStore.Write(p.AggregatePipeContext.Collector().Observe())
 	return nil
@@ -88,23 +100,23 @@ func (p *pluginv2Runner) AddPlugin(pluginName string, category pluginCategory, p
 	switch category {
 	case pluginMetricInput:
 		if metric, ok := plugin.(pipeline.MetricInputV2); ok {
-			return p.addMetricInput(metric, config["interval"].(int))
+			return p.addMetricInput(pluginName, metric, config["interval"].(int))
 		}
 	case pluginServiceInput:
 		if service, ok := plugin.(pipeline.ServiceInputV2); ok {
-			return p.addServiceInput(service)
+			return p.addServiceInput(pluginName, service)
 		}
 	case pluginProcessor:
 		if processor, ok := plugin.(pipeline.ProcessorV2); ok {
-			return p.addProcessor(processor, config["priority"].(int))
+			return p.addProcessor(pluginName, processor, config["priority"].(int))
 		}
 	case pluginAggregator:
 		if aggregator, ok := plugin.(pipeline.AggregatorV2); ok {
-			return p.addAggregator(aggregator)
+			return p.addAggregator(pluginName, aggregator)
 		}
 	case pluginFlusher:
 		if flusher, ok := plugin.(pipeline.FlusherV2); ok {
-			return p.addFlusher(flusher)
+			return p.addFlusher(pluginName, flusher)
 		}
 	case pluginExtension:
 		if extension, ok := plugin.(pipeline.Extension); ok {
@@ -136,9 +148,10 @@ func (p *pluginv2Runner) RunPlugins(category pluginCategory, control *pipeline.A
 	}
 }
 
-func (p *pluginv2Runner) addMetricInput(input pipeline.MetricInputV2, interval int) error {
+func (p *pluginv2Runner) addMetricInput(name string, input pipeline.MetricInputV2, interval int) error {
 	p.MetricPlugins = append(p.MetricPlugins, input)
 	p.TimerRunner = append(p.TimerRunner, &timerRunner{
+		name:          name,
 		state:         input,
 		interval:      time.Duration(interval) * time.Millisecond,
 		context:       p.LogstoreConfig.Context,
@@ -147,17 +160,19 @@ func (p *pluginv2Runner) addMetricInput(input pipeline.MetricInputV2, interval i
 	return nil
 }
 
-func (p *pluginv2Runner) addServiceInput(input pipeline.ServiceInputV2) error {
+func (p *pluginv2Runner) addServiceInput(name string, input pipeline.ServiceInputV2) error {
 	p.ServicePlugins = append(p.ServicePlugins, input)
+	p.serviceNames = append(p.serviceNames, name)
 	return nil
 }
 
-func (p *pluginv2Runner) addProcessor(processor pipeline.ProcessorV2, _ int) error {
+func (p *pluginv2Runner) addProcessor(name string, processor pipeline.ProcessorV2, _ int) error {
 	p.ProcessorPlugins = append(p.ProcessorPlugins, processor)
+	p.processorNames = append(p.processorNames, name)
 	return nil
 }
 
-func (p *pluginv2Runner) addAggregator(aggregator pipeline.AggregatorV2) error {
+func (p *pluginv2Runner) addAggregator(name string, aggregator pipeline.AggregatorV2) error {
 	p.AggregatorPlugins = append(p.AggregatorPlugins, aggregator)
 	interval, err := aggregator.Init(p.LogstoreConfig.Context, &AggregatorWrapper{})
 	if err != nil {
@@ -168,6 +183,7 @@ func (p *pluginv2Runner) addAggregator(aggregator pipeline.AggregatorV2) error {
 		interval = p.LogstoreConfig.GlobalConfig.AggregatIntervalMs
 	}
 	p.TimerRunner = append(p.TimerRunner, &timerRunner{
+		name:          name,
 		state:         aggregator,
 		interval:      time.Millisecond * time.Duration(interval),
 		context:       p.LogstoreConfig.Context,
@@ -176,8 +192,9 @@ func (p *pluginv2Runner) addAggregator(aggregator pipeline.AggregatorV2) error {
 	return nil
 }
 
-func (p *pluginv2Runner) addFlusher(flusher pipeline.FlusherV2) error {
+func (p *pluginv2Runner) addFlusher(name string, flusher pipeline.FlusherV2) error {
 	p.FlusherPlugins = append(p.FlusherPlugins, flusher)
+	p.flusherNames = append(p.flusherNames, name)
 	return nil
 }
 
@@ -189,16 +206,24 @@ func (p *pluginv2Runner) addExtension(name string, extension pipeline.Extension)
 func (p *pluginv2Runner) runInput() {
 	p.InputControl.Reset()
 	p.runMetricInput(p.InputControl)
-	for _, input := range p.ServicePlugins {
-		service := input
-		p.InputControl.Run(func(c *pipeline.AsyncControl) {
+	for idx, input := range p.ServicePlugins {
+		service, name := input, p.serviceNames[idx]
+		p.InputControl.Run(p.LogstoreConfig.supervisor.superviseWithError(name, func(c *pipeline.AsyncControl) error {
 			logger.Info(p.LogstoreConfig.Context.GetRuntimeContext(), "start run service", service)
-			defer panicRecover(service.Description())
-			if err := service.StartService(p.InputPipeContext); err != nil {
+			span := p.LogstoreConfig.startSpan(spanInput, name, unknownGroupSize)
+			err := service.StartService(p.InputPipeContext)
+			span.finish(err)
+			if err != nil {
 				logger.Error(p.LogstoreConfig.Context.GetRuntimeContext(), "PLUGIN_ALARM", "start service error, err", err)
 			}
 			logger.Info(p.LogstoreConfig.Context.GetRuntimeContext(), "service done", service.Description())
-		})
+			return err
+		}, func() error {
+			return reinitPlugin(p.LogstoreConfig, name, service.Stop, func() error {
+				_, err := service.Init(p.LogstoreConfig.Context)
+				return err
+			})
+		}))
 	}
 }
 
@@ -207,22 +232,29 @@ func (p *pluginv2Runner) runMetricInput(control *pipeline.AsyncControl) {
 		if plugin, ok := t.state.(pipeline.MetricInputV2); ok {
 			metric := plugin
 			timer := t
-			control.Run(func(cc *pipeline.AsyncControl) {
+			control.Run(p.LogstoreConfig.supervisor.supervise(timer.name, func(cc *pipeline.AsyncControl) {
 				timer.Run(func(state interface{}) error {
-					return metric.Read(p.InputPipeContext)
+					span := p.LogstoreConfig.startSpan(spanInput, timer.name, unknownGroupSize)
+					err := metric.Read(p.InputPipeContext)
+					span.finish(err)
+					return err
 				}, cc)
-			})
+			}, func() error {
+				return reinitPlugin(p.LogstoreConfig, timer.name, nil, func() error {
+					_, err := metric.Init(p.LogstoreConfig.Context)
+					return err
+				})
+			}))
 		}
 	}
 }
 
 func (p *pluginv2Runner) runProcessor() {
 	p.ProcessControl.Reset()
-	p.ProcessControl.Run(p.runProcessorInternal)
+	p.ProcessControl.Run(p.LogstoreConfig.supervisor.supervise(supervisedProcessors, p.runProcessorInternal, nil))
 }
 
 func (p *pluginv2Runner) runProcessorInternal(cc *pipeline.AsyncControl) {
-	defer panicRecover(p.LogstoreConfig.ConfigName)
 	pipeContext := p.ProcessPipeContext
 	pipeChan := p.InputPipeContext.Collector().Observe()
 	for {
@@ -232,20 +264,38 @@ func (p *pluginv2Runner) runProcessorInternal(cc *pipeline.AsyncControl) {
 				return
 			}
 		case group := <-pipeChan:
+			ingestTime := time.Now()
 			p.LogstoreConfig.Statistics.RawLogMetric.Add(int64(len(group.Events)))
+			if limiter := p.LogstoreConfig.rateLimiter; limiter != nil {
+				events := group.Events[:0]
+				for _, event := range group.Events {
+					if limiter.allow(eventSize(event), cc.CancelToken()) {
+						events = append(events, event)
+					}
+				}
+				group.Events = events
+				if len(events) == 0 {
+					break
+				}
+			}
 			pipeEvents := []*models.PipelineGroupEvents{group}
-			for _, processor := range p.ProcessorPlugins {
+			span := p.LogstoreConfig.startSpan(spanProcess, supervisedProcessors, len(group.Events))
+			for idx, processor := range p.ProcessorPlugins {
+				child := span.child(spanProcessor, p.processorNames[idx], countEvents(pipeEvents))
 				for _, in := range pipeEvents {
 					processor.Process(in, pipeContext)
 				}
 				pipeEvents = pipeContext.Collector().ToArray()
+				child.finish(nil)
 				if len(pipeEvents) == 0 {
 					break
 				}
 			}
+			span.finish(nil)
 			if len(pipeEvents) == 0 {
 				break
 			}
+			p.LogstoreConfig.Statistics.recordProcess(ingestTime)
 			for _, aggregator := range p.AggregatorPlugins {
 				for _, pipeEvent := range pipeEvents {
 					if len(pipeEvent.Events) == 0 {
@@ -275,22 +325,37 @@ func (p *pluginv2Runner) runAggregator() {
 		if plugin, ok := t.state.(pipeline.AggregatorV2); ok {
 			aggregator := plugin
 			timer := t
-			p.AggregateControl.Run(func(cc *pipeline.AsyncControl) {
+			p.AggregateControl.Run(p.LogstoreConfig.supervisor.supervise(timer.name, func(cc *pipeline.AsyncControl) {
 				timer.Run(func(state interface{}) error {
-					return aggregator.GetResult(p.AggregatePipeContext)
+					span := p.LogstoreConfig.startSpan(spanAggregate, timer.name, unknownGroupSize)
+					err := aggregator.GetResult(p.AggregatePipeContext)
+					span.finish(err)
+					return err
 				}, cc)
-			})
+			}, nil))
 		}
 	}
 }
 
 func (p *pluginv2Runner) runFlusher() {
 	p.FlushControl.Reset()
-	p.FlushControl.Run(p.runFlusherInternal)
+	p.FlushControl.Run(p.LogstoreConfig.supervisor.supervise(supervisedFlushers, p.runFlusherInternal, p.reinitFlushers))
+}
+
+// reinitFlushers stops and re-initializes the flushers when the flusher goroutine is restarted.
+func (p *pluginv2Runner) reinitFlushers() error {
+	for idx, flusher := range p.FlusherPlugins {
+		f := flusher
+		if err := reinitPlugin(p.LogstoreConfig, p.flusherNames[idx], f.Stop, func() error {
+			return f.Init(p.LogstoreConfig.Context)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (p *pluginv2Runner) runFlusherInternal(cc *pipeline.AsyncControl) {
-	defer panicRecover(p.LogstoreConfig.ConfigName)
 	pipeChan := p.AggregatePipeContext.Collector().Observe()
 	for {
 		select {
@@ -319,7 +384,10 @@ func (p *pluginv2Runner) runFlusherInternal(cc *pipeline.AsyncControl) {
 			for i := 1; i < dataSize; i++ {
 				data[i] = <-pipeChan
 			}
+			dequeued := time.Now()
+			span := p.LogstoreConfig.startSpan(spanFlush, supervisedFlushers, len(data))
 			p.LogstoreConfig.Statistics.FlushLogGroupMetric.Add(int64(len(data)))
+			enqueueTimes, eventTimes := p.enqueueTimes[:0], p.eventTimes[:0]
 
 			// Add tags for each non-empty LogGroup, includes: default hostname tag,
 			// env tags and global tags in config.
@@ -328,8 +396,15 @@ func (p *pluginv2Runner) runFlusherInternal(cc *pipeline.AsyncControl) {
 					continue
 				}
 				p.LogstoreConfig.Statistics.FlushLogMetric.Add(int64(len(item.Events)))
+				if t, ok := p.tracker.dequeue(item); ok {
+					enqueueTimes = append(enqueueTimes, t)
+				}
+				for _, event := range item.Events {
+					eventTimes = append(eventTimes, int64(event.GetTimestamp()))
+				}
 				item.Group.GetTags().Merge(loadAdditionalTags(p.LogstoreConfig.GlobalConfig))
 			}
+			p.enqueueTimes, p.eventTimes = enqueueTimes, eventTimes
 
 			// Flush LogGroups to all flushers.
 			// Note: multiple flushers is unrecommended, because all flushers will
@@ -343,12 +418,18 @@ func (p *pluginv2Runner) runFlusherInternal(cc *pipeline.AsyncControl) {
 						break
 					}
 				}
+				p.LogstoreConfig.health.recordReady(allReady)
 				if allReady {
-					for _, flusher := range p.FlusherPlugins {
+					for idx, flusher := range p.FlusherPlugins {
 						p.LogstoreConfig.Statistics.FlushReadyMetric.Add(1)
 						p.LogstoreConfig.Statistics.FlushLatencyMetric.Begin()
+						begin := time.Now()
+						child := span.child(spanFlusher, p.flusherNames[idx], len(data))
 						err := flusher.Export(data, p.FlushPipeContext)
+						child.finish(err)
 						p.LogstoreConfig.Statistics.FlushLatencyMetric.End()
+						p.LogstoreConfig.Statistics.recordFlush(p.flusherNames[idx], dequeued, enqueueTimes, begin, time.Now(), eventTimes)
+						p.LogstoreConfig.health.recordFlush(time.Now(), err)
 						if err != nil {
 							logger.Error(p.LogstoreConfig.Context.GetRuntimeContext(), "FLUSH_DATA_ALARM", "flush data error",
 								p.LogstoreConfig.ProjectName, p.LogstoreConfig.LogstoreName, err)
@@ -366,10 +447,23 @@ func (p *pluginv2Runner) runFlusherInternal(cc *pipeline.AsyncControl) {
 				p.FlushOutStore.Add(data...)
 				break
 			}
+			span.finish(nil)
 		}
 	}
 }
 
+func (p *pluginv2Runner) Status() runnerStatus {
+	inputQueue := p.InputPipeContext.Collector().Observe()
+	flushQueue := p.AggregatePipeContext.Collector().Observe()
+	return runnerStatus{
+		inputs:        len(p.MetricPlugins) + len(p.ServicePlugins),
+		inputQueueLen: len(inputQueue),
+		inputQueueCap: cap(inputQueue),
+		flushQueueLen: len(flushQueue),
+		flushQueueCap: cap(flushQueue),
+	}
+}
+
 func (p *pluginv2Runner) Stop(exit bool) error {
 	for _, flusher := range p.FlusherPlugins {
 		flusher.SetUrgent(exit)
@@ -381,6 +475,15 @@ func (p *pluginv2Runner) Stop(exit bool) error {
 	logger.Info(p.LogstoreConfig.Context.GetRuntimeContext(), "metric plugins stop", "done", "service plugins stop", "done")
 
 	p.ProcessControl.WaitCancel()
+	for idx, processor := range p.ProcessorPlugins {
+		if stoppable, ok := processor.(pipeline.StoppableProcessor); ok {
+			if err := stoppable.Stop(); err != nil {
+				logger.Warningf(p.LogstoreConfig.Context.GetRuntimeContext(), "STOP_PROCESSOR_ALARM",
+					"Failed to stop %vth processor (description: %v): %v",
+					idx, processor.Description(), err)
+			}
+		}
+	}
 	logger.Info(p.LogstoreConfig.Context.GetRuntimeContext(), "processor plugins stop", "done")
 
 	p.AggregateControl.WaitCancel()
@@ -404,6 +507,7 @@ func (p *pluginv2Runner) Stop(exit bool) error {
 		}
 	}
 	logger.Info(p.LogstoreConfig.Context.GetRuntimeContext(), "Flusher plugins stop", "done")
+	p.tracker.reset()
 
 	for _, extension := range p.ExtensionPlugins {
 		err := extension.Stop()
@@ -452,5 +556,8 @@ func (p *pluginv2Runner) ReceiveRawLog(in *pipeline.LogWithContext) {
 func (p *pluginv2Runner) Merge(r PluginRunner) {
 	if other, ok := r.(*pluginv2Runner); ok {
 		p.FlushOutStore.Merge(other.FlushOutStore)
+		if p.AggregatePipeContext != nil {
+			p.FlushOutStore.Write(p.AggregatePipeContext.Collector().Observe())
+		}
 	}
 }
@@ -0,0 +1,223 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/config"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+// The names of the goroutines shared by all the processors or flushers of a config, which label the restarts.
+const (
+	supervisedProcessors = "processors"
+	supervisedFlushers   = "flushers"
+)
+
+// pluginSupervisor restarts the plugin goroutines of a config terminated by panics with exponential backoff.
+type pluginSupervisor struct {
+	lc             *LogstoreConfig
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxFailures    int
+
+	mu       sync.Mutex
+	restarts int
+	degraded map[string]bool
+}
+
+func newPluginSupervisor(lc *LogstoreConfig, cfg *config.PluginRestartConfig) *pluginSupervisor {
+	s := &pluginSupervisor{
+		lc:             lc,
+		initialBackoff: time.Duration(cfg.InitialBackoffMs) * time.Millisecond,
+		maxBackoff:     time.Duration(cfg.MaxBackoffMs) * time.Millisecond,
+		maxFailures:    cfg.MaxFailures,
+		degraded:       make(map[string]bool),
+	}
+	if s.maxBackoff < s.initialBackoff {
+		s.maxBackoff = s.initialBackoff
+	}
+	return s
+}
+
+// supervise wraps the task of a plugin goroutine. When the task panics before the control is canceled, the plugin
+// is re-initialized by init if not nil, and the task runs again after the backoff, which doubles on each consecutive
+// failure up to the max backoff. The failures are not consecutive once the plugin runs longer than the max backoff.
+// The config is degraded after the max consecutive failures of a plugin, which still keeps restarting until the
+// config is stopped, and recovers once the plugin runs longer than the max backoff. A nil supervisor only recovers
+// the panic.
+func (s *pluginSupervisor) supervise(plugin string, task func(*pipeline.AsyncControl), init func() error) func(*pipeline.AsyncControl) {
+	return s.superviseWithError(plugin, func(cc *pipeline.AsyncControl) error {
+		task(cc)
+		return nil
+	}, init)
+}
+
+// superviseWithError is like supervise, but the error returned by the task is a failure too, such as the error
+// returned by the Start of a service input.
+func (s *pluginSupervisor) superviseWithError(plugin string, task func(*pipeline.AsyncControl) error, init func() error) func(*pipeline.AsyncControl) {
+	return func(cc *pipeline.AsyncControl) {
+		s.run(plugin, task, init, cc, cc.CancelToken())
+	}
+}
+
+// run runs the supervised task in the current goroutine until it returns without failures or the cancel token is
+// closed. The cancel token is passed since the goroutines detached from the control may run after it is reset.
+func (s *pluginSupervisor) run(plugin string, task func(*pipeline.AsyncControl) error, init func() error, cc *pipeline.AsyncControl, cancelToken <-chan struct{}) {
+	failures := 0
+	for {
+		begin := time.Now()
+		var err error
+		if failures > 0 && init != nil {
+			err = s.reinit(plugin, init)
+		}
+		if err == nil {
+			var recovered *time.Timer
+			if failures > 0 {
+				recovered = time.AfterFunc(s.maxBackoff, func() { s.recover(plugin) })
+			}
+			var crashed bool
+			crashed, err = runTask(plugin, task, cc)
+			if recovered != nil {
+				recovered.Stop()
+			}
+			if !crashed {
+				s.recover(plugin)
+				return
+			}
+		}
+		if s == nil || s.maxFailures <= 0 {
+			return
+		}
+		if time.Since(begin) > s.maxBackoff {
+			failures = 0
+		}
+		failures++
+		backoff := s.fail(plugin, failures, err)
+		select {
+		case <-cancelToken:
+			return
+		default:
+		}
+		select {
+		case <-cancelToken:
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// runTask runs the task and reports whether it panicked or returned an error, the panic is logged as panicRecover
+// does.
+func runTask(plugin string, task func(*pipeline.AsyncControl) error, cc *pipeline.AsyncControl) (crashed bool, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			logPanic(plugin, e)
+			crashed = true
+		}
+	}()
+	if err = task(cc); err != nil {
+		return true, fmt.Errorf("run error: %v", err)
+	}
+	return false, nil
+}
+
+func (s *pluginSupervisor) reinit(plugin string, init func() error) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			logPanic(plugin, e)
+			err = fmt.Errorf("init panicked: %v", e)
+		}
+	}()
+	if err = init(); err != nil {
+		return fmt.Errorf("reinit error: %v", err)
+	}
+	return nil
+}
+
+// fail records the failure of the plugin and returns the backoff before restarting it.
+func (s *pluginSupervisor) fail(plugin string, failures int, err error) time.Duration {
+	backoff := s.maxBackoff
+	if shift := failures - 1; shift < 32 && s.initialBackoff<<shift < s.maxBackoff {
+		backoff = s.initialBackoff << shift
+	}
+	s.mu.Lock()
+	s.restarts++
+	degrade := failures >= s.maxFailures && !s.degraded[plugin]
+	if degrade {
+		s.degraded[plugin] = true
+	}
+	s.mu.Unlock()
+
+	if s.lc.Statistics.PluginRestartMetric != nil {
+		s.lc.Statistics.PluginRestartMetric.WithLabelValues(plugin).Add(1)
+	}
+	ctx := s.lc.Context.GetRuntimeContext()
+	if err != nil {
+		logger.Warning(ctx, "PLUGIN_RESTART_ALARM", "restart plugin on error", err, "plugin", plugin, "failures", failures, "backoff", backoff)
+	} else {
+		logger.Warning(ctx, "PLUGIN_RESTART_ALARM", "plugin crashed, restart it", plugin, "failures", failures, "backoff", backoff)
+	}
+	if degrade {
+		logger.Error(ctx, "PLUGIN_DEGRADED_ALARM", "config is degraded, plugin", plugin, "consecutive failures", failures)
+	}
+	return backoff
+}
+
+// recover clears the degradation of the plugin once it runs without failures.
+func (s *pluginSupervisor) recover(plugin string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	recovered := s.degraded[plugin]
+	delete(s.degraded, plugin)
+	s.mu.Unlock()
+	if recovered {
+		logger.Info(s.lc.Context.GetRuntimeContext(), "config recovers from degradation, plugin", plugin)
+	}
+}
+
+// status returns the number of the restarts and the sorted degraded plugins of the config.
+func (s *pluginSupervisor) status() (int, []string) {
+	if s == nil {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var degraded []string
+	for plugin := range s.degraded {
+		degraded = append(degraded, plugin)
+	}
+	sort.Strings(degraded)
+	return s.restarts, degraded
+}
+
+// reinitPlugin stops the plugin by stop if not nil to release its resources such as the listeners, and
+// re-initializes it, the metrics registered meanwhile replace those of the plugin.
+func reinitPlugin(lc *LogstoreConfig, plugin string, stop func() error, init func() error) error {
+	if stop != nil {
+		if err := stop(); err != nil {
+			logger.Warning(lc.Context.GetRuntimeContext(), "PLUGIN_RESTART_ALARM", "stop plugin error", err, "plugin", plugin)
+		}
+	}
+	defer bindPluginMetrics(lc, plugin)()
+	return init()
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/config"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+func newSupervisorTestConfig(t *testing.T, maxFailures int) *LogstoreConfig {
+	lc := newHealthTestConfig(t, "c")
+	lc.Context = &ContextImp{logstoreC: lc}
+	lc.Context.InitContext("p", "l", "c")
+	lc.Statistics.Init(lc.Context)
+	lc.supervisor = newPluginSupervisor(lc, &config.PluginRestartConfig{InitialBackoffMs: 10, MaxBackoffMs: 40, MaxFailures: maxFailures})
+	return lc
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	lc := newSupervisorTestConfig(t, 3)
+	s := lc.supervisor
+	assert.Equal(t, 10*time.Millisecond, s.fail("p", 1, nil))
+	assert.Equal(t, 20*time.Millisecond, s.fail("p", 2, nil))
+	assert.Equal(t, 40*time.Millisecond, s.fail("p", 3, errors.New("init error")))
+	assert.Equal(t, 40*time.Millisecond, s.fail("p", 4, nil))
+	assert.Equal(t, 40*time.Millisecond, s.fail("p", 100, nil))
+	restarts, degraded := s.status()
+	assert.Equal(t, 5, restarts)
+	assert.Equal(t, []string{"p"}, degraded)
+	assert.Equal(t, int64(5), lc.Statistics.PluginRestartMetric.WithLabelValues("p").Get())
+}
+
+func TestSupervise(t *testing.T) {
+	lc := newSupervisorTestConfig(t, 2)
+	lc.supervisor.maxBackoff = 500 * time.Millisecond
+	var runs, inits int32
+	cc := pipeline.NewAsyncControl()
+	cc.Run(lc.supervisor.supervise("service_mock", func(cc *pipeline.AsyncControl) {
+		if atomic.AddInt32(&runs, 1) <= 2 {
+			panic("crash")
+		}
+		<-cc.CancelToken()
+	}, func() error {
+		atomic.AddInt32(&inits, 1)
+		return nil
+	}))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) == 3 }, 5*time.Second, 10*time.Millisecond)
+	status := configStatus(lc, time.Now(), testHealthThresholds)
+	assert.Equal(t, 2, status.PluginRestarts)
+	assert.Equal(t, []string{"service_mock"}, status.DegradedPlugins)
+	assert.Equal(t, []string{"plugin service_mock is degraded by repeated crashes"}, status.Problems)
+	// the config recovers once the plugin runs longer than the max backoff.
+	require.Eventually(t, func() bool { _, degraded := lc.supervisor.status(); return len(degraded) == 0 }, 5*time.Second, 10*time.Millisecond)
+	cc.WaitCancel()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&runs))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&inits))
+	restarts, _ := lc.supervisor.status()
+	assert.Equal(t, 2, restarts)
+
+	// the failed init is retried, and the task returning normally is not restarted.
+	lc = newSupervisorTestConfig(t, 5)
+	runs, inits = 0, 0
+	cc = pipeline.NewAsyncControl()
+	cc.Run(lc.supervisor.supervise("flushers", func(cc *pipeline.AsyncControl) {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			panic("crash")
+		}
+	}, func() error {
+		if atomic.AddInt32(&inits, 1) == 1 {
+			return errors.New("init error")
+		}
+		return nil
+	}))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) == 2 }, 5*time.Second, 10*time.Millisecond)
+	cc.WaitCancel()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&inits))
+	restarts, degraded := lc.supervisor.status()
+	assert.Equal(t, 2, restarts)
+	assert.Empty(t, degraded)
+}
+
+func TestSuperviseWithError(t *testing.T) {
+	lc := newSupervisorTestConfig(t, 5)
+	var runs int32
+	var calls []string
+	cc := pipeline.NewAsyncControl()
+	cc.Run(lc.supervisor.superviseWithError("service_mock", func(cc *pipeline.AsyncControl) error {
+		calls = append(calls, "start")
+		if atomic.AddInt32(&runs, 1) == 1 {
+			return errors.New("address already in use")
+		}
+		return nil
+	}, func() error {
+		// the old instance is stopped before it is initialized again.
+		return reinitPlugin(lc, "service_mock", func() error {
+			calls = append(calls, "stop")
+			return nil
+		}, func() error {
+			calls = append(calls, "init")
+			return nil
+		})
+	}))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) == 2 }, 5*time.Second, 10*time.Millisecond)
+	cc.WaitCancel()
+	assert.Equal(t, []string{"start", "stop", "init", "start"}, calls)
+	restarts, _ := lc.supervisor.status()
+	assert.Equal(t, 1, restarts)
+}
+
+func TestSuperviseCanceled(t *testing.T) {
+	lc := newSupervisorTestConfig(t, 5)
+	lc.supervisor.initialBackoff = time.Hour
+	lc.supervisor.maxBackoff = time.Hour
+	var runs int32
+	cc := pipeline.NewAsyncControl()
+	cc.Run(lc.supervisor.supervise("processors", func(cc *pipeline.AsyncControl) {
+		atomic.AddInt32(&runs, 1)
+		panic("crash")
+	}, nil))
+	require.Eventually(t, func() bool { restarts, _ := lc.supervisor.status(); return restarts == 1 }, 5*time.Second, 10*time.Millisecond)
+	// the backoff is interrupted by the cancellation.
+	cc.WaitCancel()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+
+	// the nil supervisor and the disabled restart only recover the panic.
+	var s *pluginSupervisor
+	cc = pipeline.NewAsyncControl()
+	cc.Run(s.supervise("processors", func(cc *pipeline.AsyncControl) { panic("crash") }, nil))
+	cc.WaitCancel()
+	lc = newSupervisorTestConfig(t, 0)
+	cc = pipeline.NewAsyncControl()
+	cc.Run(lc.supervisor.supervise("processors", func(cc *pipeline.AsyncControl) { panic("crash") }, nil))
+	cc.WaitCancel()
+	restarts, _ := lc.supervisor.status()
+	assert.Zero(t, restarts)
+}
@@ -19,6 +19,8 @@ import (
 )
 
 type ProcessorWrapper struct {
+	// Name is the plugin name with the optional ID, which labels the spans of the plugin.
+	Name      string
 	Processor pipeline.ProcessorV1
 	Config    *LogstoreConfig
 	LogsChan  chan *pipeline.LogWithContext
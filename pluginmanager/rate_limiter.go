@@ -0,0 +1,184 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/alibaba/ilogtail/pkg/config"
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+const (
+	rateLimitPolicyDrop   = "drop"
+	rateLimitPolicySample = "sample"
+	rateLimitPolicyBlock  = "block"
+)
+
+// rateLimiter enforces the events/sec and bytes/sec limits of a config in the runners before processing.
+type rateLimiter struct {
+	policy      string
+	sampleRatio float64
+	events      *rate.Limiter
+	bytes       *rate.Limiter
+	stats       *LogstoreStatistics
+	nowFunc     func() time.Time
+}
+
+// newRateLimiter returns nil if no limit is configured.
+func newRateLimiter(cfg *config.RateLimitConfig, stats *LogstoreStatistics) (*rateLimiter, error) {
+	if cfg.EventsPerSecond <= 0 && cfg.BytesPerSecond <= 0 {
+		return nil, nil
+	}
+	r := &rateLimiter{
+		policy:      strings.ToLower(cfg.Policy),
+		sampleRatio: cfg.SampleRatio,
+		stats:       stats,
+		nowFunc:     time.Now,
+	}
+	switch r.policy {
+	case "":
+		r.policy = rateLimitPolicyDrop
+	case rateLimitPolicyDrop, rateLimitPolicyBlock:
+	case rateLimitPolicySample:
+		if r.sampleRatio <= 0 || r.sampleRatio > 1 {
+			return nil, fmt.Errorf("invalid rate limit SampleRatio %v, must be in (0, 1]", r.sampleRatio)
+		}
+	default:
+		return nil, fmt.Errorf("unknown rate limit policy %v", cfg.Policy)
+	}
+	r.events = newTokenBucket(cfg.EventsPerSecond)
+	r.bytes = newTokenBucket(cfg.BytesPerSecond)
+	return r, nil
+}
+
+// newTokenBucket allows a burst of one second.
+func newTokenBucket(limit float64) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(limit), int(math.Max(math.Ceil(limit), 1)))
+}
+
+// allow reports whether an event of the size could be processed. For the block policy, it waits
+// for the tokens until the cancel token is closed, and always returns true.
+func (r *rateLimiter) allow(size int, cancel <-chan struct{}) bool {
+	if r == nil {
+		return true
+	}
+	now := r.nowFunc()
+	if r.policy == rateLimitPolicyBlock {
+		delay := reserve(r.events, now, 1)
+		if d := reserve(r.bytes, now, size); d > delay {
+			delay = d
+		}
+		if delay > 0 {
+			r.stats.ThrottledEventsMetric.Add(1)
+			r.stats.ThrottledBytesMetric.Add(int64(size))
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-cancel:
+				timer.Stop()
+			}
+		}
+		return true
+	}
+	// the tokens are taken only if both buckets have enough, so a rejected event does not consume either.
+	if hasTokens(r.events, now, 1) && hasTokens(r.bytes, now, size) {
+		take(r.events, now, 1)
+		take(r.bytes, now, size)
+		return true
+	}
+	r.stats.ThrottledEventsMetric.Add(1)
+	r.stats.ThrottledBytesMetric.Add(int64(size))
+	return r.policy == rateLimitPolicySample && rand.Float64() < r.sampleRatio //nolint:gosec
+}
+
+// reserve takes n tokens and returns the time to wait for them. An event larger than the burst
+// takes the whole burst.
+func reserve(limiter *rate.Limiter, now time.Time, n int) time.Duration {
+	if limiter == nil {
+		return 0
+	}
+	if n > limiter.Burst() {
+		n = limiter.Burst()
+	}
+	return limiter.ReserveN(now, n).DelayFrom(now)
+}
+
+// hasTokens reports whether n tokens are available. An event larger than the burst needs the whole burst.
+func hasTokens(limiter *rate.Limiter, now time.Time, n int) bool {
+	if limiter == nil {
+		return true
+	}
+	if n > limiter.Burst() {
+		n = limiter.Burst()
+	}
+	return limiter.TokensAt(now) >= float64(n)
+}
+
+// take takes n tokens which are checked available by hasTokens.
+func take(limiter *rate.Limiter, now time.Time, n int) {
+	if limiter == nil {
+		return
+	}
+	if n > limiter.Burst() {
+		n = limiter.Burst()
+	}
+	limiter.AllowN(now, n)
+}
+
+// eventSize estimates the bytes of a pipeline event by its names and values.
+func eventSize(event models.PipelineEvent) int {
+	size := len(event.GetName())
+	for k, v := range event.GetTags().Iterator() {
+		size += len(k) + len(v)
+	}
+	switch e := event.(type) {
+	case *models.Log:
+		for k, v := range e.GetIndices().Iterator() {
+			size += len(k)
+			switch val := v.(type) {
+			case string:
+				size += len(val)
+			case []byte:
+				size += len(val)
+			default:
+				size += 8
+			}
+		}
+	case *models.Span:
+		size += len(e.TraceID) + len(e.SpanID) + len(e.ParentSpanID)
+	case models.ByteArray:
+		size += len(e)
+	case *models.Profile:
+		for _, sample := range e.GetSamples() {
+			for _, frame := range sample.Stack {
+				size += len(frame)
+			}
+			size += 8 * len(sample.Values)
+		}
+	default:
+		size += 8
+	}
+	return size
+}
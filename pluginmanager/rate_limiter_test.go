@@ -0,0 +1,97 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/config"
+)
+
+func newTestStatistics() *LogstoreStatistics {
+	stats := &LogstoreStatistics{}
+	ctx := &ContextImp{}
+	ctx.InitContext("p", "l", "c")
+	stats.Init(ctx)
+	return stats
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	r, err := newRateLimiter(&config.RateLimitConfig{}, newTestStatistics())
+	require.NoError(t, err)
+	assert.Nil(t, r)
+	assert.True(t, r.allow(100, nil))
+
+	_, err = newRateLimiter(&config.RateLimitConfig{EventsPerSecond: 1, Policy: "unknown"}, newTestStatistics())
+	assert.Error(t, err)
+	_, err = newRateLimiter(&config.RateLimitConfig{EventsPerSecond: 1, Policy: "sample"}, newTestStatistics())
+	assert.Error(t, err)
+}
+
+func TestRateLimiterDrop(t *testing.T) {
+	stats := newTestStatistics()
+	r, err := newRateLimiter(&config.RateLimitConfig{EventsPerSecond: 2, BytesPerSecond: 100}, stats)
+	require.NoError(t, err)
+	now := time.Unix(1000000, 0)
+	r.nowFunc = func() time.Time { return now }
+
+	assert.True(t, r.allow(10, nil))
+	assert.True(t, r.allow(10, nil))
+	assert.False(t, r.allow(10, nil))
+	now = now.Add(time.Second)
+	// the bytes limit is exceeded by a large event
+	assert.True(t, r.allow(1000, nil))
+	assert.False(t, r.allow(10, nil))
+	// the event rejected by the bytes limit does not take the event token
+	now = now.Add(200 * time.Millisecond)
+	assert.True(t, r.allow(10, nil))
+	assert.Equal(t, int64(2), stats.ThrottledEventsMetric.Get())
+	assert.Equal(t, int64(20), stats.ThrottledBytesMetric.Get())
+}
+
+func TestRateLimiterSample(t *testing.T) {
+	stats := newTestStatistics()
+	r, err := newRateLimiter(&config.RateLimitConfig{EventsPerSecond: 1, Policy: "sample", SampleRatio: 1}, stats)
+	require.NoError(t, err)
+	now := time.Unix(1000000, 0)
+	r.nowFunc = func() time.Time { return now }
+	for i := 0; i < 10; i++ {
+		assert.True(t, r.allow(1, nil))
+	}
+	assert.Equal(t, int64(9), stats.ThrottledEventsMetric.Get())
+}
+
+func TestRateLimiterBlock(t *testing.T) {
+	stats := newTestStatistics()
+	r, err := newRateLimiter(&config.RateLimitConfig{EventsPerSecond: 20, Policy: "block"}, stats)
+	require.NoError(t, err)
+	begin := time.Now()
+	for i := 0; i < 30; i++ {
+		assert.True(t, r.allow(1, nil))
+	}
+	assert.GreaterOrEqual(t, time.Since(begin), 400*time.Millisecond)
+	assert.Equal(t, int64(10), stats.ThrottledEventsMetric.Get())
+
+	// waiting is stopped by the cancel token
+	cancel := make(chan struct{})
+	close(cancel)
+	begin = time.Now()
+	assert.True(t, r.allow(1, cancel))
+	assert.Less(t, time.Since(begin), 100*time.Millisecond)
+}
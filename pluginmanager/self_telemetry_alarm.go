@@ -37,7 +37,7 @@ func (r *InputAlarm) Description() string {
 
 func (r *InputAlarm) Collect(collector pipeline.Collector) error {
 	loggroup := &protocol.LogGroup{}
-	for _, config := range LogtailConfig {
+	for _, config := range getLogtailConfigs() {
 		alarm := config.Context.GetRuntimeContext().Value(pkg.LogTailMeta).(*pkg.LogtailContextMeta).GetAlarm()
 		if alarm != nil {
 			alarm.SerializeToPb(loggroup)
@@ -0,0 +1,153 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+const selfMetricNamespace = "ilogtail"
+
+var selfMetricLabels = []string{"project", "logstore", "config", "plugin"}
+
+var invalidMetricNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+var (
+	// runningConfigs are the configs whose self metrics are exported, which is updated by Resume and HoldOn
+	// because LogtailConfig is not safe to read while loading configs. It is nil while loading configs.
+	runningConfigs     []*LogstoreConfig
+	runningConfigsLock sync.RWMutex
+
+	selfMetricsHandler     http.Handler
+	selfMetricsHandlerOnce sync.Once
+)
+
+func setRunningConfigs(configs map[string]*LogstoreConfig) {
+	var running []*LogstoreConfig
+	if configs != nil {
+		running = make([]*LogstoreConfig, 0, len(configs))
+	}
+	for _, config := range configs {
+		running = append(running, config)
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].ConfigName < running[j].ConfigName })
+	runningConfigsLock.Lock()
+	runningConfigs = running
+	runningConfigsLock.Unlock()
+}
+
+func getRunningConfigs() []*LogstoreConfig {
+	runningConfigsLock.RLock()
+	defer runningConfigsLock.RUnlock()
+	return runningConfigs
+}
+
+// SelfMetricsHandler returns the handler exporting the self metrics of the running configs and the Go runtime
+// in the Prometheus text format, or the OpenMetrics format when it is accepted by the scraper.
+func SelfMetricsHandler() http.Handler {
+	selfMetricsHandlerOnce.Do(func() {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(
+			selfMetricCollector{},
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{Namespace: selfMetricNamespace}),
+		)
+		selfMetricsHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+			ErrorHandling:     promhttp.ContinueOnError,
+		})
+	})
+	return selfMetricsHandler
+}
+
+// selfMetricCollector converts the self metrics registered to the contexts of the running configs:
+// CounterMetric to counter, average CounterMetric and GaugeMetric to gauge, LatencyMetric to histogram in seconds,
+// HistogramMetric to histogram. The labels of the metric vectors follow the labels of the config and the plugin.
+// The string metrics are not exported.
+type selfMetricCollector struct{}
+
+// Describe sends nothing, so that the collector is unchecked because the metrics are registered by plugins dynamically.
+func (selfMetricCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (selfMetricCollector) Collect(ch chan<- prometheus.Metric) {
+	descs := make(map[string]*prometheus.Desc)
+	for _, config := range getRunningConfigs() {
+		contextImp, ok := config.Context.(*ContextImp)
+		if !ok {
+			continue
+		}
+		labels := []string{contextImp.GetProject(), contextImp.GetLogstore(), contextImp.GetConfigName()}
+		for _, m := range contextImp.pluginMetrics() {
+			metricLabels := append(labels[:len(labels):len(labels)], m.plugin)
+			vec, ok := m.metric.(pipeline.MetricVec)
+			if !ok {
+				collectSelfMetric(ch, descs, m.metric.Name(), nil, metricLabels, m.metric)
+				continue
+			}
+			vec.Collect(func(labelValues []string, metric interface{}) {
+				collectSelfMetric(ch, descs, vec.Name(), vec.LabelNames(), append(metricLabels[:len(metricLabels):len(metricLabels)], labelValues...), metric)
+			})
+		}
+	}
+}
+
+func collectSelfMetric(ch chan<- prometheus.Metric, descs map[string]*prometheus.Desc, name string, labelNames, labelValues []string, m interface{}) {
+	fqName := prometheus.BuildFQName(selfMetricNamespace, "", invalidMetricNameChars.ReplaceAllString(name, "_"))
+	desc := func(suffix string) *prometheus.Desc {
+		if d, ok := descs[fqName+suffix]; ok {
+			return d
+		}
+		d := prometheus.NewDesc(fqName+suffix, "ilogtail self metric "+name, append(selfMetricLabels[:len(selfMetricLabels):len(selfMetricLabels)], labelNames...), nil)
+		descs[fqName+suffix] = d
+		return d
+	}
+	var metric prometheus.Metric
+	var err error
+	switch v := m.(type) {
+	case helper.HistogramReader:
+		suffix := ""
+		if _, ok := v.(pipeline.LatencyMetric); ok {
+			suffix = "_seconds"
+		}
+		count, sum, buckets := v.Histogram()
+		metric, err = prometheus.NewConstHistogram(desc(suffix), count, sum, buckets, labelValues...)
+	case helper.GaugeReader:
+		metric, err = prometheus.NewConstMetric(desc(""), prometheus.GaugeValue, v.Value(), labelValues...)
+	case pipeline.GaugeMetric:
+		metric, err = prometheus.NewConstMetric(desc(""), prometheus.GaugeValue, v.Get(), labelValues...)
+	case helper.CumulativeReader:
+		metric, err = prometheus.NewConstMetric(desc("_total"), prometheus.CounterValue, float64(v.Total()), labelValues...)
+	case pipeline.LatencyMetric:
+		metric, err = prometheus.NewConstMetric(desc("_seconds"), prometheus.GaugeValue, float64(v.Get())/1e9, labelValues...)
+	case pipeline.CounterMetric:
+		metric, err = prometheus.NewConstMetric(desc(""), prometheus.GaugeValue, float64(v.Get()), labelValues...)
+	default:
+		return
+	}
+	if err != nil {
+		metric = prometheus.NewInvalidMetric(desc(""), err)
+	}
+	ch <- metric
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginmanager
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+)
+
+func scrapeSelfMetrics(t *testing.T, accept string) (string, string) {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	SelfMetricsHandler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	return w.Header().Get("Content-Type"), string(body)
+}
+
+func TestSelfMetricsHandler(t *testing.T) {
+	lc := &LogstoreConfig{ConfigName: "c"}
+	ctx := &ContextImp{}
+	ctx.InitContext("p", "l", "c")
+	lc.Context = ctx
+	lc.Statistics.Init(ctx)
+	restore := bindPluginMetrics(lc, "processor_regex/2")
+	matched := helper.NewCounterMetricAndRegister("regex_match", ctx)
+	latency := helper.NewLatencyMetricAndRegister("process.latency", ctx)
+	sizes := helper.NewHistogramVecAndRegister("event_size", []string{"topic"}, []float64{10, 100}, 0, ctx)
+	restore()
+	queues := helper.NewGaugeVecAndRegister("queue_size", []string{"queue"}, 0, ctx)
+	setRunningConfigs(map[string]*LogstoreConfig{"c": lc})
+	defer setRunningConfigs(nil)
+
+	lc.Statistics.RawLogMetric.Add(3)
+	lc.Statistics.FlushReadyMetric.Add(4)
+	matched.Add(2)
+	latency.Begin()
+	latency.End()
+	// the metrics serialized to SLS are reset, but the exported ones are cumulative.
+	ctx.MetricSerializeToPB(&protocol.Log{})
+	matched.Add(1)
+	sizes.WithLabelValues("t1").Observe(50)
+	queues.WithLabelValues("q1").Set(7)
+
+	contentType, body := scrapeSelfMetrics(t, "")
+	assert.Contains(t, contentType, "text/plain")
+	assert.Contains(t, body, "# TYPE ilogtail_raw_log_total counter")
+	assert.Contains(t, body, `ilogtail_raw_log_total{config="c",logstore="l",plugin="",project="p"} 3`)
+	assert.Contains(t, body, "# TYPE ilogtail_flush_ready gauge")
+	assert.Contains(t, body, `ilogtail_regex_match_total{config="c",logstore="l",plugin="processor_regex/2",project="p"} 3`)
+	assert.Contains(t, body, "# TYPE ilogtail_process_latency_seconds histogram")
+	assert.Contains(t, body, `ilogtail_process_latency_seconds_count{config="c",logstore="l",plugin="processor_regex/2",project="p"} 1`)
+	assert.Contains(t, body, `ilogtail_process_latency_seconds_bucket{config="c",logstore="l",plugin="processor_regex/2",project="p",le="+Inf"} 1`)
+	assert.Contains(t, body, "# TYPE ilogtail_collect_latency_seconds histogram")
+	assert.Contains(t, body, "# TYPE ilogtail_event_size histogram")
+	assert.Contains(t, body, `ilogtail_event_size_bucket{config="c",logstore="l",plugin="processor_regex/2",project="p",topic="t1",le="10"} 0`)
+	assert.Contains(t, body, `ilogtail_event_size_bucket{config="c",logstore="l",plugin="processor_regex/2",project="p",topic="t1",le="100"} 1`)
+	assert.Contains(t, body, `ilogtail_queue_size{config="c",logstore="l",plugin="",project="p",queue="q1"} 7`)
+	assert.Contains(t, body, "go_goroutines")
+
+	contentType, body = scrapeSelfMetrics(t, "application/openmetrics-text; version=0.0.1")
+	assert.Contains(t, contentType, "application/openmetrics-text")
+	assert.Contains(t, body, "# TYPE ilogtail_raw_log counter")
+	assert.Contains(t, body, `ilogtail_raw_log_total{config="c",logstore="l",plugin="",project="p"} 3.0`)
+	assert.Contains(t, body, "# EOF")
+
+	setRunningConfigs(nil)
+	_, body = scrapeSelfMetrics(t, "")
+	assert.NotContains(t, body, "ilogtail_raw_log_total")
+}
@@ -34,7 +34,7 @@ func (r *InputStatistics) Description() string {
 }
 
 func (r *InputStatistics) Collect(collector pipeline.Collector) error {
-	for _, config := range LogtailConfig {
+	for _, config := range getLogtailConfigs() {
 		log := &protocol.Log{}
 		config.Context.MetricSerializeToPB(log)
 		if len(log.Contents) > 0 && StatisticsConfig != nil {
@@ -24,6 +24,8 @@ import (
 )
 
 type ServiceWrapper struct {
+	// Name is the plugin name with the optional ID, which labels the restarts of the plugin.
+	Name     string
 	Input    pipeline.ServiceInputV1
 	Config   *LogstoreConfig
 	Tags     map[string]string
@@ -35,15 +37,27 @@ type ServiceWrapper struct {
 func (p *ServiceWrapper) Run(cc *pipeline.AsyncControl) {
 	logger.Info(p.Config.Context.GetRuntimeContext(), "start run service", p.Input)
 
-	go func() {
-		defer panicRecover(p.Input.Description())
-		err := p.Input.Start(p)
-		if err != nil {
-			logger.Error(p.Config.Context.GetRuntimeContext(), "PLUGIN_ALARM", "start service error, err", err)
-		}
-		logger.Info(p.Config.Context.GetRuntimeContext(), "service done", p.Input.Description())
-	}()
+	go p.Config.supervisor.run(p.Name, p.start, p.reinit, cc, cc.CancelToken())
+}
+
+// start runs the service, the error of Start is a failure restarting it.
+func (p *ServiceWrapper) start(cc *pipeline.AsyncControl) error {
+	span := p.Config.startSpan(spanInput, p.Name, unknownGroupSize)
+	err := p.Input.Start(p)
+	span.finish(err)
+	if err != nil {
+		logger.Error(p.Config.Context.GetRuntimeContext(), "PLUGIN_ALARM", "start service error, err", err)
+	}
+	logger.Info(p.Config.Context.GetRuntimeContext(), "service done", p.Input.Description())
+	return err
+}
 
+// reinit stops and re-initializes the service when it is restarted.
+func (p *ServiceWrapper) reinit() error {
+	return reinitPlugin(p.Config, p.Name, p.Input.Stop, func() error {
+		_, err := p.Input.Init(p.Config.Context)
+		return err
+	})
 }
 
 func (p *ServiceWrapper) Stop() error {
@@ -0,0 +1,327 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logmetric
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+const (
+	pluginName = "aggregator_log_to_metric"
+
+	aggCount     = "count"
+	aggSum       = "sum"
+	aggMin       = "min"
+	aggMax       = "max"
+	aggAvg       = "avg"
+	aggSummary   = "summary"
+	aggHistogram = "histogram"
+
+	groupKeySeparator = "\x00"
+)
+
+// MetricDefinition describes one metric derived from the logs, every definition produces
+// one metric event per group and window.
+type MetricDefinition struct {
+	Name        string    `comment:"the metric name."`
+	Type        string    `comment:"the aggregation, one of count, sum, min, max, avg, summary and histogram."`
+	ValueKey    string    `comment:"the content key holding the numeric value, not required for count."`
+	Percentiles []float64 `comment:"the quantiles (0~1) of a summary metric."`
+	Buckets     []float64 `comment:"the increasing upper bounds of a histogram metric."`
+}
+
+// AggregatorLogToMetric aggregates logs to metrics over tumbling windows aligned to WindowSeconds.
+type AggregatorLogToMetric struct {
+	GroupKeys          []string           `comment:"the content keys to group by, they become the metric labels."`
+	Metrics            []MetricDefinition `comment:"the metrics to compute."`
+	WindowSeconds      int                `comment:"the window size in seconds."`
+	MaxDelaySeconds    int                `comment:"how long a window is kept open after its end to wait for late events."`
+	MaxLeadSeconds     int                `comment:"how far the event time may be ahead of now, the events further ahead are dropped."`
+	UseEventTime       bool               `comment:"assign logs to windows by the log time, otherwise by the arrival time."`
+	MaxGroupsPerWindow int                `comment:"the maximum count of distinct groups in a window, logs of new groups are dropped when exceeded."`
+	MaxSamples         int                `comment:"the maximum count of samples kept per summary metric for percentile computing."`
+
+	context pipeline.Context
+	lock    sync.Mutex
+	windows map[int64]*window
+	nowFunc func() time.Time
+
+	droppedMetric     pipeline.CounterMetric
+	invalidMetric     pipeline.CounterMetric
+	lateEventsMetric  pipeline.CounterMetric
+	earlyEventsMetric pipeline.CounterMetric
+}
+
+type window struct {
+	start  int64
+	groups map[string]*group
+}
+
+type group struct {
+	tags    map[string]string
+	metrics []*helper.Distribution
+}
+
+func (a *AggregatorLogToMetric) Init(context pipeline.Context, que pipeline.LogGroupQueue) (int, error) {
+	a.context = context
+	if a.WindowSeconds <= 0 {
+		return 0, fmt.Errorf("invalid WindowSeconds %v for plugin %v", a.WindowSeconds, pluginName)
+	}
+	if len(a.Metrics) == 0 {
+		return 0, fmt.Errorf("must specify Metrics for plugin %v", pluginName)
+	}
+	if a.MaxLeadSeconds < 0 {
+		return 0, fmt.Errorf("invalid MaxLeadSeconds %v for plugin %v", a.MaxLeadSeconds, pluginName)
+	}
+	for i := range a.Metrics {
+		m := &a.Metrics[i]
+		if m.Name == "" {
+			return 0, fmt.Errorf("must specify Name of the %vth metric for plugin %v", i, pluginName)
+		}
+		m.Type = strings.ToLower(m.Type)
+		switch m.Type {
+		case aggCount:
+		case aggSum, aggMin, aggMax, aggAvg:
+			if m.ValueKey == "" {
+				return 0, fmt.Errorf("must specify ValueKey of metric %v for plugin %v", m.Name, pluginName)
+			}
+		case aggSummary:
+			if m.ValueKey == "" || len(m.Percentiles) == 0 {
+				return 0, fmt.Errorf("must specify ValueKey and Percentiles of metric %v for plugin %v", m.Name, pluginName)
+			}
+		case aggHistogram:
+			if m.ValueKey == "" || len(m.Buckets) == 0 {
+				return 0, fmt.Errorf("must specify ValueKey and Buckets of metric %v for plugin %v", m.Name, pluginName)
+			}
+			if !sort.Float64sAreSorted(m.Buckets) {
+				return 0, fmt.Errorf("buckets of metric %v must be in increasing order", m.Name)
+			}
+		default:
+			return 0, fmt.Errorf("unknown type %v of metric %v for plugin %v", m.Type, m.Name, pluginName)
+		}
+	}
+	a.windows = make(map[int64]*window)
+	if a.nowFunc == nil {
+		a.nowFunc = time.Now
+	}
+	a.droppedMetric = helper.NewCounterMetricAndRegister("log_to_metric_dropped_groups", context)
+	a.invalidMetric = helper.NewCounterMetricAndRegister("log_to_metric_invalid_values", context)
+	a.lateEventsMetric = helper.NewCounterMetricAndRegister("log_to_metric_late_events", context)
+	a.earlyEventsMetric = helper.NewCounterMetricAndRegister("log_to_metric_early_events", context)
+	// check closed windows every second, windows are emitted once they are closed
+	return 1000, nil
+}
+
+func (*AggregatorLogToMetric) Description() string {
+	return "aggregator to compute metrics from logs over time windows"
+}
+
+func (a *AggregatorLogToMetric) Record(events *models.PipelineGroupEvents, ctx pipeline.PipelineContext) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	windowNanos := int64(a.WindowSeconds) * int64(time.Second)
+	now := a.nowFunc().UnixNano()
+	deadline := now - int64(a.MaxDelaySeconds)*int64(time.Second)
+	// the open windows are bounded by the deadline and the lead, so that wrong event times do not open windows
+	// without bound
+	lead := now + int64(a.MaxLeadSeconds)*int64(time.Second)
+	for _, event := range events.Events {
+		if event.GetType() != models.EventTypeLogging {
+			continue
+		}
+		log := event.(*models.Log)
+		ts := now
+		if a.UseEventTime && log.GetTimestamp() != 0 {
+			ts = int64(log.GetTimestamp())
+		}
+		if ts > lead {
+			a.earlyEventsMetric.Add(1)
+			continue
+		}
+		start := ts - ts%windowNanos
+		// the window has been emitted or would be emitted by next GetResult
+		if start+windowNanos <= deadline {
+			a.lateEventsMetric.Add(1)
+			continue
+		}
+		w, ok := a.windows[start]
+		if !ok {
+			w = &window{start: start, groups: make(map[string]*group)}
+			a.windows[start] = w
+		}
+		a.record(w, log)
+	}
+	return nil
+}
+
+func (a *AggregatorLogToMetric) record(w *window, log *models.Log) {
+	values := make([]string, len(a.GroupKeys))
+	for i, key := range a.GroupKeys {
+		values[i], _ = lookup(log, key)
+	}
+	key := strings.Join(values, groupKeySeparator)
+	g, ok := w.groups[key]
+	if !ok {
+		if a.MaxGroupsPerWindow > 0 && len(w.groups) >= a.MaxGroupsPerWindow {
+			a.droppedMetric.Add(1)
+			return
+		}
+		g = &group{tags: make(map[string]string, len(a.GroupKeys)), metrics: make([]*helper.Distribution, len(a.Metrics))}
+		for i, k := range a.GroupKeys {
+			g.tags[k] = values[i]
+		}
+		for i, m := range a.Metrics {
+			maxSamples := 0
+			if m.Type == aggSummary {
+				maxSamples = a.MaxSamples
+			}
+			g.metrics[i] = helper.NewDistribution(m.Buckets, maxSamples)
+		}
+		w.groups[key] = g
+	}
+	for i, m := range a.Metrics {
+		if m.Type == aggCount && m.ValueKey == "" {
+			g.metrics[i].Observe(1)
+			continue
+		}
+		raw, ok := lookup(log, m.ValueKey)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			a.invalidMetric.Add(1)
+			continue
+		}
+		g.metrics[i].Observe(v)
+	}
+}
+
+func (a *AggregatorLogToMetric) GetResult(ctx pipeline.PipelineContext) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	windowNanos := int64(a.WindowSeconds) * int64(time.Second)
+	deadline := a.nowFunc().UnixNano() - int64(a.MaxDelaySeconds)*int64(time.Second)
+	starts := make([]int64, 0, len(a.windows))
+	for start := range a.windows {
+		if start+windowNanos <= deadline {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	for _, start := range starts {
+		w := a.windows[start]
+		delete(a.windows, start)
+		events := make([]models.PipelineEvent, 0, len(w.groups)*len(a.Metrics))
+		for _, g := range w.groups {
+			for i, m := range a.Metrics {
+				events = append(events, a.buildMetric(&m, g, g.metrics[i], start, start+windowNanos))
+			}
+		}
+		ctx.Collector().Collect(models.NewGroup(models.NewMetadata(), models.NewTags()), events...)
+	}
+	return nil
+}
+
+func (a *AggregatorLogToMetric) buildMetric(m *MetricDefinition, g *group, d *helper.Distribution, start, end int64) *models.Metric {
+	tags := models.NewTagsWithMap(copyTags(g.tags))
+	var metric *models.Metric
+	switch m.Type {
+	case aggCount:
+		metric = models.NewSingleValueMetric(m.Name, models.MetricTypeCounter, tags, end, d.Count())
+	case aggSum:
+		metric = models.NewSingleValueMetric(m.Name, models.MetricTypeCounter, tags, end, d.Sum())
+	case aggMin:
+		metric = models.NewSingleValueMetric(m.Name, models.MetricTypeGauge, tags, end, d.Min())
+	case aggMax:
+		metric = models.NewSingleValueMetric(m.Name, models.MetricTypeGauge, tags, end, d.Max())
+	case aggAvg:
+		metric = models.NewSingleValueMetric(m.Name, models.MetricTypeGauge, tags, end, d.Avg())
+	case aggSummary:
+		metric = models.NewMultiValuesMetric(m.Name, models.MetricTypeSummary, tags, end, d.SummaryValues(m.Percentiles).GetMultiValues())
+	case aggHistogram:
+		metric = models.NewMultiValuesMetric(m.Name, models.MetricTypeHistogram, tags, end, d.HistogramValues().GetMultiValues())
+	}
+	// the observed timestamp carries the window start, like the start timestamp of otlp data points
+	metric.SetObservedTimestamp(uint64(start))
+	return metric
+}
+
+func (a *AggregatorLogToMetric) Reset() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if len(a.windows) > 0 {
+		logger.Warning(a.context.GetRuntimeContext(), "AGGREGATOR_RESET_ALARM", "drop unclosed windows", len(a.windows))
+	}
+	a.windows = make(map[int64]*window)
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	res := make(map[string]string, len(tags))
+	for k, v := range tags {
+		res[k] = v
+	}
+	return res
+}
+
+// lookup finds the key in the log contents first, then in the log tags, because the v2 runner
+// converts the non-content fields of v1 logs into tags.
+func lookup(log *models.Log, key string) (string, bool) {
+	contents := log.GetIndices()
+	if contents.Contains(key) {
+		switch val := contents.Get(key).(type) {
+		case string:
+			return val, true
+		case []byte:
+			return string(val), true
+		case nil:
+			return "", true
+		default:
+			return fmt.Sprint(val), true
+		}
+	}
+	tags := log.GetTags()
+	if tags.Contains(key) {
+		return tags.Get(key), true
+	}
+	return "", false
+}
+
+func NewAggregatorLogToMetric() *AggregatorLogToMetric {
+	return &AggregatorLogToMetric{
+		WindowSeconds:      60,
+		MaxDelaySeconds:    5,
+		MaxLeadSeconds:     60,
+		UseEventTime:       true,
+		MaxGroupsPerWindow: 10000,
+		MaxSamples:         helper.DefaultDistributionSamples,
+	}
+}
+
+func init() {
+	pipeline.Aggregators[pluginName] = func() pipeline.Aggregator {
+		return NewAggregatorLogToMetric()
+	}
+}
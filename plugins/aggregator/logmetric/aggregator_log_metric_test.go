@@ -0,0 +1,154 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logmetric
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+func newTestAggregator(t *testing.T, now *time.Time) *AggregatorLogToMetric {
+	agg := NewAggregatorLogToMetric()
+	agg.GroupKeys = []string{"method", "status"}
+	agg.Metrics = []MetricDefinition{
+		{Name: "requests", Type: "count"},
+		{Name: "latency_sum", Type: "sum", ValueKey: "latency"},
+		{Name: "latency_max", Type: "max", ValueKey: "latency"},
+		{Name: "latency", Type: "summary", ValueKey: "latency", Percentiles: []float64{0.5, 0.99}},
+		{Name: "latency_histogram", Type: "histogram", ValueKey: "latency", Buckets: []float64{10, 100}},
+	}
+	agg.nowFunc = func() time.Time { return *now }
+	interval, err := agg.Init(mock.NewEmptyContext("p", "l", "c"), nil)
+	require.NoError(t, err)
+	require.Equal(t, 1000, interval)
+	return agg
+}
+
+func newAccessLog(ts time.Time, method, status string, latency int) *models.Log {
+	log := models.NewLog("", nil, "", "", "", models.NewTagsWithKeyValues("status", status), uint64(ts.UnixNano()))
+	log.GetIndices().Add("method", method)
+	log.GetIndices().Add("latency", []byte(strconv.Itoa(latency)))
+	return log
+}
+
+func TestInitInvalid(t *testing.T) {
+	for _, metrics := range [][]MetricDefinition{
+		nil,
+		{{Name: "", Type: "count"}},
+		{{Name: "m", Type: "unknown"}},
+		{{Name: "m", Type: "sum"}},
+		{{Name: "m", Type: "summary", ValueKey: "v"}},
+		{{Name: "m", Type: "histogram", ValueKey: "v", Buckets: []float64{10, 1}}},
+	} {
+		agg := NewAggregatorLogToMetric()
+		agg.Metrics = metrics
+		_, err := agg.Init(mock.NewEmptyContext("p", "l", "c"), nil)
+		assert.Error(t, err, "%v", metrics)
+	}
+	agg := NewAggregatorLogToMetric()
+	agg.Metrics = []MetricDefinition{{Name: "m", Type: "count"}}
+	agg.MaxLeadSeconds = -1
+	_, err := agg.Init(mock.NewEmptyContext("p", "l", "c"), nil)
+	assert.Error(t, err)
+}
+
+func TestAggregate(t *testing.T) {
+	now := time.Unix(1000020, 0)
+	agg := newTestAggregator(t, &now)
+	events := make([]models.PipelineEvent, 0)
+	for i := 1; i <= 100; i++ {
+		events = append(events, newAccessLog(now, "GET", "200", i))
+	}
+	events = append(events, newAccessLog(now, "POST", "500", 7))
+	// late event of an emitted window
+	events = append(events, newAccessLog(now.Add(-2*time.Minute), "GET", "200", 1))
+	// event too far ahead of now
+	events = append(events, newAccessLog(now.Add(time.Hour), "GET", "200", 1))
+	ctx := pipeline.NewObservePipelineConext(100)
+	require.NoError(t, agg.Record(&models.PipelineGroupEvents{Events: events}, ctx))
+	assert.Equal(t, int64(1), agg.lateEventsMetric.Get())
+	assert.Equal(t, int64(1), agg.earlyEventsMetric.Get())
+	assert.Len(t, agg.windows, 1)
+
+	// window [1000020, 1000080) is not closed yet
+	require.NoError(t, agg.GetResult(ctx))
+	assert.Empty(t, ctx.Collector().ToArray())
+
+	// the window is emitted after MaxDelaySeconds
+	now = now.Add(time.Minute)
+	require.NoError(t, agg.GetResult(ctx))
+	assert.Empty(t, ctx.Collector().ToArray())
+	now = now.Add(5 * time.Second)
+	require.NoError(t, agg.GetResult(ctx))
+	results := ctx.Collector().ToArray()
+	require.Len(t, results, 1)
+	require.Len(t, results[0].Events, 10)
+
+	for _, event := range results[0].Events {
+		metric := event.(*models.Metric)
+		assert.Equal(t, uint64(time.Unix(1000080, 0).UnixNano()), metric.GetTimestamp())
+		assert.Equal(t, uint64(time.Unix(1000020, 0).UnixNano()), metric.GetObservedTimestamp())
+		if metric.GetTags().Get("method") != "GET" {
+			continue
+		}
+		assert.Equal(t, "200", metric.GetTags().Get("status"))
+		switch metric.GetName() {
+		case "requests":
+			assert.Equal(t, models.MetricTypeCounter, metric.GetMetricType())
+			assert.Equal(t, float64(100), metric.GetValue().GetSingleValue())
+		case "latency_sum":
+			assert.Equal(t, float64(5050), metric.GetValue().GetSingleValue())
+		case "latency_max":
+			assert.Equal(t, float64(100), metric.GetValue().GetSingleValue())
+		case "latency":
+			assert.Equal(t, models.MetricTypeSummary, metric.GetMetricType())
+			assert.Equal(t, float64(50), metric.GetValue().GetMultiValues().Get("0.5"))
+			assert.Equal(t, float64(99), metric.GetValue().GetMultiValues().Get("0.99"))
+		case "latency_histogram":
+			assert.Equal(t, models.MetricTypeHistogram, metric.GetMetricType())
+			assert.Equal(t, float64(10), metric.GetValue().GetMultiValues().Get("(-Inf,10]"))
+			assert.Equal(t, float64(90), metric.GetValue().GetMultiValues().Get("(10,100]"))
+		default:
+			t.Errorf("unexpected metric %v", metric.GetName())
+		}
+	}
+	assert.Empty(t, agg.windows)
+}
+
+func TestMaxGroupsPerWindow(t *testing.T) {
+	now := time.Unix(1000020, 0)
+	agg := newTestAggregator(t, &now)
+	agg.MaxGroupsPerWindow = 1
+	ctx := pipeline.NewObservePipelineConext(100)
+	events := []models.PipelineEvent{
+		newAccessLog(now, "GET", "200", 1),
+		newAccessLog(now, "PUT", "200", 1),
+		newAccessLog(now, "GET", "200", 1),
+	}
+	require.NoError(t, agg.Record(&models.PipelineGroupEvents{Events: events}, ctx))
+	assert.Equal(t, int64(1), agg.droppedMetric.Get())
+	assert.Len(t, agg.windows[time.Unix(1000020, 0).UnixNano()].groups, 1)
+
+	agg.Reset()
+	assert.Empty(t, agg.windows)
+}
@@ -0,0 +1,229 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanmetrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+const (
+	pluginName = "aggregator_span_metrics"
+
+	tagServiceName = "service.name"
+	tagSpanName    = "span.name"
+	tagSpanKind    = "span.kind"
+	tagStatusCode  = "status.code"
+
+	seriesKeySeparator = "\x00"
+)
+
+var (
+	defaultBucketsMs = []float64{2, 4, 6, 8, 10, 50, 100, 200, 400, 800, 1000, 1400, 2000, 5000, 10000, 15000}
+
+	statusCodeTexts = map[models.StatusCode]string{
+		models.StatusCodeUnSet: "UNSET",
+		models.StatusCodeOK:    "OK",
+		models.StatusCodeError: "ERROR",
+	}
+)
+
+// AggregatorSpanMetrics derives RED metrics from spans: the request count, the error count and the latency histogram
+// per service, operation, kind, status and the configured dimensions.
+type AggregatorSpanMetrics struct {
+	Namespace            string    `comment:"the prefix of the metric names."`
+	Dimensions           []string  `comment:"the span tags or resource attributes added as extra metric labels."`
+	BucketsMs            []float64 `comment:"the increasing upper bounds of the latency histogram in milliseconds."`
+	FlushIntervalSeconds int       `comment:"the interval to emit the metrics, every interval emits the delta since last emission."`
+	MaxSeries            int       `comment:"the maximum count of distinct label sets in an interval, spans of new label sets are dropped when exceeded."`
+	KeepSpans            bool      `comment:"pass the spans through besides the metrics, set false if another aggregator outputs the spans."`
+
+	context pipeline.Context
+	lock    sync.Mutex
+	series  map[string]*series
+	start   time.Time
+	nowFunc func() time.Time
+
+	droppedMetric pipeline.CounterMetric
+}
+
+type series struct {
+	tags     map[string]string
+	errors   int64
+	duration *helper.Distribution
+}
+
+func (a *AggregatorSpanMetrics) Init(context pipeline.Context, que pipeline.LogGroupQueue) (int, error) {
+	a.context = context
+	if a.FlushIntervalSeconds <= 0 {
+		return 0, fmt.Errorf("invalid FlushIntervalSeconds %v for plugin %v", a.FlushIntervalSeconds, pluginName)
+	}
+	if len(a.BucketsMs) == 0 {
+		a.BucketsMs = defaultBucketsMs
+	}
+	if !sort.Float64sAreSorted(a.BucketsMs) {
+		return 0, fmt.Errorf("BucketsMs must be in increasing order for plugin %v", pluginName)
+	}
+	if a.nowFunc == nil {
+		a.nowFunc = time.Now
+	}
+	a.series = make(map[string]*series)
+	a.start = a.nowFunc()
+	a.droppedMetric = helper.NewCounterMetricAndRegister("span_metrics_dropped_spans", context)
+	return a.FlushIntervalSeconds * 1000, nil
+}
+
+func (*AggregatorSpanMetrics) Description() string {
+	return "aggregator to derive request, error and latency metrics from spans"
+}
+
+func (a *AggregatorSpanMetrics) Record(events *models.PipelineGroupEvents, ctx pipeline.PipelineContext) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, event := range events.Events {
+		span, ok := event.(*models.Span)
+		if !ok {
+			continue
+		}
+		a.record(events.Group, span)
+	}
+	if a.KeepSpans {
+		ctx.Collector().Collect(events.Group, events.Events...)
+	}
+	return nil
+}
+
+func (a *AggregatorSpanMetrics) record(group *models.GroupInfo, span *models.Span) {
+	values := make([]string, 4+len(a.Dimensions))
+	values[0] = lookup(group, span, tagServiceName)
+	values[1] = span.GetName()
+	values[2] = string(models.SpanKindTexts[span.GetKind()])
+	values[3] = statusCodeTexts[span.GetStatus()]
+	for i, dim := range a.Dimensions {
+		values[4+i] = lookup(group, span, dim)
+	}
+	key := strings.Join(values, seriesKeySeparator)
+	s, ok := a.series[key]
+	if !ok {
+		if a.MaxSeries > 0 && len(a.series) >= a.MaxSeries {
+			a.droppedMetric.Add(1)
+			return
+		}
+		s = &series{tags: make(map[string]string, len(values)), duration: helper.NewDistribution(a.BucketsMs, 0)}
+		for i, k := range append([]string{tagServiceName, tagSpanName, tagSpanKind, tagStatusCode}, a.Dimensions...) {
+			if values[i] != "" {
+				s.tags[k] = values[i]
+			}
+		}
+		a.series[key] = s
+	}
+	var durationMs float64
+	if span.GetEndTime() > span.GetStartTime() {
+		durationMs = float64(span.GetEndTime()-span.GetStartTime()) / float64(time.Millisecond)
+	}
+	s.duration.Observe(durationMs)
+	if span.GetStatus() == models.StatusCodeError {
+		s.errors++
+	}
+}
+
+func (a *AggregatorSpanMetrics) GetResult(ctx pipeline.PipelineContext) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	now := a.nowFunc()
+	start, end := a.start.UnixNano(), now.UnixNano()
+	events := make([]models.PipelineEvent, 0, len(a.series)*3)
+	for _, s := range a.series {
+		events = append(events, a.buildMetric("calls", models.MetricTypeCounter, s, start, end, float64(s.duration.Count())))
+		if s.errors > 0 {
+			events = append(events, a.buildMetric("errors", models.MetricTypeCounter, s, start, end, float64(s.errors)))
+		}
+		metric := models.NewMultiValuesMetric(a.metricName("duration_ms"), models.MetricTypeHistogram, models.NewTagsWithMap(copyTags(s.tags)), end, s.duration.HistogramValues().GetMultiValues())
+		metric.SetObservedTimestamp(uint64(start))
+		events = append(events, metric)
+	}
+	a.series = make(map[string]*series)
+	a.start = now
+	if len(events) > 0 {
+		ctx.Collector().Collect(models.NewGroup(models.NewMetadata(), models.NewTags()), events...)
+	}
+	return nil
+}
+
+func (a *AggregatorSpanMetrics) buildMetric(name string, typ models.MetricType, s *series, start, end int64, value float64) *models.Metric {
+	metric := models.NewSingleValueMetric(a.metricName(name), typ, models.NewTagsWithMap(copyTags(s.tags)), end, value)
+	// the observed timestamp carries the interval start, like the start timestamp of otlp data points
+	metric.SetObservedTimestamp(uint64(start))
+	return metric
+}
+
+func (a *AggregatorSpanMetrics) metricName(name string) string {
+	if a.Namespace == "" {
+		return name
+	}
+	return a.Namespace + "_" + name
+}
+
+func (a *AggregatorSpanMetrics) Reset() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if len(a.series) > 0 {
+		logger.Warning(a.context.GetRuntimeContext(), "AGGREGATOR_RESET_ALARM", "drop unflushed span metrics", len(a.series))
+	}
+	a.series = make(map[string]*series)
+	a.start = a.nowFunc()
+}
+
+// lookup finds the key in the span tags first, then in the resource attributes and the scope tags of the group.
+func lookup(group *models.GroupInfo, span *models.Span, key string) string {
+	if tags := span.GetTags(); tags.Contains(key) {
+		return tags.Get(key)
+	}
+	if meta := group.GetMetadata(); meta.Contains(key) {
+		return meta.Get(key)
+	}
+	return group.GetTags().Get(key)
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	res := make(map[string]string, len(tags))
+	for k, v := range tags {
+		res[k] = v
+	}
+	return res
+}
+
+func NewAggregatorSpanMetrics() *AggregatorSpanMetrics {
+	return &AggregatorSpanMetrics{
+		Namespace:            "traces_span_metrics",
+		FlushIntervalSeconds: 60,
+		MaxSeries:            10000,
+		KeepSpans:            true,
+	}
+}
+
+func init() {
+	pipeline.Aggregators[pluginName] = func() pipeline.Aggregator {
+		return NewAggregatorSpanMetrics()
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanmetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+func newTestAggregator(t *testing.T, now *time.Time) *AggregatorSpanMetrics {
+	agg := NewAggregatorSpanMetrics()
+	agg.Dimensions = []string{"http.method"}
+	agg.BucketsMs = []float64{10, 100}
+	agg.nowFunc = func() time.Time { return *now }
+	interval, err := agg.Init(mock.NewEmptyContext("p", "l", "c"), nil)
+	require.NoError(t, err)
+	require.Equal(t, 60000, interval)
+	return agg
+}
+
+func newSpan(name string, status models.StatusCode, durationMs int64, tags ...string) *models.Span {
+	span := models.NewSpan(name, "t", "s", models.SpanKindServer, 0, uint64(durationMs*int64(time.Millisecond)), models.NewTagsWithKeyValues(tags...), nil, nil)
+	span.Status = status
+	return span
+}
+
+func TestInitInvalid(t *testing.T) {
+	agg := NewAggregatorSpanMetrics()
+	agg.BucketsMs = []float64{10, 1}
+	_, err := agg.Init(mock.NewEmptyContext("p", "l", "c"), nil)
+	assert.Error(t, err)
+	agg = NewAggregatorSpanMetrics()
+	agg.FlushIntervalSeconds = 0
+	_, err = agg.Init(mock.NewEmptyContext("p", "l", "c"), nil)
+	assert.Error(t, err)
+}
+
+func TestSpanMetrics(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	agg := newTestAggregator(t, &now)
+	ctx := pipeline.NewObservePipelineConext(100)
+	group := models.NewGroup(models.NewMetadataWithKeyValues(tagServiceName, "cart"), models.NewTags())
+	events := []models.PipelineEvent{
+		newSpan("GET /cart", models.StatusCodeOK, 5, "http.method", "GET"),
+		newSpan("GET /cart", models.StatusCodeOK, 50, "http.method", "GET"),
+		newSpan("GET /cart", models.StatusCodeError, 500, "http.method", "GET"),
+		newSpan("GET /cart", models.StatusCodeError, 5, "http.method", "GET"),
+		models.NewSingleValueMetric("m", models.MetricTypeGauge, models.NewTags(), 0, 1),
+	}
+	require.NoError(t, agg.Record(&models.PipelineGroupEvents{Group: group, Events: events}, ctx))
+	// spans and other events are kept
+	results := ctx.Collector().ToArray()
+	require.Len(t, results, 1)
+	assert.Len(t, results[0].Events, 5)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, agg.GetResult(ctx))
+	results = ctx.Collector().ToArray()
+	require.Len(t, results, 1)
+	// calls and duration for OK, calls, errors and duration for ERROR
+	require.Len(t, results[0].Events, 5)
+	for _, event := range results[0].Events {
+		metric := event.(*models.Metric)
+		tags := metric.GetTags()
+		assert.Equal(t, "cart", tags.Get(tagServiceName))
+		assert.Equal(t, "GET /cart", tags.Get(tagSpanName))
+		assert.Equal(t, "server", tags.Get(tagSpanKind))
+		assert.Equal(t, "GET", tags.Get("http.method"))
+		assert.Equal(t, uint64(now.UnixNano()), metric.GetTimestamp())
+		assert.Equal(t, uint64(time.Unix(1000000, 0).UnixNano()), metric.GetObservedTimestamp())
+		switch metric.GetName() {
+		case "traces_span_metrics_calls":
+			assert.Equal(t, float64(2), metric.GetValue().GetSingleValue())
+		case "traces_span_metrics_errors":
+			assert.Equal(t, "ERROR", tags.Get(tagStatusCode))
+			assert.Equal(t, float64(2), metric.GetValue().GetSingleValue())
+		case "traces_span_metrics_duration_ms":
+			assert.Equal(t, models.MetricTypeHistogram, metric.GetMetricType())
+			values := metric.GetValue().GetMultiValues()
+			if tags.Get(tagStatusCode) == "OK" {
+				assert.Equal(t, float64(1), values.Get("(-Inf,10]"))
+				assert.Equal(t, float64(1), values.Get("(10,100]"))
+			} else {
+				assert.Equal(t, float64(1), values.Get("(-Inf,10]"))
+				assert.Equal(t, float64(1), values.Get("(100,+Inf]"))
+			}
+		default:
+			t.Errorf("unexpected metric %v", metric.GetName())
+		}
+	}
+
+	// metrics are deltas of the interval
+	require.NoError(t, agg.GetResult(ctx))
+	assert.Empty(t, ctx.Collector().ToArray())
+}
+
+func TestMaxSeries(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	agg := newTestAggregator(t, &now)
+	agg.MaxSeries = 1
+	agg.KeepSpans = false
+	ctx := pipeline.NewObservePipelineConext(100)
+	events := []models.PipelineEvent{
+		newSpan("a", models.StatusCodeOK, 1),
+		newSpan("b", models.StatusCodeOK, 1),
+	}
+	require.NoError(t, agg.Record(&models.PipelineGroupEvents{Group: models.NewGroup(nil, nil), Events: events}, ctx))
+	assert.Empty(t, ctx.Collector().ToArray())
+	assert.Equal(t, int64(1), agg.droppedMetric.Get())
+	assert.Len(t, agg.series, 1)
+}
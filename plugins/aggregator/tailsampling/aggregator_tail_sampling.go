@@ -0,0 +1,212 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailsampling
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+const pluginName = "aggregator_tail_sampling"
+
+// AggregatorTailSampling buffers spans by trace id for DecisionWaitSeconds, then samples or drops
+// the whole trace by the policies. Spans arriving after the decision follow the cached decision.
+type AggregatorTailSampling struct {
+	Policies            []PolicyConfig `comment:"the sampling policies, a trace is sampled if any policy samples it."`
+	DecisionWaitSeconds int            `comment:"the time to wait since the first span of a trace before making the decision."`
+	MaxTraces           int            `comment:"the maximum count of buffered traces, the oldest trace is decided early when exceeded."`
+	MaxSpansPerTrace    int            `comment:"the maximum count of buffered spans per trace, more spans are dropped."`
+	DecisionCacheSize   int            `comment:"the maximum count of decided trace ids remembered for late spans."`
+
+	context      pipeline.Context
+	policies     []policy
+	lock         sync.Mutex
+	traces       map[string]*list.Element
+	order        *list.List
+	decided      map[string]bool
+	decidedOrder *list.List
+	nowFunc      func() time.Time
+
+	sampledTracesMetric pipeline.CounterMetric
+	droppedTracesMetric pipeline.CounterMetric
+	evictedTracesMetric pipeline.CounterMetric
+	droppedSpansMetric  pipeline.CounterMetric
+	lateSpansMetric     pipeline.CounterMetric
+	policyMetrics       []pipeline.CounterMetric
+}
+
+func (a *AggregatorTailSampling) Init(context pipeline.Context, que pipeline.LogGroupQueue) (int, error) {
+	a.context = context
+	if len(a.Policies) == 0 {
+		return 0, fmt.Errorf("must specify Policies for plugin %v", pluginName)
+	}
+	if a.DecisionWaitSeconds <= 0 {
+		return 0, fmt.Errorf("invalid DecisionWaitSeconds %v for plugin %v", a.DecisionWaitSeconds, pluginName)
+	}
+	if a.DecisionCacheSize <= 0 {
+		return 0, fmt.Errorf("invalid DecisionCacheSize %v for plugin %v", a.DecisionCacheSize, pluginName)
+	}
+	for i := range a.Policies {
+		p, err := newPolicy(&a.Policies[i])
+		if err != nil {
+			return 0, err
+		}
+		a.policies = append(a.policies, p)
+		a.policyMetrics = append(a.policyMetrics, helper.NewCounterMetricAndRegister("tail_sampling_policy_"+p.name()+"_sampled_traces", context))
+	}
+	a.traces = make(map[string]*list.Element)
+	a.order = list.New()
+	a.decided = make(map[string]bool)
+	a.decidedOrder = list.New()
+	if a.nowFunc == nil {
+		a.nowFunc = time.Now
+	}
+	a.sampledTracesMetric = helper.NewCounterMetricAndRegister("tail_sampling_sampled_traces", context)
+	a.droppedTracesMetric = helper.NewCounterMetricAndRegister("tail_sampling_dropped_traces", context)
+	a.evictedTracesMetric = helper.NewCounterMetricAndRegister("tail_sampling_evicted_traces", context)
+	a.droppedSpansMetric = helper.NewCounterMetricAndRegister("tail_sampling_dropped_spans", context)
+	a.lateSpansMetric = helper.NewCounterMetricAndRegister("tail_sampling_late_spans", context)
+	// decisions are checked every second
+	return 1000, nil
+}
+
+func (*AggregatorTailSampling) Description() string {
+	return "aggregator to sample spans by trace after a decision wait"
+}
+
+func (a *AggregatorTailSampling) Record(events *models.PipelineGroupEvents, ctx pipeline.PipelineContext) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	now := a.nowFunc()
+	others := make([]models.PipelineEvent, 0)
+	lateSpans := make([]models.PipelineEvent, 0)
+	for _, event := range events.Events {
+		span, ok := event.(*models.Span)
+		if !ok {
+			others = append(others, event)
+			continue
+		}
+		if sampled, ok := a.decided[span.TraceID]; ok {
+			a.lateSpansMetric.Add(1)
+			if sampled {
+				lateSpans = append(lateSpans, span)
+			}
+			continue
+		}
+		elem, ok := a.traces[span.TraceID]
+		if !ok {
+			if a.MaxTraces > 0 && a.order.Len() >= a.MaxTraces {
+				a.evictedTracesMetric.Add(1)
+				a.decide(a.order.Front(), ctx)
+			}
+			elem = a.order.PushBack(&trace{id: span.TraceID, firstSeen: now})
+			a.traces[span.TraceID] = elem
+		}
+		t := elem.Value.(*trace)
+		if a.MaxSpansPerTrace > 0 && len(t.spans) >= a.MaxSpansPerTrace {
+			a.droppedSpansMetric.Add(1)
+			continue
+		}
+		t.add(events.Group, span)
+	}
+	// events other than spans are not sampled
+	ctx.Collector().Collect(events.Group, others...)
+	ctx.Collector().Collect(events.Group, lateSpans...)
+	return nil
+}
+
+func (a *AggregatorTailSampling) GetResult(ctx pipeline.PipelineContext) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	deadline := a.nowFunc().Add(-time.Duration(a.DecisionWaitSeconds) * time.Second)
+	for elem := a.order.Front(); elem != nil; elem = a.order.Front() {
+		if elem.Value.(*trace).firstSeen.After(deadline) {
+			break
+		}
+		a.decide(elem, ctx)
+	}
+	return nil
+}
+
+// decide evaluates the policies on the trace, collects its spans if sampled and remembers the decision.
+func (a *AggregatorTailSampling) decide(elem *list.Element, ctx pipeline.PipelineContext) {
+	t := a.order.Remove(elem).(*trace)
+	delete(a.traces, t.id)
+	sampled := false
+	for i, p := range a.policies {
+		if p.evaluate(t) {
+			a.policyMetrics[i].Add(1)
+			sampled = true
+			break
+		}
+	}
+	a.remember(t.id, sampled)
+	if !sampled {
+		a.droppedTracesMetric.Add(1)
+		return
+	}
+	a.sampledTracesMetric.Add(1)
+	// keep the original groups, spans of one trace may come from different resources
+	for start := 0; start < len(t.spans); {
+		end := start + 1
+		for end < len(t.spans) && t.groups[end] == t.groups[start] {
+			end++
+		}
+		events := make([]models.PipelineEvent, 0, end-start)
+		for _, span := range t.spans[start:end] {
+			events = append(events, span)
+		}
+		ctx.Collector().Collect(t.groups[start], events...)
+		start = end
+	}
+}
+
+func (a *AggregatorTailSampling) remember(traceID string, sampled bool) {
+	a.decided[traceID] = sampled
+	a.decidedOrder.PushBack(traceID)
+	for a.decidedOrder.Len() > a.DecisionCacheSize {
+		delete(a.decided, a.decidedOrder.Remove(a.decidedOrder.Front()).(string))
+	}
+}
+
+func (a *AggregatorTailSampling) Reset() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.traces = make(map[string]*list.Element)
+	a.order = list.New()
+	a.decided = make(map[string]bool)
+	a.decidedOrder = list.New()
+}
+
+func NewAggregatorTailSampling() *AggregatorTailSampling {
+	return &AggregatorTailSampling{
+		DecisionWaitSeconds: 10,
+		MaxTraces:           50000,
+		MaxSpansPerTrace:    1000,
+		DecisionCacheSize:   100000,
+	}
+}
+
+func init() {
+	pipeline.Aggregators[pluginName] = func() pipeline.Aggregator {
+		return NewAggregatorTailSampling()
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailsampling
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+func newTestAggregator(t *testing.T, now *time.Time, policies ...PolicyConfig) *AggregatorTailSampling {
+	agg := NewAggregatorTailSampling()
+	agg.Policies = policies
+	agg.nowFunc = func() time.Time { return *now }
+	_, err := agg.Init(mock.NewEmptyContext("p", "l", "c"), nil)
+	require.NoError(t, err)
+	return agg
+}
+
+func newSpan(traceID, spanID, parentID string, status models.StatusCode, durationMs int64, tags ...string) *models.Span {
+	span := models.NewSpan("op", traceID, spanID, models.SpanKindServer, 0, uint64(durationMs*int64(time.Millisecond)), models.NewTagsWithKeyValues(tags...), nil, nil)
+	span.ParentSpanID = parentID
+	span.Status = status
+	return span
+}
+
+func collectSpans(ctx pipeline.PipelineContext) map[string]int {
+	res := make(map[string]int)
+	for _, group := range ctx.Collector().ToArray() {
+		for _, event := range group.Events {
+			if span, ok := event.(*models.Span); ok {
+				res[span.TraceID]++
+			}
+		}
+	}
+	return res
+}
+
+func TestInvalidPolicies(t *testing.T) {
+	for _, policy := range []PolicyConfig{
+		{Type: "unknown"},
+		{Type: policyStatusCode},
+		{Type: policyStatusCode, StatusCodes: []string{"FAIL"}},
+		{Type: policyLatency},
+		{Type: policyAttribute, Key: "k"},
+		{Type: policyAttribute, Key: "k", Regex: "("},
+		{Type: policyProbabilistic, SamplingPercentage: 101},
+		{Type: policyRateLimiting},
+	} {
+		agg := NewAggregatorTailSampling()
+		agg.Policies = []PolicyConfig{policy}
+		_, err := agg.Init(mock.NewEmptyContext("p", "l", "c"), nil)
+		assert.Error(t, err, "%v", policy)
+	}
+	agg := NewAggregatorTailSampling()
+	_, err := agg.Init(mock.NewEmptyContext("p", "l", "c"), nil)
+	assert.Error(t, err)
+	agg = NewAggregatorTailSampling()
+	agg.Policies = []PolicyConfig{{Type: policyProbabilistic, SamplingPercentage: 10}}
+	agg.DecisionCacheSize = 0
+	_, err = agg.Init(mock.NewEmptyContext("p", "l", "c"), nil)
+	assert.Error(t, err)
+}
+
+func TestTailSampling(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	agg := newTestAggregator(t, &now,
+		PolicyConfig{Type: policyStatusCode, StatusCodes: []string{"ERROR"}},
+		PolicyConfig{Type: policyLatency, ThresholdMs: 500},
+		PolicyConfig{Type: policyAttribute, Key: "http.url", Regex: "^/debug"},
+	)
+	ctx := pipeline.NewObservePipelineConext(100)
+	group := models.NewGroup(models.NewMetadataWithKeyValues(serviceNameKey, "svc"), models.NewTags())
+	events := []models.PipelineEvent{
+		newSpan("error", "1", "", models.StatusCodeOK, 10),
+		newSpan("error", "2", "1", models.StatusCodeError, 10),
+		newSpan("slow", "1", "", models.StatusCodeOK, 600),
+		newSpan("fast", "1", "", models.StatusCodeOK, 10),
+		newSpan("debug", "1", "", models.StatusCodeOK, 10, "http.url", "/debug/pprof"),
+		models.NewSingleValueMetric("m", models.MetricTypeGauge, models.NewTags(), 0, 1),
+	}
+	require.NoError(t, agg.Record(&models.PipelineGroupEvents{Group: group, Events: events}, ctx))
+	// metrics pass through immediately
+	results := ctx.Collector().ToArray()
+	require.Len(t, results, 1)
+	assert.Equal(t, models.EventTypeMetric, results[0].Events[0].GetType())
+
+	require.NoError(t, agg.GetResult(ctx))
+	assert.Empty(t, collectSpans(ctx))
+
+	now = now.Add(10 * time.Second)
+	require.NoError(t, agg.GetResult(ctx))
+	assert.Equal(t, map[string]int{"error": 2, "slow": 1, "debug": 1}, collectSpans(ctx))
+	assert.Equal(t, int64(3), agg.sampledTracesMetric.Get())
+	assert.Equal(t, int64(1), agg.droppedTracesMetric.Get())
+
+	// late spans follow the decision
+	late := []models.PipelineEvent{
+		newSpan("error", "3", "1", models.StatusCodeOK, 10),
+		newSpan("fast", "2", "1", models.StatusCodeOK, 10),
+	}
+	require.NoError(t, agg.Record(&models.PipelineGroupEvents{Group: group, Events: late}, ctx))
+	assert.Equal(t, map[string]int{"error": 1}, collectSpans(ctx))
+	assert.Equal(t, int64(2), agg.lateSpansMetric.Get())
+}
+
+func TestMemoryBounds(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	agg := newTestAggregator(t, &now, PolicyConfig{Type: policyAlwaysSample})
+	agg.MaxTraces = 2
+	agg.MaxSpansPerTrace = 2
+	agg.DecisionCacheSize = 2
+	ctx := pipeline.NewObservePipelineConext(100)
+	events := []models.PipelineEvent{
+		newSpan("a", "1", "", models.StatusCodeOK, 10),
+		newSpan("a", "2", "1", models.StatusCodeOK, 10),
+		newSpan("a", "3", "1", models.StatusCodeOK, 10),
+		newSpan("b", "1", "", models.StatusCodeOK, 10),
+		newSpan("c", "1", "", models.StatusCodeOK, 10),
+		newSpan("d", "1", "", models.StatusCodeOK, 10),
+	}
+	require.NoError(t, agg.Record(&models.PipelineGroupEvents{Group: models.NewGroup(nil, nil), Events: events}, ctx))
+	assert.Equal(t, int64(1), agg.droppedSpansMetric.Get())
+	assert.Equal(t, int64(2), agg.evictedTracesMetric.Get())
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, collectSpans(ctx))
+	assert.Equal(t, 2, agg.order.Len())
+	assert.Len(t, agg.decided, 2)
+}
+
+func TestProbabilisticAndRateLimiting(t *testing.T) {
+	p, err := newPolicy(&PolicyConfig{Type: policyProbabilistic, SamplingPercentage: 0})
+	require.NoError(t, err)
+	assert.False(t, p.evaluate(&trace{id: "abc"}))
+	p, err = newPolicy(&PolicyConfig{Type: policyProbabilistic, SamplingPercentage: 100})
+	require.NoError(t, err)
+	assert.True(t, p.evaluate(&trace{id: "abc"}))
+
+	p, err = newPolicy(&PolicyConfig{Type: policyRateLimiting, TracesPerSecond: 1})
+	require.NoError(t, err)
+	now := time.Unix(1000000, 0)
+	newTrace := func(service string) *trace {
+		tr := &trace{id: service, firstSeen: now}
+		tr.add(models.NewGroup(models.NewMetadataWithKeyValues(serviceNameKey, service), nil), newSpan(service, "1", "", models.StatusCodeOK, 1))
+		return tr
+	}
+	assert.True(t, p.evaluate(newTrace("a")))
+	assert.False(t, p.evaluate(newTrace("a")))
+	assert.True(t, p.evaluate(newTrace("b")))
+	now = now.Add(time.Second)
+	assert.True(t, p.evaluate(newTrace("a")))
+	// the limiter of the idle service is evicted
+	assert.Len(t, p.(*rateLimitingPolicy).limiters, 1)
+	assert.Contains(t, p.(*rateLimitingPolicy).limiters, "a")
+}
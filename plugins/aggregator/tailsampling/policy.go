@@ -0,0 +1,308 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailsampling
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+const (
+	policyAlwaysSample  = "always_sample"
+	policyStatusCode    = "status_code"
+	policyLatency       = "latency"
+	policyAttribute     = "attribute"
+	policyProbabilistic = "probabilistic"
+	policyRateLimiting  = "rate_limiting"
+
+	serviceNameKey = "service.name"
+)
+
+// PolicyConfig describes one sampling policy. A trace is sampled if any policy samples it.
+type PolicyConfig struct {
+	Name               string   `comment:"the policy name used in alarms and self metrics."`
+	Type               string   `comment:"the policy type, one of always_sample, status_code, latency, attribute, probabilistic and rate_limiting."`
+	StatusCodes        []string `comment:"status_code: the span status codes to sample, any of OK, ERROR and UNSET."`
+	ThresholdMs        int64    `comment:"latency: sample the trace if its duration is not less than the threshold."`
+	Key                string   `comment:"attribute: the span tag or resource attribute to match."`
+	Values             []string `comment:"attribute: the values to match exactly."`
+	Regex              string   `comment:"attribute: the regular expression to match, used when Values is empty."`
+	SamplingPercentage float64  `comment:"probabilistic: the percentage (0~100) of traces to sample by trace id hash."`
+	TracesPerSecond    float64  `comment:"rate_limiting: the maximum count of traces sampled per second for every service."`
+}
+
+// trace is the buffered spans of one trace id.
+type trace struct {
+	id        string
+	firstSeen time.Time
+	spans     []*models.Span
+	groups    []*models.GroupInfo
+	minStart  uint64
+	maxEnd    uint64
+}
+
+func (t *trace) add(group *models.GroupInfo, span *models.Span) {
+	if len(t.spans) == 0 || span.StartTime < t.minStart {
+		t.minStart = span.StartTime
+	}
+	if span.EndTime > t.maxEnd {
+		t.maxEnd = span.EndTime
+	}
+	t.spans = append(t.spans, span)
+	t.groups = append(t.groups, group)
+}
+
+// service returns the service of the root span, or of the first span if the root span is missing.
+func (t *trace) service() string {
+	service := ""
+	for i, span := range t.spans {
+		name := t.groups[i].GetMetadata().Get(serviceNameKey)
+		if name == "" {
+			name = span.GetTags().Get(serviceNameKey)
+		}
+		if span.ParentSpanID == "" || strings.Trim(span.ParentSpanID, "0") == "" {
+			return name
+		}
+		if service == "" {
+			service = name
+		}
+	}
+	return service
+}
+
+type policy interface {
+	name() string
+	evaluate(t *trace) bool
+}
+
+func newPolicy(cfg *PolicyConfig) (policy, error) {
+	if cfg.Name == "" {
+		cfg.Name = cfg.Type
+	}
+	switch cfg.Type {
+	case policyAlwaysSample:
+		return &alwaysSamplePolicy{policyName: cfg.Name}, nil
+	case policyStatusCode:
+		p := &statusCodePolicy{policyName: cfg.Name, codes: make(map[models.StatusCode]bool)}
+		for _, code := range cfg.StatusCodes {
+			switch strings.ToUpper(code) {
+			case "OK":
+				p.codes[models.StatusCodeOK] = true
+			case "ERROR":
+				p.codes[models.StatusCodeError] = true
+			case "UNSET":
+				p.codes[models.StatusCodeUnSet] = true
+			default:
+				return nil, fmt.Errorf("unknown status code %v in policy %v", code, cfg.Name)
+			}
+		}
+		if len(p.codes) == 0 {
+			return nil, fmt.Errorf("must specify StatusCodes in policy %v", cfg.Name)
+		}
+		return p, nil
+	case policyLatency:
+		if cfg.ThresholdMs <= 0 {
+			return nil, fmt.Errorf("must specify ThresholdMs in policy %v", cfg.Name)
+		}
+		return &latencyPolicy{policyName: cfg.Name, threshold: uint64(cfg.ThresholdMs) * uint64(time.Millisecond)}, nil
+	case policyAttribute:
+		if cfg.Key == "" || (len(cfg.Values) == 0 && cfg.Regex == "") {
+			return nil, fmt.Errorf("must specify Key and Values or Regex in policy %v", cfg.Name)
+		}
+		p := &attributePolicy{policyName: cfg.Name, key: cfg.Key, values: make(map[string]bool)}
+		for _, v := range cfg.Values {
+			p.values[v] = true
+		}
+		if len(cfg.Values) == 0 {
+			reg, err := regexp.Compile(cfg.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Regex in policy %v: %v", cfg.Name, err)
+			}
+			p.regex = reg
+		}
+		return p, nil
+	case policyProbabilistic:
+		if cfg.SamplingPercentage < 0 || cfg.SamplingPercentage > 100 {
+			return nil, fmt.Errorf("SamplingPercentage must be in [0, 100] in policy %v", cfg.Name)
+		}
+		return &probabilisticPolicy{policyName: cfg.Name, threshold: uint64(cfg.SamplingPercentage * 100)}, nil
+	case policyRateLimiting:
+		if cfg.TracesPerSecond <= 0 {
+			return nil, fmt.Errorf("must specify TracesPerSecond in policy %v", cfg.Name)
+		}
+		return newRateLimitingPolicy(cfg.Name, cfg.TracesPerSecond), nil
+	}
+	return nil, fmt.Errorf("unknown policy type %v", cfg.Type)
+}
+
+type alwaysSamplePolicy struct {
+	policyName string
+}
+
+func (p *alwaysSamplePolicy) name() string {
+	return p.policyName
+}
+
+func (p *alwaysSamplePolicy) evaluate(*trace) bool {
+	return true
+}
+
+type statusCodePolicy struct {
+	policyName string
+	codes      map[models.StatusCode]bool
+}
+
+func (p *statusCodePolicy) name() string {
+	return p.policyName
+}
+
+func (p *statusCodePolicy) evaluate(t *trace) bool {
+	for _, span := range t.spans {
+		if p.codes[span.Status] {
+			return true
+		}
+	}
+	return false
+}
+
+type latencyPolicy struct {
+	policyName string
+	threshold  uint64
+}
+
+func (p *latencyPolicy) name() string {
+	return p.policyName
+}
+
+func (p *latencyPolicy) evaluate(t *trace) bool {
+	return t.maxEnd > t.minStart && t.maxEnd-t.minStart >= p.threshold
+}
+
+type attributePolicy struct {
+	policyName string
+	key        string
+	values     map[string]bool
+	regex      *regexp.Regexp
+}
+
+func (p *attributePolicy) name() string {
+	return p.policyName
+}
+
+func (p *attributePolicy) match(tags models.Tags) bool {
+	if !tags.Contains(p.key) {
+		return false
+	}
+	v := tags.Get(p.key)
+	if p.regex != nil {
+		return p.regex.MatchString(v)
+	}
+	return p.values[v]
+}
+
+func (p *attributePolicy) evaluate(t *trace) bool {
+	for i, span := range t.spans {
+		if p.match(span.GetTags()) || p.match(t.groups[i].GetMetadata()) {
+			return true
+		}
+	}
+	return false
+}
+
+type probabilisticPolicy struct {
+	policyName string
+	// threshold is the sampling percentage in basis points
+	threshold uint64
+}
+
+func (p *probabilisticPolicy) name() string {
+	return p.policyName
+}
+
+// evaluate hashes the trace id, so the decision is consistent across agents.
+func (p *probabilisticPolicy) evaluate(t *trace) bool {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(t.id))
+	return h.Sum64()%10000 < p.threshold
+}
+
+// rateLimitingPolicy limits the traces of each service. A limiter idle longer than the time to refill its burst is
+// the same as a new one, so such limiters are evicted to keep the limiters of the gone services from piling up.
+type rateLimitingPolicy struct {
+	policyName string
+	limit      rate.Limit
+	burst      int
+	// idleTimeout is the time to refill the burst
+	idleTimeout time.Duration
+	limiters    map[string]*serviceLimiter
+	lastEvict   time.Time
+}
+
+type serviceLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+func newRateLimitingPolicy(name string, tracesPerSecond float64) *rateLimitingPolicy {
+	burst := int(tracesPerSecond)
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitingPolicy{
+		policyName:  name,
+		limit:       rate.Limit(tracesPerSecond),
+		burst:       burst,
+		idleTimeout: time.Duration(float64(burst) / tracesPerSecond * float64(time.Second)),
+		limiters:    make(map[string]*serviceLimiter),
+	}
+}
+
+func (p *rateLimitingPolicy) name() string {
+	return p.policyName
+}
+
+func (p *rateLimitingPolicy) evaluate(t *trace) bool {
+	p.evictIdle(t.firstSeen)
+	service := t.service()
+	l, ok := p.limiters[service]
+	if !ok {
+		l = &serviceLimiter{limiter: rate.NewLimiter(p.limit, p.burst)}
+		p.limiters[service] = l
+	}
+	if t.firstSeen.After(l.lastUsed) {
+		l.lastUsed = t.firstSeen
+	}
+	return l.limiter.AllowN(t.firstSeen, 1)
+}
+
+// evictIdle removes the idle limiters at most once per idleTimeout.
+func (p *rateLimitingPolicy) evictIdle(now time.Time) {
+	if now.Sub(p.lastEvict) < p.idleTimeout {
+		return
+	}
+	p.lastEvict = now
+	for service, l := range p.limiters {
+		if now.Sub(l.lastUsed) >= p.idleTimeout {
+			delete(p.limiters, service)
+		}
+	}
+}
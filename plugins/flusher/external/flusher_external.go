@@ -0,0 +1,86 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/helper/external"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+const pluginName = "flusher_external"
+
+// FlusherExternal exports the events by an external plugin executable, it is not ready when the plugin is restarting.
+type FlusherExternal struct {
+	external.PluginConfig
+
+	context      pipeline.Context
+	client       *external.Client
+	errorsMetric pipeline.CounterMetric
+}
+
+// Init launches the plugin and waits until it is initialized.
+func (f *FlusherExternal) Init(context pipeline.Context) error {
+	f.context = context
+	client, err := f.NewClient(context.GetRuntimeContext(), pluginName, external.KindFlusher)
+	if err != nil {
+		return err
+	}
+	if err = client.Start(); err != nil {
+		logger.Error(context.GetRuntimeContext(), "FLUSHER_INIT_ALARM", "start external flusher error", err, "path", f.Path)
+		return err
+	}
+	f.client = client
+	f.errorsMetric = helper.NewCounterMetricAndRegister("external_export_errors", context)
+	return nil
+}
+
+func (f *FlusherExternal) Description() string {
+	return "external flusher for logtail, which exports the events by an external plugin executable"
+}
+
+func (f *FlusherExternal) Export(groupEventsArray []*models.PipelineGroupEvents, ctx pipeline.PipelineContext) error {
+	if err := f.client.Export(groupEventsArray); err != nil {
+		f.errorsMetric.Add(1)
+		logger.Warning(f.context.GetRuntimeContext(), "FLUSHER_FLUSH_ALARM", "external flusher export error", err, "path", f.Path)
+		return err
+	}
+	return nil
+}
+
+func (f *FlusherExternal) SetUrgent(flag bool) {
+}
+
+func (f *FlusherExternal) IsReady(projectName string, logstoreName string, logstoreKey int64) bool {
+	return f.client != nil && f.client.Ready()
+}
+
+// Stop stops the plugin process, the client is nil if Init failed.
+func (f *FlusherExternal) Stop() error {
+	if f.client != nil {
+		return f.client.Stop()
+	}
+	return nil
+}
+
+func init() {
+	pipeline.Flushers[pluginName] = func() pipeline.Flusher {
+		return &FlusherExternal{
+			PluginConfig: external.DefaultPluginConfig(),
+		}
+	}
+}
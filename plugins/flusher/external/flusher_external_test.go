@@ -0,0 +1,56 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/helper/external/externaltest"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+// TestMain runs the test binary as the external plugin when it is launched by the flusher.
+func TestMain(m *testing.M) {
+	externaltest.Main(m)
+}
+
+func TestFlusherExternal(t *testing.T) {
+	f := pipeline.Flushers[pluginName]().(*FlusherExternal)
+	f.Path = os.Args[0]
+	require.NoError(t, f.Init(mock.NewEmptyContext("p", "l", "c")))
+	assert.True(t, f.IsReady("p", "l", 0))
+
+	assert.NoError(t, f.Export(externaltest.NewLogs("hello"), nil))
+	err := f.Export(externaltest.NewLogs("hello", "fail", "true"), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "export fail")
+
+	require.NoError(t, f.Stop())
+	assert.False(t, f.IsReady("p", "l", 0))
+}
+
+func TestFlusherExternalStopWithoutInit(t *testing.T) {
+	f := pipeline.Flushers[pluginName]().(*FlusherExternal)
+	f.Path = filepath.Join(t.TempDir(), "not_exist")
+	require.Error(t, f.Init(mock.NewEmptyContext("p", "l", "c")))
+	assert.False(t, f.IsReady("p", "l", 0))
+	assert.NoError(t, f.Stop())
+}
@@ -0,0 +1,349 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fluentforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/pkg/tlscommon"
+)
+
+const (
+	pluginName = "flusher_fluent_forward"
+
+	// eventTimeExtID is the msgpack extension type of the fluent EventTime.
+	eventTimeExtID  = 0
+	eventTimeExtLen = 8
+
+	compressedGzip = "gzip"
+	contentKey     = "content"
+)
+
+// FlusherFluentForward sends logs to fluentd or fluent-bit in the PackedForward mode of the Fluent Forward protocol.
+type FlusherFluentForward struct {
+	Address             string               `comment:"the address of the fluentd or fluent-bit forward input."`
+	Tag                 string               `comment:"the fluent tag of the data."`
+	TagMetadataKey      string               `comment:"read the fluent tag from the group metadata of the key if exists, so that tags received by service_fluent_forward are kept."`
+	Compressed          string               `comment:"the compression of entries, empty or gzip (CompressedPackedForward)."`
+	RequireAckResponse  bool                 `comment:"wait for the ack of the server to make sure the data is received."`
+	AckTimeoutSeconds   int                  `comment:"the timeout of waiting for the ack."`
+	DialTimeoutSeconds  int                  `comment:"the timeout of connecting to the server."`
+	WriteTimeoutSeconds int                  `comment:"the timeout of sending a message."`
+	MaxRetries          int                  `comment:"the retry times when sending fails, the connection is rebuilt before every retry."`
+	TLS                 *tlscommon.TLSConfig `comment:"the tls settings of the connection."`
+
+	context   pipeline.Context
+	tlsConfig *tls.Config
+	conn      net.Conn
+	decoder   *msgpack.Decoder
+	lock      sync.Mutex
+
+	sendErrorsMetric    pipeline.CounterMetric
+	droppedEventsMetric pipeline.CounterMetric
+}
+
+func (f *FlusherFluentForward) Init(context pipeline.Context) error {
+	f.context = context
+	if f.Address == "" {
+		return fmt.Errorf("must specify Address for plugin %v", pluginName)
+	}
+	if f.Tag == "" {
+		return fmt.Errorf("must specify Tag for plugin %v", pluginName)
+	}
+	if f.Compressed != "" && f.Compressed != compressedGzip {
+		return fmt.Errorf("unsupported Compressed %v for plugin %v", f.Compressed, pluginName)
+	}
+	if f.AckTimeoutSeconds <= 0 {
+		return fmt.Errorf("invalid AckTimeoutSeconds %v for plugin %v", f.AckTimeoutSeconds, pluginName)
+	}
+	if f.WriteTimeoutSeconds <= 0 {
+		return fmt.Errorf("invalid WriteTimeoutSeconds %v for plugin %v", f.WriteTimeoutSeconds, pluginName)
+	}
+	if f.MaxRetries < 0 {
+		return fmt.Errorf("invalid MaxRetries %v for plugin %v", f.MaxRetries, pluginName)
+	}
+	if f.TLS != nil {
+		var err error
+		if f.tlsConfig, err = f.TLS.LoadTLSConfig(); err != nil {
+			logger.Error(f.context.GetRuntimeContext(), "FLUSHER_INIT_ALARM", "fluent forward flusher load tls config fail, error", err)
+			return err
+		}
+	}
+	f.sendErrorsMetric = helper.NewCounterMetricAndRegister("fluent_forward_send_errors", context)
+	f.droppedEventsMetric = helper.NewCounterMetricAndRegister("fluent_forward_dropped_events", context)
+	return nil
+}
+
+func (f *FlusherFluentForward) Description() string {
+	return "fluent forward flusher for ilogtail, which sends logs to fluentd or fluent-bit"
+}
+
+// Flush sends the LogGroups with the configured tag, the LogTags are added to every record.
+func (f *FlusherFluentForward) Flush(projectName string, logstoreName string, configName string, logGroupList []*protocol.LogGroup) error {
+	for _, logGroup := range logGroupList {
+		if len(logGroup.Logs) == 0 {
+			continue
+		}
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		for _, log := range logGroup.Logs {
+			timestamp := uint64(log.Time) * 1e9
+			if log.TimeNs != nil {
+				timestamp += uint64(*log.TimeNs)
+			}
+			record := make(map[string]interface{}, len(log.Contents)+len(logGroup.LogTags))
+			for _, tag := range logGroup.LogTags {
+				record[tag.Key] = tag.Value
+			}
+			for _, content := range log.Contents {
+				record[content.Key] = content.Value
+			}
+			if err := encodeEntry(enc, timestamp, record); err != nil {
+				return err
+			}
+		}
+		if err := f.send(f.Tag, buf.Bytes(), len(logGroup.Logs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export sends the logs of each group, the group and event tags are added to every record.
+// The byte arrays are sent with the content key, and the other events are dropped.
+func (f *FlusherFluentForward) Export(groupEventsArray []*models.PipelineGroupEvents, ctx pipeline.PipelineContext) error {
+	for _, groupEvents := range groupEventsArray {
+		tag := f.Tag
+		if f.TagMetadataKey != "" && groupEvents.Group != nil && groupEvents.Group.GetMetadata().Contains(f.TagMetadataKey) {
+			tag = groupEvents.Group.GetMetadata().Get(f.TagMetadataKey)
+		}
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		count := 0
+		for _, event := range groupEvents.Events {
+			record := make(map[string]interface{})
+			if groupEvents.Group != nil {
+				for k, v := range groupEvents.Group.GetTags().Iterator() {
+					record[k] = v
+				}
+			}
+			var timestamp uint64
+			switch e := event.(type) {
+			case *models.Log:
+				for k, v := range e.GetTags().Iterator() {
+					record[k] = v
+				}
+				for k, v := range e.GetIndices().Iterator() {
+					if b, ok := v.([]byte); ok {
+						v = string(b)
+					}
+					record[k] = v
+				}
+				timestamp = e.GetTimestamp()
+			case models.ByteArray:
+				record[contentKey] = string(e)
+			default:
+				f.droppedEventsMetric.Add(1)
+				continue
+			}
+			if timestamp == 0 {
+				timestamp = uint64(time.Now().UnixNano())
+			}
+			if err := encodeEntry(enc, timestamp, record); err != nil {
+				return err
+			}
+			count++
+		}
+		if count == 0 {
+			continue
+		}
+		if err := f.send(tag, buf.Bytes(), count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends a PackedForward message, and retries on a new connection if failed.
+func (f *FlusherFluentForward) send(tag string, entries []byte, count int) error {
+	chunk := ""
+	if f.RequireAckResponse {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		chunk = base64.StdEncoding.EncodeToString(id)
+	}
+	data, err := f.encodeMessage(tag, entries, count, chunk)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for i := 0; i <= f.MaxRetries; i++ {
+		if err = f.sendOnce(data, chunk); err == nil {
+			return nil
+		}
+		f.sendErrorsMetric.Add(1)
+		logger.Warning(f.context.GetRuntimeContext(), "FLUSHER_FLUSH_ALARM", "fluent forward flusher send fail, error", err, "retry", i)
+		f.closeConn()
+	}
+	return err
+}
+
+func (f *FlusherFluentForward) sendOnce(data []byte, chunk string) error {
+	if f.conn == nil {
+		if err := f.connect(); err != nil {
+			return err
+		}
+	}
+	_ = f.conn.SetWriteDeadline(time.Now().Add(time.Duration(f.WriteTimeoutSeconds) * time.Second))
+	if _, err := f.conn.Write(data); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+	_ = f.conn.SetReadDeadline(time.Now().Add(time.Duration(f.AckTimeoutSeconds) * time.Second))
+	resp, err := f.decoder.DecodeMap()
+	if err != nil {
+		return fmt.Errorf("read ack error: %w", err)
+	}
+	if ack, _ := resp["ack"].(string); ack != chunk {
+		return fmt.Errorf("unexpected ack %v, chunk %v", resp["ack"], chunk)
+	}
+	return nil
+}
+
+func (f *FlusherFluentForward) connect() error {
+	dialer := &net.Dialer{Timeout: time.Duration(f.DialTimeoutSeconds) * time.Second}
+	var err error
+	if f.tlsConfig != nil {
+		f.conn, err = tls.DialWithDialer(dialer, "tcp", f.Address, f.tlsConfig)
+	} else {
+		f.conn, err = dialer.Dial("tcp", f.Address)
+	}
+	if err != nil {
+		f.conn = nil
+		return err
+	}
+	f.decoder = msgpack.NewDecoder(f.conn)
+	return nil
+}
+
+func (f *FlusherFluentForward) closeConn() {
+	if f.conn != nil {
+		_ = f.conn.Close()
+		f.conn = nil
+		f.decoder = nil
+	}
+}
+
+// encodeMessage encodes [tag, entries, option] of the PackedForward or CompressedPackedForward mode.
+func (f *FlusherFluentForward) encodeMessage(tag string, entries []byte, count int, chunk string) ([]byte, error) {
+	if f.Compressed == compressedGzip {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		if _, err := writer.Write(entries); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		entries = compressed.Bytes()
+	}
+	option := map[string]interface{}{"size": count}
+	if chunk != "" {
+		option["chunk"] = chunk
+	}
+	if f.Compressed != "" {
+		option["compressed"] = f.Compressed
+	}
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	if err := enc.EncodeArrayLen(3); err != nil {
+		return nil, err
+	}
+	if err := enc.EncodeString(tag); err != nil {
+		return nil, err
+	}
+	if err := enc.EncodeBytes(entries); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(option); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeEntry encodes [time, record] with the time in the EventTime extension.
+func encodeEntry(enc *msgpack.Encoder, timestamp uint64, record map[string]interface{}) error {
+	if err := enc.EncodeArrayLen(2); err != nil {
+		return err
+	}
+	if err := enc.EncodeExtHeader(eventTimeExtID, eventTimeExtLen); err != nil {
+		return err
+	}
+	var eventTime [eventTimeExtLen]byte
+	binary.BigEndian.PutUint32(eventTime[:4], uint32(timestamp/1e9))
+	binary.BigEndian.PutUint32(eventTime[4:], uint32(timestamp%1e9))
+	if _, err := enc.Writer().Write(eventTime[:]); err != nil {
+		return err
+	}
+	return enc.Encode(record)
+}
+
+func (f *FlusherFluentForward) SetUrgent(flag bool) {
+}
+
+func (f *FlusherFluentForward) IsReady(projectName string, logstoreName string, logstoreKey int64) bool {
+	return true
+}
+
+func (f *FlusherFluentForward) Stop() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.closeConn()
+	return nil
+}
+
+func init() {
+	pipeline.Flushers[pluginName] = func() pipeline.Flusher {
+		return &FlusherFluentForward{
+			Tag:                 "ilogtail",
+			TagMetadataKey:      "fluent_tag",
+			AckTimeoutSeconds:   30,
+			DialTimeoutSeconds:  10,
+			WriteTimeoutSeconds: 10,
+			MaxRetries:          3,
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fluentforward
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+
+	// the forward input receives the data in tests
+	_ "github.com/alibaba/ilogtail/plugins/input/fluentforward"
+)
+
+func startServer(t *testing.T) (string, pipeline.PipelineContext, func()) {
+	// reserve a free port for the server
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	s := pipeline.ServiceInputs["service_fluent_forward"]()
+	require.NoError(t, json.Unmarshal([]byte(`{"Address":"`+address+`"}`), s))
+	_, err = s.Init(mock.NewEmptyContext("p", "l", "c"))
+	require.NoError(t, err)
+	ctx := pipeline.NewObservePipelineConext(10)
+	require.NoError(t, s.(pipeline.ServiceInputV2).StartService(ctx))
+	return address, ctx, func() {
+		require.NoError(t, s.Stop())
+	}
+}
+
+func newFlusher(t *testing.T, address string, init func(f *FlusherFluentForward)) *FlusherFluentForward {
+	f := pipeline.Flushers[pluginName]().(*FlusherFluentForward)
+	f.Address = address
+	if init != nil {
+		init(f)
+	}
+	require.NoError(t, f.Init(mock.NewEmptyContext("p", "l", "c")))
+	return f
+}
+
+func receive(t *testing.T, ctx pipeline.PipelineContext) *models.PipelineGroupEvents {
+	select {
+	case group := <-ctx.Collector().Observe():
+		return group
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for logs")
+	}
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	address, ctx, stop := startServer(t)
+	defer stop()
+	f := newFlusher(t, address, func(f *FlusherFluentForward) {
+		f.RequireAckResponse = true
+		f.Compressed = compressedGzip
+	})
+	defer f.Stop() //nolint:errcheck
+
+	log := models.NewLog("", nil, "", "", "", models.NewTagsWithKeyValues("level", "info"), 1e9+5)
+	log.SetIndices(models.NewLogContents())
+	log.GetIndices().Add("message", []byte("hello"))
+	groups := []*models.PipelineGroupEvents{
+		{
+			Group:  models.NewGroup(models.NewMetadataWithKeyValues("fluent_tag", "app.kept"), models.NewTagsWithKeyValues("host", "h1")),
+			Events: []models.PipelineEvent{log, models.ByteArray("raw")},
+		},
+		{
+			Group:  models.NewGroup(models.NewMetadata(), models.NewTags()),
+			Events: []models.PipelineEvent{models.NewSingleValueMetric("m", models.MetricTypeGauge, models.NewTags(), 1, 1)},
+		},
+	}
+	require.NoError(t, f.Export(groups, pipeline.NewObservePipelineConext(1)))
+
+	group := receive(t, ctx)
+	assert.Equal(t, "app.kept", group.Group.GetMetadata().Get("fluent_tag"))
+	require.Len(t, group.Events, 2)
+	received := group.Events[0].(*models.Log)
+	assert.Equal(t, uint64(1e9+5), received.GetTimestamp())
+	assert.Equal(t, "hello", received.GetIndices().Get("message"))
+	assert.Equal(t, "info", received.GetIndices().Get("level"))
+	assert.Equal(t, "h1", received.GetIndices().Get("host"))
+	assert.Equal(t, "raw", group.Events[1].(*models.Log).GetIndices().Get(contentKey))
+	assert.Equal(t, int64(1), f.droppedEventsMetric.Get())
+}
+
+func TestFlush(t *testing.T) {
+	address, ctx, stop := startServer(t)
+	defer stop()
+	f := newFlusher(t, address, func(f *FlusherFluentForward) {
+		f.Tag = "v1"
+	})
+	defer f.Stop() //nolint:errcheck
+
+	logGroup := &protocol.LogGroup{
+		Logs:    []*protocol.Log{{Time: 2, Contents: []*protocol.Log_Content{{Key: "k", Value: "v"}}}},
+		LogTags: []*protocol.LogTag{{Key: "t", Value: "tv"}},
+	}
+	require.NoError(t, f.Flush("p", "l", "c", []*protocol.LogGroup{logGroup}))
+	group := receive(t, ctx)
+	assert.Equal(t, "v1", group.Group.GetMetadata().Get("fluent_tag"))
+	received := group.Events[0].(*models.Log)
+	assert.Equal(t, uint64(2e9), received.GetTimestamp())
+	assert.Equal(t, "v", received.GetIndices().Get("k"))
+	assert.Equal(t, "tv", received.GetIndices().Get("t"))
+}
+
+func TestSendRetry(t *testing.T) {
+	address, ctx, stop := startServer(t)
+	defer stop()
+	f := newFlusher(t, address, func(f *FlusherFluentForward) {
+		f.RequireAckResponse = true
+	})
+	defer f.Stop() //nolint:errcheck
+
+	// a broken connection is rebuilt when sending
+	conn, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	f.conn = conn
+	require.NoError(t, f.Export([]*models.PipelineGroupEvents{{
+		Group:  models.NewGroup(models.NewMetadata(), models.NewTags()),
+		Events: []models.PipelineEvent{models.ByteArray("retry")},
+	}}, pipeline.NewObservePipelineConext(1)))
+	group := receive(t, ctx)
+	assert.Equal(t, "ilogtail", group.Group.GetMetadata().Get("fluent_tag"))
+	assert.Equal(t, int64(1), f.sendErrorsMetric.Get())
+
+	f2 := newFlusher(t, "127.0.0.1:1", func(f *FlusherFluentForward) {
+		f.MaxRetries = 1
+		f.DialTimeoutSeconds = 1
+	})
+	assert.Error(t, f2.Export([]*models.PipelineGroupEvents{{
+		Group:  models.NewGroup(models.NewMetadata(), models.NewTags()),
+		Events: []models.PipelineEvent{models.ByteArray("lost")},
+	}}, pipeline.NewObservePipelineConext(1)))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, init := range []func(f *FlusherFluentForward){
+		func(f *FlusherFluentForward) { f.AckTimeoutSeconds = 0 },
+		func(f *FlusherFluentForward) { f.WriteTimeoutSeconds = 0 },
+		func(f *FlusherFluentForward) { f.MaxRetries = -1 },
+	} {
+		f := pipeline.Flushers[pluginName]().(*FlusherFluentForward)
+		f.Address = "127.0.0.1:24224"
+		init(f)
+		assert.Error(t, f.Init(mock.NewEmptyContext("p", "l", "c")))
+	}
+}
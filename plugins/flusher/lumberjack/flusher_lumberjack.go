@@ -0,0 +1,325 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lumberjack
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	v2 "github.com/elastic/go-lumber/client/v2"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/pkg/tlscommon"
+)
+
+const (
+	pluginName = "flusher_lumberjack"
+
+	// contentKey is the key that service_lumberjack saves the raw json event to.
+	contentKey   = "content"
+	timestampKey = "@timestamp"
+)
+
+// FlusherLumberjack sends events to Logstash or other lumberjack v2 servers.
+//
+// The field mapping is the reverse of service_lumberjack: a log with the only content key holding
+// a json object is sent as is, otherwise the contents are sent as a json object with @timestamp.
+type FlusherLumberjack struct {
+	Hosts            []string             `comment:"the addresses of the lumberjack servers, such as logstash:5044."`
+	LoadBalance      bool                 `comment:"send windows to all hosts in round robin, otherwise the next host is used only if the current fails."`
+	WindowSize       int                  `comment:"the maximum count of events in a window, which is acknowledged as a whole."`
+	CompressionLevel int                  `comment:"the gzip compression level from 0 to 9, 0 disables compression."`
+	TimeoutSeconds   int                  `comment:"the timeout of connecting, sending and waiting for the ack."`
+	MaxRetries       int                  `comment:"the retry times of a window, the next host is used for every retry."`
+	TLS              *tlscommon.TLSConfig `comment:"the tls settings of the connections."`
+
+	context   pipeline.Context
+	tlsConfig *tls.Config
+	clients   []*v2.SyncClient
+	next      int
+	lock      sync.Mutex
+
+	sendErrorsMetric    pipeline.CounterMetric
+	droppedEventsMetric pipeline.CounterMetric
+}
+
+func (f *FlusherLumberjack) Init(context pipeline.Context) error {
+	f.context = context
+	if len(f.Hosts) == 0 {
+		return fmt.Errorf("must specify Hosts for plugin %v", pluginName)
+	}
+	if f.WindowSize <= 0 {
+		return fmt.Errorf("WindowSize must be positive for plugin %v", pluginName)
+	}
+	if f.CompressionLevel < 0 || f.CompressionLevel > 9 {
+		return fmt.Errorf("CompressionLevel must be within 0 and 9 for plugin %v", pluginName)
+	}
+	if f.TimeoutSeconds <= 0 {
+		return fmt.Errorf("TimeoutSeconds must be positive for plugin %v", pluginName)
+	}
+	if f.MaxRetries < 0 {
+		return fmt.Errorf("MaxRetries must not be negative for plugin %v", pluginName)
+	}
+	if f.TLS != nil {
+		var err error
+		if f.tlsConfig, err = f.TLS.LoadTLSConfig(); err != nil {
+			logger.Error(f.context.GetRuntimeContext(), "FLUSHER_INIT_ALARM", "lumberjack flusher load tls config fail, error", err)
+			return err
+		}
+	}
+	f.clients = make([]*v2.SyncClient, len(f.Hosts))
+	f.sendErrorsMetric = helper.NewCounterMetricAndRegister("lumberjack_send_errors", context)
+	f.droppedEventsMetric = helper.NewCounterMetricAndRegister("lumberjack_dropped_events", context)
+	return nil
+}
+
+func (f *FlusherLumberjack) Description() string {
+	return "lumberjack flusher for ilogtail, which sends events to logstash"
+}
+
+func (f *FlusherLumberjack) Flush(projectName string, logstoreName string, configName string, logGroupList []*protocol.LogGroup) error {
+	var events []interface{}
+	for _, logGroup := range logGroupList {
+		for _, log := range logGroup.Logs {
+			if len(log.Contents) == 1 && log.Contents[0].Key == contentKey && isJSONObject(log.Contents[0].Value) {
+				events = append(events, []byte(log.Contents[0].Value))
+				continue
+			}
+			fields := make(map[string]interface{}, len(log.Contents)+len(logGroup.LogTags)+1)
+			for _, tag := range logGroup.LogTags {
+				fields[tag.Key] = tag.Value
+			}
+			for _, content := range log.Contents {
+				fields[content.Key] = content.Value
+			}
+			nsec := int64(0)
+			if log.TimeNs != nil {
+				nsec = int64(*log.TimeNs)
+			}
+			event, err := encodeFields(fields, time.Unix(int64(log.Time), nsec))
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+	}
+	return f.sendWindows(events)
+}
+
+func (f *FlusherLumberjack) Export(groupEventsArray []*models.PipelineGroupEvents, ctx pipeline.PipelineContext) error {
+	var events []interface{}
+	for _, groupEvents := range groupEventsArray {
+		for _, event := range groupEvents.Events {
+			fields := make(map[string]interface{})
+			if groupEvents.Group != nil {
+				for k, v := range groupEvents.Group.GetTags().Iterator() {
+					fields[k] = v
+				}
+			}
+			var timestamp time.Time
+			switch e := event.(type) {
+			case *models.Log:
+				contents := e.GetIndices()
+				if contents.Len() == 1 {
+					if raw, ok := rawJSON(contents.Get(contentKey)); ok {
+						events = append(events, raw)
+						continue
+					}
+				}
+				for k, v := range e.GetTags().Iterator() {
+					fields[k] = v
+				}
+				for k, v := range contents.Iterator() {
+					if b, ok := v.([]byte); ok {
+						v = string(b)
+					}
+					fields[k] = v
+				}
+				if e.GetTimestamp() > 0 {
+					timestamp = time.Unix(0, int64(e.GetTimestamp()))
+				}
+			case models.ByteArray:
+				if raw, ok := rawJSON([]byte(e)); ok {
+					events = append(events, raw)
+					continue
+				}
+				fields[contentKey] = string(e)
+			default:
+				f.droppedEventsMetric.Add(1)
+				continue
+			}
+			if timestamp.IsZero() {
+				timestamp = time.Now()
+			}
+			encoded, err := encodeFields(fields, timestamp)
+			if err != nil {
+				return err
+			}
+			events = append(events, encoded)
+		}
+	}
+	return f.sendWindows(events)
+}
+
+// sendWindows splits the events into windows, and sends them one by one.
+func (f *FlusherLumberjack) sendWindows(events []interface{}) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for start := 0; start < len(events); start += f.WindowSize {
+		end := start + f.WindowSize
+		if end > len(events) {
+			end = len(events)
+		}
+		if err := f.sendWindow(events[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendWindow sends a window until all events are acknowledged, the unacknowledged events are retried on the next host.
+func (f *FlusherLumberjack) sendWindow(window []interface{}) error {
+	var err error
+	for i := 0; i <= f.MaxRetries; i++ {
+		idx := f.pickHost()
+		var client *v2.SyncClient
+		if client, err = f.getClient(idx); err == nil {
+			var acked int
+			acked, err = client.Send(window)
+			window = window[acked:]
+			if err == nil && len(window) == 0 {
+				return nil
+			}
+			if err == nil {
+				err = fmt.Errorf("only %v events are acknowledged", acked)
+			}
+		}
+		f.sendErrorsMetric.Add(1)
+		logger.Warning(f.context.GetRuntimeContext(), "FLUSHER_FLUSH_ALARM", "lumberjack flusher send fail, error", err, "host", f.Hosts[idx], "retry", i)
+		f.closeClient(idx)
+		// move to the next host after failures
+		f.next = (idx + 1) % len(f.Hosts)
+	}
+	return err
+}
+
+// pickHost returns the host to send the next window, which rotates in load balance mode.
+func (f *FlusherLumberjack) pickHost() int {
+	idx := f.next
+	if f.LoadBalance {
+		f.next = (f.next + 1) % len(f.Hosts)
+	}
+	return idx
+}
+
+func (f *FlusherLumberjack) getClient(idx int) (*v2.SyncClient, error) {
+	if f.clients[idx] != nil {
+		return f.clients[idx], nil
+	}
+	timeout := time.Duration(f.TimeoutSeconds) * time.Second
+	dialer := &net.Dialer{Timeout: timeout}
+	dial := dialer.Dial
+	if f.tlsConfig != nil {
+		dial = func(network, address string) (net.Conn, error) {
+			return tls.DialWithDialer(dialer, network, address, f.tlsConfig)
+		}
+	}
+	client, err := v2.SyncDialWith(dial, f.Hosts[idx],
+		v2.Timeout(timeout),
+		v2.CompressionLevel(f.CompressionLevel),
+		v2.JSONEncoder(encodeEvent),
+	)
+	if err != nil {
+		return nil, err
+	}
+	f.clients[idx] = client
+	return client, nil
+}
+
+func (f *FlusherLumberjack) closeClient(idx int) {
+	if f.clients[idx] != nil {
+		_ = f.clients[idx].Close()
+		f.clients[idx] = nil
+	}
+}
+
+// encodeEvent passes through the encoded json events.
+func encodeEvent(event interface{}) ([]byte, error) {
+	if b, ok := event.([]byte); ok {
+		return b, nil
+	}
+	return json.Marshal(event)
+}
+
+func encodeFields(fields map[string]interface{}, timestamp time.Time) ([]byte, error) {
+	if _, ok := fields[timestampKey]; !ok {
+		fields[timestampKey] = timestamp.UTC().Format(time.RFC3339Nano)
+	}
+	return json.Marshal(fields)
+}
+
+func rawJSON(value interface{}) ([]byte, bool) {
+	switch v := value.(type) {
+	case string:
+		if isJSONObject(v) {
+			return []byte(v), true
+		}
+	case []byte:
+		if isJSONObject(string(v)) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func isJSONObject(value string) bool {
+	return len(value) > 0 && value[0] == '{' && json.Valid([]byte(value))
+}
+
+func (f *FlusherLumberjack) SetUrgent(flag bool) {
+}
+
+func (f *FlusherLumberjack) IsReady(projectName string, logstoreName string, logstoreKey int64) bool {
+	return true
+}
+
+func (f *FlusherLumberjack) Stop() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for idx := range f.clients {
+		f.closeClient(idx)
+	}
+	return nil
+}
+
+func init() {
+	pipeline.Flushers[pluginName] = func() pipeline.Flusher {
+		return &FlusherLumberjack{
+			LoadBalance:      true,
+			WindowSize:       2048,
+			CompressionLevel: 3,
+			TimeoutSeconds:   30,
+			MaxRetries:       3,
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lumberjack
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-lumber/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+type testServer struct {
+	server.Server
+	address string
+	events  chan string
+	once    sync.Once
+}
+
+// close closes the server once, as closing the server twice panics.
+func (s *testServer) close() {
+	s.once.Do(func() {
+		_ = s.Server.Close()
+	})
+}
+
+func startServer(t *testing.T) *testServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s, err := server.NewWithListener(ln, server.V2(true), server.JSONDecoder(func(data []byte, out interface{}) error {
+		*out.(*interface{}) = string(data)
+		return nil
+	}))
+	require.NoError(t, err)
+	ts := &testServer{Server: s, address: ln.Addr().String(), events: make(chan string, 100)}
+	go func() {
+		for batch := range s.ReceiveChan() {
+			for _, event := range batch.Events {
+				ts.events <- event.(string)
+			}
+			batch.ACK()
+		}
+	}()
+	t.Cleanup(ts.close)
+	return ts
+}
+
+func (s *testServer) receive(t *testing.T) map[string]interface{} {
+	select {
+	case event := <-s.events:
+		fields := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal([]byte(event), &fields))
+		return fields
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for events")
+	}
+	return nil
+}
+
+func newFlusher(t *testing.T, hosts ...string) *FlusherLumberjack {
+	f := pipeline.Flushers[pluginName]().(*FlusherLumberjack)
+	f.Hosts = hosts
+	f.TimeoutSeconds = 1
+	require.NoError(t, f.Init(mock.NewEmptyContext("p", "l", "c")))
+	t.Cleanup(func() {
+		_ = f.Stop()
+	})
+	return f
+}
+
+func TestExport(t *testing.T) {
+	s := startServer(t)
+	f := newFlusher(t, s.address)
+	f.WindowSize = 2
+
+	log := models.NewLog("", nil, "", "", "", models.NewTagsWithKeyValues("level", "info"), uint64(time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC).UnixNano()))
+	log.SetIndices(models.NewLogContents())
+	log.GetIndices().Add("message", []byte("hello"))
+	raw := models.NewLog("", nil, "", "", "", models.NewTags(), 1)
+	raw.SetIndices(models.NewLogContents())
+	raw.GetIndices().Add(contentKey, `{"message":"raw","@timestamp":"2023-01-01T00:00:00Z"}`)
+	groups := []*models.PipelineGroupEvents{{
+		Group: models.NewGroup(models.NewMetadata(), models.NewTagsWithKeyValues("host", "h1")),
+		Events: []models.PipelineEvent{
+			log, raw, models.ByteArray("plain"),
+			models.NewSingleValueMetric("m", models.MetricTypeGauge, models.NewTags(), 1, 1),
+		},
+	}}
+	require.NoError(t, f.Export(groups, pipeline.NewObservePipelineConext(1)))
+
+	fields := s.receive(t)
+	assert.Equal(t, "hello", fields["message"])
+	assert.Equal(t, "info", fields["level"])
+	assert.Equal(t, "h1", fields["host"])
+	assert.Equal(t, "2023-01-02T03:04:05Z", fields[timestampKey])
+	assert.Equal(t, map[string]interface{}{"message": "raw", "@timestamp": "2023-01-01T00:00:00Z"}, s.receive(t))
+	fields = s.receive(t)
+	assert.Equal(t, "plain", fields[contentKey])
+	assert.Equal(t, "h1", fields["host"])
+	assert.Equal(t, int64(1), f.droppedEventsMetric.Get())
+}
+
+func TestFlush(t *testing.T) {
+	s := startServer(t)
+	f := newFlusher(t, s.address)
+	f.CompressionLevel = 0
+
+	timeNs := uint32(5)
+	logGroup := &protocol.LogGroup{
+		Logs: []*protocol.Log{
+			{Time: 1, TimeNs: &timeNs, Contents: []*protocol.Log_Content{{Key: "k", Value: "v"}}},
+			{Time: 1, Contents: []*protocol.Log_Content{{Key: contentKey, Value: `{"a":1}`}}},
+		},
+		LogTags: []*protocol.LogTag{{Key: "t", Value: "tv"}},
+	}
+	require.NoError(t, f.Flush("p", "l", "c", []*protocol.LogGroup{logGroup}))
+	assert.Equal(t, map[string]interface{}{"k": "v", "t": "tv", timestampKey: "1970-01-01T00:00:01.000000005Z"}, s.receive(t))
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, s.receive(t))
+}
+
+func TestLoadBalanceAndFailover(t *testing.T) {
+	s1, s2 := startServer(t), startServer(t)
+	f := newFlusher(t, s1.address, s2.address)
+	send := func(content string) {
+		require.NoError(t, f.Export([]*models.PipelineGroupEvents{{
+			Group:  models.NewGroup(models.NewMetadata(), models.NewTags()),
+			Events: []models.PipelineEvent{models.ByteArray(content)},
+		}}, pipeline.NewObservePipelineConext(1)))
+	}
+	send("a")
+	send("b")
+	assert.Equal(t, "a", s1.receive(t)[contentKey])
+	assert.Equal(t, "b", s2.receive(t)[contentKey])
+
+	// the windows are sent to the alive host after a host fails
+	s1.close()
+	send("c")
+	send("d")
+	assert.Equal(t, "c", s2.receive(t)[contentKey])
+	assert.Equal(t, "d", s2.receive(t)[contentKey])
+	assert.Greater(t, f.sendErrorsMetric.Get(), int64(0))
+
+	s2.close()
+	f.MaxRetries = 1
+	assert.Error(t, f.Export([]*models.PipelineGroupEvents{{
+		Group:  models.NewGroup(models.NewMetadata(), models.NewTags()),
+		Events: []models.PipelineEvent{models.ByteArray("lost")},
+	}}, pipeline.NewObservePipelineConext(1)))
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, init := range []func(f *FlusherLumberjack){
+		func(f *FlusherLumberjack) { f.WindowSize = 0 },
+		func(f *FlusherLumberjack) { f.TimeoutSeconds = 0 },
+		func(f *FlusherLumberjack) { f.MaxRetries = -1 },
+	} {
+		f := pipeline.Flushers[pluginName]().(*FlusherLumberjack)
+		f.Hosts = []string{"127.0.0.1:5044"}
+		init(f)
+		assert.Error(t, f.Init(mock.NewEmptyContext("p", "l", "c")))
+	}
+}
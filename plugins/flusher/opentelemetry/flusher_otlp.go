@@ -404,6 +404,37 @@ func buildGrpcClientConn(grpcConfig *helper.GrpcClientConfig) (*grpc.ClientConn,
 	return grpc.Dial(grpcConfig.GetEndpoint(), dialOpts...)
 }
 
+// TraceExporter exports the traces by the OTLP gRPC client of the flusher, it is also used to export the spans of
+// the pipeline stages traced by the agent itself.
+type TraceExporter struct {
+	client *grpcClient[ptraceotlp.GRPCClient]
+}
+
+// NewTraceExporter creates the exporter connecting to the endpoint of the config.
+func NewTraceExporter(grpcConfig *helper.GrpcClientConfig) (*TraceExporter, error) {
+	grpcConn, err := buildGrpcClientConn(grpcConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &TraceExporter{
+		client: newGrpcClient(ptraceotlp.NewGRPCClient(grpcConn), grpcConn, grpcConfig, metadata.New(grpcConfig.Headers)),
+	}, nil
+}
+
+// Export sends the traces, and retries the failures when the retry is enabled.
+func (e *TraceExporter) Export(traces ptrace.Traces) error {
+	request := ptraceotlp.NewExportRequestFromTraces(traces)
+	if e.client.grpcConfig.Retry.Enable {
+		return flushWithRetry[ptraceotlp.ExportRequest, ptraceotlp.ExportResponse](e.client.client, request, e.client.metadata, e.client.grpcConfig)
+	}
+	return timeoutFlush[ptraceotlp.ExportRequest, ptraceotlp.ExportResponse](e.client.client, request, e.client.metadata, e.client.grpcConfig)
+}
+
+// Close closes the gRPC connection of the exporter.
+func (e *TraceExporter) Close() error {
+	return e.client.grpcConn.Close()
+}
+
 func init() {
 	pipeline.Flushers["flusher_otlp"] = func() pipeline.Flusher {
 		return &FlusherOTLP{Version: v1}
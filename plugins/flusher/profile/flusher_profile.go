@@ -0,0 +1,247 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pyroscope-io/pyroscope/pkg/storage/metadata"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/segment"
+	"github.com/pyroscope-io/pyroscope/pkg/storage/tree"
+	"go.uber.org/multierr"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/helper/profile"
+	"github.com/alibaba/ilogtail/pkg/helper/profile/pyroscope/pprof"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+const (
+	pluginName = "flusher_profile"
+
+	defaultAppName = "ilogtail"
+)
+
+var invalidFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// FlusherProfile writes the profile events as pprof files, or pushes them to a pyroscope compatible server.
+// The other events are dropped.
+type FlusherProfile struct {
+	Directory      string            `comment:"the directory to write the gzipped pprof files, empty to disable."`
+	Endpoint       string            `comment:"the address of a pyroscope compatible server such as http://pyroscope:4040, the profiles are pushed to its /ingest api, empty to disable."`
+	Headers        map[string]string `comment:"the headers of the ingest requests, such as Authorization."`
+	TimeoutSeconds int               `comment:"the timeout of the ingest requests."`
+
+	context pipeline.Context
+	client  *http.Client
+	ingest  string
+	seq     uint64
+
+	droppedEventsMetric pipeline.CounterMetric
+	writeErrorsMetric   pipeline.CounterMetric
+	sendErrorsMetric    pipeline.CounterMetric
+}
+
+func (f *FlusherProfile) Init(context pipeline.Context) error {
+	f.context = context
+	if f.Directory == "" && f.Endpoint == "" {
+		return fmt.Errorf("must specify Directory or Endpoint for plugin %v", pluginName)
+	}
+	if f.Directory != "" {
+		if err := os.MkdirAll(f.Directory, 0750); err != nil {
+			logger.Error(f.context.GetRuntimeContext(), "FLUSHER_INIT_ALARM", "profile flusher create directory fail, error", err)
+			return err
+		}
+	}
+	if f.Endpoint != "" {
+		u, err := url.Parse(f.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid Endpoint %v for plugin %v: %v", f.Endpoint, pluginName, err)
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/ingest"
+		f.ingest = u.String()
+		f.client = &http.Client{Timeout: time.Duration(f.TimeoutSeconds) * time.Second}
+	}
+	f.droppedEventsMetric = helper.NewCounterMetricAndRegister("profile_dropped_events", context)
+	f.writeErrorsMetric = helper.NewCounterMetricAndRegister("profile_write_errors", context)
+	f.sendErrorsMetric = helper.NewCounterMetricAndRegister("profile_send_errors", context)
+	return nil
+}
+
+func (f *FlusherProfile) Description() string {
+	return "profile flusher for ilogtail, which writes pprof files or pushes to pyroscope"
+}
+
+// Export writes or pushes the profiles one by one. A failed profile is counted, and the others are still exported,
+// the errors of all the failed profiles are returned.
+func (f *FlusherProfile) Export(groupEventsArray []*models.PipelineGroupEvents, ctx pipeline.PipelineContext) error {
+	var errs error
+	for _, groupEvents := range groupEventsArray {
+		for _, event := range groupEvents.Events {
+			p, ok := event.(*models.Profile)
+			if !ok {
+				f.droppedEventsMetric.Add(1)
+				continue
+			}
+			data, err := pprof.ToPprof(p)
+			if err != nil {
+				logger.Warning(f.context.GetRuntimeContext(), "FLUSHER_FLUSH_ALARM", "profile flusher encode pprof fail, error", err)
+				f.droppedEventsMetric.Add(1)
+				continue
+			}
+			if f.Directory != "" {
+				if err = f.writeFile(p, data); err != nil {
+					f.writeErrorsMetric.Add(1)
+					logger.Warning(f.context.GetRuntimeContext(), "FLUSHER_FLUSH_ALARM", "profile flusher write file fail, error", err)
+					errs = multierr.Append(errs, err)
+				}
+			}
+			if f.Endpoint != "" {
+				if err = f.push(p, groupEvents.Group, data); err != nil {
+					f.sendErrorsMetric.Add(1)
+					logger.Warning(f.context.GetRuntimeContext(), "FLUSHER_FLUSH_ALARM", "profile flusher push fail, error", err)
+					errs = multierr.Append(errs, err)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// writeFile writes the profile as <name>-<start time in nanoseconds>-<sequence>.pb.gz, the sequence distinguishes the
+// profiles of the same time but different labels.
+func (f *FlusherProfile) writeFile(p *models.Profile, data []byte) error {
+	name := invalidFileChars.ReplaceAllString(appName(p), "_")
+	seq := atomic.AddUint64(&f.seq, 1)
+	path := filepath.Join(f.Directory, name+"-"+strconv.FormatUint(p.GetStartTime(), 10)+"-"+strconv.FormatUint(seq, 10)+".pb.gz")
+	return os.WriteFile(path, data, 0600)
+}
+
+// push sends the profile to the ingest api of pyroscope in the multipart form, the sample type config tells the
+// server the units and aggregation types of the values.
+func (f *FlusherProfile) push(p *models.Profile, group *models.GroupInfo, data []byte) error {
+	labels := make(map[string]string)
+	for k, v := range group.GetTags().Iterator() {
+		labels[k] = v
+	}
+	for k, v := range p.GetTags().Iterator() {
+		labels[k] = v
+	}
+	labels[profile.NameLabel] = appName(p)
+	query := url.Values{}
+	query.Set("name", segment.NewKey(labels).Normalized())
+	startTime := time.Unix(0, int64(p.GetStartTime()))
+	query.Set("from", strconv.FormatInt(startTime.Unix(), 10))
+	query.Set("until", strconv.FormatInt(startTime.Add(time.Duration(p.GetDuration())).Unix(), 10))
+	if p.GetLanguage() != "" {
+		query.Set("spyName", p.GetLanguage())
+	}
+
+	sampleTypeConfig := make(map[string]*tree.SampleTypeConfig, len(p.GetValueTypes()))
+	for _, vt := range p.GetValueTypes() {
+		config := &tree.SampleTypeConfig{Units: metadata.Units(vt.Unit)}
+		if vt.AggregationType == string(profile.AvgAggType) {
+			config.Aggregation = metadata.AverageAggregationType
+		} else {
+			config.Aggregation = metadata.SumAggregationType
+		}
+		sampleTypeConfig[vt.Type] = config
+	}
+	config, err := json.Marshal(sampleTypeConfig)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for field, content := range map[string][]byte{"profile": data, "sample_type_config": config} {
+		part, err := w.CreateFormFile(field, field)
+		if err != nil {
+			return err
+		}
+		if _, err = part.Write(content); err != nil {
+			return err
+		}
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, f.ingest+"?"+query.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	for k, v := range f.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %v: %s", resp.StatusCode, msg)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func appName(p *models.Profile) string {
+	if p.GetName() != "" {
+		return p.GetName()
+	}
+	if name := p.GetTags().Get(profile.NameLabel); name != "" {
+		return name
+	}
+	return defaultAppName
+}
+
+func (f *FlusherProfile) SetUrgent(flag bool) {
+}
+
+func (f *FlusherProfile) IsReady(projectName string, logstoreName string, logstoreKey int64) bool {
+	return true
+}
+
+func (f *FlusherProfile) Stop() error {
+	if f.client != nil {
+		f.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+func init() {
+	pipeline.Flushers[pluginName] = func() pipeline.Flusher {
+		return &FlusherProfile{
+			TimeoutSeconds: 30,
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+
+	"github.com/alibaba/ilogtail/pkg/helper/profile/pyroscope/pprof"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+func newTestProfile() *models.Profile {
+	p := models.NewProfile("app.cpu", "id", "go", 1e9, 11e9, []*models.ProfileValueType{
+		models.NewProfileValueType("cpu", "samples", "sum"),
+	}, models.NewTagsWithKeyValues("env", "test"))
+	p.AddSample([]string{"main.work", "main.main"}, []int64{10}, nil)
+	return p
+}
+
+func newTestGroupEvents(events ...models.PipelineEvent) []*models.PipelineGroupEvents {
+	return []*models.PipelineGroupEvents{{
+		Group:  models.NewGroup(models.NewMetadata(), models.NewTagsWithKeyValues("host", "h1")),
+		Events: events,
+	}}
+}
+
+func TestFlusherProfileInit(t *testing.T) {
+	f := &FlusherProfile{}
+	assert.Error(t, f.Init(mock.NewEmptyContext("p", "l", "c")))
+
+	f = &FlusherProfile{Endpoint: "http://127.0.0.1:4040/", TimeoutSeconds: 1}
+	require.NoError(t, f.Init(mock.NewEmptyContext("p", "l", "c")))
+	assert.Equal(t, "http://127.0.0.1:4040/ingest", f.ingest)
+}
+
+func TestFlusherProfileDirectory(t *testing.T) {
+	dir := t.TempDir()
+	f := &FlusherProfile{Directory: dir}
+	require.NoError(t, f.Init(mock.NewEmptyContext("p", "l", "c")))
+
+	log := models.NewLog("log", nil, "", "", "", models.NewTags(), 0)
+	require.NoError(t, f.Export(newTestGroupEvents(newTestProfile(), log), nil))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "app.cpu-1000000000-1.pb.gz", files[0].Name())
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	p, err := pprof.FromPprof(data)
+	require.NoError(t, err)
+	require.Len(t, p.GetSamples(), 1)
+	assert.Equal(t, []string{"main.work", "main.main"}, p.GetSamples()[0].Stack)
+	assert.Equal(t, []int64{10}, p.GetSamples()[0].Values)
+}
+
+func TestFlusherProfileEndpoint(t *testing.T) {
+	type request struct {
+		query            map[string]string
+		authorization    string
+		profile          []byte
+		sampleTypeConfig map[string]map[string]interface{}
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ingest", r.URL.Path)
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		var req request
+		req.query = map[string]string{}
+		for k := range r.URL.Query() {
+			req.query[k] = r.URL.Query().Get(k)
+		}
+		req.authorization = r.Header.Get("Authorization")
+		file, _, err := r.FormFile("profile")
+		require.NoError(t, err)
+		req.profile, _ = io.ReadAll(file)
+		file, _, err = r.FormFile("sample_type_config")
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(file).Decode(&req.sampleTypeConfig))
+		requests <- req
+	}))
+	defer server.Close()
+
+	f := &FlusherProfile{Endpoint: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}, TimeoutSeconds: 5}
+	require.NoError(t, f.Init(mock.NewEmptyContext("p", "l", "c")))
+	require.NoError(t, f.Export(newTestGroupEvents(newTestProfile()), nil))
+
+	req := <-requests
+	assert.Equal(t, "Bearer token", req.authorization)
+	assert.Equal(t, "app.cpu{env=test,host=h1}", req.query["name"])
+	assert.Equal(t, "1", req.query["from"])
+	assert.Equal(t, "11", req.query["until"])
+	assert.Equal(t, "go", req.query["spyName"])
+	assert.Equal(t, "samples", req.sampleTypeConfig["cpu"]["units"])
+	p, err := pprof.FromPprof(req.profile)
+	require.NoError(t, err)
+	assert.Len(t, p.GetSamples(), 1)
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("bad profile"))
+	})
+	// the failed profiles are counted, and their errors are returned
+	err = f.Export(newTestGroupEvents(newTestProfile(), newTestProfile()), nil)
+	assert.Len(t, multierr.Errors(err), 2)
+	assert.ErrorContains(t, err, "bad profile")
+	assert.Equal(t, int64(2), f.sendErrorsMetric.Get())
+}
@@ -162,6 +162,8 @@ func (p *FlusherStdout) Export(in []*models.PipelineGroupEvents, context pipelin
 				writer.WriteString("log")
 			case models.EventTypeByteArray:
 				writer.WriteString("byteArray")
+			case models.EventTypeProfile:
+				writer.WriteString("profile")
 			}
 			_, _ = writer.Write([]byte{','})
 			writer.WriteObjectField("name")
@@ -195,6 +197,8 @@ func (p *FlusherStdout) Export(in []*models.PipelineGroupEvents, context pipelin
 				p.writeLogBody(writer, event.(*models.Log))
 			case models.EventTypeByteArray:
 				p.writeByteArray(writer, event.(models.ByteArray))
+			case models.EventTypeProfile:
+				p.writeProfile(writer, event.(*models.Profile))
 			}
 
 			writer.WriteObjectEnd()
@@ -254,6 +258,30 @@ func (p *FlusherStdout) writeSpan(writer *jsoniter.Stream, metric *models.Span)
 	// TODO
 }
 
+func (p *FlusherStdout) writeProfile(writer *jsoniter.Stream, profile *models.Profile) {
+	writer.WriteObjectField("profileID")
+	writer.WriteString(profile.GetProfileID())
+	_, _ = writer.Write([]byte{','})
+	writer.WriteObjectField("language")
+	writer.WriteString(profile.GetLanguage())
+	_, _ = writer.Write([]byte{','})
+	writer.WriteObjectField("durationNs")
+	writer.WriteUint64(profile.GetDuration())
+	_, _ = writer.Write([]byte{','})
+	writer.WriteObjectField("valueTypes")
+	writer.WriteArrayStart()
+	for i, vt := range profile.GetValueTypes() {
+		if i > 0 {
+			_, _ = writer.Write([]byte{','})
+		}
+		writer.WriteString(vt.Type + "/" + vt.Unit)
+	}
+	writer.WriteArrayEnd()
+	_, _ = writer.Write([]byte{','})
+	writer.WriteObjectField("samples")
+	writer.WriteInt(len(profile.GetSamples()))
+}
+
 func (p *FlusherStdout) writeLogBody(writer *jsoniter.Stream, log *models.Log) {
 	writer.WriteObjectField("offset")
 	writer.WriteInt64(int64(log.GetOffset()))
@@ -0,0 +1,96 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/alibaba/ilogtail/pkg/helper/external"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+const pluginName = "service_external"
+
+// ServiceExternal collects the events emitted by an external plugin executable, the stream is opened again after
+// the plugin restarts.
+type ServiceExternal struct {
+	external.PluginConfig
+
+	context pipeline.Context
+	client  *external.Client
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (s *ServiceExternal) Init(context pipeline.Context) (int, error) {
+	s.context = context
+	client, err := s.NewClient(context.GetRuntimeContext(), pluginName, external.KindInput)
+	if err != nil {
+		return 0, err
+	}
+	s.client = client
+	return 0, nil
+}
+
+func (s *ServiceExternal) Description() string {
+	return "external input for logtail, which collects the events emitted by an external plugin executable"
+}
+
+// Start is not supported, the events are only emitted to the v2 pipeline.
+func (s *ServiceExternal) Start(c pipeline.Collector) error {
+	return fmt.Errorf("plugin %v only supports the v2 pipeline", pluginName)
+}
+
+func (s *ServiceExternal) StartService(ctx pipeline.PipelineContext) error {
+	if err := s.client.Start(); err != nil {
+		logger.Error(s.context.GetRuntimeContext(), "EXTERNAL_PLUGIN_ALARM", "start external input error", err, "path", s.Path)
+		return err
+	}
+	collector := ctx.Collector()
+	var collectCtx context.Context
+	collectCtx, s.cancel = context.WithCancel(context.Background())
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.client.Collect(collectCtx, func(groupEventsArray []*models.PipelineGroupEvents) {
+			collector.CollectList(groupEventsArray...)
+		})
+	}()
+	logger.Info(s.context.GetRuntimeContext(), "external input start", "success", "path", s.Path)
+	return nil
+}
+
+func (s *ServiceExternal) Stop() error {
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+	}
+	if s.client != nil {
+		return s.client.Stop()
+	}
+	return nil
+}
+
+func init() {
+	pipeline.ServiceInputs[pluginName] = func() pipeline.ServiceInput {
+		return &ServiceExternal{
+			PluginConfig: external.DefaultPluginConfig(),
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/helper/external/externaltest"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+// TestMain runs the test binary as the external plugin when it is launched by the input.
+func TestMain(m *testing.M) {
+	externaltest.Main(m)
+}
+
+func TestServiceExternal(t *testing.T) {
+	s := pipeline.ServiceInputs[pluginName]().(*ServiceExternal)
+	s.Path = os.Args[0]
+	s.Config = map[string]interface{}{"Body": "hello"}
+	_, err := s.Init(mock.NewEmptyContext("p", "l", "c"))
+	require.NoError(t, err)
+	assert.Error(t, s.Start(nil))
+
+	ctx := pipeline.NewObservePipelineConext(100)
+	require.NoError(t, s.StartService(ctx))
+	select {
+	case groupEvents := <-ctx.Collector().Observe():
+		assert.Equal(t, "external", groupEvents.Group.GetTags().Get("source"))
+		require.Len(t, groupEvents.Events, 1)
+		assert.Equal(t, []byte("hello"), groupEvents.Events[0].(*models.Log).GetBody())
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for events")
+	}
+	require.NoError(t, s.Stop())
+}
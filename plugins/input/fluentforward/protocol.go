@@ -0,0 +1,266 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fluentforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+const (
+	// eventTimeExtID is the msgpack extension type of the fluent EventTime.
+	eventTimeExtID  = 0
+	eventTimeExtLen = 8
+
+	compressedGzip = "gzip"
+
+	// minEntrySize is the encoded size of the smallest entry, which is [0, {}].
+	minEntrySize = 3
+)
+
+var errMessageTooLarge = errors.New("forward message too large")
+
+// entry is a single event of the forward protocol.
+type entry struct {
+	timestamp uint64 // in nanoseconds
+	record    map[string]interface{}
+}
+
+// message is a decoded forward message in any of the Message, Forward, PackedForward
+// and CompressedPackedForward modes.
+type message struct {
+	tag     string
+	entries []entry
+	chunk   string
+}
+
+// readMessage reads the next forward message from the decoder, the entries of a message must fit in maxMessageSize.
+// See https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1.
+func readMessage(dec *msgpack.Decoder, maxMessageSize int) (*message, error) {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+	if n < 2 || n > 4 {
+		return nil, fmt.Errorf("invalid forward message of %v elements", n)
+	}
+	msg := &message{}
+	if msg.tag, err = dec.DecodeString(); err != nil {
+		return nil, fmt.Errorf("invalid tag: %w", err)
+	}
+	code, err := dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+	var option map[string]interface{}
+	switch {
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		// Forward mode: [tag, [[time, record], ...], option]
+		var count int
+		if count, err = dec.DecodeArrayLen(); err != nil {
+			return nil, err
+		}
+		if count > maxMessageSize/minEntrySize {
+			return nil, errMessageTooLarge
+		}
+		for i := 0; i < count; i++ {
+			var e entry
+			if e, err = readEntry(dec); err != nil {
+				return nil, err
+			}
+			msg.entries = append(msg.entries, e)
+		}
+		if n > 2 {
+			if option, err = readOption(dec); err != nil {
+				return nil, err
+			}
+		}
+	case msgpcode.IsString(code) || msgpcode.IsBin(code):
+		// PackedForward mode: [tag, <concatenated entries>, option]
+		// check the length before allocating the buffer, as the length is claimed by the peer
+		var size int
+		if size, err = dec.DecodeBytesLen(); err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errors.New("invalid nil entries")
+		}
+		if size > maxMessageSize {
+			return nil, errMessageTooLarge
+		}
+		packed := make([]byte, size)
+		if err = dec.ReadFull(packed); err != nil {
+			return nil, err
+		}
+		if n > 2 {
+			if option, err = readOption(dec); err != nil {
+				return nil, err
+			}
+		}
+		if compressed, _ := option["compressed"].(string); compressed != "" {
+			if compressed != compressedGzip {
+				return nil, fmt.Errorf("unsupported compression %v", compressed)
+			}
+			if packed, err = gunzip(packed, maxMessageSize); err != nil {
+				return nil, err
+			}
+		}
+		if msg.entries, err = readPackedEntries(packed); err != nil {
+			return nil, err
+		}
+	default:
+		// Message mode: [tag, time, record, option]
+		if n < 3 {
+			return nil, fmt.Errorf("invalid message mode of %v elements", n)
+		}
+		var e entry
+		if e.timestamp, err = readTime(dec); err != nil {
+			return nil, err
+		}
+		if e.record, err = dec.DecodeMap(); err != nil {
+			return nil, fmt.Errorf("invalid record: %w", err)
+		}
+		msg.entries = append(msg.entries, e)
+		if n > 3 {
+			if option, err = readOption(dec); err != nil {
+				return nil, err
+			}
+		}
+	}
+	msg.chunk, _ = option["chunk"].(string)
+	return msg, nil
+}
+
+func readEntry(dec *msgpack.Decoder) (entry, error) {
+	var e entry
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return e, err
+	}
+	if n != 2 {
+		return e, fmt.Errorf("invalid entry of %v elements", n)
+	}
+	if e.timestamp, err = readTime(dec); err != nil {
+		return e, err
+	}
+	if e.record, err = dec.DecodeMap(); err != nil {
+		return e, fmt.Errorf("invalid record: %w", err)
+	}
+	return e, nil
+}
+
+func readPackedEntries(packed []byte) ([]entry, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(packed))
+	var entries []entry
+	for {
+		e, err := readEntry(dec)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// readTime reads the time of an entry, which is either the EventTime extension or the unix seconds.
+func readTime(dec *msgpack.Decoder) (uint64, error) {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case msgpcode.IsExt(code):
+		extID, extLen, err := dec.DecodeExtHeader()
+		if err != nil {
+			return 0, err
+		}
+		if extID != eventTimeExtID || extLen != eventTimeExtLen {
+			return 0, fmt.Errorf("invalid event time of ext type %v and length %v", extID, extLen)
+		}
+		buf := make([]byte, eventTimeExtLen)
+		if err = dec.ReadFull(buf); err != nil {
+			return 0, err
+		}
+		sec, nsec := binary.BigEndian.Uint32(buf[:4]), binary.BigEndian.Uint32(buf[4:])
+		return uint64(sec)*1e9 + uint64(nsec), nil
+	case code == msgpcode.Float || code == msgpcode.Double:
+		sec, err := dec.DecodeFloat64()
+		if err != nil {
+			return 0, err
+		}
+		return uint64(math.Max(sec, 0) * 1e9), nil
+	default:
+		sec, err := dec.DecodeInt64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid event time: %w", err)
+		}
+		if sec < 0 {
+			sec = 0
+		}
+		return uint64(sec) * 1e9, nil
+	}
+}
+
+func readOption(dec *msgpack.Decoder) (map[string]interface{}, error) {
+	option, err := dec.DecodeMap()
+	if err != nil {
+		return nil, fmt.Errorf("invalid option: %w", err)
+	}
+	return option, nil
+}
+
+func gunzip(data []byte, maxMessageSize int) ([]byte, error) {
+	// CompressedPackedForward may concatenate multiple gzip members, which gzip.Reader reads as one stream
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close() //nolint:errcheck
+	res, err := io.ReadAll(io.LimitReader(reader, int64(maxMessageSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(res) > maxMessageSize {
+		return nil, errMessageTooLarge
+	}
+	return res, nil
+}
+
+// writeAck responds the chunk id of a message that requires acknowledgment.
+func writeAck(w io.Writer, chunk string) error {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	if err := enc.EncodeMapLen(1); err != nil {
+		return err
+	}
+	if err := enc.EncodeString("ack"); err != nil {
+		return err
+	}
+	if err := enc.EncodeString(chunk); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,199 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fluentforward
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/tlscommon"
+)
+
+const pluginName = "service_fluent_forward"
+
+// ServiceFluentForward receives the Fluent Forward protocol over TCP or TLS, and converts entries to logs.
+type ServiceFluentForward struct {
+	Address            string               `comment:"the listening address."`
+	TagMetadataKey     string               `comment:"the group metadata key of the fluent tag."`
+	MaxConnections     int                  `comment:"the maximum count of concurrent connections, new connections are rejected when exceeded."`
+	MaxMessageSize     int                  `comment:"the maximum size of the entries in a message, after decompression."`
+	IdleTimeoutSeconds int                  `comment:"close the connection if nothing is received in the time, 0 means never."`
+	TLS                *tlscommon.TLSConfig `comment:"the tls settings, the certificate and key are required if enabled."`
+
+	context   pipeline.Context
+	tlsConfig *tls.Config
+	collector pipeline.PipelineCollector
+	server    *helper.ConnServer
+
+	rejectedConnsMetric   pipeline.CounterMetric
+	decodeErrorsMetric    pipeline.CounterMetric
+	receivedEntriesMetric pipeline.CounterMetric
+}
+
+func (s *ServiceFluentForward) Init(context pipeline.Context) (int, error) {
+	s.context = context
+	if s.Address == "" {
+		return 0, fmt.Errorf("must specify Address for plugin %v", pluginName)
+	}
+	if s.TagMetadataKey == "" {
+		return 0, fmt.Errorf("must specify TagMetadataKey for plugin %v", pluginName)
+	}
+	if s.MaxMessageSize <= 0 {
+		return 0, fmt.Errorf("invalid MaxMessageSize %v for plugin %v", s.MaxMessageSize, pluginName)
+	}
+	if s.TLS != nil {
+		var err error
+		if s.tlsConfig, err = s.TLS.LoadServerTLSConfig(); err != nil {
+			return 0, fmt.Errorf("load TLS config for plugin %v error: %v", pluginName, err)
+		}
+	}
+	s.rejectedConnsMetric = helper.NewCounterMetricAndRegister("fluent_forward_rejected_connections", context)
+	s.decodeErrorsMetric = helper.NewCounterMetricAndRegister("fluent_forward_decode_errors", context)
+	s.receivedEntriesMetric = helper.NewCounterMetricAndRegister("fluent_forward_received_entries", context)
+	return 0, nil
+}
+
+func (s *ServiceFluentForward) Description() string {
+	return "this is a fluent forward protocol server, which receives logs from fluentd and fluent-bit"
+}
+
+func (s *ServiceFluentForward) Start(c pipeline.Collector) error {
+	return fmt.Errorf("plugin %v only supports the v2 pipeline", pluginName)
+}
+
+func (s *ServiceFluentForward) StartService(ctx pipeline.PipelineContext) error {
+	s.collector = ctx.Collector()
+	s.server = &helper.ConnServer{
+		MaxConnections: s.MaxConnections,
+		Handle:         s.handleConn,
+		OnReject: func(conn net.Conn) {
+			s.rejectedConnsMetric.Add(1)
+			logger.Warning(s.context.GetRuntimeContext(), "FLUENT_FORWARD_ALARM", "too many connections, reject", conn.RemoteAddr())
+		},
+		OnAcceptError: func(err error) {
+			logger.Error(s.context.GetRuntimeContext(), "FLUENT_FORWARD_ALARM", "accept connection err", err)
+		},
+	}
+	if err := s.server.Start(s.Address, s.tlsConfig); err != nil {
+		logger.Error(s.context.GetRuntimeContext(), "FLUENT_FORWARD_ALARM", "start fluent forward server err", err)
+		return err
+	}
+	logger.Info(s.context.GetRuntimeContext(), "fluent forward server start", s.Address, "tls", s.tlsConfig != nil)
+	return nil
+}
+
+func (s *ServiceFluentForward) handleConn(conn net.Conn) {
+	dec := msgpack.NewDecoder(bufio.NewReader(conn))
+	for {
+		if s.IdleTimeoutSeconds > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(time.Duration(s.IdleTimeoutSeconds) * time.Second))
+		}
+		msg, err := readMessage(dec, s.MaxMessageSize)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				// the stream cannot be resynchronized after a malformed message
+				s.decodeErrorsMetric.Add(1)
+				logger.Warning(s.context.GetRuntimeContext(), "FLUENT_FORWARD_ALARM", "read forward message err, close the connection", err, "remote", conn.RemoteAddr())
+			}
+			return
+		}
+		s.collect(msg)
+		if msg.chunk != "" {
+			if err = writeAck(conn, msg.chunk); err != nil {
+				logger.Warning(s.context.GetRuntimeContext(), "FLUENT_FORWARD_ALARM", "write ack err, close the connection", err, "remote", conn.RemoteAddr())
+				return
+			}
+		}
+	}
+}
+
+func (s *ServiceFluentForward) collect(msg *message) {
+	if len(msg.entries) == 0 {
+		return
+	}
+	events := make([]models.PipelineEvent, 0, len(msg.entries))
+	for _, e := range msg.entries {
+		contents := models.NewLogContents()
+		for k, v := range e.record {
+			contents.Add(k, formatValue(v))
+		}
+		log := models.NewLog("", nil, "", "", "", models.NewTags(), e.timestamp)
+		log.SetIndices(contents)
+		events = append(events, log)
+	}
+	s.receivedEntriesMetric.Add(int64(len(events)))
+	group := models.NewGroup(models.NewMetadataWithKeyValues(s.TagMetadataKey, msg.tag), models.NewTags())
+	s.collector.Collect(group, events...)
+}
+
+// formatValue converts a record value to string, the nested maps and arrays are encoded in json.
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case bool:
+		return strconv.FormatBool(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case uint64:
+		return strconv.FormatUint(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case map[string]interface{}, []interface{}:
+		if data, err := json.Marshal(val); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// Stop stops the listener and closes all connections.
+func (s *ServiceFluentForward) Stop() error {
+	if s.server != nil {
+		s.server.Stop()
+	}
+	logger.Info(s.context.GetRuntimeContext(), "fluent forward server stop", s.Address)
+	return nil
+}
+
+func init() {
+	pipeline.ServiceInputs[pluginName] = func() pipeline.ServiceInput {
+		return &ServiceFluentForward{
+			Address:        "0.0.0.0:24224",
+			TagMetadataKey: "fluent_tag",
+			MaxConnections: 100,
+			MaxMessageSize: 32 * 1024 * 1024,
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fluentforward
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+const testMaxMessageSize = 1024 * 1024
+
+func encodeEventTime(t *testing.T, enc *msgpack.Encoder, sec, nsec uint32) {
+	require.NoError(t, enc.EncodeExtHeader(eventTimeExtID, eventTimeExtLen))
+	_, err := enc.Writer().Write([]byte{
+		byte(sec >> 24), byte(sec >> 16), byte(sec >> 8), byte(sec),
+		byte(nsec >> 24), byte(nsec >> 16), byte(nsec >> 8), byte(nsec),
+	})
+	require.NoError(t, err)
+}
+
+func encodePackedEntries(t *testing.T, compress bool) []byte {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, enc.EncodeArrayLen(2))
+		encodeEventTime(t, enc, 10, uint32(i))
+		require.NoError(t, enc.Encode(map[string]interface{}{"log": "packed"}))
+	}
+	if !compress {
+		return buf.Bytes()
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return compressed.Bytes()
+}
+
+func TestReadMessage(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	// Message mode with the integer time
+	require.NoError(t, enc.Encode([]interface{}{"app.message", 10, map[string]interface{}{
+		"log": "hello", "code": 200, "nested": map[string]interface{}{"k": "v"},
+	}}))
+	// Forward mode with the EventTime and option
+	require.NoError(t, enc.EncodeArrayLen(3))
+	require.NoError(t, enc.EncodeString("app.forward"))
+	require.NoError(t, enc.EncodeArrayLen(1))
+	require.NoError(t, enc.EncodeArrayLen(2))
+	encodeEventTime(t, enc, 10, 5)
+	require.NoError(t, enc.Encode(map[string]interface{}{"log": "forward"}))
+	require.NoError(t, enc.Encode(map[string]interface{}{"chunk": "abc"}))
+	// PackedForward and CompressedPackedForward modes
+	require.NoError(t, enc.Encode([]interface{}{"app.packed", encodePackedEntries(t, false)}))
+	require.NoError(t, enc.Encode([]interface{}{"app.compressed", encodePackedEntries(t, true), map[string]interface{}{"compressed": "gzip"}}))
+
+	dec := msgpack.NewDecoder(&buf)
+	msg, err := readMessage(dec, testMaxMessageSize)
+	require.NoError(t, err)
+	assert.Equal(t, "app.message", msg.tag)
+	require.Len(t, msg.entries, 1)
+	assert.Equal(t, uint64(10e9), msg.entries[0].timestamp)
+	assert.Equal(t, "200", formatValue(msg.entries[0].record["code"]))
+	assert.Equal(t, `{"k":"v"}`, formatValue(msg.entries[0].record["nested"]))
+
+	msg, err = readMessage(dec, testMaxMessageSize)
+	require.NoError(t, err)
+	assert.Equal(t, "app.forward", msg.tag)
+	assert.Equal(t, "abc", msg.chunk)
+	assert.Equal(t, uint64(10e9+5), msg.entries[0].timestamp)
+
+	for _, tag := range []string{"app.packed", "app.compressed"} {
+		msg, err = readMessage(dec, testMaxMessageSize)
+		require.NoError(t, err)
+		assert.Equal(t, tag, msg.tag)
+		require.Len(t, msg.entries, 2)
+		assert.Equal(t, uint64(10e9+1), msg.entries[1].timestamp)
+		assert.Equal(t, "packed", msg.entries[1].record["log"])
+	}
+}
+
+func TestReadMessageErrors(t *testing.T) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	require.NoError(t, enc.Encode([]interface{}{"tag"}))
+	_, err := readMessage(msgpack.NewDecoder(&buf), testMaxMessageSize)
+	assert.Error(t, err)
+
+	buf.Reset()
+	require.NoError(t, enc.Encode([]interface{}{"tag", encodePackedEntries(t, false)}))
+	_, err = readMessage(msgpack.NewDecoder(&buf), 16)
+	assert.ErrorIs(t, err, errMessageTooLarge)
+
+	// the claimed length is checked before reading the data
+	buf.Reset()
+	buf.Write([]byte{0x92, 0xa3, 't', 'a', 'g', 0xc6, 0x7f, 0xff, 0xff, 0xff})
+	_, err = readMessage(msgpack.NewDecoder(&buf), testMaxMessageSize)
+	assert.ErrorIs(t, err, errMessageTooLarge)
+
+	buf.Reset()
+	buf.Write([]byte{0x92, 0xa3, 't', 'a', 'g', 0xdd, 0x7f, 0xff, 0xff, 0xff})
+	_, err = readMessage(msgpack.NewDecoder(&buf), testMaxMessageSize)
+	assert.ErrorIs(t, err, errMessageTooLarge)
+
+	buf.Reset()
+	require.NoError(t, enc.Encode([]interface{}{"tag", encodePackedEntries(t, true), map[string]interface{}{"compressed": "zstd"}}))
+	_, err = readMessage(msgpack.NewDecoder(&buf), testMaxMessageSize)
+	assert.Error(t, err)
+}
+
+func TestService(t *testing.T) {
+	s := pipeline.ServiceInputs[pluginName]().(*ServiceFluentForward)
+	s.Address = "127.0.0.1:0"
+	_, err := s.Init(mock.NewEmptyContext("p", "l", "c"))
+	require.NoError(t, err)
+	ctx := pipeline.NewObservePipelineConext(10)
+	require.NoError(t, s.StartService(ctx))
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	conn, err := net.Dial("tcp", s.server.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	enc := msgpack.NewEncoder(conn)
+	require.NoError(t, enc.Encode([]interface{}{"app", encodePackedEntries(t, false), map[string]interface{}{"chunk": "c1"}}))
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	ack, err := msgpack.NewDecoder(conn).DecodeMap()
+	require.NoError(t, err)
+	assert.Equal(t, "c1", ack["ack"])
+
+	var group *models.PipelineGroupEvents
+	select {
+	case group = <-ctx.Collector().Observe():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for logs")
+	}
+	assert.Equal(t, "app", group.Group.GetMetadata().Get("fluent_tag"))
+	require.Len(t, group.Events, 2)
+	log := group.Events[0].(*models.Log)
+	assert.Equal(t, "packed", log.GetIndices().Get("log"))
+	assert.Equal(t, uint64(10e9), log.GetTimestamp())
+	assert.Equal(t, int64(2), s.receivedEntriesMetric.Get())
+}
@@ -54,7 +54,13 @@ func (g *GoProfile) Stop() error {
 }
 
 func (g *GoProfile) Start(collector pipeline.Collector) error {
-	g.manager = NewManager(collector)
+	g.manager = NewManager(&Ingestion{collector: collector})
+	return g.manager.Start(g)
+}
+
+// StartService emits the profiles as profile events.
+func (g *GoProfile) StartService(ctx pipeline.PipelineContext) error {
+	g.manager = NewManager(&Ingestion{pipelineCollector: ctx.Collector()})
 	return g.manager.Start(g)
 }
 
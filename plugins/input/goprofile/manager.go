@@ -25,6 +25,7 @@ import (
 	"github.com/alibaba/ilogtail/pkg/helper/profile"
 	"github.com/alibaba/ilogtail/pkg/helper/profile/pyroscope/pprof"
 	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
 	"github.com/alibaba/ilogtail/pkg/pipeline"
 
 	"github.com/mitchellh/mapstructure"
@@ -59,8 +60,10 @@ func (r *Register) Unregister(collector prometheus.Collector) bool {
 	return true
 }
 
+// Ingestion converts the scraped profiles into logs for the v1 pipeline, or profile events for the v2 pipeline.
 type Ingestion struct {
-	collector pipeline.Collector
+	collector         pipeline.Collector
+	pipelineCollector pipeline.PipelineCollector
 }
 
 func (i *Ingestion) Ingest(ctx context.Context, input *ingestion.IngestInput) error {
@@ -77,7 +80,7 @@ func (i *Ingestion) Ingest(ctx context.Context, input *ingestion.IngestInput) er
 		}
 
 	}
-	logs, err := p.Parse(ctx, &profile.Meta{
+	meta := &profile.Meta{
 		StartTime:       input.Metadata.StartTime,
 		EndTime:         input.Metadata.EndTime,
 		SpyName:         "go",
@@ -85,7 +88,23 @@ func (i *Ingestion) Ingest(ctx context.Context, input *ingestion.IngestInput) er
 		Units:           profile.Units(input.Metadata.Units),
 		AggregationType: getAggType(),
 		Tags:            input.Metadata.Key.Labels(),
-	}, map[string]string{})
+	}
+	if i.pipelineCollector != nil {
+		profiles, err := p.ParseV2(ctx, meta, map[string]string{})
+		if err != nil {
+			logger.Debug(context.Background(), "parse pprof fail err", err.Error())
+			return err
+		}
+		events := make([]models.PipelineEvent, 0, len(profiles))
+		for _, event := range profiles {
+			events = append(events, event)
+		}
+		if len(events) > 0 {
+			i.pipelineCollector.Collect(models.NewGroup(models.NewMetadata(), models.NewTags()), events...)
+		}
+		return nil
+	}
+	logs, err := p.Parse(ctx, meta, map[string]string{})
 	if err != nil {
 		logger.Debug(context.Background(), "parse pprof fail err", err.Error())
 		return err
@@ -101,7 +120,7 @@ type Manager struct {
 	discoveryManager *discovery.Manager
 }
 
-func NewManager(collector pipeline.Collector) *Manager {
+func NewManager(ingestion *Ingestion) *Manager {
 	logrusLogger := logrus.New()
 	logrusLogger.SetOutput(os.Stdout)
 	if logger.DebugFlag() {
@@ -111,7 +130,7 @@ func NewManager(collector pipeline.Collector) *Manager {
 	}
 	m := new(Manager)
 	m.discoveryManager = discovery.NewManager(logrusLogger)
-	m.scrapeManager = scrape.NewManager(logrusLogger, &Ingestion{collector: collector}, new(Register), false)
+	m.scrapeManager = scrape.NewManager(logrusLogger, ingestion, new(Register), false)
 	return m
 }
 
@@ -0,0 +1,128 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netflow
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+)
+
+// The normalized field names shared by all flow protocols.
+const (
+	fieldSrcAddr      = "src_addr"
+	fieldDstAddr      = "dst_addr"
+	fieldSrcPort      = "src_port"
+	fieldDstPort      = "dst_port"
+	fieldProto        = "proto"
+	fieldBytes        = "bytes"
+	fieldPackets      = "packets"
+	fieldSrcAS        = "src_as"
+	fieldDstAS        = "dst_as"
+	fieldInIf         = "in_if"
+	fieldOutIf        = "out_if"
+	fieldNextHop      = "next_hop"
+	fieldTCPFlags     = "tcp_flags"
+	fieldTos          = "tos"
+	fieldSrcMask      = "src_mask"
+	fieldDstMask      = "dst_mask"
+	fieldSrcMac       = "src_mac"
+	fieldDstMac       = "dst_mac"
+	fieldVlan         = "vlan"
+	fieldStartTime    = "start_time"
+	fieldEndTime      = "end_time"
+	fieldSamplingRate = "sampling_rate"
+)
+
+var errShortPacket = errors.New("packet is too short")
+
+// flowRecord is a decoded flow record, the key is the normalized field name.
+type flowRecord map[string]string
+
+func (r flowRecord) setUint(key string, v uint64) {
+	r[key] = strconv.FormatUint(v, 10)
+}
+
+// reader reads big endian values from a packet, the first error is kept and the later reads return zero values.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func newReader(buf []byte) *reader {
+	return &reader{buf: buf}
+}
+
+func (r *reader) len() int {
+	return len(r.buf)
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.err = errShortPacket
+		r.buf = nil
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *reader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// uintValue decodes a big endian unsigned integer of at most 8 bytes, which is the reduced size encoding of
+// NetFlow v9 and IPFIX.
+func uintValue(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func formatAddr(b []byte) string {
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return hex.EncodeToString(b)
+	}
+	return net.IP(b).String()
+}
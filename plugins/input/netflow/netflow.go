@@ -0,0 +1,480 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netflow
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	flowTypeNetflowV5 = "netflow_v5"
+	flowTypeNetflowV9 = "netflow_v9"
+	flowTypeIPFIX     = "ipfix"
+
+	netflowV5RecordLen = 48
+
+	// the set ids of templates and options templates, data sets use the template ids from 256
+	netflowV9TemplateSetID        = 0
+	netflowV9OptionsTemplateSetID = 1
+	ipfixTemplateSetID            = 2
+	ipfixOptionsTemplateSetID     = 3
+	minDataSetID                  = 256
+
+	// variableLength marks the IPFIX fields whose length is encoded in the data record
+	variableLength = 65535
+	enterpriseBit  = 0x8000
+)
+
+type fieldKind int
+
+const (
+	kindUint fieldKind = iota
+	kindAddr
+	kindMac
+	// kindUptime is the milliseconds since the exporter boots, which is converted to the unix time in milliseconds
+	kindUptime
+	kindSeconds
+	kindMilliseconds
+)
+
+type fieldSpec struct {
+	name string
+	kind fieldKind
+}
+
+// knownFields maps the information elements shared by NetFlow v9 and IPFIX to the normalized fields.
+var knownFields = map[uint16]fieldSpec{
+	1:   {fieldBytes, kindUint},
+	2:   {fieldPackets, kindUint},
+	4:   {fieldProto, kindUint},
+	5:   {fieldTos, kindUint},
+	6:   {fieldTCPFlags, kindUint},
+	7:   {fieldSrcPort, kindUint},
+	8:   {fieldSrcAddr, kindAddr},
+	9:   {fieldSrcMask, kindUint},
+	10:  {fieldInIf, kindUint},
+	11:  {fieldDstPort, kindUint},
+	12:  {fieldDstAddr, kindAddr},
+	13:  {fieldDstMask, kindUint},
+	14:  {fieldOutIf, kindUint},
+	15:  {fieldNextHop, kindAddr},
+	16:  {fieldSrcAS, kindUint},
+	17:  {fieldDstAS, kindUint},
+	21:  {fieldEndTime, kindUptime},
+	22:  {fieldStartTime, kindUptime},
+	27:  {fieldSrcAddr, kindAddr},
+	28:  {fieldDstAddr, kindAddr},
+	29:  {fieldSrcMask, kindUint},
+	30:  {fieldDstMask, kindUint},
+	34:  {fieldSamplingRate, kindUint},
+	56:  {fieldSrcMac, kindMac},
+	58:  {fieldVlan, kindUint},
+	62:  {fieldNextHop, kindAddr},
+	80:  {fieldDstMac, kindMac},
+	150: {fieldStartTime, kindSeconds},
+	151: {fieldEndTime, kindSeconds},
+	152: {fieldStartTime, kindMilliseconds},
+	153: {fieldEndTime, kindMilliseconds},
+}
+
+type templateField struct {
+	id         uint16
+	length     uint16
+	enterprise uint32
+}
+
+type template struct {
+	fields []templateField
+	// options templates describe the exporter instead of flows, their data records are not emitted
+	options   bool
+	updatedAt time.Time
+}
+
+// minRecordLength returns the minimal length of a data record, a variable length field takes one byte at least.
+func (t *template) minRecordLength() int {
+	n := 0
+	for _, f := range t.fields {
+		if f.length == variableLength {
+			n++
+		} else {
+			n += int(f.length)
+		}
+	}
+	return n
+}
+
+type templateKey struct {
+	exporter string
+	version  uint16
+	domain   uint32
+	id       uint16
+}
+
+// templateCache keeps the templates per exporter and observation domain, it is only accessed by the reading goroutine.
+// The expired templates are swept periodically, and the least recently refreshed template is evicted when the cache
+// is full, so that spoofed or churning exporters cannot grow the cache forever.
+type templateCache struct {
+	timeout      time.Duration
+	maxTemplates int
+	templates    map[templateKey]*template
+	lastSweep    time.Time
+}
+
+func newTemplateCache(timeout time.Duration, maxTemplates int) *templateCache {
+	return &templateCache{timeout: timeout, maxTemplates: maxTemplates, templates: make(map[templateKey]*template)}
+}
+
+func (c *templateCache) get(key templateKey, now time.Time) *template {
+	t, ok := c.templates[key]
+	if !ok {
+		return nil
+	}
+	if c.timeout > 0 && now.Sub(t.updatedAt) > c.timeout {
+		delete(c.templates, key)
+		return nil
+	}
+	return t
+}
+
+func (c *templateCache) put(key templateKey, t *template) {
+	if _, ok := c.templates[key]; !ok && c.maxTemplates > 0 && len(c.templates) >= c.maxTemplates {
+		c.sweep(t.updatedAt)
+		if len(c.templates) >= c.maxTemplates {
+			c.evictOldest()
+		}
+	}
+	c.templates[key] = t
+}
+
+// sweep removes the expired templates at most once per timeout.
+func (c *templateCache) sweep(now time.Time) {
+	if c.timeout <= 0 || now.Sub(c.lastSweep) < c.timeout {
+		return
+	}
+	c.lastSweep = now
+	for key, t := range c.templates {
+		if now.Sub(t.updatedAt) > c.timeout {
+			delete(c.templates, key)
+		}
+	}
+}
+
+func (c *templateCache) evictOldest() {
+	var oldestKey templateKey
+	var oldest *template
+	for key, t := range c.templates {
+		if oldest == nil || t.updatedAt.Before(oldest.updatedAt) {
+			oldestKey, oldest = key, t
+		}
+	}
+	delete(c.templates, oldestKey)
+}
+
+// packetInfo is the decoded information of a NetFlow or IPFIX packet.
+type packetInfo struct {
+	flowType string
+	domain   uint32
+	// the unix time in nanoseconds of the export
+	timestamp uint64
+	records   []flowRecord
+	// the count of data sets dropped because the templates are not received yet
+	templateMisses int
+}
+
+// netflowDecoder decodes NetFlow v5, v9 and IPFIX packets, the version is detected by the first two bytes.
+type netflowDecoder struct {
+	cache *templateCache
+}
+
+func newNetflowDecoder(templateTimeout time.Duration, maxTemplates int) *netflowDecoder {
+	return &netflowDecoder{cache: newTemplateCache(templateTimeout, maxTemplates)}
+}
+
+func (d *netflowDecoder) decode(packet []byte, exporter string, now time.Time) (*packetInfo, error) {
+	if len(packet) < 2 {
+		return nil, errShortPacket
+	}
+	d.cache.sweep(now)
+	switch version := uint16(packet[0])<<8 | uint16(packet[1]); version {
+	case 5:
+		return decodeNetflowV5(packet)
+	case 9:
+		return d.decodeNetflowV9(packet, exporter, now)
+	case 10:
+		return d.decodeIPFIX(packet, exporter, now)
+	default:
+		return nil, fmt.Errorf("unsupported netflow version %v", version)
+	}
+}
+
+func decodeNetflowV5(packet []byte) (*packetInfo, error) {
+	r := newReader(packet)
+	r.skip(2)
+	count := int(r.u16())
+	sysUptime := r.u32()
+	unixSecs := r.u32()
+	unixNsecs := r.u32()
+	r.skip(6) // flow_sequence, engine_type, engine_id
+	samplingInterval := r.u16() & 0x3fff
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.len() < count*netflowV5RecordLen {
+		return nil, errShortPacket
+	}
+	bootMillis := int64(unixSecs)*1000 + int64(unixNsecs)/1e6 - int64(sysUptime)
+	info := &packetInfo{
+		flowType:  flowTypeNetflowV5,
+		timestamp: uint64(unixSecs)*1e9 + uint64(unixNsecs),
+		records:   make([]flowRecord, 0, count),
+	}
+	for i := 0; i < count; i++ {
+		record := flowRecord{}
+		record[fieldSrcAddr] = formatAddr(r.bytes(4))
+		record[fieldDstAddr] = formatAddr(r.bytes(4))
+		record[fieldNextHop] = formatAddr(r.bytes(4))
+		record.setUint(fieldInIf, uint64(r.u16()))
+		record.setUint(fieldOutIf, uint64(r.u16()))
+		record.setUint(fieldPackets, uint64(r.u32()))
+		record.setUint(fieldBytes, uint64(r.u32()))
+		record[fieldStartTime] = strconv.FormatInt(bootMillis+int64(r.u32()), 10)
+		record[fieldEndTime] = strconv.FormatInt(bootMillis+int64(r.u32()), 10)
+		record.setUint(fieldSrcPort, uint64(r.u16()))
+		record.setUint(fieldDstPort, uint64(r.u16()))
+		r.skip(1)
+		record.setUint(fieldTCPFlags, uint64(r.u8()))
+		record.setUint(fieldProto, uint64(r.u8()))
+		record.setUint(fieldTos, uint64(r.u8()))
+		record.setUint(fieldSrcAS, uint64(r.u16()))
+		record.setUint(fieldDstAS, uint64(r.u16()))
+		record.setUint(fieldSrcMask, uint64(r.u8()))
+		record.setUint(fieldDstMask, uint64(r.u8()))
+		r.skip(2)
+		if samplingInterval > 0 {
+			record.setUint(fieldSamplingRate, uint64(samplingInterval))
+		}
+		info.records = append(info.records, record)
+	}
+	return info, r.err
+}
+
+func (d *netflowDecoder) decodeNetflowV9(packet []byte, exporter string, now time.Time) (*packetInfo, error) {
+	r := newReader(packet)
+	r.skip(4) // version, count
+	sysUptime := r.u32()
+	unixSecs := r.u32()
+	r.skip(4) // sequence
+	sourceID := r.u32()
+	if r.err != nil {
+		return nil, r.err
+	}
+	info := &packetInfo{
+		flowType:  flowTypeNetflowV9,
+		domain:    sourceID,
+		timestamp: uint64(unixSecs) * 1e9,
+	}
+	bootMillis := int64(unixSecs)*1000 - int64(sysUptime)
+	err := d.decodeSets(r, info, templateKey{exporter: exporter, version: 9, domain: sourceID}, bootMillis, now)
+	return info, err
+}
+
+func (d *netflowDecoder) decodeIPFIX(packet []byte, exporter string, now time.Time) (*packetInfo, error) {
+	r := newReader(packet)
+	r.skip(2)
+	length := int(r.u16())
+	exportTime := r.u32()
+	r.skip(4) // sequence
+	domain := r.u32()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if length < 16 || length > len(packet) {
+		return nil, fmt.Errorf("invalid ipfix message length %v", length)
+	}
+	r.buf = packet[16:length]
+	info := &packetInfo{
+		flowType:  flowTypeIPFIX,
+		domain:    domain,
+		timestamp: uint64(exportTime) * 1e9,
+	}
+	// the uptime fields of IPFIX are relative to systemInitTimeMilliseconds, which is rarely exported
+	err := d.decodeSets(r, info, templateKey{exporter: exporter, version: 10, domain: domain}, 0, now)
+	return info, err
+}
+
+// decodeSets decodes the flow sets of NetFlow v9 or the sets of IPFIX, which share the same layout.
+func (d *netflowDecoder) decodeSets(r *reader, info *packetInfo, key templateKey, bootMillis int64, now time.Time) error {
+	for r.len() >= 4 {
+		setID := r.u16()
+		setLength := int(r.u16())
+		if setLength < 4 {
+			return fmt.Errorf("invalid set length %v", setLength)
+		}
+		body := newReader(r.bytes(setLength - 4))
+		if r.err != nil {
+			return r.err
+		}
+		var err error
+		switch {
+		case key.version == 9 && setID == netflowV9TemplateSetID, key.version == 10 && setID == ipfixTemplateSetID:
+			err = d.decodeTemplates(body, key, false, now)
+		case key.version == 9 && setID == netflowV9OptionsTemplateSetID:
+			err = d.decodeNetflowV9OptionsTemplates(body, key, now)
+		case key.version == 10 && setID == ipfixOptionsTemplateSetID:
+			err = d.decodeTemplates(body, key, true, now)
+		case setID >= minDataSetID:
+			key.id = setID
+			t := d.cache.get(key, now)
+			if t == nil {
+				info.templateMisses++
+				continue
+			}
+			err = decodeDataSet(body, t, bootMillis, info)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeTemplates decodes the templates of NetFlow v9, and the templates and options templates of IPFIX.
+func (d *netflowDecoder) decodeTemplates(r *reader, key templateKey, options bool, now time.Time) error {
+	// the set may be padded with zeros
+	for r.len() >= 4 {
+		key.id = r.u16()
+		fieldCount := int(r.u16())
+		if key.id < minDataSetID {
+			if fieldCount == 0 {
+				// the padding
+				continue
+			}
+			return fmt.Errorf("invalid template id %v", key.id)
+		}
+		if fieldCount == 0 {
+			// the template withdrawal of IPFIX
+			delete(d.cache.templates, key)
+			continue
+		}
+		if options {
+			r.skip(2) // scope field count, the scope fields are listed first
+		}
+		t := &template{options: options, updatedAt: now, fields: make([]templateField, 0, fieldCount)}
+		for i := 0; i < fieldCount; i++ {
+			f := templateField{id: r.u16(), length: r.u16()}
+			if key.version == 10 && f.id&enterpriseBit != 0 {
+				f.id &^= enterpriseBit
+				f.enterprise = r.u32()
+			}
+			t.fields = append(t.fields, f)
+		}
+		if r.err != nil {
+			return r.err
+		}
+		d.cache.put(key, t)
+	}
+	return nil
+}
+
+func (d *netflowDecoder) decodeNetflowV9OptionsTemplates(r *reader, key templateKey, now time.Time) error {
+	for r.len() >= 6 {
+		key.id = r.u16()
+		scopeLength := int(r.u16())
+		optionLength := int(r.u16())
+		if key.id < minDataSetID {
+			// the padding
+			return nil
+		}
+		t := &template{options: true, updatedAt: now}
+		for i := 0; i < (scopeLength+optionLength)/4; i++ {
+			t.fields = append(t.fields, templateField{id: r.u16(), length: r.u16()})
+		}
+		if r.err != nil {
+			return r.err
+		}
+		d.cache.put(key, t)
+	}
+	return nil
+}
+
+func decodeDataSet(r *reader, t *template, bootMillis int64, info *packetInfo) error {
+	minLength := t.minRecordLength()
+	if minLength == 0 {
+		return nil
+	}
+	// the remaining bytes less than a record are the padding
+	for r.len() >= minLength {
+		record := flowRecord{}
+		for _, f := range t.fields {
+			length := int(f.length)
+			if f.length == variableLength {
+				if length = int(r.u8()); length == 255 {
+					length = int(r.u16())
+				}
+			}
+			value := r.bytes(length)
+			if r.err != nil {
+				return r.err
+			}
+			if !t.options {
+				setField(record, f, value, bootMillis)
+			}
+		}
+		if !t.options {
+			info.records = append(info.records, record)
+		}
+	}
+	return nil
+}
+
+// setField sets the normalized field of the information element, the unknown elements are kept as field_<id>
+// or field_<enterprise>_<id> for enterprise specific ones, in decimal or hex if longer than 8 bytes.
+func setField(record flowRecord, f templateField, value []byte, bootMillis int64) {
+	spec, ok := knownFields[f.id]
+	if !ok || f.enterprise != 0 {
+		key := "field_" + strconv.Itoa(int(f.id))
+		if f.enterprise != 0 {
+			key = "field_" + strconv.FormatUint(uint64(f.enterprise), 10) + "_" + strconv.Itoa(int(f.id))
+		}
+		// the variable length elements are usually strings or lists
+		if f.length != variableLength && len(value) <= 8 {
+			record.setUint(key, uintValue(value))
+		} else {
+			record[key] = hex.EncodeToString(value)
+		}
+		return
+	}
+	switch spec.kind {
+	case kindAddr:
+		record[spec.name] = formatAddr(value)
+	case kindMac:
+		record[spec.name] = net.HardwareAddr(value).String()
+	case kindUptime:
+		if bootMillis == 0 {
+			// keep the raw uptime if the boot time is unknown
+			record.setUint(spec.name+"_uptime", uintValue(value))
+			return
+		}
+		record[spec.name] = strconv.FormatInt(bootMillis+int64(uintValue(value)), 10)
+	case kindSeconds:
+		record.setUint(spec.name, uintValue(value)*1000)
+	default:
+		record.setUint(spec.name, uintValue(value))
+	}
+}
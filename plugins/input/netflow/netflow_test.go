@@ -0,0 +1,245 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// packetBuilder writes the big endian values of a test packet.
+type packetBuilder struct {
+	bytes.Buffer
+}
+
+func (b *packetBuilder) put(values ...interface{}) *packetBuilder {
+	for _, v := range values {
+		if ip, ok := v.(net.IP); ok {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			b.Write(ip)
+			continue
+		}
+		_ = binary.Write(b, binary.BigEndian, v)
+	}
+	return b
+}
+
+// set wraps the body into a NetFlow v9 flow set or an IPFIX set.
+func set(id uint16, body []byte) []byte {
+	b := &packetBuilder{}
+	b.put(id, uint16(len(body)+4))
+	b.Write(body)
+	return b.Bytes()
+}
+
+func netflowV9Packet(sets ...[]byte) []byte {
+	b := &packetBuilder{}
+	b.put(uint16(9), uint16(len(sets)), uint32(5000), uint32(1700000000), uint32(1), uint32(7))
+	for _, s := range sets {
+		b.Write(s)
+	}
+	return b.Bytes()
+}
+
+func ipfixPacket(sets ...[]byte) []byte {
+	body := &packetBuilder{}
+	for _, s := range sets {
+		body.Write(s)
+	}
+	b := &packetBuilder{}
+	b.put(uint16(10), uint16(body.Len()+16), uint32(1700000000), uint32(1), uint32(3))
+	b.Write(body.Bytes())
+	return b.Bytes()
+}
+
+func TestNetflowV5(t *testing.T) {
+	b := &packetBuilder{}
+	b.put(uint16(5), uint16(1), uint32(10000), uint32(1700000000), uint32(0), uint32(1), uint8(0), uint8(0), uint16(0x4000|100))
+	b.put(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.254"), uint16(1), uint16(2),
+		uint32(10), uint32(1500), uint32(4000), uint32(9000), uint16(1234), uint16(80),
+		uint8(0), uint8(0x12), uint8(6), uint8(0), uint16(64512), uint16(64513), uint8(24), uint8(16), uint16(0))
+
+	info, err := newNetflowDecoder(0, 100).decode(b.Bytes(), "192.168.0.1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, flowTypeNetflowV5, info.flowType)
+	assert.Equal(t, uint64(1700000000e9), info.timestamp)
+	require.Len(t, info.records, 1)
+	assert.Equal(t, flowRecord{
+		fieldSrcAddr: "10.0.0.1", fieldDstAddr: "10.0.0.2", fieldNextHop: "10.0.0.254",
+		fieldInIf: "1", fieldOutIf: "2", fieldPackets: "10", fieldBytes: "1500",
+		fieldStartTime: "1699999994000", fieldEndTime: "1699999999000",
+		fieldSrcPort: "1234", fieldDstPort: "80", fieldTCPFlags: "18", fieldProto: "6", fieldTos: "0",
+		fieldSrcAS: "64512", fieldDstAS: "64513", fieldSrcMask: "24", fieldDstMask: "16", fieldSamplingRate: "100",
+	}, info.records[0])
+
+	_, err = newNetflowDecoder(0, 100).decode(b.Bytes()[:60], "192.168.0.1", time.Now())
+	assert.Error(t, err)
+}
+
+func TestNetflowV9(t *testing.T) {
+	templates := &packetBuilder{}
+	templates.put(uint16(256), uint16(6),
+		uint16(8), uint16(4), uint16(12), uint16(4), uint16(7), uint16(2), uint16(11), uint16(2),
+		uint16(1), uint16(4), uint16(22), uint16(4))
+	options := &packetBuilder{}
+	options.put(uint16(257), uint16(4), uint16(4), uint16(1), uint16(4), uint16(34), uint16(4))
+	data := &packetBuilder{}
+	for i := 0; i < 2; i++ {
+		data.put(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), uint16(1000+i), uint16(53), uint32(100), uint32(4000))
+	}
+	data.put(uint16(0)) // padding
+	optionsData := &packetBuilder{}
+	optionsData.put(uint32(1), uint32(100))
+
+	decoder := newNetflowDecoder(time.Minute, 100)
+	now := time.Now()
+	// the data set before the template is dropped
+	info, err := decoder.decode(netflowV9Packet(set(256, data.Bytes())), "192.168.0.1", now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, info.templateMisses)
+	assert.Empty(t, info.records)
+
+	info, err = decoder.decode(netflowV9Packet(set(0, templates.Bytes()), set(1, options.Bytes()), set(257, optionsData.Bytes()), set(256, data.Bytes())), "192.168.0.1", now)
+	require.NoError(t, err)
+	assert.Equal(t, flowTypeNetflowV9, info.flowType)
+	assert.Equal(t, uint32(7), info.domain)
+	assert.Equal(t, 0, info.templateMisses)
+	require.Len(t, info.records, 2)
+	assert.Equal(t, flowRecord{
+		fieldSrcAddr: "10.0.0.1", fieldDstAddr: "10.0.0.2", fieldSrcPort: "1001", fieldDstPort: "53",
+		fieldBytes: "100", fieldStartTime: "1699999999000",
+	}, info.records[1])
+
+	// the templates are kept per exporter and expire
+	info, err = decoder.decode(netflowV9Packet(set(256, data.Bytes())), "192.168.0.2", now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, info.templateMisses)
+	info, err = decoder.decode(netflowV9Packet(set(256, data.Bytes())), "192.168.0.1", now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, info.templateMisses)
+}
+
+func TestIPFIX(t *testing.T) {
+	templates := &packetBuilder{}
+	templates.put(uint16(300), uint16(5),
+		uint16(27), uint16(16), uint16(28), uint16(16), uint16(2), uint16(8),
+		uint16(0x8000|100), uint16(65535), uint32(9999), // an enterprise specific string of variable length
+		uint16(152), uint16(8))
+	data := &packetBuilder{}
+	data.put(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), uint64(3), uint8(5))
+	data.WriteString("hello")
+	data.put(uint64(1700000000123))
+
+	decoder := newNetflowDecoder(0, 100)
+	info, err := decoder.decode(ipfixPacket(set(2, templates.Bytes()), set(300, data.Bytes())), "192.168.0.1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, flowTypeIPFIX, info.flowType)
+	assert.Equal(t, uint32(3), info.domain)
+	require.Len(t, info.records, 1)
+	assert.Equal(t, flowRecord{
+		fieldSrcAddr: "2001:db8::1", fieldDstAddr: "2001:db8::2", fieldPackets: "3",
+		"field_9999_100": "68656c6c6f", fieldStartTime: "1700000000123",
+	}, info.records[0])
+
+	// the template withdrawal
+	withdrawal := &packetBuilder{}
+	withdrawal.put(uint16(300), uint16(0))
+	info, err = decoder.decode(ipfixPacket(set(2, withdrawal.Bytes()), set(300, data.Bytes())), "192.168.0.1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, info.templateMisses)
+
+	_, err = decoder.decode([]byte{0, 10, 0, 100}, "192.168.0.1", time.Now())
+	assert.Error(t, err)
+	_, err = decoder.decode([]byte{0, 1}, "192.168.0.1", time.Now())
+	assert.Error(t, err)
+}
+
+func sflowRecord(format uint32, body []byte) []byte {
+	b := &packetBuilder{}
+	b.put(format, uint32(len(body)))
+	b.Write(body)
+	return b.Bytes()
+}
+
+func TestSflow(t *testing.T) {
+	frame := &packetBuilder{}
+	frame.put([]byte{0, 1, 2, 3, 4, 5}, []byte{6, 7, 8, 9, 10, 11}, uint16(etherTypeVlan), uint16(100), uint16(etherTypeIPv4))
+	// ipv4 header without options and the tcp header
+	frame.put(uint8(0x45), uint8(8), uint16(60), uint32(0), uint8(64), uint8(protoTCP), uint16(0),
+		net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"))
+	frame.put(uint16(443), uint16(50000), uint32(0), uint32(0), uint8(0x50), uint8(0x18))
+	for frame.Len()%4 != 0 {
+		frame.WriteByte(0)
+	}
+	header := &packetBuilder{}
+	header.put(uint32(sflowHeaderEthernet), uint32(1514), uint32(4), uint32(frame.Len()))
+	header.Write(frame.Bytes())
+	gateway := &packetBuilder{}
+	gateway.put(uint32(sflowAddressIPv4), net.ParseIP("10.0.0.254"), uint32(65000), uint32(64512), uint32(64600),
+		uint32(1), uint32(2), uint32(2), uint32(64700), uint32(64513))
+
+	sample := &packetBuilder{}
+	sample.put(uint32(1), uint32(3), uint32(512), uint32(1024), uint32(0), uint32(5), uint32(0x80000001), uint32(3))
+	sample.Write(sflowRecord(sflowRawPacketHeader, header.Bytes()))
+	sample.Write(sflowRecord(sflowExtendedGateway, gateway.Bytes()))
+	sample.Write(sflowRecord(9999, []byte{1, 2, 3, 4}))
+	counters := []byte{0, 0, 0, 0}
+
+	datagram := &packetBuilder{}
+	datagram.put(uint32(5), uint32(sflowAddressIPv4), net.ParseIP("192.168.0.1"), uint32(0), uint32(1), uint32(1000), uint32(2))
+	datagram.Write(sflowRecord(2, counters))
+	datagram.Write(sflowRecord(sflowFlowSample, sample.Bytes()))
+
+	decoded, err := decodeSflow(datagram.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.0.1", decoded.agent)
+	require.Len(t, decoded.records, 1)
+	assert.Equal(t, flowRecord{
+		fieldSamplingRate: "512", fieldInIf: "5", fieldPackets: "512", fieldBytes: "775168",
+		fieldDstMac: "00:01:02:03:04:05", fieldSrcMac: "06:07:08:09:0a:0b", fieldVlan: "100",
+		fieldTos: "8", fieldProto: "6", fieldSrcAddr: "10.0.0.1", fieldDstAddr: "10.0.0.2",
+		fieldSrcPort: "443", fieldDstPort: "50000", fieldTCPFlags: "24",
+		fieldNextHop: "10.0.0.254", fieldSrcAS: "64512", fieldDstAS: "64513",
+	}, decoded.records[0])
+
+	_, err = decodeSflow(datagram.Bytes()[:len(datagram.Bytes())-8])
+	assert.Error(t, err)
+	_, err = decodeSflow([]byte{0, 0, 0, 4})
+	assert.Error(t, err)
+}
+
+func TestTemplateCacheBounds(t *testing.T) {
+	now := time.Now()
+	cache := newTemplateCache(time.Minute, 2)
+	cache.put(templateKey{exporter: "a"}, &template{updatedAt: now})
+	cache.put(templateKey{exporter: "b"}, &template{updatedAt: now.Add(time.Second)})
+	// the least recently refreshed template is evicted when full
+	cache.put(templateKey{exporter: "c"}, &template{updatedAt: now.Add(2 * time.Second)})
+	assert.Len(t, cache.templates, 2)
+	assert.Nil(t, cache.get(templateKey{exporter: "a"}, now))
+	assert.NotNil(t, cache.get(templateKey{exporter: "b"}, now))
+
+	// the expired templates are swept without lookups
+	cache.sweep(now.Add(2 * time.Minute))
+	assert.Empty(t, cache.templates)
+}
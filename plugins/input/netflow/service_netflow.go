@@ -0,0 +1,201 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netflow
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+const (
+	pluginName = "service_netflow"
+
+	tagExporter          = "exporter"
+	tagFlowType          = "flow_type"
+	tagObservationDomain = "observation_domain"
+	tagAgent             = "agent"
+)
+
+// ServiceNetflow receives NetFlow v5/v9, IPFIX and sFlow v5 over UDP, and emits a log for each flow record.
+type ServiceNetflow struct {
+	NetflowAddress         string `comment:"the udp listening address of NetFlow v5/v9 and IPFIX, the version is detected by packets, empty to disable."`
+	SflowAddress           string `comment:"the udp listening address of sFlow v5, empty to disable."`
+	TemplateTimeoutSeconds int    `comment:"drop the NetFlow v9 and IPFIX templates not refreshed in the time, 0 means never."`
+	MaxTemplates           int    `comment:"the maximum count of the cached templates of all exporters, the least recently refreshed one is dropped when exceeded."`
+	MaxBufferSize          int    `comment:"the maximum size of an udp packet."`
+
+	context     pipeline.Context
+	collector   pipeline.PipelineCollector
+	decoder     *netflowDecoder
+	netflowConn net.PacketConn
+	sflowConn   net.PacketConn
+	wg          sync.WaitGroup
+
+	decodeErrorsMetric    pipeline.CounterMetric
+	templateMissesMetric  pipeline.CounterMetric
+	receivedRecordsMetric pipeline.CounterMetric
+}
+
+func (s *ServiceNetflow) Init(context pipeline.Context) (int, error) {
+	s.context = context
+	if s.NetflowAddress == "" && s.SflowAddress == "" {
+		return 0, fmt.Errorf("must specify NetflowAddress or SflowAddress for plugin %v", pluginName)
+	}
+	if s.MaxBufferSize <= 0 {
+		return 0, fmt.Errorf("invalid MaxBufferSize %v for plugin %v", s.MaxBufferSize, pluginName)
+	}
+	if s.MaxTemplates <= 0 {
+		return 0, fmt.Errorf("invalid MaxTemplates %v for plugin %v", s.MaxTemplates, pluginName)
+	}
+	s.decoder = newNetflowDecoder(time.Duration(s.TemplateTimeoutSeconds)*time.Second, s.MaxTemplates)
+	s.decodeErrorsMetric = helper.NewCounterMetricAndRegister("netflow_decode_errors", context)
+	s.templateMissesMetric = helper.NewCounterMetricAndRegister("netflow_template_misses", context)
+	s.receivedRecordsMetric = helper.NewCounterMetricAndRegister("netflow_received_records", context)
+	return 0, nil
+}
+
+func (s *ServiceNetflow) Description() string {
+	return "netflow, ipfix and sflow collector for logtail"
+}
+
+// Start is not supported, the flow records are only emitted to the v2 pipeline.
+func (s *ServiceNetflow) Start(c pipeline.Collector) error {
+	return fmt.Errorf("plugin %v only supports the v2 pipeline", pluginName)
+}
+
+func (s *ServiceNetflow) StartService(ctx pipeline.PipelineContext) error {
+	s.collector = ctx.Collector()
+	if s.NetflowAddress != "" {
+		conn, err := net.ListenPacket("udp", s.NetflowAddress)
+		if err != nil {
+			return fmt.Errorf("listen udp %v error: %v", s.NetflowAddress, err)
+		}
+		s.netflowConn = conn
+		s.wg.Add(1)
+		go s.serve(conn, s.handleNetflow)
+	}
+	if s.SflowAddress != "" {
+		conn, err := net.ListenPacket("udp", s.SflowAddress)
+		if err != nil {
+			if s.netflowConn != nil {
+				_ = s.netflowConn.Close()
+			}
+			return fmt.Errorf("listen udp %v error: %v", s.SflowAddress, err)
+		}
+		s.sflowConn = conn
+		s.wg.Add(1)
+		go s.serve(conn, s.handleSflow)
+	}
+	logger.Info(s.context.GetRuntimeContext(), "netflow collector start", "success", "netflow", s.NetflowAddress, "sflow", s.SflowAddress)
+	return nil
+}
+
+func (s *ServiceNetflow) serve(conn net.PacketConn, handle func(packet []byte, exporter string)) {
+	defer s.wg.Done()
+	buf := make([]byte, s.MaxBufferSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Error(s.context.GetRuntimeContext(), "NETFLOW_ALARM", "read udp packet error", err)
+			}
+			return
+		}
+		exporter := addr.String()
+		if udpAddr, ok := addr.(*net.UDPAddr); ok {
+			exporter = udpAddr.IP.String()
+		}
+		handle(buf[:n], exporter)
+	}
+}
+
+func (s *ServiceNetflow) handleNetflow(packet []byte, exporter string) {
+	info, err := s.decoder.decode(packet, exporter, time.Now())
+	if info != nil && info.templateMisses > 0 {
+		s.templateMissesMetric.Add(int64(info.templateMisses))
+		logger.Debug(s.context.GetRuntimeContext(), "drop data sets without templates", info.templateMisses, "exporter", exporter)
+	}
+	if err != nil {
+		s.decodeErrorsMetric.Add(1)
+		logger.Warning(s.context.GetRuntimeContext(), "NETFLOW_ALARM", "decode netflow packet error", err, "exporter", exporter)
+		return
+	}
+	tags := models.NewTagsWithKeyValues(tagExporter, exporter, tagFlowType, info.flowType)
+	if info.flowType != flowTypeNetflowV5 {
+		tags.Add(tagObservationDomain, strconv.FormatUint(uint64(info.domain), 10))
+	}
+	s.collect(tags, info.records, info.timestamp)
+}
+
+func (s *ServiceNetflow) handleSflow(packet []byte, exporter string) {
+	datagram, err := decodeSflow(packet)
+	if err != nil {
+		s.decodeErrorsMetric.Add(1)
+		logger.Warning(s.context.GetRuntimeContext(), "NETFLOW_ALARM", "decode sflow datagram error", err, "exporter", exporter)
+		return
+	}
+	tags := models.NewTagsWithKeyValues(tagExporter, exporter, tagFlowType, flowTypeSflowV5, tagAgent, datagram.agent)
+	s.collect(tags, datagram.records, uint64(time.Now().UnixNano()))
+}
+
+func (s *ServiceNetflow) collect(tags models.Tags, records []flowRecord, timestamp uint64) {
+	if len(records) == 0 {
+		return
+	}
+	events := make([]models.PipelineEvent, 0, len(records))
+	for _, record := range records {
+		contents := models.NewLogContents()
+		for k, v := range record {
+			contents.Add(k, v)
+		}
+		log := models.NewLog("", nil, "", "", "", models.NewTags(), timestamp)
+		log.SetIndices(contents)
+		events = append(events, log)
+	}
+	s.receivedRecordsMetric.Add(int64(len(events)))
+	s.collector.Collect(models.NewGroup(models.NewMetadata(), tags), events...)
+}
+
+func (s *ServiceNetflow) Stop() error {
+	if s.netflowConn != nil {
+		_ = s.netflowConn.Close()
+	}
+	if s.sflowConn != nil {
+		_ = s.sflowConn.Close()
+	}
+	s.wg.Wait()
+	logger.Info(s.context.GetRuntimeContext(), "netflow collector stop", "success")
+	return nil
+}
+
+func init() {
+	pipeline.ServiceInputs[pluginName] = func() pipeline.ServiceInput {
+		return &ServiceNetflow{
+			NetflowAddress:         ":2055",
+			TemplateTimeoutSeconds: 1800,
+			MaxTemplates:           10000,
+			MaxBufferSize:          65535,
+		}
+	}
+}
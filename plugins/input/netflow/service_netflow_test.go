@@ -0,0 +1,101 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netflow
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+func TestServiceInit(t *testing.T) {
+	s := pipeline.ServiceInputs[pluginName]().(*ServiceNetflow)
+	s.NetflowAddress = ""
+	_, err := s.Init(mock.NewEmptyContext("p", "l", "c"))
+	assert.Error(t, err)
+	assert.Error(t, s.Start(nil))
+}
+
+func TestService(t *testing.T) {
+	s := pipeline.ServiceInputs[pluginName]().(*ServiceNetflow)
+	s.NetflowAddress = "127.0.0.1:0"
+	s.SflowAddress = "127.0.0.1:0"
+	_, err := s.Init(mock.NewEmptyContext("p", "l", "c"))
+	require.NoError(t, err)
+	ctx := pipeline.NewObservePipelineConext(10)
+	require.NoError(t, s.StartService(ctx))
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	receive := func() *models.PipelineGroupEvents {
+		select {
+		case group := <-ctx.Collector().Observe():
+			return group
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for flows")
+		}
+		return nil
+	}
+
+	templates := &packetBuilder{}
+	templates.put(uint16(256), uint16(2), uint16(8), uint16(4), uint16(12), uint16(4))
+	data := &packetBuilder{}
+	data.put(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"))
+	conn, err := net.Dial("udp", s.netflowConn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(netflowV9Packet(set(256, data.Bytes())))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte{0, 1, 2})
+	require.NoError(t, err)
+	_, err = conn.Write(netflowV9Packet(set(0, templates.Bytes()), set(256, data.Bytes())))
+	require.NoError(t, err)
+
+	group := receive()
+	assert.Equal(t, "127.0.0.1", group.Group.GetTags().Get(tagExporter))
+	assert.Equal(t, flowTypeNetflowV9, group.Group.GetTags().Get(tagFlowType))
+	assert.Equal(t, "7", group.Group.GetTags().Get(tagObservationDomain))
+	require.Len(t, group.Events, 1)
+	log := group.Events[0].(*models.Log)
+	assert.Equal(t, "10.0.0.1", log.GetIndices().Get(fieldSrcAddr))
+	assert.Equal(t, uint64(1700000000e9), log.GetTimestamp())
+	assert.Equal(t, int64(1), s.templateMissesMetric.Get())
+	assert.Equal(t, int64(1), s.decodeErrorsMetric.Get())
+
+	sample := &packetBuilder{}
+	sample.put(uint32(1), uint32(3), uint32(512), uint32(1024), uint32(0), uint32(5), uint32(6), uint32(0))
+	datagram := &packetBuilder{}
+	datagram.put(uint32(5), uint32(sflowAddressIPv4), net.ParseIP("192.168.0.1"), uint32(0), uint32(1), uint32(1000), uint32(1))
+	datagram.Write(sflowRecord(sflowFlowSample, sample.Bytes()))
+	sflowConn, err := net.Dial("udp", s.sflowConn.LocalAddr().String())
+	require.NoError(t, err)
+	defer sflowConn.Close()
+	_, err = sflowConn.Write(datagram.Bytes())
+	require.NoError(t, err)
+
+	group = receive()
+	assert.Equal(t, flowTypeSflowV5, group.Group.GetTags().Get(tagFlowType))
+	assert.Equal(t, "192.168.0.1", group.Group.GetTags().Get(tagAgent))
+	require.Len(t, group.Events, 1)
+	assert.Equal(t, "6", group.Events[0].(*models.Log).GetIndices().Get(fieldOutIf))
+	assert.Equal(t, int64(2), s.receivedRecordsMetric.Get())
+}
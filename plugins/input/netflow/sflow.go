@@ -0,0 +1,275 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netflow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+const (
+	flowTypeSflowV5 = "sflow_v5"
+
+	sflowFlowSample         = 1
+	sflowExpandedFlowSample = 3
+
+	sflowRawPacketHeader  = 1
+	sflowSampledIPv4      = 3
+	sflowSampledIPv6      = 4
+	sflowExtendedSwitch   = 1001
+	sflowExtendedRouter   = 1002
+	sflowExtendedGateway  = 1003
+	sflowHeaderEthernet   = 1
+	sflowHeaderIPv4       = 11
+	sflowHeaderIPv6       = 12
+	sflowAddressIPv4      = 1
+	sflowAddressIPv6      = 2
+	sflowInterfaceUnknown = 0x3fffffff
+
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVlan = 0x8100
+
+	protoTCP = 6
+	protoUDP = 17
+)
+
+// sflowDatagram is the decoded sFlow v5 datagram, each flow sample is decoded into a record.
+// The counter samples are skipped.
+type sflowDatagram struct {
+	agent   string
+	records []flowRecord
+}
+
+func decodeSflow(packet []byte) (*sflowDatagram, error) {
+	r := newReader(packet)
+	if version := r.u32(); r.err == nil && version != 5 {
+		return nil, fmt.Errorf("unsupported sflow version %v", version)
+	}
+	datagram := &sflowDatagram{agent: readSflowAddress(r)}
+	r.skip(12) // sub_agent_id, sequence, uptime
+	samples := int(r.u32())
+	for i := 0; i < samples && r.err == nil; i++ {
+		format := r.u32()
+		body := newReader(r.bytes(int(r.u32())))
+		if r.err != nil {
+			break
+		}
+		// the standard formats have the enterprise 0
+		switch format {
+		case sflowFlowSample, sflowExpandedFlowSample:
+			record, err := decodeSflowFlowSample(body, format == sflowExpandedFlowSample)
+			if err != nil {
+				return nil, err
+			}
+			datagram.records = append(datagram.records, record)
+		}
+	}
+	return datagram, r.err
+}
+
+func readSflowAddress(r *reader) string {
+	switch addrType := r.u32(); addrType {
+	case sflowAddressIPv4:
+		return formatAddr(r.bytes(net.IPv4len))
+	case sflowAddressIPv6:
+		return formatAddr(r.bytes(net.IPv6len))
+	}
+	return ""
+}
+
+func decodeSflowFlowSample(r *reader, expanded bool) (flowRecord, error) {
+	record := flowRecord{}
+	var input, output, samplingRate uint32
+	r.skip(4) // sequence
+	if expanded {
+		r.skip(8) // source id type and index
+		samplingRate = r.u32()
+		r.skip(8) // sample pool, drops
+		if r.u32() == 0 {
+			input = r.u32()
+		} else {
+			r.skip(4)
+			input = sflowInterfaceUnknown
+		}
+		if r.u32() == 0 {
+			output = r.u32()
+		} else {
+			r.skip(4)
+			output = sflowInterfaceUnknown
+		}
+	} else {
+		r.skip(4) // source id
+		samplingRate = r.u32()
+		r.skip(8) // sample pool, drops
+		// the top 2 bits are the format, 0 means the ifIndex
+		if input = r.u32(); input>>30 != 0 {
+			input = sflowInterfaceUnknown
+		}
+		if output = r.u32(); output>>30 != 0 {
+			output = sflowInterfaceUnknown
+		}
+	}
+	if input != sflowInterfaceUnknown {
+		record.setUint(fieldInIf, uint64(input))
+	}
+	if output != sflowInterfaceUnknown {
+		record.setUint(fieldOutIf, uint64(output))
+	}
+	record.setUint(fieldSamplingRate, uint64(samplingRate))
+	count := int(r.u32())
+	for i := 0; i < count && r.err == nil; i++ {
+		format := r.u32()
+		body := newReader(r.bytes(int(r.u32())))
+		if r.err != nil {
+			break
+		}
+		switch format {
+		case sflowRawPacketHeader:
+			decodeSflowRawHeader(body, record)
+		case sflowSampledIPv4, sflowSampledIPv6:
+			addrLen := net.IPv4len
+			if format == sflowSampledIPv6 {
+				addrLen = net.IPv6len
+			}
+			record.setUint(fieldBytes, uint64(body.u32()))
+			record.setUint(fieldProto, uint64(body.u32()))
+			record[fieldSrcAddr] = formatAddr(body.bytes(addrLen))
+			record[fieldDstAddr] = formatAddr(body.bytes(addrLen))
+			record.setUint(fieldSrcPort, uint64(body.u32()))
+			record.setUint(fieldDstPort, uint64(body.u32()))
+			record.setUint(fieldTCPFlags, uint64(body.u32()))
+			record.setUint(fieldTos, uint64(body.u32()))
+		case sflowExtendedSwitch:
+			record.setUint(fieldVlan, uint64(body.u32()))
+		case sflowExtendedRouter:
+			record[fieldNextHop] = readSflowAddress(body)
+			record.setUint(fieldSrcMask, uint64(body.u32()))
+			record.setUint(fieldDstMask, uint64(body.u32()))
+		case sflowExtendedGateway:
+			decodeSflowGateway(body, record)
+		}
+		if body.err != nil {
+			return nil, fmt.Errorf("invalid sflow flow record %v: %v", format, body.err)
+		}
+	}
+	scaleSflowSample(record, samplingRate)
+	return record, r.err
+}
+
+// scaleSflowSample estimates the packets and bytes of the sample, every sample stands for samplingRate packets of the
+// same length.
+func scaleSflowSample(record flowRecord, samplingRate uint32) {
+	if samplingRate == 0 {
+		samplingRate = 1
+	}
+	record.setUint(fieldPackets, uint64(samplingRate))
+	if length, err := strconv.ParseUint(record[fieldBytes], 10, 64); err == nil {
+		record.setUint(fieldBytes, length*uint64(samplingRate))
+	}
+}
+
+func decodeSflowGateway(r *reader, record flowRecord) {
+	record[fieldNextHop] = readSflowAddress(r)
+	r.skip(4) // the as of the router
+	record.setUint(fieldSrcAS, uint64(r.u32()))
+	r.skip(4) // src peer as
+	// the destination as is the last one of the as path
+	var dstAS uint32
+	segments := int(r.u32())
+	for i := 0; i < segments && r.err == nil; i++ {
+		r.skip(4) // segment type
+		for n := int(r.u32()); n > 0 && r.err == nil; n-- {
+			dstAS = r.u32()
+		}
+	}
+	record.setUint(fieldDstAS, uint64(dstAS))
+}
+
+// decodeSflowRawHeader decodes the sampled packet header, the truncated headers are decoded as far as possible.
+func decodeSflowRawHeader(r *reader, record flowRecord) {
+	headerProtocol := r.u32()
+	record.setUint(fieldBytes, uint64(r.u32()))
+	r.skip(4) // stripped
+	header := r.bytes(int(r.u32()))
+	if r.err != nil {
+		return
+	}
+	switch headerProtocol {
+	case sflowHeaderEthernet:
+		decodeEthernet(header, record)
+	case sflowHeaderIPv4:
+		decodeIP(header, etherTypeIPv4, record)
+	case sflowHeaderIPv6:
+		decodeIP(header, etherTypeIPv6, record)
+	}
+}
+
+func decodeEthernet(frame []byte, record flowRecord) {
+	if len(frame) < 14 {
+		return
+	}
+	record[fieldDstMac] = net.HardwareAddr(frame[0:6]).String()
+	record[fieldSrcMac] = net.HardwareAddr(frame[6:12]).String()
+	etherType := binary.BigEndian.Uint16(frame[12:14])
+	payload := frame[14:]
+	if etherType == etherTypeVlan && len(payload) >= 4 {
+		record.setUint(fieldVlan, uint64(binary.BigEndian.Uint16(payload[0:2])&0x0fff))
+		etherType = binary.BigEndian.Uint16(payload[2:4])
+		payload = payload[4:]
+	}
+	decodeIP(payload, etherType, record)
+}
+
+func decodeIP(packet []byte, etherType uint16, record flowRecord) {
+	var proto byte
+	var transport []byte
+	switch etherType {
+	case etherTypeIPv4:
+		if len(packet) < 20 {
+			return
+		}
+		headerLen := int(packet[0]&0x0f) * 4
+		record.setUint(fieldTos, uint64(packet[1]))
+		proto = packet[9]
+		record[fieldSrcAddr] = net.IP(packet[12:16]).String()
+		record[fieldDstAddr] = net.IP(packet[16:20]).String()
+		if headerLen >= 20 && headerLen <= len(packet) {
+			transport = packet[headerLen:]
+		}
+	case etherTypeIPv6:
+		if len(packet) < 40 {
+			return
+		}
+		record.setUint(fieldTos, uint64(binary.BigEndian.Uint16(packet[0:2])>>4&0xff))
+		// the extension headers are not followed
+		proto = packet[6]
+		record[fieldSrcAddr] = net.IP(packet[8:24]).String()
+		record[fieldDstAddr] = net.IP(packet[24:40]).String()
+		transport = packet[40:]
+	default:
+		return
+	}
+	record[fieldProto] = strconv.Itoa(int(proto))
+	if (proto == protoTCP || proto == protoUDP) && len(transport) >= 4 {
+		record.setUint(fieldSrcPort, uint64(binary.BigEndian.Uint16(transport[0:2])))
+		record.setUint(fieldDstPort, uint64(binary.BigEndian.Uint16(transport[2:4])))
+	}
+	if proto == protoTCP && len(transport) >= 14 {
+		record.setUint(fieldTCPFlags, uint64(transport[13]))
+	}
+}
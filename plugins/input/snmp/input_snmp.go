@@ -204,48 +204,13 @@ func (s *Agent) Wrapper(envTarget string) (*g.GoSNMP, error) {
 
 	switch s.Version {
 	case 3:
-		var authenticationProtocol g.SnmpV3AuthProtocol
-		switch s.AuthenticationProtocol {
-		case "", "NoAuth":
-			authenticationProtocol = g.NoAuth
-		case "MD5":
-			authenticationProtocol = g.MD5
-		case "SHA":
-			authenticationProtocol = g.SHA
-		case "SHA224":
-			authenticationProtocol = g.SHA224
-		case "SHA256":
-			authenticationProtocol = g.SHA256
-		case "SHA384":
-			authenticationProtocol = g.SHA384
-		case "SHA512":
-			authenticationProtocol = g.SHA512
-		default:
-			return nil, fmt.Errorf("unrecognized authenticationProtocol %v,"+
-				" only support \"NoAuth\" \"MD5\" \"SHA\" \"SHA224\" \"SHA256\" \"SHA384\" \"SHA512\"",
-				s.AuthenticationProtocol)
+		authenticationProtocol, err := parseAuthenticationProtocol(s.AuthenticationProtocol)
+		if err != nil {
+			return nil, err
 		}
-
-		var privacyProtocol g.SnmpV3PrivProtocol
-		switch s.PrivacyProtocol {
-		case "", "NoPriv":
-			privacyProtocol = g.NoPriv
-		case "DES":
-			privacyProtocol = g.DES
-		case "AES":
-			privacyProtocol = g.AES
-		case "AES192":
-			privacyProtocol = g.AES192
-		case "AES256":
-			privacyProtocol = g.AES256
-		case "AES192C":
-			privacyProtocol = g.AES192C
-		case "AES256C":
-			privacyProtocol = g.AES256C
-		default:
-			return nil, fmt.Errorf("unrecognized privacyProtocol %v,"+
-				" only support \"NoPriv\" \"DES\" \"AES\" \"AES192\" \"AES256\" \"AES192C\" \"AES256C\"",
-				s.PrivacyProtocol)
+		privacyProtocol, err := parsePrivacyProtocol(s.PrivacyProtocol)
+		if err != nil {
+			return nil, err
 		}
 
 		thisGoSNMP.Version = g.Version3
@@ -272,6 +237,50 @@ func (s *Agent) Wrapper(envTarget string) (*g.GoSNMP, error) {
 	return thisGoSNMP, nil
 }
 
+// parseAuthenticationProtocol converts the name of the SNMP v3 authentication protocol.
+func parseAuthenticationProtocol(name string) (g.SnmpV3AuthProtocol, error) {
+	switch name {
+	case "", "NoAuth":
+		return g.NoAuth, nil
+	case "MD5":
+		return g.MD5, nil
+	case "SHA":
+		return g.SHA, nil
+	case "SHA224":
+		return g.SHA224, nil
+	case "SHA256":
+		return g.SHA256, nil
+	case "SHA384":
+		return g.SHA384, nil
+	case "SHA512":
+		return g.SHA512, nil
+	}
+	return g.NoAuth, fmt.Errorf("unrecognized authenticationProtocol %v,"+
+		" only support \"NoAuth\" \"MD5\" \"SHA\" \"SHA224\" \"SHA256\" \"SHA384\" \"SHA512\"", name)
+}
+
+// parsePrivacyProtocol converts the name of the SNMP v3 privacy protocol.
+func parsePrivacyProtocol(name string) (g.SnmpV3PrivProtocol, error) {
+	switch name {
+	case "", "NoPriv":
+		return g.NoPriv, nil
+	case "DES":
+		return g.DES, nil
+	case "AES":
+		return g.AES, nil
+	case "AES192":
+		return g.AES192, nil
+	case "AES256":
+		return g.AES256, nil
+	case "AES192C":
+		return g.AES192C, nil
+	case "AES256C":
+		return g.AES256C, nil
+	}
+	return g.NoPriv, fmt.Errorf("unrecognized privacyProtocol %v,"+
+		" only support \"NoPriv\" \"DES\" \"AES\" \"AES192\" \"AES256\" \"AES192C\" \"AES256C\"", name)
+}
+
 func Asn1BER2String(source g.Asn1BER) (res string) {
 	switch source {
 	case 0x00:
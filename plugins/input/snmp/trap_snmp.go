@@ -0,0 +1,419 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+
+	g "github.com/gosnmp/gosnmp"
+)
+
+const (
+	trapPluginName = "service_snmp_trap"
+
+	sysUpTimeOid = ".1.3.6.1.2.1.1.3.0"
+	trapOid      = ".1.3.6.1.6.3.1.1.4.1.0"
+)
+
+// translateExec runs snmptranslate, which is replaced in tests.
+var translateExec = execCmd
+
+// translateQueueSize is the maximum count of the traps waiting for the oid translation, more traps are dropped.
+const translateQueueSize = 1000
+
+// TrapReceiver listens for SNMP v1/v2c traps and v3 traps and informs over UDP, the INFORMs are responded automatically.
+// TCP is not supported as the trap listener of gosnmp cannot be closed on TCP.
+type TrapReceiver struct {
+	// Address sets the listening udp address
+	Address string
+	// Community filters the v1/v2c traps by community, empty to accept all
+	Community string
+	// TranslateOids translates the oids into names by local MIBs, needs command `snmptranslate`
+	TranslateOids bool
+	// MibDirs sets the extra directories to search MIB files
+	MibDirs []string
+
+	// Authentication information for SNMP v3
+	UserName                 string
+	AuthoritativeEngineID    string
+	AuthenticationProtocol   string
+	AuthenticationPassphrase string
+	PrivacyProtocol          string
+	PrivacyPassphrase        string
+
+	context    pipeline.Context
+	listener   *g.TrapListener
+	params     *g.GoSNMP
+	translator *oidTranslator
+	// the traps are translated out of the listener goroutine, as snmptranslate is slow
+	translateQueue chan map[string]string
+	stopCh         chan struct{}
+	wg             sync.WaitGroup
+	translateWg    sync.WaitGroup
+
+	receivedTrapsMetric pipeline.CounterMetric
+	droppedTrapsMetric  pipeline.CounterMetric
+}
+
+func (t *TrapReceiver) Description() string {
+	return "SNMP trap and inform receiver for logtail"
+}
+
+func (t *TrapReceiver) Init(context pipeline.Context) (int, error) {
+	t.context = context
+	if t.Address == "" {
+		return 0, fmt.Errorf("must specify Address for plugin %v", trapPluginName)
+	}
+	t.params = &g.GoSNMP{
+		Transport: "udp",
+		Version:   g.Version2c,
+		Community: t.Community,
+	}
+	if t.UserName != "" {
+		authenticationProtocol, err := parseAuthenticationProtocol(t.AuthenticationProtocol)
+		if err != nil {
+			return 0, err
+		}
+		privacyProtocol, err := parsePrivacyProtocol(t.PrivacyProtocol)
+		if err != nil {
+			return 0, err
+		}
+		if t.PrivacyPassphrase == "" {
+			t.PrivacyPassphrase = t.AuthenticationPassphrase
+		}
+		msgFlags := g.NoAuthNoPriv
+		if authenticationProtocol != g.NoAuth {
+			msgFlags = g.AuthNoPriv
+			if privacyProtocol != g.NoPriv {
+				msgFlags = g.AuthPriv
+			}
+		}
+		t.params.Version = g.Version3
+		t.params.SecurityModel = g.UserSecurityModel
+		t.params.MsgFlags = msgFlags
+		t.params.SecurityParameters = &g.UsmSecurityParameters{
+			UserName:                 t.UserName,
+			AuthenticationProtocol:   authenticationProtocol,
+			AuthenticationPassphrase: t.AuthenticationPassphrase,
+			PrivacyProtocol:          privacyProtocol,
+			PrivacyPassphrase:        t.PrivacyPassphrase,
+			AuthoritativeEngineID:    t.AuthoritativeEngineID,
+		}
+	}
+	if t.TranslateOids {
+		t.translator = newOidTranslator(context.GetRuntimeContext(), t.MibDirs)
+	}
+	t.receivedTrapsMetric = helper.NewCounterMetricAndRegister("snmp_trap_received", context)
+	t.droppedTrapsMetric = helper.NewCounterMetricAndRegister("snmp_trap_dropped", context)
+	return 0, nil
+}
+
+func (t *TrapReceiver) Collect(_ pipeline.Collector) error {
+	return nil
+}
+
+func (t *TrapReceiver) Start(collector pipeline.Collector) error {
+	t.listener = g.NewTrapListener()
+	t.listener.Params = t.params
+	if t.translator != nil {
+		t.translateQueue = make(chan map[string]string, translateQueueSize)
+		t.stopCh = make(chan struct{})
+		t.translateWg.Add(1)
+		go t.translateLoop(collector)
+	}
+	t.listener.OnNewTrap = func(packet *g.SnmpPacket, addr *net.UDPAddr) {
+		fields := t.convert(packet, addr)
+		if fields == nil {
+			return
+		}
+		if t.translateQueue == nil {
+			collector.AddData(nil, fields)
+			return
+		}
+		select {
+		case t.translateQueue <- fields:
+		default:
+			t.droppedTrapsMetric.Add(1)
+			logger.Warning(t.context.GetRuntimeContext(), "SNMP_TRAP_ALARM", "drop trap as the translation queue is full, from", addr)
+		}
+	}
+	listening := t.listener.Listening()
+	errCh := make(chan error, 1)
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		if err := t.listener.Listen("udp://" + t.Address); err != nil {
+			logger.Error(t.context.GetRuntimeContext(), "SNMP_TRAP_ALARM", "trap listener stopped, err", err)
+			errCh <- err
+		}
+	}()
+	select {
+	case <-listening:
+		logger.Info(t.context.GetRuntimeContext(), "snmp trap receiver start", t.Address)
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
+
+// convert translates the trap into fields, returns nil if the trap is dropped.
+func (t *TrapReceiver) convert(packet *g.SnmpPacket, addr *net.UDPAddr) map[string]string {
+	if packet.Version != g.Version3 && t.Community != "" && packet.Community != t.Community {
+		t.droppedTrapsMetric.Add(1)
+		logger.Warning(t.context.GetRuntimeContext(), "SNMP_TRAP_ALARM", "drop trap of wrong community from", addr)
+		return nil
+	}
+	t.receivedTrapsMetric.Add(1)
+	fields := map[string]string{
+		"_version_":  packet.Version.String(),
+		"_pdu_type_": pduTypeName(packet.PDUType),
+	}
+	if addr != nil {
+		fields["_source_"] = addr.IP.String()
+	}
+	if packet.Version == g.Version3 {
+		if usm, ok := packet.SecurityParameters.(*g.UsmSecurityParameters); ok {
+			fields["_user_name_"] = usm.UserName
+		}
+	} else {
+		fields["_community_"] = packet.Community
+	}
+	if packet.PDUType == g.Trap {
+		// the trap information of SNMP v1 is in the PDU header
+		fields["_enterprise_"] = packet.Enterprise
+		fields["_agent_address_"] = packet.AgentAddress
+		fields["_generic_trap_"] = strconv.Itoa(packet.GenericTrap)
+		fields["_specific_trap_"] = strconv.Itoa(packet.SpecificTrap)
+		fields["_uptime_"] = strconv.FormatUint(uint64(packet.Timestamp), 10)
+	}
+	for _, variable := range packet.Variables {
+		switch variable.Name {
+		case sysUpTimeOid:
+			fields["_uptime_"] = formatVariable(variable)
+		case trapOid:
+			fields["_trap_oid_"] = formatVariable(variable)
+		default:
+			fields[variable.Name] = formatVariable(variable)
+		}
+	}
+	return fields
+}
+
+// translateLoop translates the oids of the queued traps until stopped, the queued traps are flushed when stopping.
+func (t *TrapReceiver) translateLoop(collector pipeline.Collector) {
+	defer t.translateWg.Done()
+	for {
+		select {
+		case fields := <-t.translateQueue:
+			collector.AddData(nil, t.translateFields(fields))
+		case <-t.stopCh:
+			for {
+				select {
+				case fields := <-t.translateQueue:
+					collector.AddData(nil, t.translateFields(fields))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// translateFields translates the oid values of the trap oid and enterprise, and the oid keys of the varbinds.
+func (t *TrapReceiver) translateFields(fields map[string]string) map[string]string {
+	res := make(map[string]string, len(fields))
+	for k, v := range fields {
+		switch {
+		case k == "_trap_oid_" || k == "_enterprise_":
+			res[k] = t.translator.translate(v)
+		case strings.HasPrefix(k, "."):
+			res[t.translator.translate(k)] = v
+		default:
+			res[k] = v
+		}
+	}
+	return res
+}
+
+func pduTypeName(pduType g.PDUType) string {
+	switch pduType {
+	case g.Trap:
+		return "Trap"
+	case g.SNMPv2Trap:
+		return "SNMPv2Trap"
+	case g.InformRequest:
+		return "InformRequest"
+	}
+	return fmt.Sprintf("0x%x", byte(pduType))
+}
+
+// formatVariable converts the value of a varbind to string.
+func formatVariable(variable g.SnmpPDU) string {
+	switch variable.Type {
+	case g.OctetString:
+		if b, ok := variable.Value.([]byte); ok {
+			return string(b)
+		}
+	case g.ObjectIdentifier, g.IPAddress:
+		if s, ok := variable.Value.(string); ok {
+			return s
+		}
+	case g.Null, g.NoSuchObject, g.NoSuchInstance, g.EndOfMibView:
+		return ""
+	case g.OpaqueFloat, g.OpaqueDouble:
+		return fmt.Sprint(variable.Value)
+	}
+	return g.ToBigInt(variable.Value).String()
+}
+
+func (t *TrapReceiver) Stop() error {
+	if t.listener != nil {
+		t.listener.Close()
+	}
+	t.wg.Wait()
+	if t.stopCh != nil {
+		close(t.stopCh)
+		t.translateWg.Wait()
+	}
+	logger.Info(t.context.GetRuntimeContext(), "snmp trap receiver stop", t.Address)
+	return nil
+}
+
+// oidTranslator translates numeric oids into names like `IF-MIB::ifIndex.1` by snmptranslate, it is only used by
+// the translating goroutine. The results are cached by the base oids without the instance suffixes, so that the
+// instances of the same object are translated by one execution.
+type oidTranslator struct {
+	ctx      context.Context
+	args     []string
+	exact    map[string]string
+	objects  map[string]string
+	disabled bool
+}
+
+const maxTranslatedOids = 10000
+
+func newOidTranslator(ctx context.Context, mibDirs []string) *oidTranslator {
+	// -Td prints the details of the object after the name, which tell whether the object is a leaf
+	args := []string{"-Td", "-m", "all"}
+	if len(mibDirs) > 0 {
+		// the leading + appends the directories to the default ones
+		args = append(args, "-M", "+"+strings.Join(mibDirs, ":"))
+	}
+	return &oidTranslator{ctx: ctx, args: args, exact: make(map[string]string), objects: make(map[string]string)}
+}
+
+func (o *oidTranslator) translate(oid string) string {
+	if o.disabled || oid == "" {
+		return oid
+	}
+	if name, ok := o.exact[oid]; ok {
+		return name
+	}
+	// the objects with instances are leaves, so that the longest cached prefix is the object of the oid
+	for i := strings.LastIndexByte(oid, '.'); i > 0; i = strings.LastIndexByte(oid[:i], '.') {
+		if name, ok := o.objects[oid[:i]]; ok {
+			return name + oid[i:]
+		}
+	}
+	name, leaf := oid, false
+	args := append(append(make([]string, 0, len(o.args)+1), o.args...), oid)
+	out, err := translateExec("snmptranslate", args...)
+	var execErr *exec.Error
+	switch {
+	case errors.As(err, &execErr) && execErr.Err == exec.ErrNotFound:
+		o.disabled = true
+		logger.Warning(o.ctx, "SNMP_TRAP_ALARM", "snmptranslate not found, oids are not translated")
+		return oid
+	case err == nil:
+		name, leaf = parseTranslation(oid, string(out))
+	}
+	if object, base, ok := splitInstance(oid, name); ok && leaf {
+		cacheOid(o.objects, base, object)
+	} else {
+		cacheOid(o.exact, oid, name)
+	}
+	return name
+}
+
+// parseTranslation parses the output of snmptranslate -Td, it returns the name and whether the object is an
+// accessible leaf, whose suffix is the instance rather than the unknown sub oids.
+func parseTranslation(oid, out string) (string, bool) {
+	lines := strings.Split(out, "\n")
+	name := strings.TrimSpace(lines[0])
+	if name == "" {
+		return oid, false
+	}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && (fields[0] == "MAX-ACCESS" || fields[0] == "ACCESS") {
+			return name, fields[1] != "not-accessible"
+		}
+	}
+	return name, false
+}
+
+// splitInstance splits the numeric instance suffix of the translated name like `IF-MIB::ifDescr.2`, and returns the
+// object name and the base oid of the object.
+func splitInstance(oid, name string) (object, base string, ok bool) {
+	sep := strings.Index(name, "::")
+	if sep < 0 {
+		return "", "", false
+	}
+	dot := strings.IndexByte(name[sep+2:], '.')
+	if dot < 0 {
+		return "", "", false
+	}
+	object, suffix := name[:sep+2+dot], name[sep+2+dot:]
+	if !strings.HasSuffix(oid, suffix) {
+		// the string indexes are not numeric
+		return "", "", false
+	}
+	for _, part := range strings.Split(suffix[1:], ".") {
+		if _, err := strconv.ParseUint(part, 10, 32); err != nil {
+			return "", "", false
+		}
+	}
+	return object, strings.TrimSuffix(oid, suffix), true
+}
+
+// cacheOid caches the translation, an arbitrary entry is evicted when the cache is full.
+func cacheOid(cache map[string]string, oid, name string) {
+	if len(cache) >= maxTranslatedOids {
+		for k := range cache {
+			delete(cache, k)
+			break
+		}
+	}
+	cache[oid] = name
+}
+
+func init() {
+	pipeline.ServiceInputs[trapPluginName] = func() pipeline.ServiceInput {
+		return &TrapReceiver{
+			Address: "0.0.0.0:162",
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snmp
+
+import (
+	// stdlib
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	// other packages of this project
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/plugins/test"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+
+	// third party
+	g "github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type chanCollector struct {
+	test.MockCollector
+	fields chan map[string]string
+}
+
+func (c *chanCollector) AddData(tags map[string]string, fields map[string]string, t ...time.Time) {
+	c.fields <- fields
+}
+
+func (c *chanCollector) receive(t *testing.T) map[string]string {
+	select {
+	case fields := <-c.fields:
+		return fields
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for traps")
+	}
+	return nil
+}
+
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func startTrapReceiver(t *testing.T, init func(r *TrapReceiver)) (*chanCollector, int) {
+	port := freeUDPPort(t)
+	r := pipeline.ServiceInputs[trapPluginName]().(*TrapReceiver)
+	r.Address = "127.0.0.1:" + strconv.Itoa(port)
+	if init != nil {
+		init(r)
+	}
+	_, err := r.Init(mock.NewEmptyContext("p", "l", "c"))
+	require.NoError(t, err)
+	collector := &chanCollector{fields: make(chan map[string]string, 10)}
+	require.NoError(t, r.Start(collector))
+	t.Cleanup(func() {
+		require.NoError(t, r.Stop())
+	})
+	return collector, port
+}
+
+func newSender(t *testing.T, port int, version g.SnmpVersion) *g.GoSNMP {
+	sender := &g.GoSNMP{
+		Target:    "127.0.0.1",
+		Port:      uint16(port),
+		Transport: "udp",
+		Community: "public",
+		Version:   version,
+		Timeout:   2 * time.Second,
+		Retries:   1,
+		MaxOids:   g.MaxOids,
+	}
+	return sender
+}
+
+func sendTrap(t *testing.T, sender *g.GoSNMP, trap g.SnmpTrap) {
+	require.NoError(t, sender.Connect())
+	defer sender.Conn.Close()
+	_, err := sender.SendTrap(trap)
+	require.NoError(t, err)
+}
+
+var linkDownTrap = g.SnmpTrap{
+	Variables: []g.SnmpPDU{
+		{Name: trapOid, Type: g.ObjectIdentifier, Value: ".1.3.6.1.6.3.1.1.5.3"},
+		{Name: ".1.3.6.1.2.1.2.2.1.1.2", Type: g.Integer, Value: 2},
+		{Name: ".1.3.6.1.2.1.2.2.1.2.2", Type: g.OctetString, Value: "eth1"},
+	},
+}
+
+func TestTrapV2c(t *testing.T) {
+	collector, port := startTrapReceiver(t, func(r *TrapReceiver) {
+		r.Community = "public"
+	})
+
+	sendTrap(t, newSender(t, port, g.Version2c), linkDownTrap)
+	fields := collector.receive(t)
+	assert.Equal(t, "2c", fields["_version_"])
+	assert.Equal(t, "SNMPv2Trap", fields["_pdu_type_"])
+	assert.Equal(t, "127.0.0.1", fields["_source_"])
+	assert.Equal(t, "public", fields["_community_"])
+	assert.Equal(t, ".1.3.6.1.6.3.1.1.5.3", fields["_trap_oid_"])
+	assert.Equal(t, "2", fields[".1.3.6.1.2.1.2.2.1.1.2"])
+	assert.Equal(t, "eth1", fields[".1.3.6.1.2.1.2.2.1.2.2"])
+	assert.NotEmpty(t, fields["_uptime_"])
+
+	// the inform is responded, otherwise SendTrap fails
+	inform := linkDownTrap
+	inform.IsInform = true
+	sendTrap(t, newSender(t, port, g.Version2c), inform)
+	assert.Equal(t, "InformRequest", collector.receive(t)["_pdu_type_"])
+
+	// traps of other communities are dropped
+	sender := newSender(t, port, g.Version2c)
+	sender.Community = "private"
+	sendTrap(t, sender, linkDownTrap)
+	sendTrap(t, newSender(t, port, g.Version2c), linkDownTrap)
+	assert.Equal(t, "public", collector.receive(t)["_community_"])
+}
+
+func TestTrapV1(t *testing.T) {
+	collector, port := startTrapReceiver(t, nil)
+	sendTrap(t, newSender(t, port, g.Version1), g.SnmpTrap{
+		Variables:    []g.SnmpPDU{{Name: ".1.3.6.1.2.1.1.5.0", Type: g.OctetString, Value: "router"}},
+		Enterprise:   ".1.3.6.1.4.1.2021",
+		AgentAddress: "10.0.0.1",
+		GenericTrap:  6,
+		SpecificTrap: 1,
+		Timestamp:    300,
+	})
+	fields := collector.receive(t)
+	assert.Equal(t, "1", fields["_version_"])
+	assert.Equal(t, "Trap", fields["_pdu_type_"])
+	assert.Equal(t, ".1.3.6.1.4.1.2021", fields["_enterprise_"])
+	assert.Equal(t, "10.0.0.1", fields["_agent_address_"])
+	assert.Equal(t, "6", fields["_generic_trap_"])
+	assert.Equal(t, "1", fields["_specific_trap_"])
+	assert.Equal(t, "300", fields["_uptime_"])
+	assert.Equal(t, "router", fields[".1.3.6.1.2.1.1.5.0"])
+}
+
+func TestTrapV3(t *testing.T) {
+	collector, port := startTrapReceiver(t, func(r *TrapReceiver) {
+		r.UserName = "user"
+		r.AuthoritativeEngineID = "12345678"
+		r.AuthenticationProtocol = "SHA"
+		r.AuthenticationPassphrase = "authpassword"
+		r.PrivacyProtocol = "AES"
+		r.PrivacyPassphrase = "privpassword"
+	})
+	sender := newSender(t, port, g.Version3)
+	sender.SecurityModel = g.UserSecurityModel
+	sender.MsgFlags = g.AuthPriv
+	sender.SecurityParameters = &g.UsmSecurityParameters{
+		UserName:                 "user",
+		AuthoritativeEngineID:    "12345678",
+		AuthoritativeEngineBoots: 1,
+		AuthoritativeEngineTime:  1,
+		AuthenticationProtocol:   g.SHA,
+		AuthenticationPassphrase: "authpassword",
+		PrivacyProtocol:          g.AES,
+		PrivacyPassphrase:        "privpassword",
+	}
+	sendTrap(t, sender, linkDownTrap)
+	fields := collector.receive(t)
+	assert.Equal(t, "3", fields["_version_"])
+	assert.Equal(t, "user", fields["_user_name_"])
+	assert.Equal(t, "eth1", fields[".1.3.6.1.2.1.2.2.1.2.2"])
+}
+
+func TestOidTranslator(t *testing.T) {
+	defer func(origin func(string, ...string) ([]byte, error)) {
+		translateExec = origin
+	}(translateExec)
+	var calls []string
+	translateExec = func(arg0 string, args ...string) ([]byte, error) {
+		oid := args[len(args)-1]
+		calls = append(calls, oid)
+		assert.Equal(t, []string{"-Td", "-m", "all", "-M", "+/mibs:/more"}, args[:len(args)-1])
+		switch oid {
+		case ".1.3.6.1.6.3.1.1.5.3":
+			return []byte("IF-MIB::linkDown\nlinkDown NOTIFICATION-TYPE\n"), nil
+		case ".1.3.6.1.2.1.2.2.1.2.2":
+			return []byte("IF-MIB::ifDescr.2\nifDescr OBJECT-TYPE\n  SYNTAX\tOCTET STRING\n  MAX-ACCESS\tread-only\n"), nil
+		default:
+			return []byte("SNMPv2-SMI::enterprises" + strings.TrimPrefix(oid, ".1.3.6.1.4.1") + "\nenterprises OBJECT IDENTIFIER\n"), nil
+		}
+	}
+	translator := newOidTranslator(context.Background(), []string{"/mibs", "/more"})
+	assert.Equal(t, "IF-MIB::linkDown", translator.translate(".1.3.6.1.6.3.1.1.5.3"))
+	assert.Equal(t, "IF-MIB::linkDown", translator.translate(".1.3.6.1.6.3.1.1.5.3"))
+	// the instances of the same object are translated by the cached object
+	assert.Equal(t, "IF-MIB::ifDescr.2", translator.translate(".1.3.6.1.2.1.2.2.1.2.2"))
+	assert.Equal(t, "IF-MIB::ifDescr.3", translator.translate(".1.3.6.1.2.1.2.2.1.2.3"))
+	// the unknown sub oids of a node are not instances
+	assert.Equal(t, "SNMPv2-SMI::enterprises.9.1", translator.translate(".1.3.6.1.4.1.9.1"))
+	assert.Equal(t, "SNMPv2-SMI::enterprises.9.2", translator.translate(".1.3.6.1.4.1.9.2"))
+	assert.Equal(t, []string{".1.3.6.1.6.3.1.1.5.3", ".1.3.6.1.2.1.2.2.1.2.2", ".1.3.6.1.4.1.9.1", ".1.3.6.1.4.1.9.2"}, calls)
+
+	translateExec = func(arg0 string, args ...string) ([]byte, error) {
+		return nil, &exec.Error{Name: arg0, Err: exec.ErrNotFound}
+	}
+	translator = newOidTranslator(context.Background(), nil)
+	assert.Equal(t, ".1.3", translator.translate(".1.3"))
+	assert.True(t, translator.disabled)
+
+	translateExec = func(arg0 string, args ...string) ([]byte, error) {
+		return nil, errors.New("unknown oid")
+	}
+	translator = newOidTranslator(context.Background(), nil)
+	assert.Equal(t, ".1.3", translator.translate(".1.3"))
+	assert.False(t, translator.disabled)
+}
+
+func TestTrapTranslation(t *testing.T) {
+	defer func(origin func(string, ...string) ([]byte, error)) {
+		translateExec = origin
+	}(translateExec)
+	translateExec = func(arg0 string, args ...string) ([]byte, error) {
+		switch args[len(args)-1] {
+		case ".1.3.6.1.6.3.1.1.5.3":
+			return []byte("IF-MIB::linkDown\n"), nil
+		case ".1.3.6.1.2.1.2.2.1.1.2":
+			return []byte("IF-MIB::ifIndex.2\n  MAX-ACCESS\tread-only\n"), nil
+		default:
+			return []byte("IF-MIB::ifDescr.2\n  MAX-ACCESS\tread-only\n"), nil
+		}
+	}
+	collector, port := startTrapReceiver(t, func(r *TrapReceiver) {
+		r.TranslateOids = true
+	})
+	sendTrap(t, newSender(t, port, g.Version2c), linkDownTrap)
+	fields := collector.receive(t)
+	assert.Equal(t, "IF-MIB::linkDown", fields["_trap_oid_"])
+	assert.Equal(t, "2", fields["IF-MIB::ifIndex.2"])
+	assert.Equal(t, "eth1", fields["IF-MIB::ifDescr.2"])
+	assert.Equal(t, "public", fields["_community_"])
+}
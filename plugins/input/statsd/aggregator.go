@@ -0,0 +1,196 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/models"
+)
+
+// series is the state of one metric name, type and tag set in a flush interval.
+type series struct {
+	name    string
+	typ     string
+	tags    map[string]string
+	value   float64
+	updated bool
+	dist    *helper.Distribution
+	set     map[string]struct{}
+	// idle is the count of the intervals the kept gauge is not updated
+	idle int
+}
+
+// aggregator aggregates the samples between flushes with the statsd semantics: counters are summed and
+// corrected by the sample rate, gauges keep the last value and apply +/- deltas, timers, histograms and
+// distributions are summarized by percentiles or buckets, and sets count the distinct members.
+type aggregator struct {
+	percentiles  []float64
+	buckets      []float64
+	maxSamples   int
+	maxSeries    int
+	deleteGauges bool
+	gaugeExpire  int
+
+	lock   sync.Mutex
+	series map[string]*series
+	// gauges are kept across flushes as the base of deltas and keep reporting their last values unless
+	// deleteGauges is set, they are removed after being idle for gaugeExpire intervals
+	gauges map[string]*series
+	// activeGauges is the count of the gauges updated in the interval
+	activeGauges int
+}
+
+func newAggregator(percentiles, buckets []float64, maxSamples, maxSeries int, deleteGauges bool, gaugeExpire int) *aggregator {
+	return &aggregator{
+		percentiles:  percentiles,
+		buckets:      buckets,
+		maxSamples:   maxSamples,
+		maxSeries:    maxSeries,
+		deleteGauges: deleteGauges,
+		gaugeExpire:  gaugeExpire,
+		series:       make(map[string]*series),
+		gauges:       make(map[string]*series),
+	}
+}
+
+// add records the sample, it returns false if the sample is dropped for exceeding maxSeries. Only the series
+// updated in the interval are counted, so the kept gauges do not block the new metrics.
+func (a *aggregator) add(s *sample) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	key := seriesKey(s)
+	store := a.series
+	if s.typ == typeGauge {
+		store = a.gauges
+	}
+	ser, ok := store[key]
+	if !ok || !ser.updated {
+		if a.maxSeries > 0 && len(a.series)+a.activeGauges >= a.maxSeries {
+			return false
+		}
+	}
+	if !ok {
+		ser = &series{name: s.name, typ: s.typ, tags: s.tags}
+		switch s.typ {
+		case typeTimer, typeHistogram, typeDistribution:
+			maxSamples := a.maxSamples
+			if len(a.buckets) > 0 {
+				maxSamples = 0
+			}
+			ser.dist = helper.NewDistribution(a.buckets, maxSamples)
+		case typeSet:
+			ser.set = make(map[string]struct{})
+		}
+		store[key] = ser
+	}
+	if !ser.updated && s.typ == typeGauge {
+		a.activeGauges++
+	}
+	ser.updated = true
+	for _, raw := range s.values {
+		switch s.typ {
+		case typeCounter:
+			v, _ := strconv.ParseFloat(raw, 64)
+			ser.value += v / s.sampleRate
+		case typeGauge:
+			v, _ := strconv.ParseFloat(strings.TrimPrefix(raw, "+"), 64)
+			if strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-") {
+				ser.value += v
+			} else {
+				ser.value = v
+			}
+		case typeTimer, typeHistogram, typeDistribution:
+			v, _ := strconv.ParseFloat(raw, 64)
+			ser.dist.ObserveN(v, int64(math.Round(1/s.sampleRate)))
+		case typeSet:
+			ser.set[raw] = struct{}{}
+		}
+	}
+	return true
+}
+
+// flush returns the metrics of the interval and resets the state.
+func (a *aggregator) flush(timestamp int64) []models.PipelineEvent {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	events := make([]models.PipelineEvent, 0, len(a.series)+len(a.gauges))
+	for _, ser := range a.series {
+		tags := models.NewTagsWithMap(copyTags(ser.tags))
+		switch ser.typ {
+		case typeCounter:
+			events = append(events, models.NewSingleValueMetric(ser.name, models.MetricTypeCounter, tags, timestamp, ser.value))
+		case typeSet:
+			events = append(events, models.NewSingleValueMetric(ser.name, models.MetricTypeGauge, tags, timestamp, float64(len(ser.set))))
+		default:
+			if len(a.buckets) > 0 {
+				events = append(events, models.NewMultiValuesMetric(ser.name, models.MetricTypeHistogram, tags, timestamp, ser.dist.HistogramValues().GetMultiValues()))
+			} else {
+				events = append(events, models.NewMultiValuesMetric(ser.name, models.MetricTypeSummary, tags, timestamp, ser.dist.SummaryValues(a.percentiles).GetMultiValues()))
+			}
+		}
+	}
+	for key, ser := range a.gauges {
+		if ser.updated {
+			ser.idle = 0
+		} else {
+			ser.idle++
+			if a.gaugeExpire > 0 && ser.idle > a.gaugeExpire {
+				delete(a.gauges, key)
+				continue
+			}
+		}
+		ser.updated = false
+		events = append(events, models.NewSingleValueMetric(ser.name, models.MetricTypeGauge, models.NewTagsWithMap(copyTags(ser.tags)), timestamp, ser.value))
+	}
+	a.series = make(map[string]*series)
+	a.activeGauges = 0
+	if a.deleteGauges {
+		a.gauges = make(map[string]*series)
+	}
+	return events
+}
+
+func seriesKey(s *sample) string {
+	var sb strings.Builder
+	sb.WriteString(s.typ)
+	sb.WriteByte(0)
+	sb.WriteString(s.name)
+	keys := make([]string, 0, len(s.tags))
+	for k := range s.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteByte(0)
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(s.tags[k])
+	}
+	return sb.String()
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	res := make(map[string]string, len(tags))
+	for k, v := range tags {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,205 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	typeCounter      = "c"
+	typeGauge        = "g"
+	typeTimer        = "ms"
+	typeHistogram    = "h"
+	typeDistribution = "d"
+	typeSet          = "s"
+
+	eventPrefix        = "_e{"
+	serviceCheckPrefix = "_sc|"
+)
+
+// sample is one statsd metric line, it may carry several values in the DogStatsD 1.1 format.
+type sample struct {
+	name       string
+	typ        string
+	values     []string
+	sampleRate float64
+	tags       map[string]string
+}
+
+// event is a DogStatsD event.
+type event struct {
+	title          string
+	text           string
+	timestamp      int64
+	hostname       string
+	aggregationKey string
+	priority       string
+	sourceType     string
+	alertType      string
+	tags           map[string]string
+}
+
+// serviceCheck is a DogStatsD service check.
+type serviceCheck struct {
+	name      string
+	status    int
+	timestamp int64
+	hostname  string
+	message   string
+	tags      map[string]string
+}
+
+// parseLine parses a statsd line into one of *sample, *event and *serviceCheck.
+func parseLine(line string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(line, eventPrefix):
+		return parseEvent(line)
+	case strings.HasPrefix(line, serviceCheckPrefix):
+		return parseServiceCheck(line)
+	}
+	return parseSample(line)
+}
+
+// parseSample parses `<name>:<value>[:<value>...]|<type>[|@<rate>][|#<tags>]`.
+func parseSample(line string) (*sample, error) {
+	parts := strings.Split(line, "|")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid metric line %q", line)
+	}
+	nameValues := strings.Split(parts[0], ":")
+	if len(nameValues) < 2 || nameValues[0] == "" {
+		return nil, fmt.Errorf("invalid metric name and value %q", parts[0])
+	}
+	s := &sample{name: nameValues[0], typ: parts[1], values: nameValues[1:], sampleRate: 1}
+	switch s.typ {
+	case typeCounter, typeGauge, typeTimer, typeHistogram, typeDistribution:
+		for _, v := range s.values {
+			if _, err := strconv.ParseFloat(strings.TrimPrefix(v, "+"), 64); err != nil {
+				return nil, fmt.Errorf("invalid value %q of metric %v", v, s.name)
+			}
+		}
+	case typeSet:
+		// set members are arbitrary strings
+		s.values = []string{strings.Join(s.values, ":")}
+	default:
+		return nil, fmt.Errorf("unknown type %q of metric %v", s.typ, s.name)
+	}
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("invalid sample rate %q of metric %v", part, s.name)
+			}
+			s.sampleRate = rate
+		case strings.HasPrefix(part, "#"):
+			s.tags = parseTags(part[1:])
+		}
+	}
+	return s, nil
+}
+
+// parseEvent parses `_e{<title length>,<text length>}:<title>|<text>[|<field>...]`.
+func parseEvent(line string) (*event, error) {
+	end := strings.Index(line, "}:")
+	if end < 0 {
+		return nil, fmt.Errorf("invalid event %q", line)
+	}
+	lengths := strings.Split(line[len(eventPrefix):end], ",")
+	if len(lengths) != 2 {
+		return nil, fmt.Errorf("invalid event lengths %q", line[:end+1])
+	}
+	titleLen, err1 := strconv.Atoi(lengths[0])
+	textLen, err2 := strconv.Atoi(lengths[1])
+	body := line[end+2:]
+	if err1 != nil || err2 != nil || titleLen < 0 || textLen < 0 || len(body) < titleLen+1+textLen || body[titleLen] != '|' {
+		return nil, fmt.Errorf("invalid event lengths %q", line[:end+1])
+	}
+	e := &event{
+		title: body[:titleLen],
+		// new lines are escaped in the text
+		text: strings.ReplaceAll(body[titleLen+1:titleLen+1+textLen], "\\n", "\n"),
+	}
+	for _, field := range strings.Split(body[titleLen+1+textLen:], "|") {
+		switch {
+		case field == "":
+		case strings.HasPrefix(field, "d:"):
+			e.timestamp, _ = strconv.ParseInt(field[2:], 10, 64)
+		case strings.HasPrefix(field, "h:"):
+			e.hostname = field[2:]
+		case strings.HasPrefix(field, "k:"):
+			e.aggregationKey = field[2:]
+		case strings.HasPrefix(field, "p:"):
+			e.priority = field[2:]
+		case strings.HasPrefix(field, "s:"):
+			e.sourceType = field[2:]
+		case strings.HasPrefix(field, "t:"):
+			e.alertType = field[2:]
+		case strings.HasPrefix(field, "#"):
+			e.tags = parseTags(field[1:])
+		}
+	}
+	return e, nil
+}
+
+// parseServiceCheck parses `_sc|<name>|<status>[|<field>...]`, the message must be the last field.
+func parseServiceCheck(line string) (*serviceCheck, error) {
+	parts := strings.Split(line, "|")
+	if len(parts) < 3 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid service check %q", line)
+	}
+	status, err := strconv.Atoi(parts[2])
+	if err != nil || status < 0 || status > 3 {
+		return nil, fmt.Errorf("invalid status %q of service check %v", parts[2], parts[1])
+	}
+	sc := &serviceCheck{name: parts[1], status: status}
+	for i := 3; i < len(parts); i++ {
+		field := parts[i]
+		switch {
+		case strings.HasPrefix(field, "d:"):
+			sc.timestamp, _ = strconv.ParseInt(field[2:], 10, 64)
+		case strings.HasPrefix(field, "h:"):
+			sc.hostname = field[2:]
+		case strings.HasPrefix(field, "#"):
+			sc.tags = parseTags(field[1:])
+		case strings.HasPrefix(field, "m:"):
+			// the message may contain '|'
+			sc.message = strings.ReplaceAll(strings.Join(parts[i:], "|")[2:], "\\n", "\n")
+			return sc, nil
+		}
+	}
+	return sc, nil
+}
+
+// parseTags parses `k1:v1,k2:v2`, a tag without value gets an empty value.
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ",") {
+		if tag == "" {
+			continue
+		}
+		if idx := strings.IndexByte(tag, ':'); idx >= 0 {
+			tags[tag[:idx]] = tag[idx+1:]
+		} else {
+			tags[tag] = ""
+		}
+	}
+	return tags
+}
+
+var serviceCheckStatus = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
@@ -0,0 +1,74 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSample(t *testing.T) {
+	res, err := parseLine("page.views:1|c|@0.5|#env:prod,canary")
+	require.NoError(t, err)
+	s := res.(*sample)
+	assert.Equal(t, "page.views", s.name)
+	assert.Equal(t, typeCounter, s.typ)
+	assert.Equal(t, []string{"1"}, s.values)
+	assert.Equal(t, 0.5, s.sampleRate)
+	assert.Equal(t, map[string]string{"env": "prod", "canary": ""}, s.tags)
+
+	res, err = parseLine("latency:10:20:30|ms")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10", "20", "30"}, res.(*sample).values)
+
+	res, err = parseLine("users:host:1|s")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"host:1"}, res.(*sample).values)
+
+	for _, line := range []string{"a", "a|c", ":1|c", "a:x|c", "a:1|x", "a:1|c|@2"} {
+		_, err = parseLine(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	res, err := parseLine("_e{5,12}:title|hello\\nworld|d:1700000000|p:low|t:warning|#env:prod")
+	require.NoError(t, err)
+	e := res.(*event)
+	assert.Equal(t, "title", e.title)
+	assert.Equal(t, "hello\nworld", e.text)
+	assert.Equal(t, int64(1700000000), e.timestamp)
+	assert.Equal(t, "low", e.priority)
+	assert.Equal(t, "warning", e.alertType)
+	assert.Equal(t, map[string]string{"env": "prod"}, e.tags)
+
+	_, err = parseLine("_e{5,20}:title|short")
+	assert.Error(t, err)
+}
+
+func TestParseServiceCheck(t *testing.T) {
+	res, err := parseLine("_sc|db.up|2|h:db1|#env:prod|m:connection refused|retrying")
+	require.NoError(t, err)
+	sc := res.(*serviceCheck)
+	assert.Equal(t, "db.up", sc.name)
+	assert.Equal(t, 2, sc.status)
+	assert.Equal(t, "db1", sc.hostname)
+	assert.Equal(t, "connection refused|retrying", sc.message)
+
+	_, err = parseLine("_sc|db.up|5")
+	assert.Error(t, err)
+}
@@ -0,0 +1,279 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+const pluginName = "service_statsd"
+
+// ServiceStatsd receives StatsD and DogStatsD data over UDP and TCP, aggregates the metrics per flush
+// interval and emits them as metric events. Events and service checks are emitted as log events immediately.
+type ServiceStatsd struct {
+	UDPAddress           string    `comment:"the udp listening address, empty to disable udp."`
+	TCPAddress           string    `comment:"the tcp listening address, empty to disable tcp. Lines are separated by new lines."`
+	FlushIntervalSeconds int       `comment:"the interval to emit the aggregated metrics."`
+	Percentiles          []float64 `comment:"the quantiles (0~1) of timers, histograms and distributions."`
+	HistogramBuckets     []float64 `comment:"the increasing bucket upper bounds, timers, histograms and distributions are emitted as histograms instead of summaries if set."`
+	MaxSamples           int       `comment:"the maximum count of samples kept per timer for percentile computing."`
+	MaxSeries            int       `comment:"the maximum count of distinct metrics in an interval, samples of new metrics are dropped when exceeded."`
+	DeleteGauges         bool      `comment:"do not keep gauges across intervals, so the gauges not updated are not emitted and deltas are based on 0."`
+	GaugeExpireIntervals int       `comment:"the count of intervals a kept gauge reports its last value without updates before being removed, 0 to keep forever."`
+	MaxBufferSize        int       `comment:"the maximum size of an udp packet or a tcp line."`
+
+	context    pipeline.Context
+	collector  pipeline.PipelineCollector
+	aggregator *aggregator
+	udpConn    net.PacketConn
+	tcpServer  *helper.ConnServer
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+
+	parseErrorMetric   pipeline.CounterMetric
+	droppedMetric      pipeline.CounterMetric
+	receivedLineMetric pipeline.CounterMetric
+}
+
+func (s *ServiceStatsd) Init(context pipeline.Context) (int, error) {
+	s.context = context
+	if s.UDPAddress == "" && s.TCPAddress == "" {
+		return 0, fmt.Errorf("must specify UDPAddress or TCPAddress for plugin %v", pluginName)
+	}
+	if s.FlushIntervalSeconds <= 0 {
+		return 0, fmt.Errorf("invalid FlushIntervalSeconds %v for plugin %v", s.FlushIntervalSeconds, pluginName)
+	}
+	for _, q := range s.Percentiles {
+		if q < 0 || q > 1 {
+			return 0, fmt.Errorf("invalid percentile %v for plugin %v", q, pluginName)
+		}
+	}
+	if !sort.Float64sAreSorted(s.HistogramBuckets) {
+		return 0, fmt.Errorf("HistogramBuckets must be in increasing order for plugin %v", pluginName)
+	}
+	if s.MaxBufferSize <= 0 {
+		return 0, fmt.Errorf("invalid MaxBufferSize %v for plugin %v", s.MaxBufferSize, pluginName)
+	}
+	s.aggregator = newAggregator(s.Percentiles, s.HistogramBuckets, s.MaxSamples, s.MaxSeries, s.DeleteGauges, s.GaugeExpireIntervals)
+	s.parseErrorMetric = helper.NewCounterMetricAndRegister("statsd_parse_errors", context)
+	s.droppedMetric = helper.NewCounterMetricAndRegister("statsd_dropped_samples", context)
+	s.receivedLineMetric = helper.NewCounterMetricAndRegister("statsd_received_lines", context)
+	return 0, nil
+}
+
+func (s *ServiceStatsd) Description() string {
+	return "statsd service input plugin with aggregation"
+}
+
+// Start is not supported, statsd metrics are only emitted to the v2 pipeline.
+func (s *ServiceStatsd) Start(c pipeline.Collector) error {
+	return fmt.Errorf("plugin %v only supports the v2 pipeline", pluginName)
+}
+
+func (s *ServiceStatsd) StartService(ctx pipeline.PipelineContext) error {
+	s.collector = ctx.Collector()
+	s.stopCh = make(chan struct{})
+	if s.UDPAddress != "" {
+		conn, err := net.ListenPacket("udp", s.UDPAddress)
+		if err != nil {
+			return fmt.Errorf("listen udp %v error: %v", s.UDPAddress, err)
+		}
+		s.udpConn = conn
+		s.wg.Add(1)
+		go s.serveUDP()
+	}
+	if s.TCPAddress != "" {
+		s.tcpServer = &helper.ConnServer{
+			Handle: s.handleConn,
+			OnAcceptError: func(err error) {
+				logger.Error(s.context.GetRuntimeContext(), "STATSD_SERVER_ALARM", "accept tcp connection error", err)
+			},
+		}
+		if err := s.tcpServer.Start(s.TCPAddress, nil); err != nil {
+			if s.udpConn != nil {
+				_ = s.udpConn.Close()
+			}
+			return fmt.Errorf("listen tcp %v error: %v", s.TCPAddress, err)
+		}
+	}
+	s.wg.Add(1)
+	go s.flushLoop()
+	logger.Info(s.context.GetRuntimeContext(), "statsd server start", "success", "udp", s.UDPAddress, "tcp", s.TCPAddress)
+	return nil
+}
+
+func (s *ServiceStatsd) serveUDP() {
+	defer s.wg.Done()
+	buf := make([]byte, s.MaxBufferSize)
+	for {
+		n, _, err := s.udpConn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Error(s.context.GetRuntimeContext(), "STATSD_SERVER_ALARM", "read udp packet error", err)
+			}
+			return
+		}
+		s.handleLines(buf[:n])
+	}
+}
+
+func (s *ServiceStatsd) handleConn(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), s.MaxBufferSize)
+	for scanner.Scan() {
+		s.handleLines(scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		logger.Warning(s.context.GetRuntimeContext(), "STATSD_SERVER_ALARM", "read tcp connection error", err, "remote", conn.RemoteAddr())
+	}
+}
+
+// handleLines handles the new line separated statsd lines.
+func (s *ServiceStatsd) handleLines(data []byte) {
+	var logs []models.PipelineEvent
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		s.receivedLineMetric.Add(1)
+		res, err := parseLine(string(line))
+		if err != nil {
+			s.parseErrorMetric.Add(1)
+			logger.Debug(s.context.GetRuntimeContext(), "parse statsd line error", err)
+			continue
+		}
+		switch v := res.(type) {
+		case *sample:
+			if !s.aggregator.add(v) {
+				s.droppedMetric.Add(1)
+			}
+		case *event:
+			logs = append(logs, eventToLog(v))
+		case *serviceCheck:
+			logs = append(logs, serviceCheckToLog(v))
+		}
+	}
+	if len(logs) > 0 {
+		s.collector.Collect(models.NewGroup(models.NewMetadata(), models.NewTags()), logs...)
+	}
+}
+
+func (s *ServiceStatsd) flushLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Duration(s.FlushIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+func (s *ServiceStatsd) flush() {
+	metrics := s.aggregator.flush(time.Now().UnixNano())
+	if len(metrics) > 0 {
+		s.collector.Collect(models.NewGroup(models.NewMetadata(), models.NewTags()), metrics...)
+	}
+}
+
+func (s *ServiceStatsd) Stop() error {
+	if s.udpConn != nil {
+		_ = s.udpConn.Close()
+	}
+	if s.tcpServer != nil {
+		s.tcpServer.Stop()
+	}
+	if s.stopCh != nil {
+		close(s.stopCh)
+	}
+	s.wg.Wait()
+	// emit the metrics of the last interval
+	if s.collector != nil {
+		s.flush()
+	}
+	logger.Info(s.context.GetRuntimeContext(), "statsd server stop", "success")
+	return nil
+}
+
+func eventToLog(e *event) *models.Log {
+	ts := time.Now()
+	if e.timestamp > 0 {
+		ts = time.Unix(e.timestamp, 0)
+	}
+	contents := models.NewLogContents()
+	contents.Add("title", e.title)
+	contents.Add("text", e.text)
+	addIfNotEmpty(contents, "hostname", e.hostname)
+	addIfNotEmpty(contents, "aggregation_key", e.aggregationKey)
+	addIfNotEmpty(contents, "priority", e.priority)
+	addIfNotEmpty(contents, "source_type_name", e.sourceType)
+	addIfNotEmpty(contents, "alert_type", e.alertType)
+	log := models.NewLog("statsd_event", nil, "", "", "", models.NewTagsWithMap(e.tags), uint64(ts.UnixNano()))
+	log.SetIndices(contents)
+	return log
+}
+
+func serviceCheckToLog(sc *serviceCheck) *models.Log {
+	ts := time.Now()
+	if sc.timestamp > 0 {
+		ts = time.Unix(sc.timestamp, 0)
+	}
+	contents := models.NewLogContents()
+	contents.Add("check", sc.name)
+	contents.Add("status", strconv.Itoa(sc.status))
+	contents.Add("status_text", serviceCheckStatus[sc.status])
+	addIfNotEmpty(contents, "hostname", sc.hostname)
+	addIfNotEmpty(contents, "message", sc.message)
+	log := models.NewLog("statsd_service_check", nil, "", "", "", models.NewTagsWithMap(sc.tags), uint64(ts.UnixNano()))
+	log.SetIndices(contents)
+	return log
+}
+
+func addIfNotEmpty(contents models.LogContents, key, value string) {
+	if value != "" {
+		contents.Add(key, value)
+	}
+}
+
+func init() {
+	pipeline.ServiceInputs[pluginName] = func() pipeline.ServiceInput {
+		return &ServiceStatsd{
+			UDPAddress:           ":8125",
+			FlushIntervalSeconds: 10,
+			Percentiles:          []float64{0.5, 0.9, 0.99},
+			MaxSamples:           helper.DefaultDistributionSamples,
+			MaxSeries:            100000,
+			GaugeExpireIntervals: 60,
+			MaxBufferSize:        65535,
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+func mustParse(t *testing.T, line string) *sample {
+	res, err := parseLine(line)
+	require.NoError(t, err)
+	return res.(*sample)
+}
+
+func metricsByName(events []models.PipelineEvent) map[string]*models.Metric {
+	res := make(map[string]*models.Metric)
+	for _, event := range events {
+		if metric, ok := event.(*models.Metric); ok {
+			res[metric.GetName()] = metric
+		}
+	}
+	return res
+}
+
+func TestAggregate(t *testing.T) {
+	agg := newAggregator([]float64{0.5, 0.9}, nil, 100, 0, false, 0)
+	for _, line := range []string{
+		"requests:1|c",
+		"requests:1|c|@0.1",
+		"queue:10|g",
+		"queue:+5|g",
+		"queue:-3|g",
+		"users:alice|s",
+		"users:bob|s",
+		"users:alice|s",
+		"latency:1:2:3:4:5:6:7:8:9:10|ms",
+	} {
+		assert.True(t, agg.add(mustParse(t, line)))
+	}
+	metrics := metricsByName(agg.flush(1))
+	require.Len(t, metrics, 4)
+	assert.Equal(t, models.MetricTypeCounter, metrics["requests"].GetMetricType())
+	assert.Equal(t, float64(11), metrics["requests"].GetValue().GetSingleValue())
+	assert.Equal(t, float64(12), metrics["queue"].GetValue().GetSingleValue())
+	assert.Equal(t, float64(2), metrics["users"].GetValue().GetSingleValue())
+	assert.Equal(t, models.MetricTypeSummary, metrics["latency"].GetMetricType())
+	assert.Equal(t, float64(5), metrics["latency"].GetValue().GetMultiValues().Get("0.5"))
+	assert.Equal(t, float64(9), metrics["latency"].GetValue().GetMultiValues().Get("0.9"))
+
+	// gauges not updated report their last values, and the values are kept for deltas
+	metrics = metricsByName(agg.flush(2))
+	require.Len(t, metrics, 1)
+	assert.Equal(t, float64(12), metrics["queue"].GetValue().GetSingleValue())
+	assert.True(t, agg.add(mustParse(t, "queue:+1|g")))
+	metrics = metricsByName(agg.flush(3))
+	assert.Equal(t, float64(13), metrics["queue"].GetValue().GetSingleValue())
+}
+
+func TestAggregateGaugeExpire(t *testing.T) {
+	agg := newAggregator(nil, nil, 0, 1, false, 2)
+	assert.True(t, agg.add(mustParse(t, "a:1|g")))
+	assert.False(t, agg.add(mustParse(t, "b:1|g")))
+	assert.Len(t, agg.flush(1), 1)
+	// the kept gauges not updated are not counted in MaxSeries
+	assert.True(t, agg.add(mustParse(t, "b:2|g")))
+	metrics := metricsByName(agg.flush(2))
+	require.Len(t, metrics, 2)
+	assert.Equal(t, float64(1), metrics["a"].GetValue().GetSingleValue())
+	assert.Len(t, agg.flush(3), 2)
+	// a has been idle for 3 intervals
+	metrics = metricsByName(agg.flush(4))
+	require.Len(t, metrics, 1)
+	assert.Equal(t, float64(2), metrics["b"].GetValue().GetSingleValue())
+	assert.Empty(t, agg.flush(5))
+}
+
+func TestAggregateHistogramAndMaxSeries(t *testing.T) {
+	agg := newAggregator(nil, []float64{10}, 0, 2, true, 0)
+	assert.True(t, agg.add(mustParse(t, "latency:5|h|@0.5")))
+	assert.True(t, agg.add(mustParse(t, "latency:50|h|#env:prod")))
+	assert.False(t, agg.add(mustParse(t, "other:1|c")))
+	var found bool
+	for _, event := range agg.flush(1) {
+		metric := event.(*models.Metric)
+		assert.Equal(t, models.MetricTypeHistogram, metric.GetMetricType())
+		if metric.GetTags().Len() == 0 {
+			found = true
+			assert.Equal(t, float64(2), metric.GetValue().GetMultiValues().Get("(-Inf,10]"))
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestService(t *testing.T) {
+	input := pipeline.ServiceInputs[pluginName]().(*ServiceStatsd)
+	input.UDPAddress = "127.0.0.1:0"
+	input.TCPAddress = "127.0.0.1:0"
+	input.FlushIntervalSeconds = 1
+	_, err := input.Init(mock.NewEmptyContext("p", "l", "c"))
+	require.NoError(t, err)
+	ctx := pipeline.NewObservePipelineConext(100)
+	require.NoError(t, input.StartService(ctx))
+
+	udp, err := net.Dial("udp", input.udpConn.LocalAddr().String())
+	require.NoError(t, err)
+	_, err = udp.Write([]byte("hits:1|c\nhits:2|c\n_e{2,4}:up|text"))
+	require.NoError(t, err)
+	tcp, err := net.Dial("tcp", input.tcpServer.Addr().String())
+	require.NoError(t, err)
+	_, err = tcp.Write([]byte("hits:3|c\n"))
+	require.NoError(t, err)
+	_ = tcp.Close()
+	_ = udp.Close()
+
+	var logs, metrics []models.PipelineEvent
+	timeout := time.After(5 * time.Second)
+	for len(logs) == 0 || len(metrics) == 0 {
+		select {
+		case group := <-ctx.Collector().Observe():
+			for _, event := range group.Events {
+				if event.GetType() == models.EventTypeLogging {
+					logs = append(logs, event)
+				} else {
+					metrics = append(metrics, event)
+				}
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for statsd events")
+		}
+	}
+	require.NoError(t, input.Stop())
+	for _, group := range ctx.Collector().ToArray() {
+		metrics = append(metrics, group.Events...)
+	}
+
+	assert.Equal(t, "up", logs[0].(*models.Log).GetIndices().Get("title"))
+	var hits float64
+	for _, m := range metrics {
+		hits += m.(*models.Metric).GetValue().GetSingleValue()
+	}
+	assert.Equal(t, float64(6), hits)
+}
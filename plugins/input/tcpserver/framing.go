@@ -0,0 +1,136 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcpserver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	framingNewline        = "newline"
+	framingOctetCounted   = "octet_counted"
+	framingLengthPrefixed = "length_prefixed"
+	framingDelimiter      = "delimiter"
+
+	lengthPrefixSize = 4
+)
+
+var errFrameTooLarge = errors.New("frame too large")
+
+// newSplitFunc returns the bufio.SplitFunc of the framing, which splits the stream into frames.
+func newSplitFunc(framing, delimiter string, maxFrameSize int) (bufio.SplitFunc, error) {
+	switch framing {
+	case framingNewline:
+		return splitByDelimiter([]byte("\n"), true), nil
+	case framingDelimiter:
+		if delimiter == "" {
+			return nil, errors.New("must specify Delimiter for the delimiter framing")
+		}
+		return splitByDelimiter([]byte(delimiter), false), nil
+	case framingOctetCounted:
+		return splitOctetCounted(maxFrameSize), nil
+	case framingLengthPrefixed:
+		return splitLengthPrefixed(maxFrameSize), nil
+	}
+	return nil, fmt.Errorf("unknown framing %v", framing)
+}
+
+// splitByDelimiter splits frames ended by the delimiter, the last frame without delimiter is returned at EOF.
+func splitByDelimiter(delimiter []byte, trimCR bool) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.Index(data, delimiter); i >= 0 {
+			frame := data[:i]
+			if trimCR {
+				frame = bytes.TrimSuffix(frame, []byte("\r"))
+			}
+			return i + len(delimiter), frame, nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// splitOctetCounted splits frames of RFC 6587 octet counting, i.e. `<length> <frame>`.
+func splitOctetCounted(maxFrameSize int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		sp := bytes.IndexByte(data, ' ')
+		if sp < 0 {
+			if atEOF || len(data) > 10 {
+				return 0, nil, fmt.Errorf("invalid octet count %q", truncate(data))
+			}
+			return 0, nil, nil
+		}
+		length, err := strconv.Atoi(string(data[:sp]))
+		if err != nil || length < 0 {
+			return 0, nil, fmt.Errorf("invalid octet count %q", truncate(data[:sp]))
+		}
+		if maxFrameSize > 0 && length > maxFrameSize {
+			return 0, nil, errFrameTooLarge
+		}
+		if len(data) < sp+1+length {
+			if atEOF {
+				return 0, nil, fmt.Errorf("incomplete frame of %v bytes", length)
+			}
+			return 0, nil, nil
+		}
+		return sp + 1 + length, data[sp+1 : sp+1+length], nil
+	}
+}
+
+// splitLengthPrefixed splits frames prefixed by a 4 bytes big endian length.
+func splitLengthPrefixed(maxFrameSize int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if len(data) < lengthPrefixSize {
+			if atEOF {
+				return 0, nil, errors.New("incomplete length prefix")
+			}
+			return 0, nil, nil
+		}
+		length := int(binary.BigEndian.Uint32(data[:lengthPrefixSize]))
+		if maxFrameSize > 0 && length > maxFrameSize {
+			return 0, nil, errFrameTooLarge
+		}
+		if len(data) < lengthPrefixSize+length {
+			if atEOF {
+				return 0, nil, fmt.Errorf("incomplete frame of %v bytes", length)
+			}
+			return 0, nil, nil
+		}
+		return lengthPrefixSize + length, data[lengthPrefixSize : lengthPrefixSize+length], nil
+	}
+}
+
+func truncate(data []byte) []byte {
+	if len(data) > 16 {
+		return data[:16]
+	}
+	return data
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcpserver
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scanAll(t *testing.T, framing, delimiter string, data []byte) ([]string, error) {
+	split, err := newSplitFunc(framing, delimiter, 16)
+	require.NoError(t, err)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 4), 64)
+	scanner.Split(split)
+	var frames []string
+	for scanner.Scan() {
+		frames = append(frames, scanner.Text())
+	}
+	return frames, scanner.Err()
+}
+
+func TestFraming(t *testing.T) {
+	frames, err := scanAll(t, framingNewline, "", []byte("a\r\nbb\nccc"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "bb", "ccc"}, frames)
+
+	frames, err = scanAll(t, framingDelimiter, "||", []byte("a||b\nc||"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b\nc"}, frames)
+
+	frames, err = scanAll(t, framingOctetCounted, "", []byte("3 a\nb11 hello world"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a\nb", "hello world"}, frames)
+
+	frames, err = scanAll(t, framingLengthPrefixed, "", []byte("\x00\x00\x00\x02ab\x00\x00\x00\x00\x00\x00\x00\x01c"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ab", "", "c"}, frames)
+}
+
+func TestFramingErrors(t *testing.T) {
+	_, err := newSplitFunc("unknown", "", 0)
+	assert.Error(t, err)
+	_, err = newSplitFunc(framingDelimiter, "", 0)
+	assert.Error(t, err)
+
+	_, err = scanAll(t, framingOctetCounted, "", []byte("x a"))
+	assert.Error(t, err)
+	_, err = scanAll(t, framingOctetCounted, "", []byte("5 abc"))
+	assert.Error(t, err)
+	_, err = scanAll(t, framingOctetCounted, "", []byte("17 a"))
+	assert.ErrorIs(t, err, errFrameTooLarge)
+	_, err = scanAll(t, framingLengthPrefixed, "", []byte("\x00\x00\x01\x00"))
+	assert.ErrorIs(t, err, errFrameTooLarge)
+}
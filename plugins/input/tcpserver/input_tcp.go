@@ -0,0 +1,243 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcpserver
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/pipeline/extensions"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/pkg/tlscommon"
+)
+
+const (
+	pluginName = "service_tcp_server"
+	tagPrefix  = "__tag__:"
+)
+
+const (
+	v1 = iota
+	v2
+)
+
+// TCPServer receives a stream of frames over TCP or TLS, and decodes every frame by the decoder.
+type TCPServer struct {
+	Decoder            string               `comment:"the decoder extension to use, default is ext_default_decoder."`
+	Format             string               `comment:"the format of the default decoder, such as raw, sls, influx and prometheus."`
+	FieldsExtend       bool                 `comment:"the FieldsExtend option of the default decoder."`
+	DisableUncompress  bool                 `comment:"the DisableUncompress option of the default decoder."`
+	AllowUnsafeMode    bool                 `comment:"the AllowUnsafeMode option of the default decoder."`
+	Address            string               `comment:"the listening address."`
+	Framing            string               `comment:"how frames are separated in the stream, one of newline, octet_counted, length_prefixed and delimiter."`
+	Delimiter          string               `comment:"the frame delimiter of the delimiter framing."`
+	MaxFrameSize       int                  `comment:"the maximum size of a frame, the connection is closed if exceeded."`
+	MaxConnections     int                  `comment:"the maximum count of concurrent connections, new connections are rejected when exceeded."`
+	IdleTimeoutSeconds int                  `comment:"close the connection if nothing is received in the time, 0 means never."`
+	PeerAddressTag     string               `comment:"the tag key of the peer address, empty to disable."`
+	Tags               map[string]string    `comment:"the extra tags added to the decoded data."`
+	TLS                *tlscommon.TLSConfig `comment:"the tls settings, the certificate and key are required if enabled."`
+
+	context     pipeline.Context
+	decoder     extensions.Decoder
+	splitFunc   bufio.SplitFunc
+	tlsConfig   *tls.Config
+	version     int8
+	collector   pipeline.Collector
+	collectorV2 pipeline.PipelineCollector
+	server      *helper.ConnServer
+
+	rejectedConnsMetric pipeline.CounterMetric
+	decodeErrorsMetric  pipeline.CounterMetric
+}
+
+func (s *TCPServer) Init(context pipeline.Context) (int, error) {
+	s.context = context
+	options := &struct {
+		Format            string
+		FieldsExtend      bool
+		DisableUncompress bool
+		AllowUnsafeMode   bool
+	}{
+		Format:            s.Format,
+		FieldsExtend:      s.FieldsExtend,
+		DisableUncompress: s.DisableUncompress,
+		AllowUnsafeMode:   s.AllowUnsafeMode,
+	}
+	ext, err := context.GetExtension(s.Decoder, options)
+	if err != nil {
+		return 0, err
+	}
+	decoder, ok := ext.(extensions.Decoder)
+	if !ok {
+		return 0, fmt.Errorf("extension %s with type %T not implement extensions.Decoder", s.Decoder, ext)
+	}
+	s.decoder = decoder
+	if s.Address == "" {
+		return 0, fmt.Errorf("must specify Address for plugin %v", pluginName)
+	}
+	if s.MaxFrameSize <= 0 {
+		return 0, fmt.Errorf("invalid MaxFrameSize %v for plugin %v", s.MaxFrameSize, pluginName)
+	}
+	if s.splitFunc, err = newSplitFunc(strings.ToLower(s.Framing), s.Delimiter, s.MaxFrameSize); err != nil {
+		return 0, err
+	}
+	if s.TLS != nil {
+		if s.tlsConfig, err = s.TLS.LoadServerTLSConfig(); err != nil {
+			return 0, fmt.Errorf("load TLS config for plugin %v error: %v", pluginName, err)
+		}
+	}
+	s.rejectedConnsMetric = helper.NewCounterMetricAndRegister("tcp_server_rejected_connections", context)
+	s.decodeErrorsMetric = helper.NewCounterMetricAndRegister("tcp_server_decode_errors", context)
+	return 0, nil
+}
+
+func (s *TCPServer) Description() string {
+	return "this is a tcp listening server with pluggable framing and decoders"
+}
+
+// Start starts the server for the v1 pipeline.
+func (s *TCPServer) Start(collector pipeline.Collector) error {
+	s.collector = collector
+	s.version = v1
+	return s.start()
+}
+
+// StartService starts the server for the v2 pipeline.
+func (s *TCPServer) StartService(context pipeline.PipelineContext) error {
+	s.collectorV2 = context.Collector()
+	s.version = v2
+	return s.start()
+}
+
+func (s *TCPServer) start() error {
+	s.server = &helper.ConnServer{
+		MaxConnections: s.MaxConnections,
+		Handle:         s.handleConn,
+		OnReject: func(conn net.Conn) {
+			s.rejectedConnsMetric.Add(1)
+			logger.Warning(s.context.GetRuntimeContext(), "TCP_SERVER_ALARM", "too many connections, reject", conn.RemoteAddr())
+		},
+		OnAcceptError: func(err error) {
+			logger.Error(s.context.GetRuntimeContext(), "TCP_SERVER_ALARM", "accept connection err", err)
+		},
+	}
+	if err := s.server.Start(s.Address, s.tlsConfig); err != nil {
+		logger.Error(s.context.GetRuntimeContext(), "TCP_SERVER_ALARM", "start tcp server err", err)
+		return err
+	}
+	logger.Info(s.context.GetRuntimeContext(), "tcp server start", s.Address, "tls", s.tlsConfig != nil)
+	return nil
+}
+
+func (s *TCPServer) handleConn(conn net.Conn) {
+	tags := make(map[string]string, len(s.Tags)+1)
+	for k, v := range s.Tags {
+		tags[k] = v
+	}
+	if s.PeerAddressTag != "" {
+		tags[s.PeerAddressTag] = conn.RemoteAddr().String()
+	}
+	scanner := bufio.NewScanner(conn)
+	// leave some room for the frame header
+	scanner.Buffer(make([]byte, 0, 4096), s.MaxFrameSize+64)
+	scanner.Split(s.splitFunc)
+	for {
+		if s.IdleTimeoutSeconds > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(time.Duration(s.IdleTimeoutSeconds) * time.Second))
+		}
+		if !scanner.Scan() {
+			break
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		// the scanner reuses the buffer, while decoders may keep the data
+		frame := make([]byte, len(scanner.Bytes()))
+		copy(frame, scanner.Bytes())
+		s.decode(frame, tags)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		logger.Warning(s.context.GetRuntimeContext(), "TCP_SERVER_ALARM", "read connection err, close it", err, "remote", conn.RemoteAddr())
+	}
+}
+
+func (s *TCPServer) decode(frame []byte, tags map[string]string) {
+	switch s.version {
+	case v1:
+		logs, err := s.decoder.Decode(frame, nil, tags)
+		if err != nil {
+			s.decodeErrorsMetric.Add(1)
+			logger.Warning(s.context.GetRuntimeContext(), "DECODE_BODY_FAIL_ALARM", "decode frame failed", err)
+			return
+		}
+		for _, log := range logs {
+			for k, v := range tags {
+				log.Contents = append(log.Contents, &protocol.Log_Content{Key: tagPrefix + k, Value: v})
+			}
+			s.collector.AddRawLog(log)
+		}
+	case v2:
+		groups, err := s.decoder.DecodeV2(frame, nil)
+		if err != nil {
+			s.decodeErrorsMetric.Add(1)
+			logger.Warning(s.context.GetRuntimeContext(), "DECODE_BODY_FAIL_ALARM", "decode frame failed", err)
+			return
+		}
+		if len(tags) > 0 {
+			for _, g := range groups {
+				if g.Group == nil {
+					g.Group = models.NewGroup(models.NewMetadata(), models.NewTags())
+				}
+				if g.Group.Metadata == nil {
+					g.Group.Metadata = models.NewMetadata()
+				}
+				g.Group.Metadata.Merge(models.NewMetadataWithMap(tags))
+			}
+		}
+		s.collectorV2.CollectList(groups...)
+	}
+}
+
+// Stop stops the listener and closes all connections.
+func (s *TCPServer) Stop() error {
+	if s.server != nil {
+		s.server.Stop()
+	}
+	logger.Info(s.context.GetRuntimeContext(), "tcp server stop", s.Address)
+	return nil
+}
+
+func init() {
+	pipeline.ServiceInputs[pluginName] = func() pipeline.ServiceInput {
+		return &TCPServer{
+			Decoder:        "ext_default_decoder",
+			Format:         "raw",
+			Framing:        framingNewline,
+			MaxFrameSize:   1024 * 1024,
+			MaxConnections: 100,
+			PeerAddressTag: "peer_address",
+		}
+	}
+}
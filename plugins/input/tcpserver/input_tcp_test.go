@@ -0,0 +1,188 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcpserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/tlscommon"
+	_ "github.com/alibaba/ilogtail/plugins/extension/default_decoder"
+	"github.com/alibaba/ilogtail/plugins/test"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+func newTestServer(t *testing.T, init func(s *TCPServer)) *TCPServer {
+	s := pipeline.ServiceInputs[pluginName]().(*TCPServer)
+	s.Address = "127.0.0.1:0"
+	if init != nil {
+		init(s)
+	}
+	_, err := s.Init(mock.NewEmptyContext("p", "l", "c"))
+	require.NoError(t, err)
+	return s
+}
+
+func receiveV2(t *testing.T, ctx pipeline.PipelineContext, count int) []*models.PipelineGroupEvents {
+	var groups []*models.PipelineGroupEvents
+	timeout := time.After(5 * time.Second)
+	for len(groups) < count {
+		select {
+		case group := <-ctx.Collector().Observe():
+			groups = append(groups, group)
+		case <-timeout:
+			t.Fatalf("timeout waiting for %v groups, got %v", count, len(groups))
+		}
+	}
+	return groups
+}
+
+func TestServiceV2(t *testing.T) {
+	s := newTestServer(t, func(s *TCPServer) {
+		s.Framing = framingOctetCounted
+		s.Tags = map[string]string{"env": "test"}
+	})
+	ctx := pipeline.NewObservePipelineConext(10)
+	require.NoError(t, s.StartService(ctx))
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	conn, err := net.Dial("tcp", s.server.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("5 hello11 hello\nworld"))
+	require.NoError(t, err)
+	groups := receiveV2(t, ctx, 2)
+	assert.Equal(t, models.ByteArray("hello"), groups[0].Events[0])
+	assert.Equal(t, models.ByteArray("hello\nworld"), groups[1].Events[0])
+	assert.Equal(t, conn.LocalAddr().String(), groups[0].Group.GetMetadata().Get("peer_address"))
+	assert.Equal(t, "test", groups[0].Group.GetMetadata().Get("env"))
+	_ = conn.Close()
+}
+
+func TestServiceV1(t *testing.T) {
+	s := newTestServer(t, func(s *TCPServer) {
+		s.Framing = framingDelimiter
+		s.Delimiter = "\x00"
+	})
+	collector := &test.MockCollector{}
+	require.NoError(t, s.Start(collector))
+	conn, err := net.Dial("tcp", s.server.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("a\x00b"))
+	require.NoError(t, err)
+	// the server closes the connection after all frames are decoded
+	require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+	require.NoError(t, s.Stop())
+	require.Len(t, collector.RawLogs, 2)
+
+	assert.Equal(t, "a", test.ReadLogVal(collector.RawLogs[0], "content"))
+	assert.Equal(t, "b", test.ReadLogVal(collector.RawLogs[1], "content"))
+	assert.Equal(t, conn.LocalAddr().String(), test.ReadLogVal(collector.RawLogs[0], "__tag__:peer_address"))
+}
+
+func TestMaxConnections(t *testing.T) {
+	s := newTestServer(t, func(s *TCPServer) {
+		s.MaxConnections = 1
+	})
+	ctx := pipeline.NewObservePipelineConext(10)
+	require.NoError(t, s.StartService(ctx))
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	first, err := net.Dial("tcp", s.server.Addr().String())
+	require.NoError(t, err)
+	defer first.Close()
+	_, err = first.Write([]byte("a\n"))
+	require.NoError(t, err)
+	receiveV2(t, ctx, 1)
+
+	second, err := net.Dial("tcp", s.server.Addr().String())
+	require.NoError(t, err)
+	defer second.Close()
+	// the rejected connection is closed by the server
+	_ = second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = second.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), s.rejectedConnsMetric.Get())
+}
+
+func TestServiceTLS(t *testing.T) {
+	certFile, keyFile := generateCert(t)
+	s := newTestServer(t, func(s *TCPServer) {
+		s.TLS = &tlscommon.TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile}
+	})
+	ctx := pipeline.NewObservePipelineConext(10)
+	require.NoError(t, s.StartService(ctx))
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	conn, err := tls.Dial("tcp", s.server.Addr().String(), &tls.Config{InsecureSkipVerify: true}) //nolint:gosec
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("secure\n"))
+	require.NoError(t, err)
+	groups := receiveV2(t, ctx, 1)
+	assert.Equal(t, models.ByteArray("secure"), groups[0].Events[0])
+
+	s2 := pipeline.ServiceInputs[pluginName]().(*TCPServer)
+	s2.Address = "127.0.0.1:0"
+	s2.TLS = &tlscommon.TLSConfig{Enabled: true}
+	_, err = s2.Init(mock.NewEmptyContext("p", "l", "c"))
+	assert.Error(t, err)
+}
+
+func generateCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
@@ -0,0 +1,258 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cef
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	cefMagic  = "CEF:"
+	leefMagic = "LEEF:"
+)
+
+var (
+	errNotCEF  = errors.New("no CEF header found")
+	errNotLEEF = errors.New("no LEEF header found")
+
+	cefHeaderKeys  = []string{"version", "device_vendor", "device_product", "device_version", "signature_id", "name", "severity"}
+	leefHeaderKeys = []string{"version", "vendor", "product", "product_version", "event_id"}
+)
+
+type field struct {
+	key   string
+	value string
+}
+
+// SeverityLabel maps a CEF severity, either numeric 0-10 or a keyword, to the normalized
+// keyword defined by the CEF specification: Low, Medium, High or Very-High.
+func SeverityLabel(severity string) string {
+	n, err := strconv.Atoi(severity)
+	if err != nil {
+		switch strings.ToLower(severity) {
+		case "low":
+			return "Low"
+		case "medium":
+			return "Medium"
+		case "high":
+			return "High"
+		case "very-high", "veryhigh":
+			return "Very-High"
+		}
+		return "Unknown"
+	}
+	switch {
+	case n < 0 || n > 10:
+		return "Unknown"
+	case n <= 3:
+		return "Low"
+	case n <= 6:
+		return "Medium"
+	case n <= 8:
+		return "High"
+	default:
+		return "Very-High"
+	}
+}
+
+// splitHeader splits @data into at most @n pipe separated header fields, unescaping `\|` and `\\`.
+// It returns the fields and the remaining data after the last consumed pipe.
+func splitHeader(data string, n int) ([]string, string, error) {
+	fields := make([]string, 0, n)
+	var sb strings.Builder
+	i := 0
+	for i < len(data) && len(fields) < n {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data) && (data[i+1] == '|' || data[i+1] == '\\'):
+			sb.WriteByte(data[i+1])
+			i += 2
+			continue
+		case c == '|':
+			fields = append(fields, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+		i++
+	}
+	if len(fields) < n {
+		return nil, "", fmt.Errorf("incomplete header, expect %d fields but got %d", n, len(fields))
+	}
+	return fields, data[i:], nil
+}
+
+// parseCEF parses a CEF record. Any text before the `CEF:` magic, e.g. a syslog header, is ignored.
+func parseCEF(data string) ([]field, string, error) {
+	start := strings.Index(data, cefMagic)
+	if start < 0 {
+		return nil, "", errNotCEF
+	}
+	headers, extension, err := splitHeader(data[start+len(cefMagic):], len(cefHeaderKeys))
+	if err != nil {
+		return nil, "", err
+	}
+	fields := make([]field, 0, len(headers)+8)
+	for i, h := range headers {
+		fields = append(fields, field{key: cefHeaderKeys[i], value: h})
+	}
+	severity := headers[len(headers)-1]
+	fields = append(fields, field{key: "severity_label", value: SeverityLabel(severity)})
+	return append(fields, parseCEFExtension(extension)...), severity, nil
+}
+
+// parseCEFExtension parses the space separated key=value extension of a CEF record.
+// Values may contain spaces, so a value ends right before the next ` key=` token.
+func parseCEFExtension(ext string) []field {
+	var fields []field
+	ext = strings.TrimSpace(ext)
+	key := ""
+	valueStart := -1
+	for i := 0; i < len(ext); i++ {
+		c := ext[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c != '=' {
+			continue
+		}
+		// find the beginning of the key before '='
+		j := i - 1
+		for j >= 0 && isKeyChar(ext[j]) {
+			j--
+		}
+		if j == i-1 || (j >= 0 && ext[j] != ' ') {
+			continue
+		}
+		if valueStart >= 0 {
+			fields = append(fields, field{key: key, value: unescapeCEFValue(strings.TrimRight(ext[valueStart:j+1], " "))})
+		}
+		key = ext[j+1 : i]
+		valueStart = i + 1
+	}
+	if valueStart >= 0 {
+		fields = append(fields, field{key: key, value: unescapeCEFValue(strings.TrimRight(ext[valueStart:], " "))})
+	}
+	return fields
+}
+
+func isKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-' || c == '[' || c == ']'
+}
+
+func unescapeCEFValue(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+	var sb strings.Builder
+	sb.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' || i+1 == len(value) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		default:
+			sb.WriteByte(value[i])
+		}
+	}
+	return sb.String()
+}
+
+// parseLEEF parses a LEEF 1.0 or 2.0 record. LEEF 1.0 attributes are tab separated, LEEF 2.0
+// carries the attribute delimiter as an extra header field, either a character or a hex code like x09.
+func parseLEEF(data string) ([]field, string, error) {
+	start := strings.Index(data, leefMagic)
+	if start < 0 {
+		return nil, "", errNotLEEF
+	}
+	headers, rest, err := splitHeader(data[start+len(leefMagic):], len(leefHeaderKeys))
+	if err != nil {
+		return nil, "", err
+	}
+	fields := make([]field, 0, len(headers)+8)
+	for i, h := range headers {
+		fields = append(fields, field{key: leefHeaderKeys[i], value: h})
+	}
+	delimiter := "\t"
+	if strings.HasPrefix(headers[0], "2") {
+		if idx := strings.IndexByte(rest, '|'); idx >= 0 {
+			if d := parseLEEFDelimiter(rest[:idx]); d != "" {
+				delimiter = d
+			}
+			rest = rest[idx+1:]
+		}
+	}
+	severity := ""
+	for _, attr := range splitLEEFAttributes(rest, delimiter) {
+		if attr == "" {
+			continue
+		}
+		kv := strings.SplitN(attr, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := unescapeCEFValue(kv[1])
+		if kv[0] == "sev" {
+			severity = value
+		}
+		fields = append(fields, field{key: kv[0], value: value})
+	}
+	if severity != "" {
+		fields = append(fields, field{key: "severity_label", value: SeverityLabel(severity)})
+	}
+	return fields, severity, nil
+}
+
+// splitLEEFAttributes splits the attributes by the delimiter, a delimiter escaped by a backslash is kept in the
+// value, which is unescaped later.
+func splitLEEFAttributes(data, delimiter string) []string {
+	var attrs []string
+	start := 0
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(data[i:], delimiter) {
+			attrs = append(attrs, data[start:i])
+			i += len(delimiter) - 1
+			start = i + 1
+		}
+	}
+	return append(attrs, data[start:])
+}
+
+func parseLEEFDelimiter(d string) string {
+	if len(d) > 1 && (d[0] == 'x' || d[0] == 'X' || strings.HasPrefix(d, "0x") || strings.HasPrefix(d, "0X")) {
+		hex := strings.TrimLeft(d[1:], "xX")
+		if v, err := strconv.ParseUint(hex, 16, 8); err == nil {
+			return string(rune(v))
+		}
+	}
+	return d
+}
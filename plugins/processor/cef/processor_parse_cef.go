@@ -0,0 +1,194 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cef
+
+import (
+	"fmt"
+
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/pkg/util"
+)
+
+const (
+	cefPluginName  = "processor_parse_cef"
+	leefPluginName = "processor_parse_leef"
+	// levelKey is the content holding the level of the v1 logs
+	levelKey = "level"
+)
+
+// ProcessorParseCEF parses ArcSight CEF or IBM QRadar LEEF records in the source field.
+// The same type backs both processor_parse_cef and processor_parse_leef, which differ in the parse function only.
+type ProcessorParseCEF struct {
+	SourceKey              string `comment:"the field holding the raw CEF/LEEF record."`
+	HeaderPrefix           string `comment:"the prefix added to the header field names."`
+	ExtensionPrefix        string `comment:"the prefix added to the extension (attribute) field names."`
+	KeepSource             bool   `comment:"Whether to keep the source field after parsing."`
+	KeepSourceIfParseError bool   `comment:"Whether to keep the source field when the record cannot be parsed."`
+	NoKeyError             bool   `comment:"Whether to alarm when the source field is not found."`
+	AlarmIfFail            bool   `comment:"Whether to alarm when the record cannot be parsed."`
+
+	name       string
+	headerSize int
+	parse      func(data string) ([]field, string, error)
+	context    pipeline.Context
+}
+
+// Init called for init some system resources, like socket, mutex...
+func (p *ProcessorParseCEF) Init(context pipeline.Context) error {
+	if p.SourceKey == "" {
+		return fmt.Errorf("must specify SourceKey for plugin %v", p.name)
+	}
+	p.context = context
+	return nil
+}
+
+func (p *ProcessorParseCEF) Description() string {
+	if p.name == leefPluginName {
+		return "leef processor to parse LEEF 1.0/2.0 records in the source field"
+	}
+	return "cef processor to parse CEF records in the source field"
+}
+
+func (p *ProcessorParseCEF) fieldName(idx int, key string) string {
+	// the first headerSize fields and the severity label are headers, the others are extensions
+	if idx < p.headerSize || key == "severity_label" {
+		return p.HeaderPrefix + key
+	}
+	return p.ExtensionPrefix + key
+}
+
+func (p *ProcessorParseCEF) ProcessLogs(logArray []*protocol.Log) []*protocol.Log {
+	for _, log := range logArray {
+		p.processLog(log)
+	}
+	return logArray
+}
+
+func (p *ProcessorParseCEF) processLog(log *protocol.Log) {
+	for idx, content := range log.Contents {
+		if content.Key != p.SourceKey {
+			continue
+		}
+		fields, severity, err := p.parse(content.Value)
+		if err != nil {
+			if p.AlarmIfFail {
+				logger.Warning(p.context.GetRuntimeContext(), "PROCESSOR_PARSE_CEF_ALARM", "plugin", p.name, "parse error", err, "value", content.Value)
+			}
+			if !p.KeepSourceIfParseError {
+				log.Contents = append(log.Contents[:idx], log.Contents[idx+1:]...)
+			}
+			return
+		}
+		if !p.KeepSource {
+			log.Contents = append(log.Contents[:idx], log.Contents[idx+1:]...)
+		}
+		for i, f := range fields {
+			log.Contents = append(log.Contents, &protocol.Log_Content{Key: p.fieldName(i, f.key), Value: f.value})
+		}
+		if severity != "" {
+			setLevel(log, SeverityLabel(severity))
+		}
+		return
+	}
+	if p.NoKeyError {
+		logger.Warningf(p.context.GetRuntimeContext(), "PROCESSOR_PARSE_CEF_FIND_ALARM", "cannot find key %v", p.SourceKey)
+	}
+}
+
+func (p *ProcessorParseCEF) Process(in *models.PipelineGroupEvents, context pipeline.PipelineContext) {
+	for _, event := range in.Events {
+		p.processEvent(event)
+	}
+	context.Collector().Collect(in.Group, in.Events...)
+}
+
+func (p *ProcessorParseCEF) processEvent(event models.PipelineEvent) {
+	if event.GetType() != models.EventTypeLogging {
+		return
+	}
+	log := event.(*models.Log)
+	contents := log.GetIndices()
+	if !contents.Contains(p.SourceKey) {
+		if p.NoKeyError {
+			logger.Warningf(p.context.GetRuntimeContext(), "PROCESSOR_PARSE_CEF_FIND_ALARM", "cannot find key %v", p.SourceKey)
+		}
+		return
+	}
+	var data string
+	switch val := contents.Get(p.SourceKey).(type) {
+	case []byte:
+		data = util.ZeroCopyBytesToString(val)
+	case string:
+		data = val
+	default:
+		logger.Warningf(p.context.GetRuntimeContext(), "PROCESSOR_PARSE_CEF_FIND_ALARM", "key %v is not string", p.SourceKey)
+		return
+	}
+	fields, severity, err := p.parse(data)
+	if err != nil {
+		if p.AlarmIfFail {
+			logger.Warning(p.context.GetRuntimeContext(), "PROCESSOR_PARSE_CEF_ALARM", "plugin", p.name, "parse error", err, "value", data)
+		}
+		if !p.KeepSourceIfParseError {
+			contents.Delete(p.SourceKey)
+		}
+		return
+	}
+	if !p.KeepSource {
+		contents.Delete(p.SourceKey)
+	}
+	for i, f := range fields {
+		contents.Add(p.fieldName(i, f.key), f.value)
+	}
+	if severity != "" {
+		log.SetLevel(SeverityLabel(severity))
+	}
+}
+
+// setLevel sets the level content of the v1 log, as the level of the v2 log.
+func setLevel(log *protocol.Log, level string) {
+	for _, content := range log.Contents {
+		if content.Key == levelKey {
+			content.Value = level
+			return
+		}
+	}
+	log.Contents = append(log.Contents, &protocol.Log_Content{Key: levelKey, Value: level})
+}
+
+func newProcessor(name string, headerSize int, parse func(data string) ([]field, string, error)) *ProcessorParseCEF {
+	return &ProcessorParseCEF{
+		SourceKey:              "content",
+		KeepSource:             false,
+		KeepSourceIfParseError: true,
+		NoKeyError:             true,
+		AlarmIfFail:            true,
+		name:                   name,
+		headerSize:             headerSize,
+		parse:                  parse,
+	}
+}
+
+func init() {
+	pipeline.Processors[cefPluginName] = func() pipeline.Processor {
+		return newProcessor(cefPluginName, len(cefHeaderKeys), parseCEF)
+	}
+	pipeline.Processors[leefPluginName] = func() pipeline.Processor {
+		return newProcessor(leefPluginName, len(leefHeaderKeys), parseLEEF)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cef
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/plugins/test"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+func init() {
+	logger.InitTestLogger(logger.OptionOpenMemoryReceiver)
+}
+
+func newTestProcessor(t *testing.T, name string) *ProcessorParseCEF {
+	processor := pipeline.Processors[name]().(*ProcessorParseCEF)
+	require.NoError(t, processor.Init(mock.NewEmptyContext("p", "l", "c")))
+	return processor
+}
+
+func TestSeverityLabel(t *testing.T) {
+	assert.Equal(t, "Low", SeverityLabel("0"))
+	assert.Equal(t, "Low", SeverityLabel("3"))
+	assert.Equal(t, "Medium", SeverityLabel("5"))
+	assert.Equal(t, "High", SeverityLabel("8"))
+	assert.Equal(t, "Very-High", SeverityLabel("10"))
+	assert.Equal(t, "Unknown", SeverityLabel("11"))
+	assert.Equal(t, "High", SeverityLabel("high"))
+	assert.Equal(t, "Unknown", SeverityLabel("foo"))
+}
+
+func TestParseCEFExtension(t *testing.T) {
+	fields := parseCEFExtension(`src=10.0.0.1 msg=Detected a threat. No action needed. path=C:\\Windows eq=a\=b nl=x\ny`)
+	assert.Equal(t, []field{
+		{key: "src", value: "10.0.0.1"},
+		{key: "msg", value: "Detected a threat. No action needed."},
+		{key: "path", value: `C:\Windows`},
+		{key: "eq", value: "a=b"},
+		{key: "nl", value: "x\ny"},
+	}, fields)
+	assert.Empty(t, parseCEFExtension(""))
+}
+
+func TestProcessCEF(t *testing.T) {
+	processor := newTestProcessor(t, cefPluginName)
+	processor.HeaderPrefix = "cef_"
+	log := test.CreateLogs("content", `Sep 19 08:26:10 host CEF:0|Security|threat\|manager|1.0|100|worm successfully stopped|10|src=10.0.0.1 dst=2.1.2.2 spt=1232`)
+	processor.ProcessLogs([]*protocol.Log{log})
+	assert.Equal(t, "", test.ReadLogVal(log, "content"))
+	assert.Equal(t, "0", test.ReadLogVal(log, "cef_version"))
+	assert.Equal(t, "Security", test.ReadLogVal(log, "cef_device_vendor"))
+	assert.Equal(t, "threat|manager", test.ReadLogVal(log, "cef_device_product"))
+	assert.Equal(t, "100", test.ReadLogVal(log, "cef_signature_id"))
+	assert.Equal(t, "worm successfully stopped", test.ReadLogVal(log, "cef_name"))
+	assert.Equal(t, "10", test.ReadLogVal(log, "cef_severity"))
+	assert.Equal(t, "Very-High", test.ReadLogVal(log, "cef_severity_label"))
+	assert.Equal(t, "10.0.0.1", test.ReadLogVal(log, "src"))
+	assert.Equal(t, "2.1.2.2", test.ReadLogVal(log, "dst"))
+	assert.Equal(t, "1232", test.ReadLogVal(log, "spt"))
+	// the level is set as the v2 log
+	assert.Equal(t, "Very-High", test.ReadLogVal(log, "level"))
+}
+
+func TestProcessCEFError(t *testing.T) {
+	processor := newTestProcessor(t, cefPluginName)
+	log := test.CreateLogs("content", "CEF:0|Security|threat")
+	processor.ProcessLogs([]*protocol.Log{log})
+	assert.Equal(t, "CEF:0|Security|threat", test.ReadLogVal(log, "content"))
+	assert.Len(t, log.Contents, 1)
+}
+
+func TestProcessLEEF(t *testing.T) {
+	processor := newTestProcessor(t, leefPluginName)
+	log := test.CreateLogs("content", "LEEF:1.0|Microsoft|MSExchange|4.0 SP1|15345|src=192.0.2.0\tdst=172.50.123.1\tsev=5")
+	processor.ProcessLogs([]*protocol.Log{log})
+	assert.Equal(t, "1.0", test.ReadLogVal(log, "version"))
+	assert.Equal(t, "Microsoft", test.ReadLogVal(log, "vendor"))
+	assert.Equal(t, "15345", test.ReadLogVal(log, "event_id"))
+	assert.Equal(t, "192.0.2.0", test.ReadLogVal(log, "src"))
+	assert.Equal(t, "172.50.123.1", test.ReadLogVal(log, "dst"))
+	assert.Equal(t, "Medium", test.ReadLogVal(log, "severity_label"))
+
+	log = test.CreateLogs("content", "LEEF:2.0|Lancope|StealthWatch|1.0|41|^|src=10.0.1.8^dst=10.0.0.5^sev=9")
+	processor.ProcessLogs([]*protocol.Log{log})
+	assert.Equal(t, "10.0.1.8", test.ReadLogVal(log, "src"))
+	assert.Equal(t, "10.0.0.5", test.ReadLogVal(log, "dst"))
+	assert.Equal(t, "Very-High", test.ReadLogVal(log, "severity_label"))
+
+	log = test.CreateLogs("content", "LEEF:2.0|Lancope|StealthWatch|1.0|41|x09|src=10.0.1.8\tdst=10.0.0.5")
+	processor.ProcessLogs([]*protocol.Log{log})
+	assert.Equal(t, "10.0.0.5", test.ReadLogVal(log, "dst"))
+	assert.Equal(t, "", test.ReadLogVal(log, "level"))
+
+	// the escaped delimiter, backslash and equal sign are unescaped
+	log = test.CreateLogs("content", `LEEF:2.0|Lancope|StealthWatch|1.0|41|^|path=C:\\Windows^msg=a\^b\=c^sev=3`)
+	processor.ProcessLogs([]*protocol.Log{log})
+	assert.Equal(t, `C:\Windows`, test.ReadLogVal(log, "path"))
+	assert.Equal(t, "a^b=c", test.ReadLogVal(log, "msg"))
+	assert.Equal(t, "Low", test.ReadLogVal(log, "level"))
+}
+
+func TestProcessCEFV2(t *testing.T) {
+	processor := newTestProcessor(t, cefPluginName)
+	processor.ExtensionPrefix = "ext_"
+	log := models.NewLog("", nil, "", "", "", models.NewTags(), 0)
+	log.GetIndices().Add("content", "CEF:0|Vendor|Product|1.0|42|Login failed|4|suser=admin act=blocked")
+	context := pipeline.NewObservePipelineConext(10)
+	processor.Process(&models.PipelineGroupEvents{Events: []models.PipelineEvent{log}}, context)
+	require.Len(t, context.Collector().ToArray(), 1)
+	contents := log.GetIndices()
+	assert.False(t, contents.Contains("content"))
+	assert.Equal(t, "Login failed", contents.Get("name"))
+	assert.Equal(t, "admin", contents.Get("ext_suser"))
+	assert.Equal(t, "blocked", contents.Get("ext_act"))
+	assert.Equal(t, "Medium", contents.Get("severity_label"))
+	assert.Equal(t, "Medium", log.GetLevel())
+}
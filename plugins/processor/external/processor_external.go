@@ -0,0 +1,82 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/helper/external"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+)
+
+const pluginName = "processor_external"
+
+// ProcessorExternal processes the events by an external plugin executable, the events are passed through unchanged
+// when the plugin fails or is restarting.
+type ProcessorExternal struct {
+	external.PluginConfig
+
+	context      pipeline.Context
+	client       *external.Client
+	errorsMetric pipeline.CounterMetric
+}
+
+// Init launches the plugin and waits until it is initialized.
+func (p *ProcessorExternal) Init(context pipeline.Context) error {
+	p.context = context
+	client, err := p.NewClient(context.GetRuntimeContext(), pluginName, external.KindProcessor)
+	if err != nil {
+		return err
+	}
+	if err = client.Start(); err != nil {
+		logger.Error(context.GetRuntimeContext(), "EXTERNAL_PLUGIN_ALARM", "start external processor error", err, "path", p.Path)
+		return err
+	}
+	p.client = client
+	p.errorsMetric = helper.NewCounterMetricAndRegister("external_process_errors", context)
+	return nil
+}
+
+func (*ProcessorExternal) Description() string {
+	return "external processor for logtail, which processes the events by an external plugin executable"
+}
+
+func (p *ProcessorExternal) Process(in *models.PipelineGroupEvents, context pipeline.PipelineContext) {
+	out, err := p.client.Process([]*models.PipelineGroupEvents{in})
+	if err != nil {
+		p.errorsMetric.Add(1)
+		logger.Warning(p.context.GetRuntimeContext(), "EXTERNAL_PLUGIN_ALARM", "process events error", err, "path", p.Path)
+		context.Collector().Collect(in.Group, in.Events...)
+		return
+	}
+	context.Collector().CollectList(out...)
+}
+
+// Stop stops the plugin process.
+func (p *ProcessorExternal) Stop() error {
+	if p.client != nil {
+		return p.client.Stop()
+	}
+	return nil
+}
+
+func init() {
+	pipeline.Processors[pluginName] = func() pipeline.Processor {
+		return &ProcessorExternal{
+			PluginConfig: external.DefaultPluginConfig(),
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/helper/external/externaltest"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+// TestMain runs the test binary as the external plugin when it is launched by the processor.
+func TestMain(m *testing.M) {
+	externaltest.Main(m)
+}
+
+func TestProcessorExternal(t *testing.T) {
+	p := pipeline.Processors[pluginName]().(*ProcessorExternal)
+	p.Path = os.Args[0]
+	p.Config = map[string]interface{}{"Tag": "external"}
+	require.NoError(t, p.Init(mock.NewEmptyContext("p", "l", "c")))
+	defer p.Stop()
+
+	ctx := pipeline.NewObservePipelineConext(10)
+	p.Process(externaltest.NewLogs("hello")[0], ctx)
+	out := <-ctx.Collector().Observe()
+	require.Len(t, out.Events, 1)
+	assert.Equal(t, "external", out.Events[0].GetTags().Get("processed"))
+	assert.Equal(t, []byte("hello"), out.Events[0].(*models.Log).GetBody())
+
+	// the events are passed through on errors
+	p.Process(externaltest.NewLogs("hello", "fail", "true")[0], ctx)
+	out = <-ctx.Collector().Observe()
+	require.Len(t, out.Events, 1)
+	assert.Equal(t, "", out.Events[0].GetTags().Get("processed"))
+}
+
+func TestProcessorExternalInit(t *testing.T) {
+	p := pipeline.Processors[pluginName]().(*ProcessorExternal)
+	assert.Error(t, p.Init(mock.NewEmptyContext("p", "l", "c")))
+}
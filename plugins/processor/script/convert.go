@@ -0,0 +1,288 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"fmt"
+	"time"
+
+	"go.starlark.net/starlark"
+
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+)
+
+// The keys of the dicts passed to the script.
+const (
+	keyName      = "name"
+	keyLevel     = "level"
+	keyTimestamp = "timestamp"
+	keyContents  = "contents"
+	keyTags      = "tags"
+	keyMetadata  = "metadata"
+	keyEvents    = "events"
+)
+
+func newEventDict(name, level string, timestamp uint64, contents, tags *starlark.Dict) *starlark.Dict {
+	d := starlark.NewDict(5)
+	_ = d.SetKey(starlark.String(keyName), starlark.String(name))
+	_ = d.SetKey(starlark.String(keyLevel), starlark.String(level))
+	_ = d.SetKey(starlark.String(keyTimestamp), starlark.MakeUint64(timestamp))
+	_ = d.SetKey(starlark.String(keyContents), contents)
+	_ = d.SetKey(starlark.String(keyTags), tags)
+	return d
+}
+
+func logToDict(log *models.Log) *starlark.Dict {
+	contents := log.GetIndices().Iterator()
+	contentsDict := starlark.NewDict(len(contents))
+	for k, v := range contents {
+		_ = contentsDict.SetKey(starlark.String(k), toValue(v))
+	}
+	return newEventDict(log.Name, log.Level, log.Timestamp, contentsDict, stringMapToDict(log.GetTags().Iterator()))
+}
+
+func logV1ToDict(log *protocol.Log) *starlark.Dict {
+	contents := starlark.NewDict(len(log.Contents))
+	for _, content := range log.Contents {
+		_ = contents.SetKey(starlark.String(content.Key), starlark.String(content.Value))
+	}
+	timestamp := uint64(log.Time)*1e9 + uint64(log.GetTimeNs())
+	return newEventDict("", "", timestamp, contents, starlark.NewDict(0))
+}
+
+func newGroupDict(group *models.GroupInfo, events *starlark.List) *starlark.Dict {
+	d := starlark.NewDict(3)
+	_ = d.SetKey(starlark.String(keyMetadata), stringMapToDict(group.GetMetadata().Iterator()))
+	_ = d.SetKey(starlark.String(keyTags), stringMapToDict(group.GetTags().Iterator()))
+	if events != nil {
+		_ = d.SetKey(starlark.String(keyEvents), events)
+	}
+	return d
+}
+
+func stringMapToDict(m map[string]string) *starlark.Dict {
+	d := starlark.NewDict(len(m))
+	for k, v := range m {
+		_ = d.SetKey(starlark.String(k), starlark.String(v))
+	}
+	return d
+}
+
+// dictToLog builds the log event from the dict returned by the script, the event inherits the fields absent in the
+// dict from src. An event with no src is stamped with the current time.
+func dictToLog(d *starlark.Dict, src *models.Log) (*models.Log, error) {
+	var log *models.Log
+	if src != nil {
+		log = &models.Log{
+			Name:              src.Name,
+			Level:             src.Level,
+			SpanID:            src.SpanID,
+			TraceID:           src.TraceID,
+			Timestamp:         src.Timestamp,
+			ObservedTimestamp: src.ObservedTimestamp,
+			Offset:            src.Offset,
+			Tags:              models.NewTagsWithMap(copyStringMap(src.GetTags().Iterator())),
+		}
+	} else {
+		log = &models.Log{
+			Timestamp: uint64(time.Now().UnixNano()),
+			Tags:      models.NewTags(),
+		}
+	}
+	if v, ok := dictGet(d, keyName); ok {
+		log.Name = toString(v)
+	}
+	if v, ok := dictGet(d, keyLevel); ok {
+		log.Level = toString(v)
+	}
+	if v, ok := dictGet(d, keyTimestamp); ok {
+		timestamp, err := toTimestamp(v)
+		if err != nil {
+			return nil, err
+		}
+		log.Timestamp = timestamp
+	}
+	if v, ok := dictGet(d, keyTags); ok {
+		tags, err := dictToStringMap(v, keyTags)
+		if err != nil {
+			return nil, err
+		}
+		log.Tags = models.NewTagsWithMap(tags)
+	}
+	contents := models.NewLogContents()
+	if v, ok := dictGet(d, keyContents); ok {
+		contentsDict, ok := v.(*starlark.Dict)
+		if !ok {
+			return nil, fmt.Errorf("%s must be dict, got %s", keyContents, v.Type())
+		}
+		for _, item := range contentsDict.Items() {
+			if value := fromValue(item[1]); value != nil {
+				contents.Add(toString(item[0]), value)
+			}
+		}
+	}
+	log.Contents = contents
+	return log, nil
+}
+
+// dictToLogV1 builds the v1 log from the dict returned by the script, the order of the contents is kept and the tags
+// are ignored.
+func dictToLogV1(d *starlark.Dict, srcTimestamp uint64) (*protocol.Log, error) {
+	timestamp := srcTimestamp
+	if v, ok := dictGet(d, keyTimestamp); ok {
+		var err error
+		if timestamp, err = toTimestamp(v); err != nil {
+			return nil, err
+		}
+	}
+	log := &protocol.Log{}
+	protocol.SetLogTimeWithNano(log, uint32(timestamp/1e9), uint32(timestamp%1e9))
+	if v, ok := dictGet(d, keyContents); ok {
+		contentsDict, ok := v.(*starlark.Dict)
+		if !ok {
+			return nil, fmt.Errorf("%s must be dict, got %s", keyContents, v.Type())
+		}
+		log.Contents = make([]*protocol.Log_Content, 0, contentsDict.Len())
+		for _, item := range contentsDict.Items() {
+			if item[1] == starlark.None {
+				continue
+			}
+			log.Contents = append(log.Contents, &protocol.Log_Content{Key: toString(item[0]), Value: toString(item[1])})
+		}
+	}
+	return log, nil
+}
+
+// dictsOf returns the dicts in the value returned by the script, which is None, a dict or a list of dicts.
+func dictsOf(v starlark.Value) ([]*starlark.Dict, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case *starlark.Dict:
+		return []*starlark.Dict{v}, nil
+	case *starlark.List, starlark.Tuple:
+		list := v.(starlark.Indexable)
+		dicts := make([]*starlark.Dict, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			switch item := list.Index(i).(type) {
+			case starlark.NoneType:
+			case *starlark.Dict:
+				dicts = append(dicts, item)
+			default:
+				return nil, fmt.Errorf("the script must return None, a dict or a list of dicts, got a list of %s", item.Type())
+			}
+		}
+		return dicts, nil
+	default:
+		return nil, fmt.Errorf("the script must return None, a dict or a list of dicts, got %s", v.Type())
+	}
+}
+
+func dictGet(d *starlark.Dict, key string) (starlark.Value, bool) {
+	v, found, _ := d.Get(starlark.String(key))
+	return v, found
+}
+
+func dictToStringMap(v starlark.Value, name string) (map[string]string, error) {
+	d, ok := v.(*starlark.Dict)
+	if !ok {
+		return nil, fmt.Errorf("%s must be dict, got %s", name, v.Type())
+	}
+	m := make(map[string]string, d.Len())
+	for _, item := range d.Items() {
+		if item[1] != starlark.None {
+			m[toString(item[0])] = toString(item[1])
+		}
+	}
+	return m, nil
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+func toTimestamp(v starlark.Value) (uint64, error) {
+	i, ok := v.(starlark.Int)
+	if !ok {
+		return 0, fmt.Errorf("%s must be int, got %s", keyTimestamp, v.Type())
+	}
+	timestamp, ok := i.Uint64()
+	if !ok {
+		return 0, fmt.Errorf("%s %s is out of range", keyTimestamp, i)
+	}
+	return timestamp, nil
+}
+
+func toString(v starlark.Value) string {
+	if s, ok := starlark.AsString(v); ok {
+		return s
+	}
+	return v.String()
+}
+
+func toValue(v interface{}) starlark.Value {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None
+	case string:
+		return starlark.String(v)
+	case []byte:
+		return starlark.String(v)
+	case bool:
+		return starlark.Bool(v)
+	case int:
+		return starlark.MakeInt(v)
+	case int32:
+		return starlark.MakeInt64(int64(v))
+	case int64:
+		return starlark.MakeInt64(v)
+	case uint32:
+		return starlark.MakeUint64(uint64(v))
+	case uint64:
+		return starlark.MakeUint64(v)
+	case float32:
+		return starlark.Float(v)
+	case float64:
+		return starlark.Float(v)
+	default:
+		return starlark.String(fmt.Sprint(v))
+	}
+}
+
+// fromValue converts the content value set by the script, None means the content is removed.
+func fromValue(v starlark.Value) interface{} {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.String:
+		return v.GoString()
+	case starlark.Bool:
+		return bool(v)
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i
+		}
+		return v.String()
+	case starlark.Float:
+		return float64(v)
+	default:
+		return v.String()
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"fmt"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+const (
+	// memoryCheckSteps is the interval of the execution steps to check the memory held by a call.
+	memoryCheckSteps = 1000
+	// valueOverhead is the estimated size of a value besides its content.
+	valueOverhead = 16
+)
+
+// memoryLimiter bounds the memory held by one call of the script. Starlark has no allocation accounting, so the
+// sizes of the values held by the local variables of the running functions are summed every memoryCheckSteps
+// steps, and the value returned by the call is checked at last. Only the values held by the call are counted, the
+// temporary values of an expression are not.
+type memoryLimiter struct {
+	limit int
+	// locals is the number of the local variables of the functions of the script, keyed by their positions.
+	locals map[syntax.Position]int
+}
+
+// newMemoryLimiter creates the limiter for the functions of the resolved script file.
+func newMemoryLimiter(limit int, file *syntax.File) *memoryLimiter {
+	l := &memoryLimiter{
+		limit:  limit,
+		locals: make(map[syntax.Position]int),
+	}
+	syntax.Walk(file, func(n syntax.Node) bool {
+		var fn interface{}
+		switch n := n.(type) {
+		case *syntax.DefStmt:
+			fn = n.Function
+		case *syntax.LambdaExpr:
+			fn = n.Function
+		}
+		if f, ok := fn.(*resolve.Function); ok {
+			l.locals[f.Pos] = len(f.Locals)
+		}
+		return true
+	})
+	return l
+}
+
+// checkThread cancels the thread when the values held by the local variables of its running functions exceed the
+// limit.
+func (l *memoryLimiter) checkThread(thread *starlark.Thread) {
+	m := &memoryMeter{limit: l.limit, seen: make(map[starlark.Value]struct{})}
+	for depth := 0; depth < thread.CallStackDepth() && !m.exceeded(); depth++ {
+		frame := thread.DebugFrame(depth)
+		fn, ok := frame.Callable().(*starlark.Function)
+		if !ok {
+			continue
+		}
+		for i := 0; i < l.locals[fn.Position()]; i++ {
+			m.add(frame.Local(i))
+		}
+	}
+	if m.exceeded() {
+		thread.Cancel(fmt.Sprintf("memory limit exceeded, hold more than %d bytes", l.limit))
+	}
+}
+
+// checkValue returns an error when the value exceeds the limit.
+func (l *memoryLimiter) checkValue(v starlark.Value) error {
+	m := &memoryMeter{limit: l.limit, seen: make(map[starlark.Value]struct{})}
+	if m.add(v); m.exceeded() {
+		return fmt.Errorf("memory limit exceeded, return more than %d bytes", l.limit)
+	}
+	return nil
+}
+
+// memoryMeter sums the sizes of the values, the shared lists, dicts and sets are counted once. It stops once the
+// limit is exceeded.
+type memoryMeter struct {
+	limit int
+	size  int
+	seen  map[starlark.Value]struct{}
+}
+
+func (m *memoryMeter) exceeded() bool {
+	return m.size > m.limit
+}
+
+func (m *memoryMeter) add(v starlark.Value) {
+	if v == nil || m.exceeded() {
+		return
+	}
+	m.size += valueOverhead
+	switch v := v.(type) {
+	case starlark.String:
+		m.size += len(v)
+	case starlark.Bytes:
+		m.size += len(v)
+	case starlark.Tuple:
+		for _, elem := range v {
+			m.add(elem)
+		}
+	case *starlark.List:
+		if m.visit(v) {
+			for i := 0; i < v.Len(); i++ {
+				m.add(v.Index(i))
+			}
+		}
+	case *starlark.Dict:
+		if m.visit(v) {
+			for _, item := range v.Items() {
+				m.add(item[0])
+				m.add(item[1])
+			}
+		}
+	case *starlark.Set:
+		if m.visit(v) {
+			iter := v.Iterate()
+			defer iter.Done()
+			var elem starlark.Value
+			for iter.Next(&elem) {
+				m.add(elem)
+			}
+		}
+	}
+}
+
+// visit reports whether the container is visited for the first time.
+func (m *memoryMeter) visit(v starlark.Value) bool {
+	if _, ok := m.seen[v]; ok {
+		return false
+	}
+	m.seen[v] = struct{}{}
+	return true
+}
@@ -0,0 +1,452 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"go.starlark.net/lib/json"
+	starlarkmath "go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+
+	"github.com/alibaba/ilogtail/pkg/helper"
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+)
+
+const (
+	pluginName   = "processor_script"
+	functionName = "process"
+
+	modeEvent = "event"
+	modeGroup = "group"
+)
+
+// predeclared is the only environment of the scripts, they have no access to files, network or the clock.
+var predeclared = starlark.StringDict{
+	"json": json.Module,
+	"math": starlarkmath.Module,
+}
+
+// ProcessorScript transforms the log events by a Starlark script. The script defines the function process, which
+// takes the events and the groups as dicts and returns None to drop them, a dict or a list of dicts. The events are
+// passed through unchanged when the script fails.
+type ProcessorScript struct {
+	Script                string `comment:"the Starlark script, which must define the function process."`
+	ScriptFile            string `comment:"the path of the Starlark script file, the script is reloaded when the file is modified."`
+	Mode                  string `comment:"event calls process(event, group) for every log event, group calls process(group) for every group."`
+	MaxSteps              uint64 `comment:"the max execution steps of one call of the script, 0 means no limit."`
+	MaxMemoryMB           int    `comment:"the max memory held by the values of one call of the script, 0 means no limit."`
+	ReloadIntervalSeconds int    `comment:"the interval of checking whether the script file is modified."`
+
+	context      pipeline.Context
+	thread       *starlark.Thread
+	limiter      *memoryLimiter
+	running      *memoryLimiter // the limiter of the running call or the script being loaded
+	process      starlark.Callable
+	numParams    int
+	modTime      time.Time
+	lastCheck    time.Time
+	errorsMetric pipeline.CounterMetric
+}
+
+// Init called for init some system resources, like socket, mutex...
+func (p *ProcessorScript) Init(context pipeline.Context) error {
+	p.context = context
+	if (p.Script == "") == (p.ScriptFile == "") {
+		return fmt.Errorf("must specify one of Script and ScriptFile for plugin %v", pluginName)
+	}
+	if p.Mode != modeEvent && p.Mode != modeGroup {
+		return fmt.Errorf("unsupported Mode %v for plugin %v", p.Mode, pluginName)
+	}
+	if p.MaxMemoryMB < 0 {
+		return fmt.Errorf("invalid MaxMemoryMB %v for plugin %v", p.MaxMemoryMB, pluginName)
+	}
+	p.thread = &starlark.Thread{
+		Name: pluginName,
+		Print: func(_ *starlark.Thread, msg string) {
+			logger.Info(p.context.GetRuntimeContext(), "script print", msg)
+		},
+		OnMaxSteps: func(thread *starlark.Thread) {
+			p.onMaxSteps()
+		},
+	}
+	p.errorsMetric = helper.NewCounterMetricAndRegister("script_errors", context)
+	if p.ScriptFile == "" {
+		return p.load(pluginName+".star", []byte(p.Script))
+	}
+	p.lastCheck = time.Now()
+	info, err := os.Stat(p.ScriptFile)
+	if err != nil {
+		return fmt.Errorf("cannot stat ScriptFile for plugin %v: %v", pluginName, err)
+	}
+	p.modTime = info.ModTime()
+	return p.loadFile()
+}
+
+func (*ProcessorScript) Description() string {
+	return "script processor to transform the log events by a sandboxed Starlark script"
+}
+
+func (p *ProcessorScript) ProcessLogs(logArray []*protocol.Log) []*protocol.Log {
+	p.reloadIfModified()
+	if p.Mode == modeGroup {
+		return p.processGroupV1(logArray)
+	}
+	res := make([]*protocol.Log, 0, len(logArray))
+	group := newGroupDict(nil, nil)
+	group.Freeze()
+	for _, log := range logArray {
+		out, err := p.processEventV1(log, group)
+		if err != nil {
+			p.alarm(err)
+			res = append(res, log)
+			continue
+		}
+		res = append(res, out...)
+	}
+	return res
+}
+
+func (p *ProcessorScript) processEventV1(log *protocol.Log, group *starlark.Dict) ([]*protocol.Log, error) {
+	event := logV1ToDict(log)
+	result, err := p.call(event, group)
+	if err != nil {
+		return nil, err
+	}
+	dicts, err := dictsOf(result)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := uint64(log.Time)*1e9 + uint64(log.GetTimeNs())
+	res := make([]*protocol.Log, 0, len(dicts))
+	for _, d := range dicts {
+		out, err := dictToLogV1(d, timestamp)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, out)
+	}
+	return res, nil
+}
+
+func (p *ProcessorScript) processGroupV1(logArray []*protocol.Log) []*protocol.Log {
+	timestamps := make(map[*starlark.Dict]uint64, len(logArray))
+	events := make([]starlark.Value, 0, len(logArray))
+	for _, log := range logArray {
+		event := logV1ToDict(log)
+		timestamps[event] = uint64(log.Time)*1e9 + uint64(log.GetTimeNs())
+		events = append(events, event)
+	}
+	res, err := p.runGroupV1(newGroupDict(nil, starlark.NewList(events)), timestamps)
+	if err != nil {
+		p.alarm(err)
+		return logArray
+	}
+	return res
+}
+
+func (p *ProcessorScript) runGroupV1(group *starlark.Dict, timestamps map[*starlark.Dict]uint64) ([]*protocol.Log, error) {
+	result, err := p.call(group)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := dictsOf(result)
+	if err != nil {
+		return nil, err
+	}
+	var res []*protocol.Log
+	for _, g := range groups {
+		v, ok := dictGet(g, keyEvents)
+		if !ok {
+			continue
+		}
+		events, err := dictsOf(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			timestamp, ok := timestamps[event]
+			if !ok {
+				timestamp = uint64(time.Now().UnixNano())
+			}
+			out, err := dictToLogV1(event, timestamp)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, out)
+		}
+	}
+	return res, nil
+}
+
+func (p *ProcessorScript) Process(in *models.PipelineGroupEvents, context pipeline.PipelineContext) {
+	p.reloadIfModified()
+	if p.Mode == modeGroup {
+		p.processGroup(in, context)
+		return
+	}
+	group := newGroupDict(in.Group, nil)
+	group.Freeze()
+	events := make([]models.PipelineEvent, 0, len(in.Events))
+	for _, event := range in.Events {
+		log, ok := event.(*models.Log)
+		if !ok {
+			events = append(events, event)
+			continue
+		}
+		out, err := p.processEvent(log, group)
+		if err != nil {
+			p.alarm(err)
+			events = append(events, event)
+			continue
+		}
+		events = append(events, out...)
+	}
+	context.Collector().Collect(in.Group, events...)
+}
+
+func (p *ProcessorScript) processEvent(log *models.Log, group *starlark.Dict) ([]models.PipelineEvent, error) {
+	result, err := p.call(logToDict(log), group)
+	if err != nil {
+		return nil, err
+	}
+	dicts, err := dictsOf(result)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]models.PipelineEvent, 0, len(dicts))
+	for _, d := range dicts {
+		out, err := dictToLog(d, log)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, out)
+	}
+	return res, nil
+}
+
+// processGroup passes the log events of the group to the script at once, the other events are kept in the group.
+func (p *ProcessorScript) processGroup(in *models.PipelineGroupEvents, context pipeline.PipelineContext) {
+	sources := make(map[*starlark.Dict]*models.Log, len(in.Events))
+	events := make([]starlark.Value, 0, len(in.Events))
+	var others []models.PipelineEvent
+	for _, event := range in.Events {
+		log, ok := event.(*models.Log)
+		if !ok {
+			others = append(others, event)
+			continue
+		}
+		d := logToDict(log)
+		sources[d] = log
+		events = append(events, d)
+	}
+	res, err := p.runGroup(newGroupDict(in.Group, starlark.NewList(events)), in.Group, sources)
+	if err != nil {
+		p.alarm(err)
+		context.Collector().Collect(in.Group, in.Events...)
+		return
+	}
+	if len(others) > 0 {
+		context.Collector().Collect(in.Group, others...)
+	}
+	context.Collector().CollectList(res...)
+}
+
+func (p *ProcessorScript) runGroup(group *starlark.Dict, groupInfo *models.GroupInfo, sources map[*starlark.Dict]*models.Log) ([]*models.PipelineGroupEvents, error) {
+	result, err := p.call(group)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := dictsOf(result)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*models.PipelineGroupEvents, 0, len(groups))
+	for _, g := range groups {
+		out := &models.PipelineGroupEvents{
+			Group: models.NewGroup(groupInfo.GetMetadata(), groupInfo.GetTags()),
+		}
+		if v, ok := dictGet(g, keyMetadata); ok {
+			metadata, err := dictToStringMap(v, keyMetadata)
+			if err != nil {
+				return nil, err
+			}
+			out.Group.Metadata = models.NewMetadataWithMap(metadata)
+		}
+		if v, ok := dictGet(g, keyTags); ok {
+			tags, err := dictToStringMap(v, keyTags)
+			if err != nil {
+				return nil, err
+			}
+			out.Group.Tags = models.NewTagsWithMap(tags)
+		}
+		if v, ok := dictGet(g, keyEvents); ok {
+			events, err := dictsOf(v)
+			if err != nil {
+				return nil, err
+			}
+			out.Events = make([]models.PipelineEvent, 0, len(events))
+			for _, event := range events {
+				log, err := dictToLog(event, sources[event])
+				if err != nil {
+					return nil, err
+				}
+				out.Events = append(out.Events, log)
+			}
+		}
+		res = append(res, out)
+	}
+	return res, nil
+}
+
+// call calls the process function of the script, the event mode function may omit the group parameter.
+func (p *ProcessorScript) call(args ...starlark.Value) (starlark.Value, error) {
+	if len(args) > p.numParams {
+		args = args[:p.numParams]
+	}
+	var result starlark.Value
+	err := p.run(p.limiter, func() (err error) {
+		result, err = starlark.Call(p.thread, p.process, args, nil)
+		return err
+	})
+	if err == nil && p.limiter != nil {
+		err = p.limiter.checkValue(result)
+	}
+	return result, err
+}
+
+// run runs f with the execution steps and the memory limits of one call.
+func (p *ProcessorScript) run(limiter *memoryLimiter, f func() error) error {
+	p.thread.Steps = 0
+	p.thread.Uncancel()
+	p.running = limiter
+	p.thread.SetMaxExecutionSteps(p.nextCheckSteps())
+	return f()
+}
+
+// nextCheckSteps returns the execution steps at which onMaxSteps is called next, the memory is checked every
+// memoryCheckSteps steps.
+func (p *ProcessorScript) nextCheckSteps() uint64 {
+	maxSteps := p.MaxSteps
+	if maxSteps == 0 {
+		maxSteps = math.MaxUint64
+	}
+	if p.running == nil || maxSteps-p.thread.Steps <= memoryCheckSteps {
+		return maxSteps
+	}
+	return p.thread.Steps + memoryCheckSteps
+}
+
+func (p *ProcessorScript) onMaxSteps() {
+	if p.MaxSteps > 0 && p.thread.Steps >= p.MaxSteps {
+		p.thread.Cancel("too many steps")
+		return
+	}
+	if p.running != nil {
+		p.running.checkThread(p.thread)
+	}
+	p.thread.SetMaxExecutionSteps(p.nextCheckSteps())
+}
+
+func (p *ProcessorScript) load(filename string, src []byte) error {
+	file, program, err := starlark.SourceProgram(filename, src, predeclared.Has)
+	if err != nil {
+		return fmt.Errorf("load script error for plugin %v: %v", pluginName, err)
+	}
+	var limiter *memoryLimiter
+	if p.MaxMemoryMB > 0 {
+		limiter = newMemoryLimiter(p.MaxMemoryMB<<20, file)
+	}
+	var globals starlark.StringDict
+	err = p.run(limiter, func() (err error) {
+		globals, err = program.Init(p.thread, predeclared)
+		globals.Freeze()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("load script error for plugin %v: %v", pluginName, err)
+	}
+	process, ok := globals[functionName].(starlark.Callable)
+	if !ok {
+		return fmt.Errorf("the script must define the function %v for plugin %v", functionName, pluginName)
+	}
+	numParams := 1
+	if p.Mode == modeEvent {
+		numParams = 2
+	}
+	if fn, ok := process.(*starlark.Function); ok {
+		if fn.NumParams() < 1 || fn.NumParams() > numParams {
+			return fmt.Errorf("the function %v of the script must take %v parameters in %v mode for plugin %v", functionName, numParams, p.Mode, pluginName)
+		}
+		numParams = fn.NumParams()
+	}
+	p.process = process
+	p.numParams = numParams
+	p.limiter = limiter
+	return nil
+}
+
+func (p *ProcessorScript) loadFile() error {
+	src, err := os.ReadFile(p.ScriptFile)
+	if err != nil {
+		return fmt.Errorf("cannot read ScriptFile for plugin %v: %v", pluginName, err)
+	}
+	return p.load(p.ScriptFile, src)
+}
+
+// reloadIfModified reloads the script file when its modification time changes, the previous script is kept when the
+// new one cannot be loaded.
+func (p *ProcessorScript) reloadIfModified() {
+	if p.ScriptFile == "" || time.Since(p.lastCheck) < time.Duration(p.ReloadIntervalSeconds)*time.Second {
+		return
+	}
+	p.lastCheck = time.Now()
+	info, err := os.Stat(p.ScriptFile)
+	if err != nil {
+		logger.Warning(p.context.GetRuntimeContext(), "PROCESSOR_SCRIPT_ALARM", "stat script file error", err, "file", p.ScriptFile)
+		return
+	}
+	if info.ModTime().Equal(p.modTime) {
+		return
+	}
+	p.modTime = info.ModTime()
+	if err = p.loadFile(); err != nil {
+		logger.Warning(p.context.GetRuntimeContext(), "PROCESSOR_SCRIPT_ALARM", "reload script error, keep the previous script", err)
+		return
+	}
+	logger.Info(p.context.GetRuntimeContext(), "script reloaded", p.ScriptFile)
+}
+
+func (p *ProcessorScript) alarm(err error) {
+	p.errorsMetric.Add(1)
+	logger.Warning(p.context.GetRuntimeContext(), "PROCESSOR_SCRIPT_ALARM", "run script error", err)
+}
+
+func init() {
+	pipeline.Processors[pluginName] = func() pipeline.Processor {
+		return &ProcessorScript{
+			Mode:                  modeEvent,
+			MaxSteps:              100000,
+			MaxMemoryMB:           64,
+			ReloadIntervalSeconds: 10,
+		}
+	}
+}
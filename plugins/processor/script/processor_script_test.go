@@ -0,0 +1,288 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/plugins/test"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+func init() {
+	logger.InitTestLogger(logger.OptionOpenMemoryReceiver)
+}
+
+func newProcessor(script string, configure func(p *ProcessorScript)) (*ProcessorScript, error) {
+	ctx := mock.NewEmptyContext("p", "l", "c")
+	processor := pipeline.Processors[pluginName]().(*ProcessorScript)
+	processor.Script = script
+	if configure != nil {
+		configure(processor)
+	}
+	err := processor.Init(ctx)
+	return processor, err
+}
+
+func newLog(timestamp uint64, keyValues ...string) *models.Log {
+	log := models.NewLog("", nil, "", "", "", models.NewTags(), timestamp)
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		log.GetIndices().Add(keyValues[i], keyValues[i+1])
+	}
+	return log
+}
+
+func TestInitError(t *testing.T) {
+	_, err := newProcessor("", nil)
+	assert.Error(t, err)
+	_, err = newProcessor("def process(event):\n    return event\n", func(p *ProcessorScript) { p.ScriptFile = "a.star" })
+	assert.Error(t, err)
+	_, err = newProcessor("def process(event):\n    return event\n", func(p *ProcessorScript) { p.Mode = "batch" })
+	assert.Error(t, err)
+	_, err = newProcessor("def process(event):\n    return event\n", func(p *ProcessorScript) { p.MaxMemoryMB = -1 })
+	assert.Error(t, err)
+	_, err = newProcessor("def transform(event):\n    return event\n", nil)
+	assert.Error(t, err)
+	_, err = newProcessor("def process(event, group, other):\n    return event\n", nil)
+	assert.Error(t, err)
+	_, err = newProcessor("def process(event):\n  return event +\n", nil)
+	assert.Error(t, err)
+	_, err = newProcessor("def process(event):\n    return event\n", func(p *ProcessorScript) { p.ScriptFile = ""; p.Mode = modeGroup })
+	assert.NoError(t, err)
+}
+
+func TestProcessLogs(t *testing.T) {
+	script := `
+def process(event):
+    contents = event["contents"]
+    if contents.get("level") == "debug":
+        return None
+    if "items" in contents:
+        return [{"contents": {"item": item}} for item in contents["items"].split(",")]
+    contents["method"] = contents.pop("request").split(" ")[0]
+    contents["size"] = int(contents["size"]) * 2
+    return event
+`
+	processor, err := newProcessor(script, nil)
+	require.NoError(t, err)
+	log := test.CreateLogs("request", "GET /index.html", "size", "10")
+	protocol.SetLogTimeWithNano(log, 1700000000, 5)
+	logs := processor.ProcessLogs([]*protocol.Log{
+		log,
+		test.CreateLogs("level", "debug"),
+		test.CreateLogs("items", "a,b"),
+	})
+	require.Len(t, logs, 3)
+	assert.Equal(t, []*protocol.Log_Content{{Key: "size", Value: "20"}, {Key: "method", Value: "GET"}}, logs[0].Contents)
+	assert.Equal(t, uint32(1700000000), logs[0].Time)
+	assert.Equal(t, uint32(5), logs[0].GetTimeNs())
+	assert.Equal(t, "a", test.ReadLogVal(logs[1], "item"))
+	assert.Equal(t, "b", test.ReadLogVal(logs[2], "item"))
+}
+
+func TestProcessLogsGroup(t *testing.T) {
+	script := `
+def process(group):
+    group["events"] = [e for e in group["events"] if e["contents"]["keep"] == "true"]
+    group["events"].append({"contents": {"count": len(group["events"])}})
+    return group
+`
+	processor, err := newProcessor(script, func(p *ProcessorScript) { p.Mode = modeGroup })
+	require.NoError(t, err)
+	logs := processor.ProcessLogs([]*protocol.Log{
+		test.CreateLogs("keep", "true"),
+		test.CreateLogs("keep", "false"),
+	})
+	require.Len(t, logs, 2)
+	assert.Equal(t, "true", test.ReadLogVal(logs[0], "keep"))
+	assert.Equal(t, "1", test.ReadLogVal(logs[1], "count"))
+}
+
+func TestProcessEvent(t *testing.T) {
+	script := `
+def process(event, group):
+    contents = event["contents"]
+    if contents.get("drop"):
+        return None
+    data = json.decode(contents.pop("data"))
+    contents.update(data)
+    event["tags"]["source"] = group["metadata"]["source"]
+    event["level"] = "warn" if data["latency"] > 100 else "info"
+    event["timestamp"] = event["timestamp"] + 1
+    split = {"contents": {"user": data["user"]}}
+    return [event, split]
+`
+	processor, err := newProcessor(script, nil)
+	require.NoError(t, err)
+	group := models.NewGroup(models.NewMetadataWithKeyValues("source", "app"), models.NewTags())
+	log := newLog(100, "data", `{"user": "alice", "latency": 150}`)
+	log.GetTags().Add("host", "a")
+	metric := models.NewSingleValueMetric("m", models.MetricTypeGauge, models.NewTags(), 0, 1)
+	context := pipeline.NewObservePipelineConext(10)
+	processor.Process(&models.PipelineGroupEvents{
+		Group:  group,
+		Events: []models.PipelineEvent{log, newLog(0, "drop", "true"), metric},
+	}, context)
+	results := context.Collector().ToArray()
+	require.Len(t, results, 1)
+	require.Len(t, results[0].Events, 3)
+
+	out := results[0].Events[0].(*models.Log)
+	assert.Equal(t, "alice", out.GetIndices().Get("user"))
+	assert.Equal(t, int64(150), out.GetIndices().Get("latency"))
+	assert.False(t, out.GetIndices().Contains("data"))
+	assert.Equal(t, "app", out.GetTags().Get("source"))
+	assert.Equal(t, "a", out.GetTags().Get("host"))
+	assert.Equal(t, "warn", out.GetLevel())
+	assert.Equal(t, uint64(101), out.GetTimestamp())
+	assert.False(t, log.GetTags().Contains("source"))
+
+	split := results[0].Events[1].(*models.Log)
+	assert.Equal(t, "alice", split.GetIndices().Get("user"))
+	assert.Equal(t, "a", split.GetTags().Get("host"))
+	assert.Equal(t, uint64(100), split.GetTimestamp())
+
+	assert.Equal(t, metric, results[0].Events[2])
+}
+
+func TestProcessGroup(t *testing.T) {
+	script := `
+def process(group):
+    errors = [e for e in group["events"] if e["level"] == "error"]
+    if not errors:
+        return None
+    group["metadata"]["errors"] = str(len(errors))
+    group["events"] = errors
+    return [group, {"events": [{"contents": {"summary": "%d errors" % len(errors)}}]}]
+`
+	processor, err := newProcessor(script, func(p *ProcessorScript) { p.Mode = modeGroup })
+	require.NoError(t, err)
+	group := models.NewGroup(models.NewMetadataWithKeyValues("source", "app"), models.NewTagsWithKeyValues("env", "prod"))
+	errorLog := newLog(100, "msg", "boom")
+	errorLog.SetLevel("error")
+	context := pipeline.NewObservePipelineConext(10)
+	processor.Process(&models.PipelineGroupEvents{Group: group, Events: []models.PipelineEvent{newLog(1, "msg", "ok"), errorLog}}, context)
+	processor.Process(&models.PipelineGroupEvents{Group: group, Events: []models.PipelineEvent{newLog(1, "msg", "ok")}}, context)
+	results := context.Collector().ToArray()
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "app", results[0].Group.GetMetadata().Get("source"))
+	assert.Equal(t, "1", results[0].Group.GetMetadata().Get("errors"))
+	assert.Equal(t, "prod", results[0].Group.GetTags().Get("env"))
+	require.Len(t, results[0].Events, 1)
+	assert.Equal(t, "boom", results[0].Events[0].(*models.Log).GetIndices().Get("msg"))
+	assert.Equal(t, uint64(100), results[0].Events[0].GetTimestamp())
+	assert.False(t, group.GetMetadata().Contains("errors"))
+
+	require.Len(t, results[1].Events, 1)
+	assert.Equal(t, "1 errors", results[1].Events[0].(*models.Log).GetIndices().Get("summary"))
+	assert.NotZero(t, results[1].Events[0].GetTimestamp())
+}
+
+func TestMaxSteps(t *testing.T) {
+	script := `
+def process(event):
+    for i in range(int(event["contents"]["n"])):
+        pass
+    return event
+`
+	processor, err := newProcessor(script, func(p *ProcessorScript) { p.MaxSteps = 1000 })
+	require.NoError(t, err)
+	context := pipeline.NewObservePipelineConext(10)
+	slow := newLog(0, "n", "100000")
+	fast := newLog(0, "n", "10")
+	processor.Process(&models.PipelineGroupEvents{Events: []models.PipelineEvent{slow, fast}}, context)
+	results := context.Collector().ToArray()
+	require.Len(t, results, 1)
+	require.Len(t, results[0].Events, 2)
+	assert.Same(t, slow, results[0].Events[0])
+	assert.NotSame(t, fast, results[0].Events[1])
+	_, err = processor.call(logToDict(slow))
+	assert.ErrorContains(t, err, "too many steps")
+}
+
+func TestMaxMemory(t *testing.T) {
+	script := `
+def process(event):
+    chunks = []
+    for i in range(int(event["contents"]["n"])):
+        chunks.append("x" * 4096)
+    return event
+`
+	processor, err := newProcessor(script, func(p *ProcessorScript) {
+		p.MaxSteps = 0
+		p.MaxMemoryMB = 1
+	})
+	require.NoError(t, err)
+	large := newLog(0, "n", "100000")
+	small := newLog(0, "n", "10")
+	context := pipeline.NewObservePipelineConext(10)
+	processor.Process(&models.PipelineGroupEvents{Events: []models.PipelineEvent{large, small}}, context)
+	results := context.Collector().ToArray()
+	require.Len(t, results, 1)
+	require.Len(t, results[0].Events, 2)
+	assert.Same(t, large, results[0].Events[0])
+	assert.NotSame(t, small, results[0].Events[1])
+	_, err = processor.call(logToDict(large))
+	assert.ErrorContains(t, err, "memory limit exceeded")
+
+	// the returned value is checked too.
+	processor, err = newProcessor("def process(event):\n    return [event for i in range(1000000)]\n", func(p *ProcessorScript) {
+		p.MaxSteps = 0
+		p.MaxMemoryMB = 1
+	})
+	require.NoError(t, err)
+	_, err = processor.call(logToDict(small))
+	assert.ErrorContains(t, err, "memory limit exceeded")
+}
+
+func TestReloadScriptFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "process.star")
+	writeScript := func(value string, modTime time.Time) {
+		script := "def process(event):\n    event[\"contents\"][\"version\"] = " + value + "\n    return event\n"
+		require.NoError(t, os.WriteFile(file, []byte(script), 0600))
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+	}
+	now := time.Now()
+	writeScript(`"v1"`, now.Add(-time.Minute))
+	processor, err := newProcessor("", func(p *ProcessorScript) { p.ScriptFile = file })
+	require.NoError(t, err)
+
+	version := func() interface{} {
+		context := pipeline.NewObservePipelineConext(10)
+		processor.Process(&models.PipelineGroupEvents{Events: []models.PipelineEvent{newLog(0)}}, context)
+		return context.Collector().ToArray()[0].Events[0].(*models.Log).GetIndices().Get("version")
+	}
+	assert.Equal(t, "v1", version())
+
+	writeScript(`"v2"`, now)
+	assert.Equal(t, "v1", version())
+	processor.lastCheck = time.Time{}
+	assert.Equal(t, "v2", version())
+
+	writeScript(`v3 +`, now.Add(time.Minute))
+	processor.lastCheck = time.Time{}
+	assert.Equal(t, "v2", version())
+}
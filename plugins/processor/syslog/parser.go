@@ -0,0 +1,226 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syslog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/go-syslog/rfc5424"
+	"github.com/jeromer/syslogparser/rfc3164"
+)
+
+const (
+	formatRFC3164 = "rfc3164"
+	formatRFC5424 = "rfc5424"
+	formatAuto    = "auto"
+)
+
+var (
+	severityLabels = []string{
+		"emergency", "alert", "critical", "error", "warning", "notice", "informational", "debug",
+	}
+	facilityLabels = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+)
+
+// SeverityLabel maps the numeric syslog severity to its RFC5424 keyword.
+func SeverityLabel(severity int) string {
+	if severity < 0 || severity >= len(severityLabels) {
+		return ""
+	}
+	return severityLabels[severity]
+}
+
+// FacilityLabel maps the numeric syslog facility to its RFC5424 keyword.
+func FacilityLabel(facility int) string {
+	if facility < 0 || facility >= len(facilityLabels) {
+		return ""
+	}
+	return facilityLabels[facility]
+}
+
+type parseResult struct {
+	format   string
+	hostname string
+	program  string
+	priority int
+	facility int
+	severity int
+	time     time.Time
+	hasTime  bool
+	message  string
+
+	// RFC5424
+	version        int
+	procID         *string
+	msgID          *string
+	structuredData *map[string]map[string]string
+}
+
+type field struct {
+	key   string
+	value string
+}
+
+// fields flattens the result into ordered key/value pairs, the keys are prefixed with @prefix.
+func (r *parseResult) fields(prefix string) []field {
+	fs := make([]field, 0, 12)
+	add := func(k, v string) {
+		fs = append(fs, field{key: prefix + k, value: v})
+	}
+	add("format", r.format)
+	add("hostname", r.hostname)
+	add("program", r.program)
+	add("priority", strconv.Itoa(r.priority))
+	add("facility", strconv.Itoa(r.facility))
+	add("facility_label", FacilityLabel(r.facility))
+	add("severity", strconv.Itoa(r.severity))
+	add("severity_label", SeverityLabel(r.severity))
+	if r.hasTime {
+		add("timestamp", r.time.Format(time.RFC3339Nano))
+	}
+	if r.format == formatRFC5424 {
+		add("version", strconv.Itoa(r.version))
+		if r.procID != nil {
+			add("proc_id", *r.procID)
+		}
+		if r.msgID != nil {
+			add("msg_id", *r.msgID)
+		}
+		if r.structuredData != nil {
+			if data, err := json.Marshal(*r.structuredData); err == nil {
+				add("structured_data", string(data))
+			}
+		}
+	}
+	add("message", r.message)
+	return fs
+}
+
+type parser func(data []byte) (*parseResult, error)
+
+func newParser(format string, location *time.Location) (parser, error) {
+	switch format {
+	case formatRFC3164:
+		return func(data []byte) (*parseResult, error) {
+			return parseRFC3164(data, location)
+		}, nil
+	case formatRFC5424:
+		p := rfc5424.NewParser()
+		return func(data []byte) (*parseResult, error) {
+			return parseRFC5424(p, data)
+		}, nil
+	case formatAuto, "":
+		p := rfc5424.NewParser()
+		return func(data []byte) (*parseResult, error) {
+			if isRFC5424(data) {
+				return parseRFC5424(p, data)
+			}
+			return parseRFC3164(data, location)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown syslog format %v", format)
+}
+
+// isRFC5424 reports whether data starts with `<PRI>VERSION SP`, which is the header
+// prefix only RFC5424 messages have.
+func isRFC5424(data []byte) bool {
+	if len(data) == 0 || data[0] != '<' {
+		return false
+	}
+	i := 1
+	for i < len(data) && i <= 4 && data[i] >= '0' && data[i] <= '9' {
+		i++
+	}
+	if i == 1 || i >= len(data) || data[i] != '>' {
+		return false
+	}
+	i++
+	start := i
+	for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+		i++
+	}
+	return i > start && i < len(data) && data[i] == ' '
+}
+
+func parseRFC3164(data []byte, location *time.Location) (*parseResult, error) {
+	p := rfc3164.NewParser(data)
+	p.Location(location)
+	if err := p.Parse(); err != nil {
+		return nil, err
+	}
+	r := p.DumpParseResult()
+	return &parseResult{
+		format:   formatRFC3164,
+		hostname: r.Hostname,
+		program:  r.Tag,
+		priority: r.Priority,
+		facility: r.Facility,
+		severity: r.Severity,
+		time:     r.Timestamp,
+		hasTime:  !r.Timestamp.IsZero(),
+		message:  r.Content,
+	}, nil
+}
+
+func parseRFC5424(p *rfc5424.Parser, data []byte) (*parseResult, error) {
+	m, err := p.Parse(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, errors.New("empty rfc5424 message")
+	}
+	r := &parseResult{
+		format:         formatRFC5424,
+		priority:       -1,
+		facility:       -1,
+		severity:       -1,
+		version:        int(m.Version()),
+		procID:         m.ProcID(),
+		msgID:          m.MsgID(),
+		structuredData: m.StructuredData(),
+	}
+	if m.Priority() != nil {
+		r.priority = int(*m.Priority())
+	}
+	if m.Facility() != nil {
+		r.facility = int(*m.Facility())
+	}
+	if m.Severity() != nil {
+		r.severity = int(*m.Severity())
+	}
+	if m.Hostname() != nil {
+		r.hostname = *m.Hostname()
+	}
+	if m.Appname() != nil {
+		r.program = *m.Appname()
+	}
+	if m.Message() != nil {
+		r.message = *m.Message()
+	}
+	if m.Timestamp() != nil {
+		r.time = *m.Timestamp()
+		r.hasTime = true
+	}
+	return r, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syslog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/pkg/util"
+)
+
+const (
+	pluginName = "processor_parse_syslog"
+	// levelKey is the content holding the level of the v1 logs
+	levelKey = "level"
+)
+
+type ProcessorParseSyslog struct {
+	SourceKey              string `comment:"the field holding the raw syslog message."`
+	Format                 string `comment:"the syslog protocol, one of rfc3164, rfc5424 and auto. auto detects the protocol by the message header."`
+	Prefix                 string `comment:"the prefix added to every parsed field name."`
+	SetTime                bool   `comment:"Whether to use the syslog header timestamp as the log time."`
+	KeepSource             bool   `comment:"Whether to keep the source field after parsing."`
+	KeepSourceIfParseError bool   `comment:"Whether to keep the source field when the message cannot be parsed."`
+	NoKeyError             bool   `comment:"Whether to alarm when the source field is not found."`
+	AlarmIfFail            bool   `comment:"Whether to alarm when the message cannot be parsed."`
+
+	parse   parser
+	context pipeline.Context
+}
+
+// Init called for init some system resources, like socket, mutex...
+func (p *ProcessorParseSyslog) Init(context pipeline.Context) error {
+	if p.SourceKey == "" {
+		return fmt.Errorf("must specify SourceKey for plugin %v", pluginName)
+	}
+	parse, err := newParser(p.Format, time.Local)
+	if err != nil {
+		return err
+	}
+	p.parse = parse
+	p.context = context
+	return nil
+}
+
+func (*ProcessorParseSyslog) Description() string {
+	return "syslog processor to parse rfc3164 or rfc5424 messages in the source field"
+}
+
+func (p *ProcessorParseSyslog) ProcessLogs(logArray []*protocol.Log) []*protocol.Log {
+	for _, log := range logArray {
+		p.processLog(log)
+	}
+	return logArray
+}
+
+func (p *ProcessorParseSyslog) processLog(log *protocol.Log) {
+	for idx, content := range log.Contents {
+		if content.Key != p.SourceKey {
+			continue
+		}
+		result, err := p.parse(util.ZeroCopyStringToBytes(content.Value))
+		if err != nil {
+			if p.AlarmIfFail {
+				logger.Warning(p.context.GetRuntimeContext(), "PROCESSOR_PARSE_SYSLOG_ALARM", "parse syslog error", err, "value", content.Value)
+			}
+		} else {
+			if !p.KeepSource {
+				log.Contents = append(log.Contents[:idx], log.Contents[idx+1:]...)
+			}
+			for _, f := range result.fields(p.Prefix) {
+				log.Contents = append(log.Contents, &protocol.Log_Content{Key: f.key, Value: f.value})
+			}
+			if label := SeverityLabel(result.severity); label != "" {
+				setLevel(log, label)
+			}
+			if p.SetTime && result.hasTime {
+				protocol.SetLogTimeWithNano(log, uint32(result.time.Unix()), uint32(result.time.Nanosecond()))
+			}
+			return
+		}
+		if !p.KeepSourceIfParseError {
+			log.Contents = append(log.Contents[:idx], log.Contents[idx+1:]...)
+		}
+		return
+	}
+	if p.NoKeyError {
+		logger.Warningf(p.context.GetRuntimeContext(), "PROCESSOR_PARSE_SYSLOG_FIND_ALARM", "cannot find key %v", p.SourceKey)
+	}
+}
+
+func (p *ProcessorParseSyslog) Process(in *models.PipelineGroupEvents, context pipeline.PipelineContext) {
+	for _, event := range in.Events {
+		p.processEvent(event)
+	}
+	context.Collector().Collect(in.Group, in.Events...)
+}
+
+func (p *ProcessorParseSyslog) processEvent(event models.PipelineEvent) {
+	if event.GetType() != models.EventTypeLogging {
+		return
+	}
+	log := event.(*models.Log)
+	contents := log.GetIndices()
+	if !contents.Contains(p.SourceKey) {
+		if p.NoKeyError {
+			logger.Warningf(p.context.GetRuntimeContext(), "PROCESSOR_PARSE_SYSLOG_FIND_ALARM", "cannot find key %v", p.SourceKey)
+		}
+		return
+	}
+	var data []byte
+	switch val := contents.Get(p.SourceKey).(type) {
+	case []byte:
+		data = val
+	case string:
+		data = util.ZeroCopyStringToBytes(val)
+	default:
+		logger.Warningf(p.context.GetRuntimeContext(), "PROCESSOR_PARSE_SYSLOG_FIND_ALARM", "key %v is not string", p.SourceKey)
+		return
+	}
+	result, err := p.parse(data)
+	if err != nil {
+		if p.AlarmIfFail {
+			logger.Warning(p.context.GetRuntimeContext(), "PROCESSOR_PARSE_SYSLOG_ALARM", "parse syslog error", err, "value", string(data))
+		}
+		if !p.KeepSourceIfParseError {
+			contents.Delete(p.SourceKey)
+		}
+		return
+	}
+	if !p.KeepSource {
+		contents.Delete(p.SourceKey)
+	}
+	for _, f := range result.fields(p.Prefix) {
+		contents.Add(f.key, f.value)
+	}
+	if label := SeverityLabel(result.severity); label != "" {
+		log.SetLevel(label)
+	}
+	if p.SetTime && result.hasTime {
+		log.Timestamp = uint64(result.time.UnixNano())
+	}
+}
+
+// setLevel sets the level content of the v1 log, as the level of the v2 log.
+func setLevel(log *protocol.Log, level string) {
+	for _, content := range log.Contents {
+		if content.Key == levelKey {
+			content.Value = level
+			return
+		}
+	}
+	log.Contents = append(log.Contents, &protocol.Log_Content{Key: levelKey, Value: level})
+}
+
+func init() {
+	pipeline.Processors[pluginName] = func() pipeline.Processor {
+		return &ProcessorParseSyslog{
+			SourceKey:              "content",
+			Format:                 formatAuto,
+			KeepSource:             false,
+			KeepSourceIfParseError: true,
+			NoKeyError:             true,
+			AlarmIfFail:            true,
+		}
+	}
+}
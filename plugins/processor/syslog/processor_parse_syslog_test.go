@@ -0,0 +1,123 @@
+// Copyright 2023 iLogtail Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syslog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/alibaba/ilogtail/pkg/logger"
+	"github.com/alibaba/ilogtail/pkg/models"
+	"github.com/alibaba/ilogtail/pkg/pipeline"
+	"github.com/alibaba/ilogtail/pkg/protocol"
+	"github.com/alibaba/ilogtail/plugins/test"
+	"github.com/alibaba/ilogtail/plugins/test/mock"
+)
+
+func init() {
+	logger.InitTestLogger(logger.OptionOpenMemoryReceiver)
+}
+
+func newProcessor(format string) (*ProcessorParseSyslog, error) {
+	ctx := mock.NewEmptyContext("p", "l", "c")
+	processor := pipeline.Processors[pluginName]().(*ProcessorParseSyslog)
+	processor.Format = format
+	err := processor.Init(ctx)
+	return processor, err
+}
+
+func TestIsRFC5424(t *testing.T) {
+	assert.True(t, isRFC5424([]byte("<34>1 2003-10-11T22:14:15.003Z mymachine su - ID47 - msg")))
+	assert.False(t, isRFC5424([]byte("<34>Oct 11 22:14:15 mymachine su: msg")))
+	assert.False(t, isRFC5424([]byte("34>1 msg")))
+	assert.False(t, isRFC5424([]byte("<>1 msg")))
+	assert.False(t, isRFC5424([]byte("")))
+}
+
+func TestInvalidFormat(t *testing.T) {
+	_, err := newProcessor("rfc1234")
+	require.Error(t, err)
+}
+
+func TestProcessRFC3164(t *testing.T) {
+	processor, err := newProcessor(formatRFC3164)
+	require.NoError(t, err)
+	log := test.CreateLogs("content", "<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8")
+	processor.ProcessLogs([]*protocol.Log{log})
+	assert.Equal(t, "", test.ReadLogVal(log, "content"))
+	assert.Equal(t, formatRFC3164, test.ReadLogVal(log, "format"))
+	assert.Equal(t, "mymachine", test.ReadLogVal(log, "hostname"))
+	assert.Equal(t, "su", test.ReadLogVal(log, "program"))
+	assert.Equal(t, "34", test.ReadLogVal(log, "priority"))
+	assert.Equal(t, "4", test.ReadLogVal(log, "facility"))
+	assert.Equal(t, "auth", test.ReadLogVal(log, "facility_label"))
+	assert.Equal(t, "2", test.ReadLogVal(log, "severity"))
+	assert.Equal(t, "critical", test.ReadLogVal(log, "severity_label"))
+	assert.Equal(t, "'su root' failed for lonvick on /dev/pts/8", test.ReadLogVal(log, "message"))
+	// the level is set as the v2 log
+	assert.Equal(t, "critical", test.ReadLogVal(log, "level"))
+}
+
+func TestProcessRFC5424(t *testing.T) {
+	processor, err := newProcessor(formatAuto)
+	require.NoError(t, err)
+	processor.Prefix = "syslog_"
+	processor.SetTime = true
+	log := test.CreateLogs("content", `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3"] An application event`)
+	processor.ProcessLogs([]*protocol.Log{log})
+	assert.Equal(t, formatRFC5424, test.ReadLogVal(log, "syslog_format"))
+	assert.Equal(t, "mymachine.example.com", test.ReadLogVal(log, "syslog_hostname"))
+	assert.Equal(t, "evntslog", test.ReadLogVal(log, "syslog_program"))
+	assert.Equal(t, "local4", test.ReadLogVal(log, "syslog_facility_label"))
+	assert.Equal(t, "notice", test.ReadLogVal(log, "syslog_severity_label"))
+	assert.Equal(t, "1234", test.ReadLogVal(log, "syslog_proc_id"))
+	assert.Equal(t, "ID47", test.ReadLogVal(log, "syslog_msg_id"))
+	assert.Equal(t, `{"exampleSDID@32473":{"iut":"3"}}`, test.ReadLogVal(log, "syslog_structured_data"))
+	assert.Equal(t, "An application event", test.ReadLogVal(log, "syslog_message"))
+	assert.Equal(t, uint32(1065910455), log.Time)
+}
+
+func TestProcessParseError(t *testing.T) {
+	processor, err := newProcessor(formatRFC5424)
+	require.NoError(t, err)
+	log := test.CreateLogs("content", "not a syslog message")
+	processor.ProcessLogs([]*protocol.Log{log})
+	assert.Equal(t, "not a syslog message", test.ReadLogVal(log, "content"))
+	assert.Len(t, log.Contents, 1)
+
+	processor.KeepSourceIfParseError = false
+	processor.ProcessLogs([]*protocol.Log{log})
+	assert.Len(t, log.Contents, 0)
+}
+
+func TestProcessV2(t *testing.T) {
+	processor, err := newProcessor(formatAuto)
+	require.NoError(t, err)
+	log := models.NewLog("", nil, "", "", "", models.NewTags(), 0)
+	log.GetIndices().Add("content", []byte("<11>Oct 11 22:14:15 host app[12]: disk failure"))
+	context := pipeline.NewObservePipelineConext(10)
+	processor.Process(&models.PipelineGroupEvents{Events: []models.PipelineEvent{log}}, context)
+	results := context.Collector().ToArray()
+	require.Len(t, results, 1)
+	contents := log.GetIndices()
+	assert.False(t, contents.Contains("content"))
+	assert.Equal(t, "host", contents.Get("hostname"))
+	assert.Equal(t, "app", contents.Get("program"))
+	assert.Equal(t, "error", contents.Get("severity_label"))
+	assert.Equal(t, "disk failure", contents.Get("message"))
+	assert.Equal(t, "error", log.GetLevel())
+}
@@ -58,7 +58,8 @@ func LoadMockConfig(project, logstore, configName, jsonStr string) *pluginmanage
 	if err != nil {
 		panic(err)
 	}
-	return pluginmanager.LogtailConfig[configName]
+	lc, _ := pluginmanager.GetLogtailConfig(configName)
+	return lc
 }
 
 func PluginStart() error {
@@ -35,6 +35,7 @@ func NewEmptyContext(project, logstore, configName string) *EmptyContext {
 		StringMetrics:  make(map[string]pipeline.StringMetric),
 		CounterMetrics: make(map[string]pipeline.CounterMetric),
 		LatencyMetrics: make(map[string]pipeline.LatencyMetric),
+		MetricVecs:     make(map[string]pipeline.MetricVec),
 		checkpoint:     make(map[string][]byte),
 	}
 }
@@ -43,6 +44,7 @@ type EmptyContext struct {
 	StringMetrics  map[string]pipeline.StringMetric
 	CounterMetrics map[string]pipeline.CounterMetric
 	LatencyMetrics map[string]pipeline.LatencyMetric
+	MetricVecs     map[string]pipeline.MetricVec
 
 	common      *pkg.LogtailContextMeta
 	ctx         context.Context
@@ -101,6 +103,27 @@ func (p *EmptyContext) RegisterLatencyMetric(metric pipeline.LatencyMetric) {
 	p.LatencyMetrics[metric.Name()] = metric
 }
 
+func (p *EmptyContext) RegisterCounterVec(vec pipeline.CounterVec) {
+	p.registerMetricVec(vec)
+}
+
+func (p *EmptyContext) RegisterGaugeVec(vec pipeline.GaugeVec) {
+	p.registerMetricVec(vec)
+}
+
+func (p *EmptyContext) RegisterHistogramVec(vec pipeline.HistogramVec) {
+	p.registerMetricVec(vec)
+}
+
+func (p *EmptyContext) registerMetricVec(vec pipeline.MetricVec) {
+	contextMutex.Lock()
+	defer contextMutex.Unlock()
+	if p.MetricVecs == nil {
+		p.MetricVecs = make(map[string]pipeline.MetricVec)
+	}
+	p.MetricVecs[vec.Name()] = vec
+}
+
 func (p *EmptyContext) MetricSerializeToPB(log *protocol.Log) {
 	if log == nil {
 		return
@@ -129,6 +152,9 @@ func (p *EmptyContext) MetricSerializeToPB(log *protocol.Log) {
 			value.Clear()
 		}
 	}
+	for _, vec := range p.MetricVecs {
+		vec.Serialize(log)
+	}
 }
 
 func (p *EmptyContext) SaveCheckPoint(key string, value []byte) error {